@@ -17,6 +17,7 @@ import (
 	"github.com/mini-membership/api/internal/repository"
 	"github.com/mini-membership/api/internal/service"
 	"github.com/mini-membership/api/pkg/database"
+	"github.com/mini-membership/api/pkg/sms"
 )
 
 const mobileSessionExpiration = 30 * 24 * time.Hour // 30 days
@@ -52,31 +53,74 @@ func main() {
 	stockTransferRepo := repository.NewStockTransferRepository(db)
 	inventoryRepo := repository.NewInventoryRepository(db)
 	pointsRepo := repository.NewPointsRepository(db)
+	warehouseRepo := repository.NewWarehouseRepository(db)
+	purchaseOrderRepo := repository.NewPurchaseOrderRepository(db)
+	storeSettingsRepo := repository.NewStoreSettingsRepository(db)
+	stocktakeRepo := repository.NewStocktakeRepository(db)
+	earnRuleRepo := repository.NewEarnRuleRepository(db)
+	tierRepo := repository.NewMembershipTierRepository(db)
+	customerRepo := repository.NewCustomerRepository(db)
+	customerPortalRepo := repository.NewCustomerPortalRepository(db)
+	csvImportRepo := repository.NewCSVImportRepository(db)
+	storeExportRepo := repository.NewStoreExportRepository(db)
+
+	// The console sender logs OTPs, so only debug mode falls back to it
+	if cfg.SMS.Provider == "" && cfg.Server.Mode != gin.DebugMode {
+		log.Fatal("SMS_PROVIDER is required outside debug mode")
+	}
+	smsSender, err := sms.New(cfg.SMS.Provider)
+	if err != nil {
+		log.Fatalf("Failed to set up SMS: %v", err)
+	}
 
 	authService := service.NewAuthService(staffUserRepo, cfg.JWT.Secret, cfg.JWT.Expiration)
 	memberService := service.NewMemberService(memberRepo)
 	transactionService := service.NewTransactionService(transactionRepo, memberRepo)
 	appAuthService := service.NewAppAuthService(appAuthRepo, mobileSessionExpiration)
 	shiftService := service.NewShiftService(shiftRepo)
-	orderService := service.NewOrderService(orderRepo)
+	orderService := service.NewOrderService(orderRepo, earnRuleRepo, tierRepo, storeSettingsRepo)
 	promotionService := service.NewPromotionService(promotionRepo)
-	stockTransferService := service.NewStockTransferService(stockTransferRepo)
-	inventoryService := service.NewInventoryService(inventoryRepo)
-	pointsService := service.NewPointsService(pointsRepo, orderRepo)
+	stockTransferService := service.NewStockTransferService(stockTransferRepo, warehouseRepo)
+	pointsService := service.NewPointsService(pointsRepo, orderRepo, tierRepo, storeSettingsRepo)
+	warehouseService := service.NewWarehouseService(warehouseRepo, stockTransferRepo, inventoryRepo, storeSettingsRepo)
+	purchaseOrderService := service.NewPurchaseOrderService(purchaseOrderRepo)
+	inventoryService := service.NewInventoryService(inventoryRepo, storeSettingsRepo, stockTransferService, purchaseOrderService)
+	stockReconciliationService := service.NewStockReconciliationService(inventoryRepo)
+	storeSettingsService := service.NewStoreSettingsService(storeSettingsRepo)
+	stocktakeService := service.NewStocktakeService(stocktakeRepo)
+	earnRuleService := service.NewEarnRuleService(earnRuleRepo, tierRepo)
+	tierService := service.NewMembershipTierService(tierRepo, storeSettingsRepo)
+	customerService := service.NewCustomerService(customerRepo, pointsRepo, storeSettingsRepo)
+	customerPortalService := service.NewCustomerPortalService(customerPortalRepo, pointsRepo, tierRepo, storeSettingsRepo, smsSender)
+	csvImportService := service.NewCSVImportService(csvImportRepo)
+	storeExportService := service.NewStoreExportService(storeExportRepo)
 
 	authHandler := handler.NewAuthHandler(authService)
 	memberHandler := handler.NewMemberHandler(memberService)
 	transactionHandler := handler.NewTransactionHandler(transactionService)
 	appAuthHandler := handler.NewAppAuthHandler(appAuthService)
 	shiftHandler := handler.NewShiftHandler(shiftService, appAuthService)
-	orderHandler := handler.NewOrderHandler(orderService, appAuthService, shiftService, pointsService)
+	orderHandler := handler.NewOrderHandler(orderService, appAuthService, shiftService)
 	promotionHandler := handler.NewPromotionHandler(promotionService, appAuthService)
 	stockTransferHandler := handler.NewStockTransferHandler(stockTransferService, appAuthService)
-	inventoryHandler := handler.NewInventoryHandler(inventoryService, appAuthService)
-	pointsHandler := handler.NewPointsHandler(pointsService, appAuthService)
+	inventoryHandler := handler.NewInventoryHandler(inventoryService, stockReconciliationService, appAuthService)
+	pointsHandler := handler.NewPointsHandler(pointsService, appAuthService, shiftService)
+	warehouseHandler := handler.NewWarehouseHandler(warehouseService, appAuthService)
+	purchaseOrderHandler := handler.NewPurchaseOrderHandler(purchaseOrderService, appAuthService)
+	storeSettingsHandler := handler.NewStoreSettingsHandler(storeSettingsService, appAuthService)
+	stocktakeHandler := handler.NewStocktakeHandler(stocktakeService, appAuthService)
+	earnRuleHandler := handler.NewEarnRuleHandler(earnRuleService, appAuthService)
+	tierHandler := handler.NewMembershipTierHandler(tierService, appAuthService)
+	customerHandler := handler.NewCustomerHandler(customerService, appAuthService)
+	customerPortalHandler := handler.NewCustomerPortalHandler(customerPortalService)
+	csvImportHandler := handler.NewCSVImportHandler(csvImportService, appAuthService)
+	storeExportHandler := handler.NewStoreExportHandler(storeExportService, appAuthService)
 
 	gin.SetMode(cfg.Server.Mode)
 	router := gin.Default()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	router.Use(middleware.CORSMiddleware())
 
@@ -149,6 +193,9 @@ func main() {
 		customers := mobileV1.Group("/customers")
 		{
 			customers.GET("/search", orderHandler.SearchCustomers)
+			customers.GET("/duplicates", customerHandler.FindDuplicates)
+			customers.POST("/merge", customerHandler.MergeCustomers)
+			customers.GET("/merges", customerHandler.GetMerges)
 		}
 
 		orders := mobileV1.Group("/orders")
@@ -172,8 +219,13 @@ func main() {
 			stockTransfers.POST("/withdraw", stockTransferHandler.WithdrawGoods)
 			stockTransfers.GET("", stockTransferHandler.GetTransfers)
 			stockTransfers.GET("/pending", stockTransferHandler.GetPendingTransfers)
+			stockTransfers.GET("/outgoing", stockTransferHandler.GetOutgoingTransfers)
+			stockTransfers.GET("/discrepancies", stockTransferHandler.GetDiscrepancies)
+			stockTransfers.POST("/discrepancies/:discrepancy_id/resolve", stockTransferHandler.ResolveDiscrepancy)
 			stockTransfers.GET("/:id", stockTransferHandler.GetTransfer)
+			stockTransfers.POST("/:id/dispatch", stockTransferHandler.DispatchTransfer)
 			stockTransfers.POST("/:id/receive", stockTransferHandler.ReceiveTransfer)
+			stockTransfers.GET("/:id/receipts", stockTransferHandler.GetReceipts)
 			stockTransfers.POST("/:id/cancel", stockTransferHandler.CancelTransfer)
 		}
 
@@ -181,15 +233,127 @@ func main() {
 		{
 			inventory.POST("/adjust", inventoryHandler.AdjustStock)
 			inventory.GET("/movements", inventoryHandler.GetMovements)
+			inventory.GET("/movements/export", inventoryHandler.ExportMovements)
 			inventory.GET("/low-stock", inventoryHandler.GetLowStockItems)
+			inventory.PUT("/reorder-settings", inventoryHandler.UpdateReorderSettings)
+			inventory.GET("/replenishment", inventoryHandler.GetReplenishmentSuggestions)
+			inventory.POST("/replenishment/order", inventoryHandler.CreateReplenishmentOrder)
+			inventory.POST("/reconcile", inventoryHandler.ReconcileStock)
+			inventory.GET("/oversells", inventoryHandler.GetOversells)
+			inventory.GET("/valuation", inventoryHandler.GetValuation)
+			inventory.GET("/lots", inventoryHandler.GetLots)
+			inventory.GET("/lots/expiring", inventoryHandler.GetExpiringLots)
+			inventory.POST("/lots/:id/write-off", inventoryHandler.WriteOffLot)
+			inventory.PUT("/products/:product_id/negative-stock-policy", inventoryHandler.UpdateProductNegativeStockPolicy)
+			inventory.PUT("/products/:product_id/lot-tracking", inventoryHandler.UpdateProductLotTracking)
+			inventory.GET("/adjustment-reasons", inventoryHandler.GetAdjustmentReasons)
+			inventory.POST("/adjustment-reasons", inventoryHandler.CreateAdjustmentReason)
+			inventory.PUT("/adjustment-reasons/:id", inventoryHandler.UpdateAdjustmentReason)
 		}
 
 		points := mobileV1.Group("/points")
 		{
 			points.GET("/customer/:customer_id", pointsHandler.GetCustomerPoints)
 			points.GET("/customer/:customer_id/history", pointsHandler.GetPointHistory)
+			points.GET("/customer/:customer_id/wallet", pointsHandler.GetWallet)
+			points.GET("/expiring", pointsHandler.GetExpiringPoints)
 			points.GET("/redeemable-products", pointsHandler.GetRedeemableProducts)
 			points.POST("/redeem", pointsHandler.RedeemPoints)
+			points.POST("/redemptions/:id/reverse", pointsHandler.ReverseRedemption)
+			points.POST("/adjust", pointsHandler.AdjustPoints)
+			points.GET("/adjustments", pointsHandler.GetAdjustments)
+			points.POST("/earn-preview", earnRuleHandler.PreviewEarn)
+			points.GET("/earn-rules", earnRuleHandler.GetRules)
+			points.POST("/earn-rules", earnRuleHandler.CreateRule)
+			points.PUT("/earn-rules/:id", earnRuleHandler.UpdateRule)
+			points.DELETE("/earn-rules/:id", earnRuleHandler.DeleteRule)
+			points.GET("/tiers", tierHandler.GetTiers)
+			points.POST("/tiers", tierHandler.CreateTier)
+			points.PUT("/tiers/:id", tierHandler.UpdateTier)
+			points.DELETE("/tiers/:id", tierHandler.DeleteTier)
+			points.POST("/tiers/evaluate", tierHandler.EvaluateTiers)
+			points.GET("/customer/:customer_id/tier-history", tierHandler.GetTierHistory)
+		}
+
+		warehouses := mobileV1.Group("/warehouses")
+		{
+			warehouses.POST("", warehouseHandler.CreateWarehouse)
+			warehouses.GET("", warehouseHandler.GetWarehouses)
+			warehouses.GET("/:id/stock", warehouseHandler.GetStock)
+			warehouses.GET("/:id/movements", warehouseHandler.GetMovements)
+			warehouses.POST("/:id/adjust", warehouseHandler.AdjustStock)
+			warehouses.GET("/:id/queue", warehouseHandler.GetQueue)
+			warehouses.GET("/:id/short-shipped", warehouseHandler.GetShortShipped)
+			warehouses.GET("/:id/discrepancies", warehouseHandler.GetDiscrepancies)
+			warehouses.POST("/:id/discrepancies/:discrepancy_id/resolve", warehouseHandler.ResolveDiscrepancy)
+			warehouses.POST("/:id/transfers/:transfer_id/pick", warehouseHandler.PickTransfer)
+			warehouses.POST("/:id/transfers/:transfer_id/dispatch", warehouseHandler.DispatchTransfer)
+		}
+
+		storeSettings := mobileV1.Group("/store-settings")
+		{
+			storeSettings.GET("", storeSettingsHandler.GetSettings)
+			storeSettings.PUT("", storeSettingsHandler.UpdateSettings)
+		}
+
+		suppliers := mobileV1.Group("/suppliers")
+		{
+			suppliers.POST("", purchaseOrderHandler.CreateSupplier)
+			suppliers.GET("", purchaseOrderHandler.GetSuppliers)
+			suppliers.PUT("/:id", purchaseOrderHandler.UpdateSupplier)
+		}
+
+		purchaseOrders := mobileV1.Group("/purchase-orders")
+		{
+			purchaseOrders.POST("", purchaseOrderHandler.CreatePurchaseOrder)
+			purchaseOrders.GET("", purchaseOrderHandler.GetPurchaseOrders)
+			purchaseOrders.GET("/:id", purchaseOrderHandler.GetPurchaseOrder)
+			purchaseOrders.POST("/:id/receive", purchaseOrderHandler.ReceiveGoods)
+			purchaseOrders.GET("/:id/receipts", purchaseOrderHandler.GetGoodsReceivedNotes)
+			purchaseOrders.POST("/:id/close", purchaseOrderHandler.ClosePurchaseOrder)
+			purchaseOrders.POST("/:id/cancel", purchaseOrderHandler.CancelPurchaseOrder)
+		}
+
+		stocktakes := mobileV1.Group("/stocktakes")
+		{
+			stocktakes.POST("", stocktakeHandler.CreateStocktake)
+			stocktakes.GET("", stocktakeHandler.GetStocktakes)
+			stocktakes.GET("/:id", stocktakeHandler.GetStocktake)
+			stocktakes.POST("/:id/counts", stocktakeHandler.SubmitCounts)
+			stocktakes.POST("/:id/recount", stocktakeHandler.RequestRecount)
+			stocktakes.POST("/:id/post", stocktakeHandler.PostStocktake)
+			stocktakes.POST("/:id/cancel", stocktakeHandler.CancelStocktake)
+		}
+
+		imports := mobileV1.Group("/imports")
+		{
+			imports.POST("/:kind", csvImportHandler.Import)
+		}
+
+		storeData := mobileV1.Group("/store-data")
+		{
+			storeData.GET("/export", storeExportHandler.ExportStore)
+		}
+	}
+
+	// Customer self-service; OTP requests get a tighter per-IP limit on top of
+	// the per-phone limit in the service
+	public := router.Group("/api/public")
+	public.Use(middleware.RateLimitMiddleware(60, time.Minute))
+	{
+		otp := public.Group("/stores/:store_id/otp")
+		otp.Use(middleware.RateLimitMiddleware(10, 15*time.Minute))
+		{
+			otp.POST("/request", customerPortalHandler.RequestOTP)
+			otp.POST("/verify", customerPortalHandler.VerifyOTP)
+		}
+
+		me := public.Group("/me")
+		{
+			me.GET("/points", customerPortalHandler.GetPoints)
+			me.GET("/history", customerPortalHandler.GetHistory)
+			me.GET("/rewards", customerPortalHandler.GetRewards)
+			me.POST("/logout", customerPortalHandler.Logout)
 		}
 	}
 
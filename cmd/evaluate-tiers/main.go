@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/mini-membership/api/config"
+	"github.com/mini-membership/api/internal/repository"
+	"github.com/mini-membership/api/internal/service"
+	"github.com/mini-membership/api/pkg/database"
+)
+
+// evaluate-tiers moves every customer to the membership tier their qualifying
+// points reach and records the changes. Orders and adjustments re-evaluate
+// customers as they go; schedule this daily for stores with a rolling tier
+// window so customers drop a tier once old points leave it, e.g.
+//
+//	15 0 * * * cd /srv/api && go run ./cmd/evaluate-tiers
+//	go run ./cmd/evaluate-tiers -store 1
+func main() {
+	storeID := flag.Int64("store", 0, "store ID to evaluate tiers for (0 = all stores)")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.NewPostgresDB(&database.Config{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.DBName,
+		SSLMode:  cfg.Database.SSLMode,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	tierService := service.NewMembershipTierService(
+		repository.NewMembershipTierRepository(db),
+		repository.NewStoreSettingsRepository(db),
+	)
+
+	var storeFilter *int64
+	if *storeID != 0 {
+		storeFilter = storeID
+	}
+
+	result, err := tierService.EvaluateTiers(context.Background(), storeFilter)
+	if err != nil {
+		log.Fatalf("Tier evaluation failed: %v", err)
+	}
+
+	log.Printf("%d customers evaluated, %d changed tier", result.CustomersEvaluated, result.TiersChanged)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/mini-membership/api/config"
+	"github.com/mini-membership/api/internal/repository"
+	"github.com/mini-membership/api/internal/service"
+	"github.com/mini-membership/api/pkg/database"
+)
+
+// expire-points writes EXPIRE point transactions for every balance past its
+// store's expiry policy, oldest earned points first. Schedule it daily, e.g.
+//
+//	5 0 * * * cd /srv/api && go run ./cmd/expire-points
+//	go run ./cmd/expire-points -store 1
+func main() {
+	storeID := flag.Int64("store", 0, "store ID to expire points for (0 = all stores)")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.NewPostgresDB(&database.Config{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.DBName,
+		SSLMode:  cfg.Database.SSLMode,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	pointsService := service.NewPointsService(
+		repository.NewPointsRepository(db),
+		repository.NewOrderRepository(db),
+		repository.NewMembershipTierRepository(db),
+		repository.NewStoreSettingsRepository(db),
+	)
+
+	var storeFilter *int64
+	if *storeID != 0 {
+		storeFilter = storeID
+	}
+
+	result, err := pointsService.ExpirePoints(context.Background(), storeFilter)
+	if err != nil {
+		log.Fatalf("Points expiry failed: %v", err)
+	}
+
+	log.Printf("%d points expired across %d customer balances", result.PointsExpired, result.BalancesExpired)
+}
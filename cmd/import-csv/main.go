@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mini-membership/api/config"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+	"github.com/mini-membership/api/internal/service"
+	"github.com/mini-membership/api/pkg/database"
+)
+
+// import-csv loads customers, products and categories, or opening stock per
+// branch from a CSV file into a store. It validates every row and prints what
+// each would do, writing nothing unless -apply is given and every row is valid.
+//
+//	go run ./cmd/import-csv -store 1 -kind customers -file customers.csv
+//	go run ./cmd/import-csv -store 1 -kind stock -file stock.csv -staff 2 -apply
+func main() {
+	storeID := flag.Int64("store", 0, "store ID to import into")
+	kind := flag.String("kind", "", "what the file holds: customers, products or stock")
+	path := flag.String("file", "", "CSV file to import")
+	staffID := flag.Int64("staff", 0, "staff ID recorded on opening stock movements")
+	apply := flag.Bool("apply", false, "write the import (default is a dry run)")
+	flag.Parse()
+
+	if *path == "" {
+		log.Fatalf("-file is required")
+	}
+	file, err := os.Open(*path)
+	if err != nil {
+		log.Fatalf("Failed to open CSV: %v", err)
+	}
+	defer file.Close()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.NewPostgresDB(&database.Config{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.DBName,
+		SSLMode:  cfg.Database.SSLMode,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	importService := service.NewCSVImportService(repository.NewCSVImportRepository(db))
+
+	opts := &domain.ImportOptions{
+		Kind:    domain.ImportKind(*kind),
+		StoreID: *storeID,
+		DryRun:  !*apply,
+	}
+	if *staffID > 0 {
+		opts.StaffID = staffID
+	}
+
+	result, err := importService.Import(context.Background(), opts, file)
+	if err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROW\tKEY\tACTION\tID")
+	for _, row := range result.Rows {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\n", row.Row, row.Key, row.Action, row.ID)
+	}
+	w.Flush()
+
+	if len(result.Errors) > 0 {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "\nROW\tCOLUMN\tERROR")
+		for _, e := range result.Errors {
+			column := e.Column
+			if column == "" {
+				column = "-"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", e.Row, column, e.Message)
+		}
+		w.Flush()
+	}
+
+	log.Printf("%d rows: %d created, %d updated, %d unchanged, %d failed",
+		result.TotalRows, result.Created, result.Updated, result.Unchanged, result.FailedRows())
+	if result.CategoriesCreated > 0 {
+		log.Printf("%d new categories", result.CategoriesCreated)
+	}
+	switch {
+	case len(result.Errors) > 0:
+		log.Fatalf("nothing was imported, fix the rows above and run again")
+	case result.DryRun:
+		log.Printf("dry run, nothing was written (use -apply to import)")
+	}
+}
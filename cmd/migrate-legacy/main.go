@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mini-membership/api/config"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+	"github.com/mini-membership/api/internal/service"
+	"github.com/mini-membership/api/pkg/database"
+)
+
+// migrate-legacy copies v1 members and their point history into a store's v2
+// customers, mapping the 1.0L and 1.5L buckets to two products. It prints a
+// diff report and changes nothing unless -apply is given. Runs are repeatable:
+// members and transactions already copied are not copied again, and only
+// changes to v1 balances since the last run are applied.
+//
+//	go run ./cmd/migrate-legacy -store 1 -product-1-0 3 -product-1-5 4
+//	go run ./cmd/migrate-legacy -store 1 -product-1-0 3 -product-1-5 4 -apply
+func main() {
+	storeID := flag.Int64("store", 0, "store ID to migrate members into")
+	product1_0 := flag.Int64("product-1-0", 0, "product ID that receives the 1.0L bucket")
+	product1_5 := flag.Int64("product-1-5", 0, "product ID that receives the 1.5L bucket")
+	apply := flag.Bool("apply", false, "write the migration (default is a dry run)")
+	all := flag.Bool("all", false, "list unchanged members in the report too")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.NewPostgresDB(&database.Config{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.DBName,
+		SSLMode:  cfg.Database.SSLMode,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	migrationService := service.NewLegacyMigrationService(repository.NewLegacyMigrationRepository(db))
+
+	result, err := migrationService.Migrate(context.Background(), &domain.LegacyMigrationOptions{
+		StoreID:           *storeID,
+		Product1_0LiterID: *product1_0,
+		Product1_5LiterID: *product1_5,
+		DryRun:            !*apply,
+	})
+	if err != nil {
+		log.Fatalf("Legacy migration failed: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MEMBER\tNUMBER\tNAME\tACTION\tCUSTOMER\tBUCKET\tV1\tV2_BEFORE\tV2_AFTER\tHISTORY\tCORRECTION\tERROR")
+	for _, m := range result.Members {
+		if m.Action == domain.LegacyMemberUnchanged && !*all {
+			continue
+		}
+		number := "-"
+		if m.MembershipNumber != nil {
+			number = *m.MembershipNumber
+		}
+		if len(m.Buckets) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t-\t-\t-\t-\t-\t-\t-\t%s\n",
+				m.MemberID, number, m.Name, m.Action, m.Error)
+			continue
+		}
+		for _, b := range m.Buckets {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%d\t%d\t%d\t%d (%+d)\t%+d\t%s\n",
+				m.MemberID, number, m.Name, m.Action, m.CustomerID, b.ProductType,
+				b.LegacyBalance, b.BalanceBefore, b.BalanceAfter, b.HistoryRows, b.HistoryAdded, b.Correction, m.Error)
+		}
+	}
+	w.Flush()
+
+	log.Printf("%d created, %d linked, %d updated, %d unchanged, %d failed; %d history rows",
+		result.Created, result.Linked, result.Updated, result.Unchanged, result.Failed, result.HistoryRows)
+	if result.DryRun {
+		log.Printf("dry run, nothing was written (use -apply to migrate)")
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mini-membership/api/config"
+	"github.com/mini-membership/api/internal/repository"
+	"github.com/mini-membership/api/internal/service"
+	"github.com/mini-membership/api/pkg/database"
+)
+
+// reconcile replays inventory_movements against branch_products.on_stock and
+// reports drift. With -apply it books a corrective ADJUST movement per product.
+//
+//	go run ./cmd/reconcile -store 1 -branch 2
+//	go run ./cmd/reconcile -apply
+func main() {
+	storeID := flag.Int64("store", 0, "store ID to reconcile (0 = all stores)")
+	branchID := flag.Int64("branch", 0, "branch ID to reconcile (0 = all branches)")
+	apply := flag.Bool("apply", false, "write corrective movements for any drift found")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.NewPostgresDB(&database.Config{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.DBName,
+		SSLMode:  cfg.Database.SSLMode,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	reconciliationService := service.NewStockReconciliationService(repository.NewInventoryRepository(db))
+
+	var storeFilter, branchFilter *int64
+	if *storeID != 0 {
+		storeFilter = storeID
+	}
+	if *branchID != 0 {
+		branchFilter = branchID
+	}
+
+	result, err := reconciliationService.Reconcile(context.Background(), storeFilter, branchFilter, *apply, nil)
+	if err != nil {
+		log.Fatalf("Reconciliation failed: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STORE\tBRANCH\tPRODUCT\tON_STOCK\tLEDGER\tDRIFT\tMOVEMENTS\tCORRECTED")
+	for _, item := range result.Items {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%+d\t%d\t%t\n",
+			item.StoreID, item.BranchName, item.ProductName,
+			item.OnStock, item.LedgerQty, item.Drift, item.MovementCount, item.Corrected)
+	}
+	w.Flush()
+
+	if *apply {
+		log.Printf("%d products drifted, %d corrected", result.DriftCount, result.CorrectedCount)
+	} else {
+		log.Printf("%d products drifted (dry run, use -apply to correct)", result.DriftCount)
+	}
+}
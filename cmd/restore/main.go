@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mini-membership/api/config"
+	"github.com/mini-membership/api/internal/repository"
+	"github.com/mini-membership/api/internal/service"
+	"github.com/mini-membership/api/pkg/database"
+)
+
+// restore loads a zip written by cmd/export into the configured database,
+// keeping the store's original IDs. The database must be migrated and must
+// not hold the store's rows yet; a fresh database without seed data is the
+// safe target. The restore is all-or-nothing and writes nothing unless -apply
+// is given.
+//
+//	go run ./cmd/restore -file store-1.zip
+//	go run ./cmd/restore -file store-1.zip -apply
+func main() {
+	path := flag.String("file", "", "store export zip to restore")
+	apply := flag.Bool("apply", false, "write the restore (default is a dry run)")
+	flag.Parse()
+
+	if *path == "" {
+		log.Fatalf("-file is required")
+	}
+	f, err := os.Open(*path)
+	if err != nil {
+		log.Fatalf("Failed to open %s: %v", *path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *path, err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := database.NewPostgresDB(&database.Config{
+		Host:     cfg.Database.Host,
+		Port:     cfg.Database.Port,
+		User:     cfg.Database.User,
+		Password: cfg.Database.Password,
+		DBName:   cfg.Database.DBName,
+		SSLMode:  cfg.Database.SSLMode,
+	})
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	exportService := service.NewStoreExportService(repository.NewStoreExportRepository(db))
+
+	result, err := exportService.Restore(context.Background(), f, info.Size(), !*apply)
+	if err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tROWS")
+	for _, t := range result.Tables {
+		fmt.Fprintf(w, "%s\t%d\n", t.Name, t.Rows)
+	}
+	w.Flush()
+
+	log.Printf("restored %d rows of %s (store %d)", result.TotalRows, result.StoreName, result.StoreID)
+	if result.DryRun {
+		log.Printf("dry run, nothing was written (use -apply to restore)")
+	}
+}
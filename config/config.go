@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -13,6 +14,7 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	JWT      JWTConfig
+	SMS      SMSConfig
 }
 
 type ServerConfig struct {
@@ -21,6 +23,9 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
 	ShutdownTimeout time.Duration
+	// TrustedProxies lists the proxies whose X-Forwarded-For is believed; when
+	// empty the client IP is the connection's remote address
+	TrustedProxies []string
 }
 
 type DatabaseConfig struct {
@@ -37,6 +42,10 @@ type JWTConfig struct {
 	Expiration time.Duration
 }
 
+type SMSConfig struct {
+	Provider string
+}
+
 func Load() (*Config, error) {
 	godotenv.Load()
 
@@ -47,6 +56,7 @@ func Load() (*Config, error) {
 			ReadTimeout:     getEnvDuration("SERVER_READ_TIMEOUT", 10*time.Second),
 			WriteTimeout:    getEnvDuration("SERVER_WRITE_TIMEOUT", 10*time.Second),
 			ShutdownTimeout: getEnvDuration("SERVER_SHUTDOWN_TIMEOUT", 5*time.Second),
+			TrustedProxies:  getEnvList("TRUSTED_PROXIES"),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("POSTGRES_HOST", "localhost"),
@@ -60,6 +70,9 @@ func Load() (*Config, error) {
 			Secret:     getEnv("JWT_SECRET", ""),
 			Expiration: getEnvDuration("JWT_EXPIRATION", 24*time.Hour),
 		},
+		SMS: SMSConfig{
+			Provider: getEnv("SMS_PROVIDER", ""),
+		},
 	}
 
 	if cfg.JWT.Secret == "" {
@@ -83,6 +96,16 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := strconv.Atoi(value); err == nil {
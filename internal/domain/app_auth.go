@@ -51,5 +51,6 @@ type AppSessionInfo struct {
 	BranchName *string   `json:"branch_name,omitempty"`
 	StaffID    *int64    `json:"staff_id,omitempty"`
 	StaffName  *string   `json:"staff_name,omitempty"`
+	IsManager  bool      `json:"is_manager"`
 	ExpiresAt  time.Time `json:"expires_at"`
 }
@@ -0,0 +1,129 @@
+package domain
+
+import "github.com/shopspring/decimal"
+
+// ImportKind is the kind of record a CSV import creates or updates
+type ImportKind string
+
+const (
+	ImportKindCustomers ImportKind = "customers"
+	ImportKindProducts  ImportKind = "products"
+	ImportKindStock     ImportKind = "stock"
+)
+
+// ImportOptions configures one CSV import. StaffID is recorded on the stock
+// movements an opening stock import writes.
+type ImportOptions struct {
+	Kind    ImportKind
+	StoreID int64
+	StaffID *int64
+	DryRun  bool
+}
+
+// ImportRowAction is what an import did, or would do, with one CSV row
+type ImportRowAction string
+
+const (
+	ImportRowCreated   ImportRowAction = "CREATE"
+	ImportRowUpdated   ImportRowAction = "UPDATE"
+	ImportRowUnchanged ImportRowAction = "UNCHANGED"
+)
+
+// ImportRowError reports a problem with one CSV row. Row is the line in the
+// file, the header being line 1; Column is empty when the whole row is at fault.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportRowResult is the record a valid CSV row maps to. Key is the row's
+// customer code, SKU, or branch and SKU for stock.
+type ImportRowResult struct {
+	Row    int             `json:"row"`
+	Key    string          `json:"key"`
+	Action ImportRowAction `json:"action"`
+	ID     int64           `json:"id,omitempty"`
+}
+
+// ImportResult reports a CSV import. Imports are all-or-nothing: Committed is
+// false for a dry run and whenever any row has an error.
+type ImportResult struct {
+	Kind              ImportKind        `json:"kind"`
+	DryRun            bool              `json:"dry_run"`
+	Committed         bool              `json:"committed"`
+	TotalRows         int               `json:"total_rows"`
+	Created           int               `json:"created"`
+	Updated           int               `json:"updated"`
+	Unchanged         int               `json:"unchanged"`
+	CategoriesCreated int               `json:"categories_created,omitempty"`
+	Rows              []ImportRowResult `json:"rows"`
+	Errors            []ImportRowError  `json:"errors"`
+}
+
+// CustomerImportRow is a validated customers CSV row. Nil fields were blank or
+// not in the file and keep the customer's current value.
+type CustomerImportRow struct {
+	Row          int
+	CustomerCode string
+	FullName     *string
+	Phone        *string
+	PhoneLast4   *string
+	Email        *string
+	IsActive     *bool
+}
+
+// ProductImportRow is a validated products CSV row. A category named here that
+// the store does not have yet is created.
+type ProductImportRow struct {
+	Row            int
+	SKU            string
+	ProductName    *string
+	CategoryName   *string
+	Barcode        *string
+	BasePrice      *float64
+	PointsToRedeem *int
+	IsActive       *bool
+}
+
+// StockImportRow is a validated opening stock CSV row. The branch is given by
+// ID or by name. OnStock replaces the branch's count; the difference is booked
+// as an ADJUST movement, at UnitCost when stock goes up.
+type StockImportRow struct {
+	Row          int
+	BranchID     *int64
+	BranchName   *string
+	SKU          string
+	OnStock      int
+	ReorderLevel *int
+	UnitCost     *decimal.Decimal
+	LotNumber    *string
+	ExpiryDate   *string
+}
+
+// AddRow records a row's outcome and counts it in the totals
+func (r *ImportResult) AddRow(row ImportRowResult) {
+	r.Rows = append(r.Rows, row)
+	switch row.Action {
+	case ImportRowCreated:
+		r.Created++
+	case ImportRowUpdated:
+		r.Updated++
+	case ImportRowUnchanged:
+		r.Unchanged++
+	}
+}
+
+// AddError records a problem with a row
+func (r *ImportResult) AddError(row int, column, message string) {
+	r.Errors = append(r.Errors, ImportRowError{Row: row, Column: column, Message: message})
+}
+
+// FailedRows counts the rows with at least one error
+func (r *ImportResult) FailedRows() int {
+	rows := make(map[int]bool, len(r.Errors))
+	for _, e := range r.Errors {
+		rows[e.Row] = true
+	}
+	return len(rows)
+}
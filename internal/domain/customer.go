@@ -0,0 +1,76 @@
+package domain
+
+import "time"
+
+// DuplicateCustomer is one customer in a group sharing a phone number
+// NameSimilarity compares the name with the group's suggested survivor, from
+// 0 (nothing alike) to 1 (same name).
+type DuplicateCustomer struct {
+	ID             int64     `json:"id"`
+	CustomerCode   *string   `json:"customer_code,omitempty"`
+	FullName       *string   `json:"full_name,omitempty"`
+	Phone          string    `json:"phone"`
+	ProductPoints  int       `json:"product_points"`
+	WalletBalance  int       `json:"wallet_balance"`
+	OrderCount     int       `json:"order_count"`
+	NameSimilarity float64   `json:"name_similarity"`
+	SimilarName    bool      `json:"similar_name"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// DuplicateCustomerGroup is a set of active customers with the same phone number
+// and similar names; NamesMatch is false when a group listing different names was
+// asked for. The oldest record is suggested as the survivor.
+type DuplicateCustomerGroup struct {
+	PhoneKey            string              `json:"phone_key"`
+	SuggestedSurvivorID int64               `json:"suggested_survivor_id"`
+	NamesMatch          bool                `json:"names_match"`
+	Customers           []DuplicateCustomer `json:"customers"`
+}
+
+type DuplicateCustomersResponse struct {
+	Groups     []DuplicateCustomerGroup `json:"groups"`
+	TotalCount int                      `json:"total_count"`
+}
+
+// MergeCustomersRequest merges the MergeIDs customers into SurvivorID
+type MergeCustomersRequest struct {
+	SurvivorID int64   `json:"survivor_id" binding:"required"`
+	MergeIDs   []int64 `json:"merge_ids" binding:"required,min=1,max=20"`
+	Note       *string `json:"note,omitempty"`
+}
+
+// CustomerMerge is the audit record of one customer merged into another
+type CustomerMerge struct {
+	ID                     int64     `json:"id"`
+	SurvivorID             int64     `json:"survivor_id"`
+	MergedID               int64     `json:"merged_id"`
+	MergedCustomerCode     *string   `json:"merged_customer_code,omitempty"`
+	MergedFullName         *string   `json:"merged_full_name,omitempty"`
+	MergedPhone            *string   `json:"merged_phone,omitempty"`
+	ProductPointsMoved     int       `json:"product_points_moved"`
+	WalletPointsMoved      int       `json:"wallet_points_moved"`
+	OrdersMoved            int       `json:"orders_moved"`
+	PointTransactionsMoved int       `json:"point_transactions_moved"`
+	RedemptionsMoved       int       `json:"redemptions_moved"`
+	Note                   *string   `json:"note,omitempty"`
+	MergedBy               *int64    `json:"merged_by,omitempty"`
+	MergedByName           *string   `json:"merged_by_name,omitempty"`
+	CreatedAt              time.Time `json:"created_at"`
+}
+
+// MergeCustomersResponse shows the survivor's balances after the merge
+type MergeCustomersResponse struct {
+	SurvivorID  int64                       `json:"survivor_id"`
+	Merges      []CustomerMerge             `json:"merges"`
+	Products    []CustomerProductPointsInfo `json:"products"`
+	Wallet      *WalletBalance              `json:"wallet,omitempty"`
+	Tier        *CustomerTier               `json:"tier,omitempty"`
+	TierChanged bool                        `json:"tier_changed"`
+	Message     string                      `json:"message"`
+}
+
+type CustomerMergeListResponse struct {
+	Merges     []CustomerMerge `json:"merges"`
+	TotalCount int             `json:"total_count"`
+}
@@ -0,0 +1,57 @@
+package domain
+
+import "time"
+
+// CustomerOTPRequest asks for a one-time password sent to the customer's phone
+type CustomerOTPRequest struct {
+	Phone string `json:"phone" binding:"required,max=20"`
+}
+
+// CustomerOTPRequestResponse is the same whether or not the phone belongs to a
+// customer, so the endpoint cannot be used to look up members
+type CustomerOTPRequestResponse struct {
+	Message   string `json:"message"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+type CustomerOTPVerifyRequest struct {
+	Phone string `json:"phone" binding:"required,max=20"`
+	Code  string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type CustomerSessionResponse struct {
+	SessionToken string    `json:"session_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CustomerName string    `json:"customer_name"`
+	StoreName    string    `json:"store_name"`
+}
+
+// CustomerSessionInfo is the customer and store a self-service session is scoped to
+type CustomerSessionInfo struct {
+	StoreID    int64     `json:"store_id"`
+	CustomerID int64     `json:"customer_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+type CustomerPortalPointsResponse struct {
+	CustomerName string                      `json:"customer_name"`
+	CustomerCode string                      `json:"customer_code"`
+	Tier         *CustomerTier               `json:"tier,omitempty"`
+	Products     []CustomerProductPointsInfo `json:"products"`
+	Wallet       *WalletBalance              `json:"wallet,omitempty"`
+}
+
+// CustomerReward is a redeemable product with the customer's points for it
+type CustomerReward struct {
+	ProductID      int64   `json:"product_id"`
+	ProductName    string  `json:"product_name"`
+	CategoryName   *string `json:"category_name,omitempty"`
+	ImagePath      *string `json:"image_path,omitempty"`
+	PointsToRedeem int     `json:"points_to_redeem"`
+	Points         int     `json:"points"`
+	CanRedeem      bool    `json:"can_redeem"`
+}
+
+type CustomerRewardsResponse struct {
+	Rewards []CustomerReward `json:"rewards"`
+}
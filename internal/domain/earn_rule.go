@@ -0,0 +1,102 @@
+package domain
+
+import "time"
+
+// EarnRuleType represents how an earn rule awards points
+type EarnRuleType string
+
+const (
+	EarnRuleTypePerUnit    EarnRuleType = "PER_UNIT"
+	EarnRuleTypePerBaht    EarnRuleType = "PER_BAHT"
+	EarnRuleTypeMultiplier EarnRuleType = "MULTIPLIER"
+)
+
+// EarnRule represents a store's rule for earning points on an order line
+// PER_UNIT and PER_BAHT rules set a line's base points and the most specific
+// match wins; MULTIPLIER rules scale the base points and stack.
+type EarnRule struct {
+	ID            int64        `json:"id"`
+	RuleName      string       `json:"rule_name"`
+	RuleType      EarnRuleType `json:"rule_type"`
+	PointsPerUnit *int         `json:"points_per_unit,omitempty"`
+	BahtPerPoint  *float64     `json:"baht_per_point,omitempty"`
+	Multiplier    *float64     `json:"multiplier,omitempty"`
+	ProductID     *int64       `json:"product_id,omitempty"`
+	ProductName   *string      `json:"product_name,omitempty"`
+	CategoryID    *int64       `json:"category_id,omitempty"`
+	CategoryName  *string      `json:"category_name,omitempty"`
+	PromotionID   *int64       `json:"promotion_id,omitempty"`
+	TierCode      *string      `json:"tier_code,omitempty"`
+	DaysOfWeek    []int        `json:"days_of_week,omitempty"`
+	StartTime     *string      `json:"start_time,omitempty"`
+	EndTime       *string      `json:"end_time,omitempty"`
+	StartsAt      *time.Time   `json:"starts_at,omitempty"`
+	EndsAt        *time.Time   `json:"ends_at,omitempty"`
+	Priority      int          `json:"priority"`
+	IsActive      bool         `json:"is_active"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+// EarnRuleRequest represents a request to create or replace an earn rule
+// DaysOfWeek uses 0 for Sunday. StartTime and EndTime (HH:MM) form a daily
+// window that may wrap past midnight.
+type EarnRuleRequest struct {
+	RuleName      string       `json:"rule_name" binding:"required"`
+	RuleType      EarnRuleType `json:"rule_type" binding:"required,oneof=PER_UNIT PER_BAHT MULTIPLIER"`
+	PointsPerUnit *int         `json:"points_per_unit,omitempty" binding:"omitempty,min=1"`
+	BahtPerPoint  *float64     `json:"baht_per_point,omitempty" binding:"omitempty,gt=0"`
+	Multiplier    *float64     `json:"multiplier,omitempty" binding:"omitempty,gt=0"`
+	ProductID     *int64       `json:"product_id,omitempty"`
+	CategoryID    *int64       `json:"category_id,omitempty"`
+	PromotionID   *int64       `json:"promotion_id,omitempty"`
+	TierCode      *string      `json:"tier_code,omitempty"`
+	DaysOfWeek    []int        `json:"days_of_week,omitempty" binding:"omitempty,dive,min=0,max=6"`
+	StartTime     *string      `json:"start_time,omitempty" binding:"omitempty,datetime=15:04"`
+	EndTime       *string      `json:"end_time,omitempty" binding:"omitempty,datetime=15:04"`
+	StartsAt      *time.Time   `json:"starts_at,omitempty"`
+	EndsAt        *time.Time   `json:"ends_at,omitempty"`
+	Priority      int          `json:"priority"`
+	IsActive      *bool        `json:"is_active,omitempty"`
+}
+
+// EarnRuleListResponse represents a store's earn rules
+type EarnRuleListResponse struct {
+	Rules []EarnRule `json:"rules"`
+}
+
+// EarnProductInfo is the product data the earn rules match on
+type EarnProductInfo struct {
+	ProductID   int64  `db:"id"`
+	ProductName string `db:"product_name"`
+	CategoryID  *int64 `db:"category_id"`
+}
+
+// PreviewEarnRequest represents a cart to price in points before checkout
+// CustomerID applies that customer's tier.
+type PreviewEarnRequest struct {
+	Items       []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+	PromotionID *int64             `json:"promotion_id,omitempty"`
+	CustomerID  *int64             `json:"customer_id,omitempty"`
+}
+
+// EarnPreviewResponse represents the points a cart would earn
+// DefaultRule is set when the store has no active rules and every unit earns 1 point.
+type EarnPreviewResponse struct {
+	TotalPoints int               `json:"total_points"`
+	DefaultRule bool              `json:"default_rule"`
+	Tier        *CustomerTier     `json:"tier,omitempty"`
+	Items       []EarnPreviewItem `json:"items"`
+}
+
+// EarnPreviewItem represents the points for one cart line
+type EarnPreviewItem struct {
+	ProductID    int64    `json:"product_id"`
+	ProductName  string   `json:"product_name"`
+	Quantity     int      `json:"quantity"`
+	LineTotal    float64  `json:"line_total"`
+	BasePoints   int      `json:"base_points"`
+	Multiplier   float64  `json:"multiplier"`
+	Points       int      `json:"points"`
+	AppliedRules []string `json:"applied_rules,omitempty"`
+}
@@ -6,14 +6,16 @@ import "time"
 type MovementType string
 
 const (
-	MovementTypeSale        MovementType = "SALE"
-	MovementTypeCancelSale  MovementType = "CANCEL_SALE"
-	MovementTypeReceive     MovementType = "RECEIVE"
-	MovementTypeIssue       MovementType = "ISSUE"
-	MovementTypeAdjust      MovementType = "ADJUST"
-	MovementTypeTransferIn  MovementType = "TRANSFER_IN"
-	MovementTypeTransferOut MovementType = "TRANSFER_OUT"
-	MovementTypeDamage      MovementType = "DAMAGE"
+	MovementTypeSale         MovementType = "SALE"
+	MovementTypeCancelSale   MovementType = "CANCEL_SALE"
+	MovementTypeReceive      MovementType = "RECEIVE"
+	MovementTypeIssue        MovementType = "ISSUE"
+	MovementTypeAdjust       MovementType = "ADJUST"
+	MovementTypeTransferIn   MovementType = "TRANSFER_IN"
+	MovementTypeTransferOut  MovementType = "TRANSFER_OUT"
+	MovementTypeDamage       MovementType = "DAMAGE"
+	MovementTypeRedeem       MovementType = "REDEEM"
+	MovementTypeCancelRedeem MovementType = "CANCEL_REDEEM"
 )
 
 // InventoryMovement represents a stock movement record
@@ -43,18 +45,67 @@ type InventoryMovementResponse struct {
 	QuantityChange int          `json:"quantity_change"`
 	FromStockCount *int         `json:"from_stock_count,omitempty"`
 	ToStockCount   *int         `json:"to_stock_count,omitempty"`
+	ReasonCode     *string      `json:"reason_code,omitempty"`
 	Reason         *string      `json:"reason,omitempty"`
 	Note           *string      `json:"note,omitempty"`
 	ChangedByName  *string      `json:"changed_by_name,omitempty"`
+	ApprovedByName *string      `json:"approved_by_name,omitempty"`
+	UnitCost       *float64     `json:"unit_cost,omitempty"`
+	ReferenceTable *string      `json:"reference_table,omitempty"`
+	ReferenceID    *int64       `json:"reference_id,omitempty"`
+	RunningBalance *int         `json:"running_balance,omitempty"`
 	CreatedAt      time.Time    `json:"created_at"`
 }
 
+// InventoryMovementFilter narrows the movement history. Zero values match everything;
+// To is exclusive.
+type InventoryMovementFilter struct {
+	ProductID      *int64
+	MovementType   MovementType
+	StaffID        *int64
+	From           *time.Time
+	To             *time.Time
+	ReferenceTable string
+	ReferenceID    *int64
+}
+
 // AdjustStockRequest represents a request to adjust stock
+// Quantity is signed: positive records found stock, negative writes stock off.
+// MovementType defaults to the reason code's type and must match it when given.
+// ManagerPin is required when the adjustment reaches the store's approval threshold.
 type AdjustStockRequest struct {
-	ProductID int64  `json:"product_id" binding:"required"`
-	Quantity  int    `json:"quantity" binding:"required,min=1"`
-	Reason    string `json:"reason" binding:"required"`
-	Note      string `json:"note,omitempty"`
+	ProductID    int64        `json:"product_id" binding:"required"`
+	Quantity     int          `json:"quantity" binding:"required"`
+	MovementType MovementType `json:"movement_type,omitempty" binding:"omitempty,oneof=DAMAGE ISSUE ADJUST"`
+	ReasonCode   string       `json:"reason_code" binding:"required"`
+	Reason       string       `json:"reason,omitempty"`
+	Note         string       `json:"note,omitempty"`
+	ManagerPin   *string      `json:"manager_pin,omitempty"`
+}
+
+// StockAdjustmentReason represents an entry in the adjustment reason catalogue
+// StoreID is nil for system defaults shared by every store
+type StockAdjustmentReason struct {
+	ID           int64        `json:"id"`
+	StoreID      *int64       `json:"store_id,omitempty"`
+	Code         string       `json:"code"`
+	Label        string       `json:"label"`
+	MovementType MovementType `json:"movement_type"`
+	IsActive     bool         `json:"is_active"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+// CreateStockAdjustmentReasonRequest represents a request to add a store reason code
+type CreateStockAdjustmentReasonRequest struct {
+	Code         string       `json:"code" binding:"required,max=50"`
+	Label        string       `json:"label" binding:"required"`
+	MovementType MovementType `json:"movement_type" binding:"required,oneof=DAMAGE ISSUE ADJUST"`
+}
+
+// UpdateStockAdjustmentReasonRequest represents a request to update a store reason code
+type UpdateStockAdjustmentReasonRequest struct {
+	Label    string `json:"label" binding:"required"`
+	IsActive bool   `json:"is_active"`
 }
 
 // BranchProduct represents a product's stock in a branch
@@ -66,10 +117,18 @@ type BranchProduct struct {
 	IsActive     bool      `json:"is_active" db:"is_active"`
 	OnStock      int       `json:"on_stock" db:"on_stock"`
 	ReorderLevel int       `json:"reorder_level" db:"reorder_level"`
+	LeadTimeDays int       `json:"lead_time_days" db:"lead_time_days"`
 	CreatedAt    time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// UpdateReorderSettingsRequest represents a request to set a product's reorder level and lead time in a branch
+type UpdateReorderSettingsRequest struct {
+	ProductID    int64 `json:"product_id" binding:"required"`
+	ReorderLevel int   `json:"reorder_level" binding:"min=0"`
+	LeadTimeDays int   `json:"lead_time_days" binding:"min=0"`
+}
+
 // LowStockItem represents a product with low stock
 type LowStockItem struct {
 	ProductID    int64   `json:"product_id"`
@@ -85,3 +144,161 @@ type LowStockResponse struct {
 	Items      []LowStockItem `json:"items"`
 	TotalCount int            `json:"total_count"`
 }
+
+// ReplenishmentSource is where a replenishment order is placed
+type ReplenishmentSource string
+
+const (
+	ReplenishmentSourceWarehouse     ReplenishmentSource = "WAREHOUSE"
+	ReplenishmentSourcePurchaseOrder ReplenishmentSource = "PURCHASE_ORDER"
+)
+
+// ReplenishmentSuggestion represents a suggested order quantity for one product
+// ReorderPoint = reorder level + expected sales over the lead time.
+// SuggestedQty tops stock up to the reorder point plus the cover period, net of incoming stock.
+type ReplenishmentSuggestion struct {
+	ProductID     int64   `json:"product_id"`
+	ProductName   string  `json:"product_name"`
+	CategoryName  string  `json:"category_name"`
+	OnStock       int     `json:"on_stock"`
+	IncomingQty   int     `json:"incoming_qty"`
+	ReorderLevel  int     `json:"reorder_level"`
+	LeadTimeDays  int     `json:"lead_time_days"`
+	AvgDailySales float64 `json:"avg_daily_sales"`
+	ReorderPoint  int     `json:"reorder_point"`
+	SuggestedQty  int     `json:"suggested_qty"`
+}
+
+// ReplenishmentResponse represents the replenishment suggestions for a branch
+type ReplenishmentResponse struct {
+	SalesWindowDays int                       `json:"sales_window_days"`
+	CoverDays       int                       `json:"cover_days"`
+	Items           []ReplenishmentSuggestion `json:"items"`
+}
+
+// ReplenishmentCandidate is the raw per-product data suggestions are computed from
+type ReplenishmentCandidate struct {
+	ProductID    int64
+	ProductName  string
+	CategoryName string
+	OnStock      int
+	IncomingQty  int
+	ReorderLevel int
+	LeadTimeDays int
+	SoldQty      int
+	LastUnitCost float64
+}
+
+// CreateReplenishmentOrderRequest turns suggestions into a warehouse withdrawal or a purchase order
+// Items defaults to every current suggestion at its suggested quantity.
+// SupplierID is required for PURCHASE_ORDER; WarehouseID defaults to the store's default warehouse.
+type CreateReplenishmentOrderRequest struct {
+	Source          ReplenishmentSource `json:"source" binding:"required,oneof=WAREHOUSE PURCHASE_ORDER"`
+	WarehouseID     *int64              `json:"warehouse_id,omitempty"`
+	SupplierID      *int64              `json:"supplier_id,omitempty"`
+	SalesWindowDays int                 `json:"sales_window_days,omitempty" binding:"omitempty,min=1,max=365"`
+	CoverDays       *int                `json:"cover_days,omitempty" binding:"omitempty,min=0,max=365"`
+	Note            *string             `json:"note,omitempty"`
+	Items           []WithdrawItemInput `json:"items,omitempty" binding:"omitempty,dive"`
+}
+
+// ReplenishmentOrderResponse represents the order created from suggestions
+type ReplenishmentOrderResponse struct {
+	Source        ReplenishmentSource    `json:"source"`
+	Transfer      *StockTransferResponse `json:"transfer,omitempty"`
+	PurchaseOrder *PurchaseOrderResponse `json:"purchase_order,omitempty"`
+}
+
+// StockDriftItem compares a branch product's on_stock with the sum of its movements
+// Drift = OnStock - LedgerQty; a positive drift means stock exists that the ledger doesn't explain.
+type StockDriftItem struct {
+	StoreID          int64  `json:"store_id"`
+	BranchID         int64  `json:"branch_id"`
+	BranchName       string `json:"branch_name"`
+	ProductID        int64  `json:"product_id"`
+	ProductName      string `json:"product_name"`
+	OnStock          int    `json:"on_stock"`
+	LedgerQty        int    `json:"ledger_qty"`
+	Drift            int    `json:"drift"`
+	MovementCount    int    `json:"movement_count"`
+	LastToStockCount *int   `json:"last_to_stock_count,omitempty"`
+	Corrected        bool   `json:"corrected"`
+}
+
+// StockReconciliationRequest represents a request to reconcile stock against the ledger
+// Apply writes an ADJUST movement per drifting product so the ledger matches on_stock;
+// on_stock itself is never changed.
+type StockReconciliationRequest struct {
+	AllBranches bool `json:"all_branches"`
+	Apply       bool `json:"apply"`
+}
+
+// StockReconciliationResponse represents the result of a reconciliation run
+type StockReconciliationResponse struct {
+	StoreID        *int64           `json:"store_id,omitempty"`
+	BranchID       *int64           `json:"branch_id,omitempty"`
+	Applied        bool             `json:"applied"`
+	DriftCount     int              `json:"drift_count"`
+	CorrectedCount int              `json:"corrected_count"`
+	Items          []StockDriftItem `json:"items"`
+	RunAt          time.Time        `json:"run_at"`
+}
+
+// NegativeStockPolicy controls what happens when a sale exceeds on_stock
+type NegativeStockPolicy string
+
+const (
+	NegativeStockPolicyBlock            NegativeStockPolicy = "BLOCK"
+	NegativeStockPolicyAllow            NegativeStockPolicy = "ALLOW"
+	NegativeStockPolicyAllowWithWarning NegativeStockPolicy = "ALLOW_WITH_WARNING"
+)
+
+// UpdateProductNegativeStockPolicyRequest sets or clears a product's policy override
+// A nil Policy falls back to the store policy
+type UpdateProductNegativeStockPolicyRequest struct {
+	Policy *NegativeStockPolicy `json:"policy" binding:"omitempty,oneof=BLOCK ALLOW ALLOW_WITH_WARNING"`
+}
+
+// OversellItem represents a sale that took a product's stock below zero
+type OversellItem struct {
+	MovementID     int64     `json:"movement_id"`
+	OrderID        *int64    `json:"order_id,omitempty"`
+	BranchID       int64     `json:"branch_id"`
+	BranchName     string    `json:"branch_name"`
+	ProductID      int64     `json:"product_id"`
+	ProductName    string    `json:"product_name"`
+	Quantity       int       `json:"quantity"`
+	FromStockCount int       `json:"from_stock_count"`
+	ToStockCount   int       `json:"to_stock_count"`
+	OversoldQty    int       `json:"oversold_qty"`
+	StaffName      *string   `json:"staff_name,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// OversellReportResponse represents the oversell report
+type OversellReportResponse struct {
+	Items      []OversellItem `json:"items"`
+	TotalCount int            `json:"total_count"`
+}
+
+// InventoryValuationRow represents stock on hand for one branch and category
+// StockValue is at moving-average cost; RetailValue is at base price.
+// Negative stock is excluded from both.
+type InventoryValuationRow struct {
+	BranchID     int64   `json:"branch_id"`
+	BranchName   string  `json:"branch_name"`
+	CategoryID   *int64  `json:"category_id,omitempty"`
+	CategoryName string  `json:"category_name"`
+	ProductCount int     `json:"product_count"`
+	OnStock      int     `json:"on_stock"`
+	StockValue   float64 `json:"stock_value"`
+	RetailValue  float64 `json:"retail_value"`
+}
+
+// InventoryValuationResponse represents the valuation report
+type InventoryValuationResponse struct {
+	Rows             []InventoryValuationRow `json:"rows"`
+	TotalOnStock     int                     `json:"total_on_stock"`
+	TotalStockValue  float64                 `json:"total_stock_value"`
+	TotalRetailValue float64                 `json:"total_retail_value"`
+}
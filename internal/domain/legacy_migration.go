@@ -0,0 +1,58 @@
+package domain
+
+import "github.com/google/uuid"
+
+// LegacyMigrationOptions configures copying v1 members into one store's v2
+// customers. The v1 liter buckets become points on the two products.
+type LegacyMigrationOptions struct {
+	StoreID           int64
+	Product1_0LiterID int64
+	Product1_5LiterID int64
+	DryRun            bool
+}
+
+// LegacyMemberAction is what the migration did with a v1 member
+type LegacyMemberAction string
+
+const (
+	LegacyMemberCreated   LegacyMemberAction = "CREATE"
+	LegacyMemberLinked    LegacyMemberAction = "LINK"
+	LegacyMemberUpdated   LegacyMemberAction = "UPDATE"
+	LegacyMemberUnchanged LegacyMemberAction = "UNCHANGED"
+	LegacyMemberFailed    LegacyMemberAction = "ERROR"
+)
+
+// LegacyBucketDiff compares a v1 liter bucket with the customer's v2 points for
+// its product. HistoryAdded is the net change of the v1 transactions replayed
+// this run; Correction is the ADJUST booked for any balance they do not explain.
+type LegacyBucketDiff struct {
+	ProductType   ProductType `json:"product_type"`
+	ProductID     int64       `json:"product_id"`
+	LegacyBalance int         `json:"legacy_balance"`
+	BalanceBefore int         `json:"balance_before"`
+	BalanceAfter  int         `json:"balance_after"`
+	HistoryRows   int         `json:"history_rows"`
+	HistoryAdded  int         `json:"history_added"`
+	Correction    int         `json:"correction"`
+}
+
+type LegacyMemberResult struct {
+	MemberID         uuid.UUID          `json:"member_id"`
+	MembershipNumber *string            `json:"membership_number,omitempty"`
+	Name             string             `json:"name"`
+	CustomerID       int64              `json:"customer_id,omitempty"`
+	Action           LegacyMemberAction `json:"action"`
+	Buckets          []LegacyBucketDiff `json:"buckets"`
+	Error            string             `json:"error,omitempty"`
+}
+
+type LegacyMigrationResult struct {
+	DryRun      bool                 `json:"dry_run"`
+	Members     []LegacyMemberResult `json:"members"`
+	Created     int                  `json:"created"`
+	Linked      int                  `json:"linked"`
+	Updated     int                  `json:"updated"`
+	Unchanged   int                  `json:"unchanged"`
+	Failed      int                  `json:"failed"`
+	HistoryRows int                  `json:"history_rows"`
+}
@@ -16,10 +16,11 @@ type ListProductsResponse struct {
 }
 
 type CustomerInfo struct {
-	ID           int64  `json:"id"`
-	CustomerCode string `json:"customer_code"`
-	FullName     string `json:"full_name"`
-	PhoneLast4   string `json:"phone_last4"`
+	ID           int64         `json:"id"`
+	CustomerCode string        `json:"customer_code"`
+	FullName     string        `json:"full_name"`
+	PhoneLast4   string        `json:"phone_last4"`
+	Tier         *CustomerTier `json:"tier,omitempty"`
 }
 
 type SearchCustomersResponse struct {
@@ -43,17 +44,51 @@ type CreateOrderRequest struct {
 	Subtotal      float64            `json:"subtotal" binding:"required,gte=0"`
 	DiscountTotal float64            `json:"discount_total" binding:"gte=0"`
 	TotalPrice    float64            `json:"total_price" binding:"required,gte=0"`
-	Payments      []PaymentRequest   `json:"payments" binding:"required,min=1,dive"`
-	ChangeAmount  float64            `json:"change_amount" binding:"gte=0"`
-	PromotionID   *int64             `json:"promotion_id"`
+	// Payments may be empty when wallet points pay for the whole order
+	Payments     []PaymentRequest    `json:"payments" binding:"dive"`
+	ChangeAmount float64             `json:"change_amount" binding:"gte=0"`
+	PromotionID  *int64              `json:"promotion_id"`
+	Wallet       *OrderWalletRequest `json:"wallet,omitempty"`
 }
 
 type CreateOrderResponse struct {
-	OrderID      int64     `json:"order_id"`
-	Status       string    `json:"status"`
-	TotalPrice   float64   `json:"total_price"`
-	ChangeAmount float64   `json:"change_amount"`
-	CreatedAt    time.Time `json:"created_at"`
+	OrderID      int64          `json:"order_id"`
+	Status       string         `json:"status"`
+	TotalPrice   float64        `json:"total_price"`
+	ChangeAmount float64        `json:"change_amount"`
+	Warnings     []OrderWarning `json:"warnings,omitempty"`
+	// Points is set when the order has a customer
+	Points    *OrderPointsEarned `json:"points,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+// OrderPointsEarned represents the points an order earned for its customer
+// Tier is the customer's tier after the order; TierChanged is set when the
+// order moved them to it. Wallet is set when the store uses the points wallet.
+type OrderPointsEarned struct {
+	PointsEarned int                `json:"points_earned"`
+	Items        []OrderPointsItem  `json:"items"`
+	Tier         *CustomerTier      `json:"tier,omitempty"`
+	TierChanged  bool               `json:"tier_changed"`
+	Wallet       *OrderWalletResult `json:"wallet,omitempty"`
+}
+
+// OrderPointsItem represents the points earned for a product and the customer's new balance
+type OrderPointsItem struct {
+	ProductID    int64  `json:"product_id"`
+	ProductName  string `json:"product_name"`
+	PointsEarned int    `json:"points_earned"`
+	Balance      int    `json:"balance"`
+}
+
+const OrderWarningNegativeStock = "NEGATIVE_STOCK"
+
+// OrderWarning flags something the cashier should know about a completed order
+type OrderWarning struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	ProductID int64  `json:"product_id,omitempty"`
+	OnStock   int    `json:"on_stock"`
 }
 
 type OrderInfo struct {
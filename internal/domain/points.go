@@ -16,7 +16,7 @@ type CustomerProductPoints struct {
 type PointTransaction struct {
 	ID              int64     `db:"id" json:"id"`
 	StoreID         int64     `db:"store_id" json:"store_id"`
-	BranchID        int64     `db:"branch_id" json:"branch_id"`
+	BranchID        *int64    `db:"branch_id" json:"branch_id,omitempty"`
 	CustomerID      int64     `db:"customer_id" json:"customer_id"`
 	TransactionType string    `db:"transaction_type" json:"transaction_type"`
 	PointsChange    int       `db:"points_change" json:"points_change"`
@@ -28,6 +28,11 @@ type PointTransaction struct {
 	CreatedAt       time.Time `db:"created_at" json:"created_at"`
 }
 
+const (
+	RedemptionStatusCompleted = "COMPLETED"
+	RedemptionStatusReversed  = "REVERSED"
+)
+
 type PointRedemption struct {
 	ID         int64     `db:"id" json:"id"`
 	StoreID    int64     `db:"store_id" json:"store_id"`
@@ -37,6 +42,7 @@ type PointRedemption struct {
 	PointsUsed int       `db:"points_used" json:"points_used"`
 	Quantity   int       `db:"quantity" json:"quantity"`
 	Status     string    `db:"status" json:"status"`
+	ShiftID    *int64    `db:"shift_id" json:"shift_id,omitempty"`
 	StaffID    *int64    `db:"staff_id" json:"staff_id,omitempty"`
 	CreatedAt  time.Time `db:"created_at" json:"created_at"`
 	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
@@ -48,7 +54,10 @@ type GetCustomerPointsResponse struct {
 	CustomerID   int64                       `json:"customer_id"`
 	CustomerName string                      `json:"customer_name"`
 	CustomerCode string                      `json:"customer_code"`
+	Tier         *CustomerTier               `json:"tier,omitempty"`
 	Products     []CustomerProductPointsInfo `json:"products"`
+	// Wallet is set when the store uses the points wallet
+	Wallet *WalletBalance `json:"wallet,omitempty"`
 }
 
 // CustomerProductPointsInfo shows points for a specific product
@@ -88,18 +97,99 @@ type RedeemPointsResponse struct {
 	RemainingPoints int    `json:"remaining_points"`
 	ProductName     string `json:"product_name"`
 	Quantity        int    `json:"quantity"`
+	RemainingStock  int    `json:"remaining_stock"`
+	Message         string `json:"message"`
+}
+
+// ReverseRedemptionRequest represents a request to undo a redemption
+// ManagerPin is needed unless a manager is signed in.
+type ReverseRedemptionRequest struct {
+	Reason     string  `json:"reason" binding:"required"`
+	ManagerPin *string `json:"manager_pin,omitempty"`
+}
+
+// ReverseRedemptionResponse represents a reversed redemption
+type ReverseRedemptionResponse struct {
+	RedemptionID    int64  `json:"redemption_id"`
+	Status          string `json:"status"`
+	PointsRestored  int    `json:"points_restored"`
+	RemainingPoints int    `json:"remaining_points"`
+	StockReturned   int    `json:"stock_returned"`
 	Message         string `json:"message"`
 }
 
-type OrderItemForPoints struct {
-	ProductID int64 `json:"product_id"`
-	Quantity  int   `json:"quantity"`
+// PointAdjustReason is why a manager changed a customer's points by hand
+type PointAdjustReason string
+
+const (
+	PointAdjustGoodwill     PointAdjustReason = "GOODWILL"
+	PointAdjustCorrection   PointAdjustReason = "CORRECTION"
+	PointAdjustCompensation PointAdjustReason = "COMPENSATION"
+	PointAdjustOther        PointAdjustReason = "OTHER"
+)
+
+// AdjustPointsRequest represents a manual change to a customer's points for a product
+// Points is signed: positive grants points, negative takes them away.
+type AdjustPointsRequest struct {
+	CustomerID int64             `json:"customer_id" binding:"required"`
+	ProductID  int64             `json:"product_id" binding:"required"`
+	Points     int               `json:"points" binding:"required"`
+	ReasonCode PointAdjustReason `json:"reason_code" binding:"required,oneof=GOODWILL CORRECTION COMPENSATION OTHER"`
+	Note       string            `json:"note" binding:"required"`
+}
+
+type AdjustPointsResponse struct {
+	CustomerID   int64  `json:"customer_id"`
+	ProductID    int64  `json:"product_id"`
+	PointsChange int    `json:"points_change"`
+	Balance      int    `json:"balance"`
+	Message      string `json:"message"`
+}
+
+// PointAdjustmentFilter narrows the adjustment report. Zero values match everything;
+// To is exclusive.
+type PointAdjustmentFilter struct {
+	BranchID *int64
+	StaffID  *int64
+	From     *time.Time
+	To       *time.Time
 }
 
-type EarnPointsResponse struct {
-	PointsEarned    int `json:"points_earned"`
-	TotalPoints     int `json:"total_points"`
-	RemainingPoints int `json:"remaining_points"`
+// PointAdjustmentItem represents one manual ADJUST point transaction
+type PointAdjustmentItem struct {
+	TransactionID int64             `json:"transaction_id"`
+	BranchID      *int64            `json:"branch_id,omitempty"`
+	BranchName    *string           `json:"branch_name,omitempty"`
+	StaffID       *int64            `json:"staff_id,omitempty"`
+	StaffName     *string           `json:"staff_name,omitempty"`
+	CustomerID    int64             `json:"customer_id"`
+	CustomerName  *string           `json:"customer_name,omitempty"`
+	CustomerCode  *string           `json:"customer_code,omitempty"`
+	ProductID     *int64            `json:"product_id,omitempty"`
+	ProductName   *string           `json:"product_name,omitempty"`
+	PointsChange  int               `json:"points_change"`
+	ReasonCode    PointAdjustReason `json:"reason_code,omitempty"`
+	Note          *string           `json:"note,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// PointAdjustmentSummary totals manual adjustments for one branch and staff member
+type PointAdjustmentSummary struct {
+	BranchID      *int64  `json:"branch_id,omitempty"`
+	BranchName    *string `json:"branch_name,omitempty"`
+	StaffID       *int64  `json:"staff_id,omitempty"`
+	StaffName     *string `json:"staff_name,omitempty"`
+	Adjustments   int     `json:"adjustments"`
+	PointsAdded   int     `json:"points_added"`
+	PointsRemoved int     `json:"points_removed"`
+}
+
+// PointAdjustmentReportResponse represents the manual adjustment report
+// Summary covers every adjustment matching the filter; Items is one page of them.
+type PointAdjustmentReportResponse struct {
+	Summary    []PointAdjustmentSummary `json:"summary"`
+	Items      []PointAdjustmentItem    `json:"items"`
+	TotalCount int                      `json:"total_count"`
 }
 
 type PointHistoryItem struct {
@@ -116,3 +206,39 @@ type GetPointHistoryResponse struct {
 	History    []PointHistoryItem `json:"history"`
 	Total      int                `json:"total"`
 }
+
+// PointsExpiryMode controls when earned points expire
+type PointsExpiryMode string
+
+const (
+	PointsExpiryNone        PointsExpiryMode = "NONE"
+	PointsExpiryFixedPeriod PointsExpiryMode = "FIXED_PERIOD"
+	PointsExpiryEndOfYear   PointsExpiryMode = "END_OF_YEAR"
+)
+
+// DefaultExpiringPointsDays is how far ahead the expiring points list looks by default
+const DefaultExpiringPointsDays = 30
+
+// ExpiringPointsItem represents a customer's points for a product that expire at the same time
+type ExpiringPointsItem struct {
+	CustomerID   int64     `db:"customer_id" json:"customer_id"`
+	CustomerName *string   `db:"customer_name" json:"customer_name,omitempty"`
+	CustomerCode *string   `db:"customer_code" json:"customer_code,omitempty"`
+	ProductID    int64     `db:"product_id" json:"product_id"`
+	ProductName  string    `db:"product_name" json:"product_name"`
+	Points       int       `db:"points" json:"points"`
+	ExpiresAt    time.Time `db:"expires_at" json:"expires_at"`
+}
+
+type ExpiringPointsResponse struct {
+	Days  int                  `json:"days"`
+	Items []ExpiringPointsItem `json:"items"`
+	Total int                  `json:"total"`
+}
+
+// ExpirePointsResult summarises an expiry run
+type ExpirePointsResult struct {
+	BalancesExpired int       `json:"balances_expired"`
+	PointsExpired   int       `json:"points_expired"`
+	RunAt           time.Time `json:"run_at"`
+}
@@ -36,13 +36,17 @@ type PromotionResponse struct {
 	IsActive       bool               `json:"is_active"`
 	StartsAt       *time.Time         `json:"starts_at,omitempty"`
 	EndsAt         *time.Time         `json:"ends_at,omitempty"`
+	// TierCode limits the promotion to customers in that tier or a higher one
+	TierCode *string `json:"tier_code,omitempty"`
 }
 
 // CalculateDiscountRequest represents a request to calculate discount
+// CustomerID is needed for tier-only promotions.
 type CalculateDiscountRequest struct {
 	PromotionID int64                    `json:"promotion_id"`
 	Items       []CalculateDiscountItem  `json:"items"`
 	Subtotal    float64                  `json:"subtotal"`
+	CustomerID  *int64                   `json:"customer_id,omitempty"`
 }
 
 type CalculateDiscountItem struct {
@@ -63,8 +67,10 @@ type CalculateDiscountResponse struct {
 }
 
 // DetectPromotionsRequest represents a request to detect applicable promotions
+// CustomerID also detects the tier-only promotions their tier qualifies for.
 type DetectPromotionsRequest struct {
-	Items []CalculateDiscountItem `json:"items"`
+	Items      []CalculateDiscountItem `json:"items"`
+	CustomerID *int64                  `json:"customer_id,omitempty"`
 }
 
 // DetectedPromotion represents a promotion that can be applied
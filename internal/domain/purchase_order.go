@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Supplier represents a vendor the store buys stock from
+type Supplier struct {
+	ID           int64     `json:"id"`
+	StoreID      int64     `json:"store_id"`
+	SupplierName string    `json:"supplier_name"`
+	ContactName  *string   `json:"contact_name,omitempty"`
+	Phone        *string   `json:"phone,omitempty"`
+	Email        *string   `json:"email,omitempty"`
+	Note         *string   `json:"note,omitempty"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// CreateSupplierRequest represents a request to create a supplier
+type CreateSupplierRequest struct {
+	SupplierName string  `json:"supplier_name" binding:"required"`
+	ContactName  *string `json:"contact_name,omitempty"`
+	Phone        *string `json:"phone,omitempty"`
+	Email        *string `json:"email,omitempty"`
+	Note         *string `json:"note,omitempty"`
+}
+
+// UpdateSupplierRequest represents a request to update a supplier
+type UpdateSupplierRequest struct {
+	SupplierName string  `json:"supplier_name" binding:"required"`
+	ContactName  *string `json:"contact_name,omitempty"`
+	Phone        *string `json:"phone,omitempty"`
+	Email        *string `json:"email,omitempty"`
+	Note         *string `json:"note,omitempty"`
+	IsActive     bool    `json:"is_active"`
+}
+
+// PurchaseOrderStatus represents the status of a purchase order
+type PurchaseOrderStatus string
+
+const (
+	PurchaseOrderStatusOpen              PurchaseOrderStatus = "OPEN"
+	PurchaseOrderStatusPartiallyReceived PurchaseOrderStatus = "PARTIALLY_RECEIVED"
+	PurchaseOrderStatusClosed            PurchaseOrderStatus = "CLOSED"
+	PurchaseOrderStatusCancelled         PurchaseOrderStatus = "CANCELLED"
+)
+
+// PurchaseOrderResponse represents a purchase order with its lines
+type PurchaseOrderResponse struct {
+	ID            int64                       `json:"id"`
+	SupplierID    int64                       `json:"supplier_id"`
+	SupplierName  string                      `json:"supplier_name"`
+	BranchID      int64                       `json:"branch_id"`
+	BranchName    string                      `json:"branch_name"`
+	Status        PurchaseOrderStatus         `json:"status"`
+	ExpectedAt    *time.Time                  `json:"expected_at,omitempty"`
+	Note          *string                     `json:"note,omitempty"`
+	CreatedByName *string                     `json:"created_by_name,omitempty"`
+	TotalCost     float64                     `json:"total_cost"`
+	Items         []PurchaseOrderItemResponse `json:"items"`
+	ClosedAt      *time.Time                  `json:"closed_at,omitempty"`
+	CreatedAt     time.Time                   `json:"created_at"`
+}
+
+// PurchaseOrderItemResponse represents a purchase order line
+type PurchaseOrderItemResponse struct {
+	ID             int64   `json:"id"`
+	ProductID      int64   `json:"product_id"`
+	ProductName    string  `json:"product_name"`
+	OrderedQty     int     `json:"ordered_qty"`
+	ReceivedQty    int     `json:"received_qty"`
+	OutstandingQty int     `json:"outstanding_qty"`
+	UnitCost       float64 `json:"unit_cost"`
+}
+
+// CreatePurchaseOrderRequest represents a request to create a purchase order
+// BranchID defaults to the session branch
+type CreatePurchaseOrderRequest struct {
+	SupplierID int64                          `json:"supplier_id" binding:"required"`
+	BranchID   *int64                         `json:"branch_id,omitempty"`
+	ExpectedAt *time.Time                     `json:"expected_at,omitempty"`
+	Note       *string                        `json:"note,omitempty"`
+	Items      []CreatePurchaseOrderItemInput `json:"items" binding:"required,min=1,dive"`
+}
+
+// CreatePurchaseOrderItemInput represents a purchase order line input
+type CreatePurchaseOrderItemInput struct {
+	ProductID  int64   `json:"product_id" binding:"required"`
+	OrderedQty int     `json:"ordered_qty" binding:"required,min=1"`
+	UnitCost   float64 `json:"unit_cost" binding:"gte=0"`
+}
+
+// ReceiveGoodsRequest represents a goods received note against a purchase order
+// UnitCost is optional per line and defaults to the purchase order cost
+type ReceiveGoodsRequest struct {
+	SupplierRef *string                 `json:"supplier_ref,omitempty"`
+	Note        *string                 `json:"note,omitempty"`
+	Items       []ReceiveGoodsItemInput `json:"items" binding:"required,min=1,dive"`
+}
+
+// ReceiveGoodsItemInput represents a received line
+// LotNumber is required for products that track lots; ExpiryDate is YYYY-MM-DD.
+// UnitCost must not be negative.
+type ReceiveGoodsItemInput struct {
+	ProductID  int64            `json:"product_id" binding:"required"`
+	Quantity   int              `json:"quantity" binding:"required,min=1"`
+	UnitCost   *decimal.Decimal `json:"unit_cost,omitempty"`
+	LotNumber  *string          `json:"lot_number,omitempty"`
+	ExpiryDate *string          `json:"expiry_date,omitempty" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// GoodsReceivedNoteResponse represents a recorded delivery
+type GoodsReceivedNoteResponse struct {
+	ID              int64                           `json:"id"`
+	PurchaseOrderID int64                           `json:"purchase_order_id"`
+	BranchID        int64                           `json:"branch_id"`
+	SupplierRef     *string                         `json:"supplier_ref,omitempty"`
+	Note            *string                         `json:"note,omitempty"`
+	ReceivedByName  *string                         `json:"received_by_name,omitempty"`
+	Items           []GoodsReceivedNoteItemResponse `json:"items"`
+	CreatedAt       time.Time                       `json:"created_at"`
+}
+
+// GoodsReceivedNoteItemResponse represents a line on a goods received note
+type GoodsReceivedNoteItemResponse struct {
+	ProductID   int64      `json:"product_id"`
+	ProductName string     `json:"product_name"`
+	Quantity    int        `json:"quantity"`
+	UnitCost    float64    `json:"unit_cost"`
+	LotNumber   *string    `json:"lot_number,omitempty"`
+	ExpiryDate  *time.Time `json:"expiry_date,omitempty"`
+}
+
+// PurchaseOrderListResponse represents a paginated list of purchase orders
+type PurchaseOrderListResponse struct {
+	PurchaseOrders []PurchaseOrderResponse `json:"purchase_orders"`
+	Total          int                     `json:"total"`
+}
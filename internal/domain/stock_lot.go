@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+// DefaultExpiryWarningDays is how far ahead the expiring-soon report looks by default
+const DefaultExpiryWarningDays = 7
+
+// StockLot represents what is left of a lot at a branch or a warehouse
+// StockValue is the lot quantity at the location's moving-average cost.
+type StockLot struct {
+	ID           int64      `json:"id"`
+	BranchID     *int64     `json:"branch_id,omitempty"`
+	WarehouseID  *int64     `json:"warehouse_id,omitempty"`
+	LocationName string     `json:"location_name"`
+	ProductID    int64      `json:"product_id"`
+	ProductName  string     `json:"product_name"`
+	LotNumber    string     `json:"lot_number"`
+	ExpiryDate   *time.Time `json:"expiry_date,omitempty"`
+	DaysToExpiry *int       `json:"days_to_expiry,omitempty"`
+	Quantity     int        `json:"quantity"`
+	StockValue   float64    `json:"stock_value"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// StockLotListResponse represents a list of lots on hand
+type StockLotListResponse struct {
+	Lots       []StockLot `json:"lots"`
+	TotalCount int        `json:"total_count"`
+}
+
+// ExpiringLotsResponse represents lots that expire within Days, including lots already expired
+type ExpiringLotsResponse struct {
+	Days          int        `json:"days"`
+	Lots          []StockLot `json:"lots"`
+	TotalQuantity int        `json:"total_quantity"`
+	TotalValue    float64    `json:"total_value"`
+}
+
+// WriteOffLotRequest represents a request to write off stock from a lot
+// Quantity defaults to everything left in the lot. ReasonCode defaults to EXPIRED
+// and must be a DAMAGE reason.
+type WriteOffLotRequest struct {
+	Quantity   *int    `json:"quantity,omitempty" binding:"omitempty,min=1"`
+	ReasonCode string  `json:"reason_code,omitempty"`
+	Reason     string  `json:"reason,omitempty"`
+	Note       string  `json:"note,omitempty"`
+	ManagerPin *string `json:"manager_pin,omitempty"`
+}
+
+// UpdateProductLotTrackingRequest turns lot tracking on or off for a product
+type UpdateProductLotTrackingRequest struct {
+	TrackLots *bool `json:"track_lots" binding:"required"`
+}
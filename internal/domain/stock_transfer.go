@@ -6,26 +6,29 @@ import "time"
 type StockTransferStatus string
 
 const (
-	StockTransferStatusCreated   StockTransferStatus = "CREATED"
-	StockTransferStatusSent      StockTransferStatus = "SENT"
-	StockTransferStatusReceived  StockTransferStatus = "RECEIVED"
-	StockTransferStatusCancelled StockTransferStatus = "CANCELLED"
+	StockTransferStatusCreated           StockTransferStatus = "CREATED"
+	StockTransferStatusPicking           StockTransferStatus = "PICKING"
+	StockTransferStatusSent              StockTransferStatus = "SENT"
+	StockTransferStatusPartiallyReceived StockTransferStatus = "PARTIALLY_RECEIVED"
+	StockTransferStatusReceived          StockTransferStatus = "RECEIVED"
+	StockTransferStatusCancelled         StockTransferStatus = "CANCELLED"
 )
 
 // StockTransfer represents a stock transfer between branches
 type StockTransfer struct {
-	ID           int64               `json:"id"`
-	StoreID      int64               `json:"store_id"`
-	FromBranchID *int64              `json:"from_branch_id,omitempty"`
-	ToBranchID   int64               `json:"to_branch_id"`
-	Status       StockTransferStatus `json:"status"`
-	SentBy       *int64              `json:"sent_by,omitempty"`
-	ReceivedBy   *int64              `json:"received_by,omitempty"`
-	SentAt       *time.Time          `json:"sent_at,omitempty"`
-	ReceivedAt   *time.Time          `json:"received_at,omitempty"`
-	Note         *string             `json:"note,omitempty"`
-	CreatedAt    time.Time           `json:"created_at"`
-	UpdatedAt    time.Time           `json:"updated_at"`
+	ID              int64               `json:"id"`
+	StoreID         int64               `json:"store_id"`
+	FromBranchID    *int64              `json:"from_branch_id,omitempty"`
+	FromWarehouseID *int64              `json:"from_warehouse_id,omitempty"`
+	ToBranchID      int64               `json:"to_branch_id"`
+	Status          StockTransferStatus `json:"status"`
+	SentBy          *int64              `json:"sent_by,omitempty"`
+	ReceivedBy      *int64              `json:"received_by,omitempty"`
+	SentAt          *time.Time          `json:"sent_at,omitempty"`
+	ReceivedAt      *time.Time          `json:"received_at,omitempty"`
+	Note            *string             `json:"note,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
 }
 
 // StockTransferItem represents an item in a stock transfer
@@ -33,6 +36,8 @@ type StockTransferItem struct {
 	ID              int64     `json:"id"`
 	StockTransferID int64     `json:"stock_transfer_id"`
 	ProductID       int64     `json:"product_id"`
+	RequestedCount  int       `json:"requested_count"`
+	PickedCount     int       `json:"picked_count"`
 	SendCount       int       `json:"send_count"`
 	ReceiveCount    int       `json:"receive_count"`
 	CreatedAt       time.Time `json:"created_at"`
@@ -41,36 +46,42 @@ type StockTransferItem struct {
 
 // StockTransferResponse represents a stock transfer with details for API response
 type StockTransferResponse struct {
-	ID             int64                       `json:"id"`
-	FromBranchID   *int64                      `json:"from_branch_id,omitempty"`
-	FromBranchName *string                     `json:"from_branch_name,omitempty"`
-	ToBranchID     int64                       `json:"to_branch_id"`
-	ToBranchName   string                      `json:"to_branch_name"`
-	Status         StockTransferStatus         `json:"status"`
-	SentByName     *string                     `json:"sent_by_name,omitempty"`
-	ReceivedByName *string                     `json:"received_by_name,omitempty"`
-	SentAt         *time.Time                  `json:"sent_at,omitempty"`
-	ReceivedAt     *time.Time                  `json:"received_at,omitempty"`
-	Note           *string                     `json:"note,omitempty"`
-	Items          []StockTransferItemResponse `json:"items"`
-	CreatedAt      time.Time                   `json:"created_at"`
+	ID                int64                       `json:"id"`
+	FromBranchID      *int64                      `json:"from_branch_id,omitempty"`
+	FromBranchName    *string                     `json:"from_branch_name,omitempty"`
+	FromWarehouseID   *int64                      `json:"from_warehouse_id,omitempty"`
+	FromWarehouseName *string                     `json:"from_warehouse_name,omitempty"`
+	ToBranchID        int64                       `json:"to_branch_id"`
+	ToBranchName      string                      `json:"to_branch_name"`
+	Status            StockTransferStatus         `json:"status"`
+	SentByName        *string                     `json:"sent_by_name,omitempty"`
+	ReceivedByName    *string                     `json:"received_by_name,omitempty"`
+	SentAt            *time.Time                  `json:"sent_at,omitempty"`
+	ReceivedAt        *time.Time                  `json:"received_at,omitempty"`
+	Note              *string                     `json:"note,omitempty"`
+	Items             []StockTransferItemResponse `json:"items"`
+	CreatedAt         time.Time                   `json:"created_at"`
 }
 
 // StockTransferItemResponse represents an item with product details
 type StockTransferItemResponse struct {
-	ID           int64  `json:"id"`
-	ProductID    int64  `json:"product_id"`
-	ProductName  string `json:"product_name"`
-	SendCount    int    `json:"send_count"`
-	ReceiveCount int    `json:"receive_count"`
+	ID             int64  `json:"id"`
+	ProductID      int64  `json:"product_id"`
+	ProductName    string `json:"product_name"`
+	RequestedCount int    `json:"requested_count"`
+	PickedCount    int    `json:"picked_count"`
+	SendCount      int    `json:"send_count"`
+	ReceiveCount   int    `json:"receive_count"`
 }
 
 // CreateStockTransferRequest represents a request to create a stock transfer
+// Set either FromBranchID or FromWarehouseID as the source
 type CreateStockTransferRequest struct {
-	FromBranchID *int64                         `json:"from_branch_id,omitempty"`
-	ToBranchID   int64                          `json:"to_branch_id" binding:"required"`
-	Note         *string                        `json:"note,omitempty"`
-	Items        []CreateStockTransferItemInput `json:"items" binding:"required,min=1"`
+	FromBranchID    *int64                         `json:"from_branch_id,omitempty"`
+	FromWarehouseID *int64                         `json:"from_warehouse_id,omitempty"`
+	ToBranchID      int64                          `json:"to_branch_id" binding:"required"`
+	Note            *string                        `json:"note,omitempty"`
+	Items           []CreateStockTransferItemInput `json:"items" binding:"required,min=1"`
 }
 
 // CreateStockTransferItemInput represents an item input for creating a transfer
@@ -79,22 +90,41 @@ type CreateStockTransferItemInput struct {
 	SendCount int   `json:"send_count" binding:"required,min=1"`
 }
 
-// UpdateStockTransferRequest represents a request to update transfer status
+// UpdateStockTransferRequest represents a receipt against a transfer
+// Items carry the quantities received in this receipt. The transfer closes when every
+// item is fully received, or when Close is set; shortfalls then become discrepancies.
 type UpdateStockTransferRequest struct {
 	Status StockTransferStatus            `json:"status,omitempty"`
-	Items  []UpdateStockTransferItemInput `json:"items,omitempty"`
+	Items  []UpdateStockTransferItemInput `json:"items,omitempty" binding:"omitempty,dive"`
+	Note   *string                        `json:"note,omitempty"`
+	Close  bool                           `json:"close"`
 }
 
 // UpdateStockTransferItemInput represents an item input for receiving
 type UpdateStockTransferItemInput struct {
 	ProductID    int64 `json:"product_id" binding:"required"`
-	ReceiveCount int   `json:"receive_count" binding:"required,min=0"`
+	ReceiveCount int   `json:"receive_count" binding:"min=0"`
+}
+
+// DispatchStockTransferRequest represents a request from the sending branch to dispatch a transfer
+// Items is optional; items not listed are sent with their requested count
+type DispatchStockTransferRequest struct {
+	Items []DispatchStockTransferItemInput `json:"items,omitempty" binding:"omitempty,dive"`
+	Note  *string                          `json:"note,omitempty"`
+}
+
+// DispatchStockTransferItemInput represents the actual quantity sent for an item
+type DispatchStockTransferItemInput struct {
+	ProductID int64 `json:"product_id" binding:"required"`
+	SendCount int   `json:"send_count" binding:"min=0"`
 }
 
 // WithdrawGoodsRequest represents a request to withdraw goods (simplified transfer)
+// WarehouseID is optional; the store's default warehouse is used when omitted
 type WithdrawGoodsRequest struct {
-	Items []WithdrawItemInput `json:"items" binding:"required,min=1"`
-	Note  *string             `json:"note,omitempty"`
+	WarehouseID *int64              `json:"warehouse_id,omitempty"`
+	Items       []WithdrawItemInput `json:"items" binding:"required,min=1"`
+	Note        *string             `json:"note,omitempty"`
 }
 
 // WithdrawItemInput represents an item to withdraw
@@ -103,6 +133,70 @@ type WithdrawItemInput struct {
 	Quantity  int   `json:"quantity" binding:"required,min=1"`
 }
 
+// StockTransferReceiptResponse represents one receipt recorded against a transfer
+type StockTransferReceiptResponse struct {
+	ID             int64                              `json:"id"`
+	ReceivedByName *string                            `json:"received_by_name,omitempty"`
+	Note           *string                            `json:"note,omitempty"`
+	IsFinal        bool                               `json:"is_final"`
+	Items          []StockTransferReceiptItemResponse `json:"items"`
+	CreatedAt      time.Time                          `json:"created_at"`
+}
+
+// StockTransferReceiptItemResponse represents an item received in a receipt
+type StockTransferReceiptItemResponse struct {
+	ProductID    int64  `json:"product_id"`
+	ProductName  string `json:"product_name"`
+	ReceiveCount int    `json:"receive_count"`
+}
+
+// DiscrepancyStatus represents the status of a transfer discrepancy
+type DiscrepancyStatus string
+
+const (
+	DiscrepancyStatusOpen     DiscrepancyStatus = "OPEN"
+	DiscrepancyStatusResolved DiscrepancyStatus = "RESOLVED"
+)
+
+// DiscrepancyResolution represents how missing transfer units were accounted for
+type DiscrepancyResolution string
+
+const (
+	DiscrepancyResolutionLostInTransit DiscrepancyResolution = "LOST_IN_TRANSIT"
+	DiscrepancyResolutionDamaged       DiscrepancyResolution = "DAMAGED"
+	DiscrepancyResolutionResent        DiscrepancyResolution = "RESENT"
+)
+
+// StockTransferDiscrepancy represents units sent but never received on a closed transfer
+type StockTransferDiscrepancy struct {
+	ID                int64                  `json:"id"`
+	StockTransferID   int64                  `json:"stock_transfer_id"`
+	FromBranchID      *int64                 `json:"from_branch_id,omitempty"`
+	FromBranchName    *string                `json:"from_branch_name,omitempty"`
+	FromWarehouseID   *int64                 `json:"from_warehouse_id,omitempty"`
+	FromWarehouseName *string                `json:"from_warehouse_name,omitempty"`
+	ToBranchID        int64                  `json:"to_branch_id"`
+	ToBranchName      string                 `json:"to_branch_name"`
+	ProductID         int64                  `json:"product_id"`
+	ProductName       string                 `json:"product_name"`
+	SendCount         int                    `json:"send_count"`
+	ReceiveCount      int                    `json:"receive_count"`
+	MissingCount      int                    `json:"missing_count"`
+	Status            DiscrepancyStatus      `json:"status"`
+	Resolution        *DiscrepancyResolution `json:"resolution,omitempty"`
+	ResolutionNote    *string                `json:"resolution_note,omitempty"`
+	ResolvedByName    *string                `json:"resolved_by_name,omitempty"`
+	ResolvedAt        *time.Time             `json:"resolved_at,omitempty"`
+	ResentTransferID  *int64                 `json:"resent_transfer_id,omitempty"`
+	CreatedAt         time.Time              `json:"created_at"`
+}
+
+// ResolveDiscrepancyRequest represents a request to resolve a transfer discrepancy
+type ResolveDiscrepancyRequest struct {
+	Resolution DiscrepancyResolution `json:"resolution" binding:"required,oneof=LOST_IN_TRANSIT DAMAGED RESENT"`
+	Note       *string               `json:"note,omitempty"`
+}
+
 // StockTransferListResponse represents paginated list of transfers
 type StockTransferListResponse struct {
 	Transfers []StockTransferResponse `json:"transfers"`
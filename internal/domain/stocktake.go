@@ -0,0 +1,117 @@
+package domain
+
+import "time"
+
+// StocktakeType represents the scope of a stocktake
+type StocktakeType string
+
+const (
+	StocktakeTypeFull  StocktakeType = "FULL"
+	StocktakeTypeCycle StocktakeType = "CYCLE"
+)
+
+// StocktakeStatus represents the status of a stocktake
+type StocktakeStatus string
+
+const (
+	StocktakeStatusCounting  StocktakeStatus = "COUNTING"
+	StocktakeStatusPosted    StocktakeStatus = "POSTED"
+	StocktakeStatusCancelled StocktakeStatus = "CANCELLED"
+)
+
+// StocktakeItemStatus represents where an item is in the count
+type StocktakeItemStatus string
+
+const (
+	StocktakeItemStatusPending StocktakeItemStatus = "PENDING"
+	StocktakeItemStatusCounted StocktakeItemStatus = "COUNTED"
+	StocktakeItemStatusRecount StocktakeItemStatus = "RECOUNT"
+)
+
+// CreateStocktakeRequest represents a request to start a stocktake at the current branch
+// A CYCLE count covers only the given categories. Items whose variance reaches
+// RecountThreshold units are flagged for a recount.
+type CreateStocktakeRequest struct {
+	CountType        StocktakeType `json:"count_type" binding:"required,oneof=FULL CYCLE"`
+	CategoryIDs      []int64       `json:"category_ids,omitempty"`
+	RecountThreshold *int          `json:"recount_threshold,omitempty" binding:"omitempty,min=1"`
+	Note             *string       `json:"note,omitempty"`
+}
+
+// SubmitStocktakeCountsRequest represents one counter's quantities
+// Submitting the same product again in the same round replaces that counter's entry.
+type SubmitStocktakeCountsRequest struct {
+	Items []StocktakeCountInput `json:"items" binding:"required,min=1,dive"`
+}
+
+// StocktakeCountInput represents a counted quantity for a product
+type StocktakeCountInput struct {
+	ProductID  int64 `json:"product_id" binding:"required"`
+	CountedQty *int  `json:"counted_qty" binding:"required,min=0"`
+}
+
+// RequestRecountRequest represents a request to count items again
+// Without product IDs every item at or over the stocktake's recount threshold is recounted.
+type RequestRecountRequest struct {
+	ProductIDs []int64 `json:"product_ids,omitempty"`
+}
+
+// PostStocktakeRequest represents a request to post a stocktake
+// ZeroUncounted posts items nobody counted as zero instead of rejecting the post.
+type PostStocktakeRequest struct {
+	ZeroUncounted bool `json:"zero_uncounted"`
+}
+
+// StocktakeResponse represents a stocktake with its count progress
+type StocktakeResponse struct {
+	ID               int64                   `json:"id"`
+	BranchID         int64                   `json:"branch_id"`
+	BranchName       string                  `json:"branch_name"`
+	CountType        StocktakeType           `json:"count_type"`
+	Status           StocktakeStatus         `json:"status"`
+	CategoryIDs      []int64                 `json:"category_ids,omitempty"`
+	RecountThreshold *int                    `json:"recount_threshold,omitempty"`
+	Note             *string                 `json:"note,omitempty"`
+	CreatedByName    *string                 `json:"created_by_name,omitempty"`
+	PostedByName     *string                 `json:"posted_by_name,omitempty"`
+	PostedAt         *time.Time              `json:"posted_at,omitempty"`
+	ItemCount        int                     `json:"item_count"`
+	CountedCount     int                     `json:"counted_count"`
+	RecountCount     int                     `json:"recount_count"`
+	NetVariance      int                     `json:"net_variance"`
+	VarianceValue    float64                 `json:"variance_value"`
+	Items            []StocktakeItemResponse `json:"items,omitempty"`
+	CreatedAt        time.Time               `json:"created_at"`
+}
+
+// StocktakeItemResponse represents a product on a stocktake
+// Variance is counted minus snapshot; OverThreshold marks a variance at or over
+// the stocktake's recount threshold.
+type StocktakeItemResponse struct {
+	ProductID     int64                 `json:"product_id"`
+	ProductName   string                `json:"product_name"`
+	CategoryName  *string               `json:"category_name,omitempty"`
+	SnapshotQty   int                   `json:"snapshot_qty"`
+	CountedQty    *int                  `json:"counted_qty,omitempty"`
+	Variance      *int                  `json:"variance,omitempty"`
+	VarianceValue float64               `json:"variance_value"`
+	OverThreshold bool                  `json:"over_threshold"`
+	Round         int                   `json:"round"`
+	Status        StocktakeItemStatus   `json:"status"`
+	AdjustedQty   *int                  `json:"adjusted_qty,omitempty"`
+	Counts        []StocktakeCountEntry `json:"counts,omitempty"`
+}
+
+// StocktakeCountEntry represents one counter's entry for an item
+type StocktakeCountEntry struct {
+	Round         int       `json:"round"`
+	CountedQty    int       `json:"counted_qty"`
+	CountedByName *string   `json:"counted_by_name,omitempty"`
+	CountedAt     time.Time `json:"counted_at"`
+}
+
+// StocktakeListResponse represents a paginated list of stocktakes
+type StocktakeListResponse struct {
+	Stocktakes []StocktakeResponse `json:"stocktakes"`
+	Total      int                 `json:"total"`
+}
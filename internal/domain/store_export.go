@@ -0,0 +1,73 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StoreExportVersion is the archive layout a store export writes and a restore reads.
+// Version 1 CSV files wrote NULL as an empty cell, so only its JSON-lines files
+// can be restored.
+const StoreExportVersion = 2
+
+// StoreExportCSVNull is how a CSV file in a store export writes NULL, as
+// PostgreSQL's COPY text format does. A value starting with a backslash is
+// written with one more in front, so the text \N is written \\N.
+const StoreExportCSVNull = `\N`
+
+// StoreExportFormat is how each table is written inside a store export zip
+type StoreExportFormat string
+
+const (
+	StoreExportCSV   StoreExportFormat = "csv"
+	StoreExportJSONL StoreExportFormat = "jsonl"
+)
+
+// ParseStoreExportFormat returns the format for a query or flag value, defaulting to CSV
+func ParseStoreExportFormat(s string) (StoreExportFormat, error) {
+	switch strings.ToLower(s) {
+	case "", "csv":
+		return StoreExportCSV, nil
+	case "jsonl":
+		return StoreExportJSONL, nil
+	}
+	return "", fmt.Errorf("unsupported export format %q, expected csv or jsonl", s)
+}
+
+// StoreExportTable describes one table file in a store export
+type StoreExportTable struct {
+	Name    string   `json:"name"`
+	File    string   `json:"file"`
+	Columns []string `json:"columns"`
+	Rows    int      `json:"rows"`
+}
+
+// StoreExportManifest is the manifest.json of a store export. Tables are
+// listed parents first, the order a restore loads them in. Every value is
+// written as PostgreSQL text; in CSV files a cell of StoreExportCSVNull is NULL
+// and an empty cell is an empty string.
+type StoreExportManifest struct {
+	Version    int                `json:"version"`
+	StoreID    int64              `json:"store_id"`
+	StoreName  string             `json:"store_name"`
+	Format     StoreExportFormat  `json:"format"`
+	ExportedAt time.Time          `json:"exported_at"`
+	Tables     []StoreExportTable `json:"tables"`
+	Excluded   []string           `json:"excluded"`
+}
+
+// StoreRestoreTable is the number of rows restored into one table
+type StoreRestoreTable struct {
+	Name string `json:"name"`
+	Rows int    `json:"rows"`
+}
+
+// StoreRestoreResult reports restoring a store export. Nothing is committed on a dry run.
+type StoreRestoreResult struct {
+	StoreID   int64               `json:"store_id"`
+	StoreName string              `json:"store_name"`
+	DryRun    bool                `json:"dry_run"`
+	Tables    []StoreRestoreTable `json:"tables"`
+	TotalRows int                 `json:"total_rows"`
+}
@@ -0,0 +1,42 @@
+package domain
+
+import "time"
+
+// StoreSettings represents per-store configuration
+// A nil threshold disables that check; AdjustmentApprovalValue is compared with the
+// adjusted quantity at the location's moving-average cost
+type StoreSettings struct {
+	StoreID                 int64               `json:"store_id"`
+	AdjustmentApprovalQty   *int                `json:"adjustment_approval_qty,omitempty"`
+	AdjustmentApprovalValue *float64            `json:"adjustment_approval_value,omitempty"`
+	NegativeStockPolicy     NegativeStockPolicy `json:"negative_stock_policy"`
+	PointsExpiryMode        PointsExpiryMode    `json:"points_expiry_mode"`
+	PointsExpiryMonths      *int                `json:"points_expiry_months,omitempty"`
+	TierWindowMonths        *int                `json:"tier_window_months,omitempty"`
+	LoyaltyMode             LoyaltyMode         `json:"loyalty_mode"`
+	WalletBahtPerPoint      *float64            `json:"wallet_baht_per_point,omitempty"`
+	WalletPointValue        *float64            `json:"wallet_point_value,omitempty"`
+	UpdatedAt               time.Time           `json:"updated_at"`
+}
+
+// UpdateStoreSettingsRequest represents a request to update store settings
+type UpdateStoreSettingsRequest struct {
+	// AdjustmentApprovalQty and AdjustmentApprovalValue keep their current values when
+	// omitted; 0 removes the threshold
+	AdjustmentApprovalQty   *int     `json:"adjustment_approval_qty,omitempty" binding:"omitempty,min=0"`
+	AdjustmentApprovalValue *float64 `json:"adjustment_approval_value,omitempty" binding:"omitempty,min=0"`
+	// NegativeStockPolicy keeps its current value when omitted
+	NegativeStockPolicy NegativeStockPolicy `json:"negative_stock_policy,omitempty" binding:"omitempty,oneof=BLOCK ALLOW ALLOW_WITH_WARNING"`
+	// PointsExpiryMode and PointsExpiryMonths keep their current values when omitted;
+	// FIXED_PERIOD needs a number of months
+	PointsExpiryMode   PointsExpiryMode `json:"points_expiry_mode,omitempty" binding:"omitempty,oneof=NONE FIXED_PERIOD END_OF_YEAR"`
+	PointsExpiryMonths *int             `json:"points_expiry_months,omitempty" binding:"omitempty,min=1"`
+	// TierWindowMonths keeps its current value when omitted; 0 switches tiers back
+	// to lifetime points
+	TierWindowMonths *int `json:"tier_window_months,omitempty" binding:"omitempty,min=0"`
+	// LoyaltyMode and the wallet rates keep their current values when omitted;
+	// WALLET and BOTH need both rates
+	LoyaltyMode        LoyaltyMode `json:"loyalty_mode,omitempty" binding:"omitempty,oneof=STAMPS WALLET BOTH"`
+	WalletBahtPerPoint *float64    `json:"wallet_baht_per_point,omitempty" binding:"omitempty,gt=0"`
+	WalletPointValue   *float64    `json:"wallet_point_value,omitempty" binding:"omitempty,gt=0"`
+}
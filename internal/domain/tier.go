@@ -0,0 +1,77 @@
+package domain
+
+import "time"
+
+// MembershipTier represents a store-defined membership tier
+// Customers hold the active tier with the highest MinPoints they have reached.
+type MembershipTier struct {
+	ID             int64     `json:"id"`
+	TierCode       string    `json:"tier_code"`
+	TierName       string    `json:"tier_name"`
+	MinPoints      int       `json:"min_points"`
+	EarnMultiplier float64   `json:"earn_multiplier"`
+	IsActive       bool      `json:"is_active"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// MembershipTierRequest represents a request to create or replace a tier
+// EarnMultiplier defaults to 1; IsActive keeps its current value when omitted.
+type MembershipTierRequest struct {
+	TierCode       string   `json:"tier_code" binding:"required,max=30"`
+	TierName       string   `json:"tier_name" binding:"required"`
+	MinPoints      int      `json:"min_points" binding:"min=0"`
+	EarnMultiplier *float64 `json:"earn_multiplier,omitempty" binding:"omitempty,gt=0"`
+	IsActive       *bool    `json:"is_active,omitempty"`
+}
+
+// MembershipTierListResponse represents a store's tiers, lowest first
+// WindowMonths is nil when tiers qualify on lifetime points.
+type MembershipTierListResponse struct {
+	Tiers        []MembershipTier `json:"tiers"`
+	WindowMonths *int             `json:"window_months,omitempty"`
+}
+
+// CustomerTier is the tier shown alongside a customer
+type CustomerTier struct {
+	TierID         int64   `json:"tier_id"`
+	TierCode       string  `json:"tier_code"`
+	TierName       string  `json:"tier_name"`
+	EarnMultiplier float64 `json:"earn_multiplier"`
+}
+
+// TierChangeReason records what triggered a tier change
+type TierChangeReason string
+
+const (
+	TierChangeReasonOrder      TierChangeReason = "ORDER"
+	TierChangeReasonAdjust     TierChangeReason = "ADJUST"
+	TierChangeReasonEvaluation TierChangeReason = "EVALUATION"
+	TierChangeReasonMerge      TierChangeReason = "MERGE"
+)
+
+// TierChange represents a customer moving between tiers
+// A nil tier code means no tier.
+type TierChange struct {
+	ID               int64            `json:"id"`
+	CustomerID       int64            `json:"customer_id"`
+	FromTierCode     *string          `json:"from_tier_code,omitempty"`
+	ToTierCode       *string          `json:"to_tier_code,omitempty"`
+	QualifyingPoints int              `json:"qualifying_points"`
+	Reason           TierChangeReason `json:"reason"`
+	CreatedAt        time.Time        `json:"created_at"`
+}
+
+type TierHistoryResponse struct {
+	CustomerID int64         `json:"customer_id"`
+	Tier       *CustomerTier `json:"tier,omitempty"`
+	History    []TierChange  `json:"history"`
+	Total      int           `json:"total"`
+}
+
+// EvaluateTiersResult summarises a tier evaluation run
+type EvaluateTiersResult struct {
+	CustomersEvaluated int       `json:"customers_evaluated"`
+	TiersChanged       int       `json:"tiers_changed"`
+	RunAt              time.Time `json:"run_at"`
+}
@@ -0,0 +1,76 @@
+package domain
+
+import "time"
+
+// LoyaltyMode chooses which points a store's orders earn
+type LoyaltyMode string
+
+const (
+	LoyaltyModeStamps LoyaltyMode = "STAMPS"
+	LoyaltyModeWallet LoyaltyMode = "WALLET"
+	LoyaltyModeBoth   LoyaltyMode = "BOTH"
+)
+
+// EarnsStamps reports whether orders earn per-product points
+func (m LoyaltyMode) EarnsStamps() bool {
+	return m != LoyaltyModeWallet
+}
+
+// UsesWallet reports whether orders earn and can spend wallet points
+func (m LoyaltyMode) UsesWallet() bool {
+	return m == LoyaltyModeWallet || m == LoyaltyModeBoth
+}
+
+// WalletRedeemAs is how spent wallet points appear on an order
+type WalletRedeemAs string
+
+const (
+	WalletRedeemAsPayment  WalletRedeemAs = "PAYMENT"
+	WalletRedeemAsDiscount WalletRedeemAs = "DISCOUNT"
+)
+
+// OrderWalletRequest spends wallet points on an order
+// As PAYMENT the points become a WALLET payment worth Points x the store's point
+// value. As DISCOUNT that value must already be included in discount_total.
+type OrderWalletRequest struct {
+	Points   int            `json:"points" binding:"required,min=1"`
+	RedeemAs WalletRedeemAs `json:"redeem_as" binding:"required,oneof=PAYMENT DISCOUNT"`
+}
+
+// OrderWalletResult represents the wallet points an order spent and earned
+type OrderWalletResult struct {
+	PointsRedeemed int            `json:"points_redeemed"`
+	AmountRedeemed float64        `json:"amount_redeemed"`
+	RedeemedAs     WalletRedeemAs `json:"redeemed_as,omitempty"`
+	PointsEarned   int            `json:"points_earned"`
+	Balance        int            `json:"balance"`
+}
+
+// WalletTransaction represents one entry in a customer's wallet ledger
+type WalletTransaction struct {
+	ID              int64           `db:"id" json:"id"`
+	BranchID        *int64          `db:"branch_id" json:"branch_id,omitempty"`
+	TransactionType string          `db:"transaction_type" json:"transaction_type"`
+	PointsChange    int             `db:"points_change" json:"points_change"`
+	BalanceAfter    int             `db:"balance_after" json:"balance_after"`
+	Amount          *float64        `db:"amount" json:"amount,omitempty"`
+	RedeemedAs      *WalletRedeemAs `db:"redeemed_as" json:"redeemed_as,omitempty"`
+	ReferenceTable  *string         `db:"reference_table" json:"reference_table,omitempty"`
+	ReferenceID     *int64          `db:"reference_id" json:"reference_id,omitempty"`
+	Note            *string         `db:"note" json:"note,omitempty"`
+	CreatedAt       time.Time       `db:"created_at" json:"created_at"`
+}
+
+// WalletBalance represents a customer's wallet and what it is worth in baht
+type WalletBalance struct {
+	Balance        int     `db:"balance" json:"balance"`
+	LifetimeEarned int     `db:"lifetime_earned" json:"lifetime_earned"`
+	Value          float64 `db:"-" json:"value"`
+}
+
+type GetWalletResponse struct {
+	CustomerID int64               `json:"customer_id"`
+	Wallet     WalletBalance       `json:"wallet"`
+	History    []WalletTransaction `json:"history"`
+	Total      int                 `json:"total"`
+}
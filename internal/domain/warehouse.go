@@ -0,0 +1,93 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Warehouse represents a central stock location that supplies branches
+type Warehouse struct {
+	ID            int64     `json:"id"`
+	StoreID       int64     `json:"store_id"`
+	WarehouseName string    `json:"warehouse_name"`
+	IsDefault     bool      `json:"is_default"`
+	IsActive      bool      `json:"is_active"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// CreateWarehouseRequest represents a request to create a warehouse
+type CreateWarehouseRequest struct {
+	WarehouseName string `json:"warehouse_name" binding:"required"`
+	IsDefault     bool   `json:"is_default"`
+}
+
+// WarehouseStockItem represents a product's stock in a warehouse
+type WarehouseStockItem struct {
+	ProductID    int64  `json:"product_id"`
+	ProductName  string `json:"product_name"`
+	CategoryName string `json:"category_name"`
+	OnStock      int    `json:"on_stock"`
+	ReorderLevel int    `json:"reorder_level"`
+}
+
+// WarehouseStockResponse represents the API response for warehouse stock
+type WarehouseStockResponse struct {
+	WarehouseID int64                `json:"warehouse_id"`
+	Items       []WarehouseStockItem `json:"items"`
+	TotalCount  int                  `json:"total_count"`
+}
+
+// AdjustWarehouseStockRequest represents a request to receive or adjust warehouse stock
+// Quantity is signed: positive adds stock, negative removes it.
+// UnitCost applies to incoming stock and must not be negative; without it units come in
+// at the current average cost.
+// LotNumber and ExpiryDate (YYYY-MM-DD) apply to incoming stock and a lot is required
+// when receiving a product that tracks lots. Outgoing stock is taken first-expiry-first-out.
+// ADJUST, DAMAGE and ISSUE need a ReasonCode from the adjustment reason catalogue and
+// follow the same approval rules as branch adjustments; ManagerPin is required when the
+// adjustment reaches the store's approval threshold.
+type AdjustWarehouseStockRequest struct {
+	ProductID    int64            `json:"product_id" binding:"required"`
+	Quantity     int              `json:"quantity" binding:"required"`
+	UnitCost     *decimal.Decimal `json:"unit_cost,omitempty"`
+	LotNumber    *string          `json:"lot_number,omitempty"`
+	ExpiryDate   *string          `json:"expiry_date,omitempty" binding:"omitempty,datetime=2006-01-02"`
+	MovementType MovementType     `json:"movement_type" binding:"required,oneof=RECEIVE ADJUST DAMAGE ISSUE"`
+	ReasonCode   string           `json:"reason_code,omitempty"`
+	Reason       string           `json:"reason,omitempty"`
+	Note         string           `json:"note,omitempty"`
+	ManagerPin   *string          `json:"manager_pin,omitempty"`
+}
+
+// PickStockTransferRequest represents the quantities picked for a warehouse transfer
+type PickStockTransferRequest struct {
+	Items []PickStockTransferItemInput `json:"items" binding:"required,min=1,dive"`
+}
+
+// PickStockTransferItemInput represents the picked quantity for an item
+type PickStockTransferItemInput struct {
+	ProductID   int64 `json:"product_id" binding:"required"`
+	PickedCount int   `json:"picked_count" binding:"min=0"`
+}
+
+// ShortShippedItem represents a transfer item sent with less than was requested
+type ShortShippedItem struct {
+	TransferID     int64      `json:"transfer_id"`
+	ToBranchID     int64      `json:"to_branch_id"`
+	ToBranchName   string     `json:"to_branch_name"`
+	ProductID      int64      `json:"product_id"`
+	ProductName    string     `json:"product_name"`
+	RequestedCount int        `json:"requested_count"`
+	SendCount      int        `json:"send_count"`
+	ShortCount     int        `json:"short_count"`
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+}
+
+// ShortShippedResponse represents the short-shipped report for a warehouse
+type ShortShippedResponse struct {
+	WarehouseID int64              `json:"warehouse_id"`
+	Items       []ShortShippedItem `json:"items"`
+	TotalCount  int                `json:"total_count"`
+}
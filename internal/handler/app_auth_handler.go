@@ -128,6 +128,23 @@ func extractToken(c *gin.Context) string {
 	return parts[1]
 }
 
+// managerApproval returns the manager approving an action: the session's own staff when
+// they are a manager, otherwise the manager whose PIN was entered. It returns nil when
+// no PIN was given and an error when the PIN is not a manager's.
+func managerApproval(c *gin.Context, appAuthService service.AppAuthService, session *domain.AppSessionInfo, pin *string) (*int64, error) {
+	if session.IsManager {
+		return session.StaffID, nil
+	}
+	if pin == nil || *pin == "" {
+		return nil, nil
+	}
+	managerID, err := appAuthService.VerifyManagerPin(c.Request.Context(), session.StoreID, *pin)
+	if err != nil {
+		return nil, err
+	}
+	return &managerID, nil
+}
+
 // GenerateHash generates both SHA256 (for PIN) and bcrypt (for password) hashes
 func (h *AppAuthHandler) GenerateHash(c *gin.Context) {
 	var req struct {
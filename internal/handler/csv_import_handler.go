@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/service"
+)
+
+// maxImportFileSize caps an uploaded import CSV
+const maxImportFileSize = 5 << 20
+
+type CSVImportHandler struct {
+	importService  service.CSVImportService
+	appAuthService service.AppAuthService
+}
+
+func NewCSVImportHandler(importService service.CSVImportService, appAuthService service.AppAuthService) *CSVImportHandler {
+	return &CSVImportHandler{
+		importService:  importService,
+		appAuthService: appAuthService,
+	}
+}
+
+// Import loads a customers, products or stock CSV uploaded as the "file" form
+// field (manager only). The import is all-or-nothing; ?dry_run=true validates
+// and previews it without writing anything.
+func (h *CSVImportHandler) Import(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.StaffID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please verify staff PIN first"})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "CSV file is required"})
+		return
+	}
+	if fileHeader.Size > maxImportFileSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("CSV file is larger than %d MB", maxImportFileSize>>20)})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer file.Close()
+
+	result, err := h.importService.Import(c.Request.Context(), &domain.ImportOptions{
+		Kind:    domain.ImportKind(c.Param("kind")),
+		StoreID: sessionInfo.StoreID,
+		StaffID: sessionInfo.StaffID,
+		DryRun:  c.Query("dry_run") == "true",
+	}, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(result.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  fmt.Sprintf("%d rows have errors, nothing was imported", result.FailedRows()),
+			"result": result,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/service"
+)
+
+type CustomerHandler struct {
+	customerService service.CustomerService
+	appAuthService  service.AppAuthService
+}
+
+func NewCustomerHandler(customerService service.CustomerService, appAuthService service.AppAuthService) *CustomerHandler {
+	return &CustomerHandler{
+		customerService: customerService,
+		appAuthService:  appAuthService,
+	}
+}
+
+// FindDuplicates lists groups of customers sharing a phone number with similar
+// names; ?include_different_names=true also lists same-phone customers whose
+// names differ
+func (h *CustomerHandler) FindDuplicates(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	includeDifferentNames := c.Query("include_different_names") == "true"
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	result, err := h.customerService.FindDuplicates(c.Request.Context(), sessionInfo.StoreID, includeDifferentNames, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// MergeCustomers merges duplicate customers into a survivor (manager only)
+func (h *CustomerHandler) MergeCustomers(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.StaffID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please verify staff PIN first"})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	var req domain.MergeCustomersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.customerService.MergeCustomers(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.StaffID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetMerges lists customer merge audit records, optionally for one ?customer_id (manager only)
+func (h *CustomerHandler) GetMerges(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	var customerID *int64
+	if v := c.Query("customer_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer_id"})
+			return
+		}
+		customerID = &id
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	result, err := h.customerService.GetMerges(c.Request.Context(), sessionInfo.StoreID, customerID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
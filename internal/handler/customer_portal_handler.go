@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/service"
+)
+
+// CustomerPortalHandler serves the public self-service API customers use to
+// check their own points
+type CustomerPortalHandler struct {
+	portalService service.CustomerPortalService
+}
+
+func NewCustomerPortalHandler(portalService service.CustomerPortalService) *CustomerPortalHandler {
+	return &CustomerPortalHandler{portalService: portalService}
+}
+
+// RequestOTP sends a one-time password to a customer's phone
+func (h *CustomerPortalHandler) RequestOTP(c *gin.Context) {
+	storeID, err := strconv.ParseInt(c.Param("store_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store ID"})
+		return
+	}
+
+	var req domain.CustomerOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.portalService.RequestOTP(c.Request.Context(), storeID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrTooManyOTPRequests) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrInvalidPhone) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to request OTP"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// VerifyOTP exchanges a phone's OTP for a customer session token
+func (h *CustomerPortalHandler) VerifyOTP(c *gin.Context) {
+	storeID, err := strconv.ParseInt(c.Param("store_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid store ID"})
+		return
+	}
+
+	var req domain.CustomerOTPVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.portalService.VerifyOTP(c.Request.Context(), storeID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidOTP) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// customerSession validates the bearer token, writing the 401 itself when it
+// is missing or invalid
+func (h *CustomerPortalHandler) customerSession(c *gin.Context) (*domain.CustomerSessionInfo, bool) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return nil, false
+	}
+
+	session, err := h.portalService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return session, true
+}
+
+// GetPoints returns the signed-in customer's points per product
+func (h *CustomerPortalHandler) GetPoints(c *gin.Context) {
+	session, ok := h.customerSession(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.portalService.GetPoints(c.Request.Context(), session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetHistory returns the signed-in customer's point history
+func (h *CustomerPortalHandler) GetHistory(c *gin.Context) {
+	session, ok := h.customerSession(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	result, err := h.portalService.GetHistory(c.Request.Context(), session, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetRewards lists the rewards the signed-in customer can redeem points for
+func (h *CustomerPortalHandler) GetRewards(c *gin.Context) {
+	session, ok := h.customerSession(c)
+	if !ok {
+		return
+	}
+
+	result, err := h.portalService.GetRewards(c.Request.Context(), session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Logout ends the customer's session
+func (h *CustomerPortalHandler) Logout(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	if err := h.portalService.Logout(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out successfully"})
+}
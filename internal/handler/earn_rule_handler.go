@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/service"
+)
+
+type EarnRuleHandler struct {
+	earnRuleService service.EarnRuleService
+	appAuthService  service.AppAuthService
+}
+
+func NewEarnRuleHandler(earnRuleService service.EarnRuleService, appAuthService service.AppAuthService) *EarnRuleHandler {
+	return &EarnRuleHandler{
+		earnRuleService: earnRuleService,
+		appAuthService:  appAuthService,
+	}
+}
+
+// GetRules lists the store's earn rules
+func (h *EarnRuleHandler) GetRules(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules, err := h.earnRuleService.GetRules(c.Request.Context(), sessionInfo.StoreID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateRule adds an earn rule (manager only)
+func (h *EarnRuleHandler) CreateRule(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	var req domain.EarnRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.earnRuleService.CreateRule(c.Request.Context(), sessionInfo.StoreID, sessionInfo.StaffID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateRule replaces an earn rule (manager only)
+func (h *EarnRuleHandler) UpdateRule(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	ruleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule ID"})
+		return
+	}
+
+	var req domain.EarnRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.earnRuleService.UpdateRule(c.Request.Context(), sessionInfo.StoreID, ruleID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule removes an earn rule (manager only)
+func (h *EarnRuleHandler) DeleteRule(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	ruleID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rule ID"})
+		return
+	}
+
+	if err := h.earnRuleService.DeleteRule(c.Request.Context(), sessionInfo.StoreID, ruleID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "earn rule deleted"})
+}
+
+// PreviewEarn shows the points a cart would earn if checked out now
+func (h *EarnRuleHandler) PreviewEarn(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req domain.PreviewEarnRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := h.earnRuleService.PreviewEarn(c.Request.Context(), sessionInfo.StoreID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
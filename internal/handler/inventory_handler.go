@@ -1,24 +1,29 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mini-membership/api/internal/domain"
 	"github.com/mini-membership/api/internal/service"
+	"github.com/mini-membership/api/pkg/export"
 )
 
 type InventoryHandler struct {
-	inventoryService service.InventoryService
-	appAuthService   service.AppAuthService
+	inventoryService      service.InventoryService
+	reconciliationService service.StockReconciliationService
+	appAuthService        service.AppAuthService
 }
 
-func NewInventoryHandler(inventoryService service.InventoryService, appAuthService service.AppAuthService) *InventoryHandler {
+func NewInventoryHandler(inventoryService service.InventoryService, reconciliationService service.StockReconciliationService, appAuthService service.AppAuthService) *InventoryHandler {
 	return &InventoryHandler{
-		inventoryService: inventoryService,
-		appAuthService:   appAuthService,
+		inventoryService:      inventoryService,
+		reconciliationService: reconciliationService,
+		appAuthService:        appAuthService,
 	}
 }
 
@@ -47,7 +52,18 @@ func (h *InventoryHandler) AdjustStock(c *gin.Context) {
 		return
 	}
 
-	err = h.inventoryService.AdjustStock(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, *sessionInfo.StaffID, &req)
+	// A manager's own adjustments are self-approved; anyone else can have a manager enter their PIN
+	approvedBy, err := managerApproval(c, h.appAuthService, sessionInfo, req.ManagerPin)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.inventoryService.AdjustStock(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, *sessionInfo.StaffID, approvedBy, &req)
+	if errors.Is(err, service.ErrManagerApprovalRequired) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -56,7 +72,50 @@ func (h *InventoryHandler) AdjustStock(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "stock adjusted successfully"})
 }
 
-// GetMovements returns inventory movement history
+// parseMovementFilter reads the movement history filters from the query string:
+// product_id, movement_type, staff_id, from and to (YYYY-MM-DD, inclusive),
+// reference_table and reference_id
+func parseMovementFilter(c *gin.Context) (*domain.InventoryMovementFilter, error) {
+	filter := &domain.InventoryMovementFilter{
+		MovementType:   domain.MovementType(c.Query("movement_type")),
+		ReferenceTable: c.Query("reference_table"),
+	}
+
+	for param, dest := range map[string]**int64{
+		"product_id":   &filter.ProductID,
+		"staff_id":     &filter.StaffID,
+		"reference_id": &filter.ReferenceID,
+	} {
+		if v := c.Query(param); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s", param)
+			}
+			*dest = &id
+		}
+	}
+
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return nil, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+		filter.From = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return nil, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+		t = t.AddDate(0, 0, 1)
+		filter.To = &t
+	}
+
+	return filter, nil
+}
+
+// GetMovements returns inventory movement history, filtered by the query string
+// (see parseMovementFilter), with each product's running balance
 func (h *InventoryHandler) GetMovements(c *gin.Context) {
 	token := extractBearerToken(c)
 	if token == "" {
@@ -75,10 +134,16 @@ func (h *InventoryHandler) GetMovements(c *gin.Context) {
 		return
 	}
 
+	filter, err := parseMovementFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	movements, err := h.inventoryService.GetMovements(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, limit, offset)
+	movements, err := h.inventoryService.GetMovements(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, filter, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -87,6 +152,56 @@ func (h *InventoryHandler) GetMovements(c *gin.Context) {
 	c.JSON(http.StatusOK, movements)
 }
 
+// ExportMovements streams the filtered movement history as CSV or, with
+// ?format=xlsx, as a spreadsheet
+func (h *InventoryHandler) ExportMovements(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	filter, err := parseMovementFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format, err := export.ParseFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("movements-branch-%d-%s.%s", *sessionInfo.BranchID, time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	w, err := export.NewWriter(format, c.Writer)
+	if err == nil {
+		err = h.inventoryService.ExportMovements(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, filter, w)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		// Headers are already sent; the client sees a truncated file
+		_ = c.Error(err)
+	}
+}
+
 // GetLowStockItems returns products with low stock
 func (h *InventoryHandler) GetLowStockItems(c *gin.Context) {
 	token := extractBearerToken(c)
@@ -115,3 +230,555 @@ func (h *InventoryHandler) GetLowStockItems(c *gin.Context) {
 
 	c.JSON(http.StatusOK, response)
 }
+
+// GetAdjustmentReasons returns the store's adjustment reason catalogue
+func (h *InventoryHandler) GetAdjustmentReasons(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	activeOnly := c.DefaultQuery("include_inactive", "false") != "true"
+
+	reasons, err := h.inventoryService.GetAdjustmentReasons(c.Request.Context(), sessionInfo.StoreID, activeOnly)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reasons)
+}
+
+// CreateAdjustmentReason adds a reason code to the store's catalogue
+func (h *InventoryHandler) CreateAdjustmentReason(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	var req domain.CreateStockAdjustmentReasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reason, err := h.inventoryService.CreateAdjustmentReason(c.Request.Context(), sessionInfo.StoreID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, reason)
+}
+
+// UpdateAdjustmentReason updates one of the store's own reason codes
+func (h *InventoryHandler) UpdateAdjustmentReason(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	reasonID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid reason ID"})
+		return
+	}
+
+	var req domain.UpdateStockAdjustmentReasonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reason, err := h.inventoryService.UpdateAdjustmentReason(c.Request.Context(), sessionInfo.StoreID, reasonID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reason)
+}
+
+// UpdateReorderSettings sets a product's reorder level and lead time for the current branch
+func (h *InventoryHandler) UpdateReorderSettings(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	var req domain.UpdateReorderSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.inventoryService.UpdateReorderSettings(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "reorder settings updated successfully"})
+}
+
+// GetReplenishmentSuggestions returns suggested order quantities for the current branch
+func (h *InventoryHandler) GetReplenishmentSuggestions(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	salesWindowDays, _ := strconv.Atoi(c.DefaultQuery("sales_window_days", "0"))
+	coverDays, err := strconv.Atoi(c.DefaultQuery("cover_days", "-1"))
+	if err != nil {
+		coverDays = -1
+	}
+
+	response, err := h.inventoryService.GetReplenishmentSuggestions(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, salesWindowDays, coverDays)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateReplenishmentOrder turns replenishment suggestions into a warehouse withdrawal or a purchase order
+func (h *InventoryHandler) CreateReplenishmentOrder(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	if sessionInfo.StaffID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please verify staff PIN first"})
+		return
+	}
+
+	var req domain.CreateReplenishmentOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.inventoryService.CreateReplenishmentOrder(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, *sessionInfo.StaffID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// ReconcileStock compares on_stock with the movement ledger for the current branch,
+// or every branch with all_branches, and optionally books corrective movements
+func (h *InventoryHandler) ReconcileStock(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req domain.StockReconciliationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var branchID *int64
+	if !req.AllBranches {
+		if sessionInfo.BranchID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+			return
+		}
+		branchID = sessionInfo.BranchID
+	}
+
+	if req.Apply && !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	response, err := h.reconciliationService.Reconcile(c.Request.Context(), &sessionInfo.StoreID, branchID, req.Apply, sessionInfo.StaffID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateProductNegativeStockPolicy sets or clears a product's negative stock policy override
+func (h *InventoryHandler) UpdateProductNegativeStockPolicy(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	productID, err := strconv.ParseInt(c.Param("product_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req domain.UpdateProductNegativeStockPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.inventoryService.UpdateProductNegativeStockPolicy(c.Request.Context(), sessionInfo.StoreID, productID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "negative stock policy updated successfully"})
+}
+
+// GetOversells lists sales that took stock below zero for the current branch, or the
+// whole store with ?all=true. from and to are optional dates (YYYY-MM-DD, inclusive).
+func (h *InventoryHandler) GetOversells(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var branchID *int64
+	if c.Query("all") != "true" {
+		if sessionInfo.BranchID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+			return
+		}
+		branchID = sessionInfo.BranchID
+	}
+
+	var from, to *time.Time
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		from = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	response, err := h.inventoryService.GetOversells(c.Request.Context(), sessionInfo.StoreID, branchID, from, to, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetValuation returns the value of stock on hand by branch and category for the
+// current branch, or the whole store with ?all=true
+func (h *InventoryHandler) GetValuation(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var branchID *int64
+	if c.Query("all") != "true" {
+		if sessionInfo.BranchID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+			return
+		}
+		branchID = sessionInfo.BranchID
+	}
+
+	response, err := h.inventoryService.GetValuation(c.Request.Context(), sessionInfo.StoreID, branchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetLots lists lots with stock left at the current branch, or across every branch
+// and warehouse with ?all=true. product_id narrows the list to one product.
+func (h *InventoryHandler) GetLots(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var branchID *int64
+	if c.Query("all") != "true" {
+		if sessionInfo.BranchID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+			return
+		}
+		branchID = sessionInfo.BranchID
+	}
+
+	var productID *int64
+	if v := c.Query("product_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+			return
+		}
+		productID = &id
+	}
+
+	response, err := h.inventoryService.GetLots(c.Request.Context(), sessionInfo.StoreID, branchID, productID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetExpiringLots returns lots expiring within ?days= (default 7) at the current
+// branch, or across every branch and warehouse with ?all=true
+func (h *InventoryHandler) GetExpiringLots(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var branchID *int64
+	if c.Query("all") != "true" {
+		if sessionInfo.BranchID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+			return
+		}
+		branchID = sessionInfo.BranchID
+	}
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(domain.DefaultExpiryWarningDays)))
+
+	response, err := h.inventoryService.GetExpiringLots(c.Request.Context(), sessionInfo.StoreID, branchID, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// WriteOffLot writes off expired or damaged stock from a single lot
+func (h *InventoryHandler) WriteOffLot(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	if sessionInfo.StaffID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please verify staff PIN first"})
+		return
+	}
+
+	lotID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid lot ID"})
+		return
+	}
+
+	var req domain.WriteOffLotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Same approval rules as AdjustStock
+	approvedBy, err := managerApproval(c, h.appAuthService, sessionInfo, req.ManagerPin)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.inventoryService.WriteOffLot(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, *sessionInfo.StaffID, approvedBy, lotID, &req)
+	if errors.Is(err, service.ErrManagerApprovalRequired) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "lot written off successfully"})
+}
+
+// UpdateProductLotTracking turns lot tracking on or off for a product (manager only)
+func (h *InventoryHandler) UpdateProductLotTracking(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	productID, err := strconv.ParseInt(c.Param("product_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid product ID"})
+		return
+	}
+
+	var req domain.UpdateProductLotTrackingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = h.inventoryService.UpdateProductLotTracking(c.Request.Context(), sessionInfo.StoreID, productID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "lot tracking updated successfully"})
+}
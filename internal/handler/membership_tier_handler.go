@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/service"
+)
+
+type MembershipTierHandler struct {
+	tierService    service.MembershipTierService
+	appAuthService service.AppAuthService
+}
+
+func NewMembershipTierHandler(tierService service.MembershipTierService, appAuthService service.AppAuthService) *MembershipTierHandler {
+	return &MembershipTierHandler{
+		tierService:    tierService,
+		appAuthService: appAuthService,
+	}
+}
+
+// GetTiers lists the store's membership tiers, lowest first
+func (h *MembershipTierHandler) GetTiers(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	tiers, err := h.tierService.GetTiers(c.Request.Context(), sessionInfo.StoreID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tiers)
+}
+
+// CreateTier adds a membership tier (manager only)
+func (h *MembershipTierHandler) CreateTier(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	var req domain.MembershipTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tier, err := h.tierService.CreateTier(c.Request.Context(), sessionInfo.StoreID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tier)
+}
+
+// UpdateTier replaces a membership tier (manager only)
+func (h *MembershipTierHandler) UpdateTier(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	tierID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tier ID"})
+		return
+	}
+
+	var req domain.MembershipTierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tier, err := h.tierService.UpdateTier(c.Request.Context(), sessionInfo.StoreID, tierID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tier)
+}
+
+// DeleteTier removes a membership tier (manager only)
+func (h *MembershipTierHandler) DeleteTier(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	tierID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tier ID"})
+		return
+	}
+
+	if err := h.tierService.DeleteTier(c.Request.Context(), sessionInfo.StoreID, tierID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tier deleted"})
+}
+
+// GetTierHistory returns a customer's current tier and tier changes
+func (h *MembershipTierHandler) GetTierHistory(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	customerID, err := strconv.ParseInt(c.Param("customer_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer_id"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	history, err := h.tierService.GetTierHistory(c.Request.Context(), sessionInfo.StoreID, customerID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// EvaluateTiers re-evaluates every customer's tier now, e.g. after the tiers or
+// the evaluation window change (manager only)
+func (h *MembershipTierHandler) EvaluateTiers(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	result, err := h.tierService.EvaluateTiers(c.Request.Context(), &sessionInfo.StoreID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mini-membership/api/internal/domain"
@@ -13,12 +14,14 @@ import (
 type PointsHandler struct {
 	pointsService  service.PointsService
 	appAuthService service.AppAuthService
+	shiftService   service.ShiftService
 }
 
-func NewPointsHandler(pointsService service.PointsService, appAuthService service.AppAuthService) *PointsHandler {
+func NewPointsHandler(pointsService service.PointsService, appAuthService service.AppAuthService, shiftService service.ShiftService) *PointsHandler {
 	return &PointsHandler{
 		pointsService:  pointsService,
 		appAuthService: appAuthService,
+		shiftService:   shiftService,
 	}
 }
 
@@ -106,7 +109,18 @@ func (h *PointsHandler) RedeemPoints(c *gin.Context) {
 		return
 	}
 
-	result, err := h.pointsService.RedeemPoints(c.Request.Context(), session.StoreID, *session.BranchID, &req, session.StaffID)
+	// Redemptions are recorded against the current shift
+	currentShift, err := h.shiftService.GetCurrentShift(c.Request.Context(), session.StoreID, *session.BranchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !currentShift.HasActiveShift || currentShift.Shift == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no active shift, please open a shift first"})
+		return
+	}
+
+	result, err := h.pointsService.RedeemPoints(c.Request.Context(), session.StoreID, *session.BranchID, currentShift.Shift.ID, &req, session.StaffID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -145,3 +159,234 @@ func (h *PointsHandler) GetPointHistory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, result)
 }
+
+// GetWallet returns the customer's store-wide wallet balance and its ledger
+func (h *PointsHandler) GetWallet(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	session, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	customerID, err := strconv.ParseInt(c.Param("customer_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer_id"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	result, err := h.pointsService.GetWallet(c.Request.Context(), session.StoreID, customerID, page, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetExpiringPoints lists customers' points expiring within ?days (default 30),
+// optionally for one ?customer_id
+func (h *PointsHandler) GetExpiringPoints(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	session, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	var customerID *int64
+	if v := c.Query("customer_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer_id"})
+			return
+		}
+		customerID = &id
+	}
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(domain.DefaultExpiringPointsDays)))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	result, err := h.pointsService.GetExpiringPoints(c.Request.Context(), session.StoreID, customerID, days, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ReverseRedemption undoes a mistaken redemption. A manager's own reversal is
+// self-approved; anyone else needs a manager to enter their PIN.
+func (h *PointsHandler) ReverseRedemption(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	session, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	if session.StaffID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please verify staff PIN first"})
+		return
+	}
+
+	redemptionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid redemption ID"})
+		return
+	}
+
+	var req domain.ReverseRedemptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	approvedBy, err := managerApproval(c, h.appAuthService, session, req.ManagerPin)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if approvedBy == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager approval required to reverse a redemption"})
+		return
+	}
+
+	result, err := h.pointsService.ReverseRedemption(c.Request.Context(), session.StoreID, redemptionID, *session.StaffID, *approvedBy, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// AdjustPoints grants or removes a customer's points for a product by hand (manager only)
+func (h *PointsHandler) AdjustPoints(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	session, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	if session.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	if session.StaffID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please verify staff PIN first"})
+		return
+	}
+
+	if !session.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	var req domain.AdjustPointsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.pointsService.AdjustPoints(c.Request.Context(), session.StoreID, *session.BranchID, *session.StaffID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetAdjustments reports manual point adjustments with totals per branch and staff
+// member (manager only). Optional filters: branch_id, staff_id, from and to
+// (YYYY-MM-DD, inclusive).
+func (h *PointsHandler) GetAdjustments(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	session, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+		return
+	}
+
+	if !session.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	filter := &domain.PointAdjustmentFilter{}
+	for param, dest := range map[string]**int64{
+		"branch_id": &filter.BranchID,
+		"staff_id":  &filter.StaffID,
+	} {
+		if v := c.Query(param); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param})
+				return
+			}
+			*dest = &id
+		}
+	}
+
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		filter.From = &t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		t = t.AddDate(0, 0, 1)
+		filter.To = &t
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	result, err := h.pointsService.GetAdjustments(c.Request.Context(), session.StoreID, filter, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mini-membership/api/internal/domain"
@@ -20,7 +21,8 @@ func NewPromotionHandler(promotionService service.PromotionService, appAuthServi
 	}
 }
 
-// GetActivePromotions returns all active promotions for the current branch
+// GetActivePromotions returns all active promotions for the current branch,
+// including tier-only promotions open to the optional ?customer_id
 func (h *PromotionHandler) GetActivePromotions(c *gin.Context) {
 	token := extractBearerToken(c)
 	if token == "" {
@@ -39,7 +41,17 @@ func (h *PromotionHandler) GetActivePromotions(c *gin.Context) {
 		return
 	}
 
-	promotions, err := h.promotionService.GetActivePromotions(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID)
+	var customerID *int64
+	if v := c.Query("customer_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid customer_id"})
+			return
+		}
+		customerID = &id
+	}
+
+	promotions, err := h.promotionService.GetActivePromotions(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, customerID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
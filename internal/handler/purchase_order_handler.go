@@ -0,0 +1,350 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/service"
+)
+
+type PurchaseOrderHandler struct {
+	purchaseOrderService service.PurchaseOrderService
+	appAuthService       service.AppAuthService
+}
+
+func NewPurchaseOrderHandler(purchaseOrderService service.PurchaseOrderService, appAuthService service.AppAuthService) *PurchaseOrderHandler {
+	return &PurchaseOrderHandler{
+		purchaseOrderService: purchaseOrderService,
+		appAuthService:       appAuthService,
+	}
+}
+
+// CreateSupplier creates a new supplier
+func (h *PurchaseOrderHandler) CreateSupplier(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req domain.CreateSupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	supplier, err := h.purchaseOrderService.CreateSupplier(c.Request.Context(), sessionInfo.StoreID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, supplier)
+}
+
+// UpdateSupplier updates a supplier
+func (h *PurchaseOrderHandler) UpdateSupplier(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	supplierID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid supplier ID"})
+		return
+	}
+
+	var req domain.UpdateSupplierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	supplier, err := h.purchaseOrderService.UpdateSupplier(c.Request.Context(), sessionInfo.StoreID, supplierID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, supplier)
+}
+
+// GetSuppliers lists the store's suppliers
+func (h *PurchaseOrderHandler) GetSuppliers(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	suppliers, err := h.purchaseOrderService.GetSuppliers(c.Request.Context(), sessionInfo.StoreID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, suppliers)
+}
+
+// CreatePurchaseOrder creates a purchase order for the current branch
+func (h *PurchaseOrderHandler) CreatePurchaseOrder(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	if sessionInfo.StaffID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please verify staff PIN first"})
+		return
+	}
+
+	var req domain.CreatePurchaseOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	po, err := h.purchaseOrderService.CreatePurchaseOrder(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, *sessionInfo.StaffID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, po)
+}
+
+// GetPurchaseOrders lists purchase orders for the current branch, or the whole store with ?all=true
+func (h *PurchaseOrderHandler) GetPurchaseOrders(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var branchID *int64
+	if c.Query("all") != "true" {
+		if sessionInfo.BranchID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+			return
+		}
+		branchID = sessionInfo.BranchID
+	}
+
+	status := domain.PurchaseOrderStatus(c.Query("status"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	purchaseOrders, err := h.purchaseOrderService.GetPurchaseOrders(c.Request.Context(), sessionInfo.StoreID, branchID, status, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, purchaseOrders)
+}
+
+// GetPurchaseOrder gets a purchase order by ID
+func (h *PurchaseOrderHandler) GetPurchaseOrder(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchaseOrderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid purchase order ID"})
+		return
+	}
+
+	po, err := h.purchaseOrderService.GetPurchaseOrder(c.Request.Context(), sessionInfo.StoreID, purchaseOrderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if po == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "purchase order not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, po)
+}
+
+// ReceiveGoods records a goods received note against a purchase order
+func (h *PurchaseOrderHandler) ReceiveGoods(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	if sessionInfo.StaffID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please verify staff PIN first"})
+		return
+	}
+
+	purchaseOrderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid purchase order ID"})
+		return
+	}
+
+	var req domain.ReceiveGoodsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	po, err := h.purchaseOrderService.ReceiveGoods(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, purchaseOrderID, *sessionInfo.StaffID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, po)
+}
+
+// GetGoodsReceivedNotes lists deliveries recorded against a purchase order
+func (h *PurchaseOrderHandler) GetGoodsReceivedNotes(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchaseOrderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid purchase order ID"})
+		return
+	}
+
+	notes, err := h.purchaseOrderService.GetGoodsReceivedNotes(c.Request.Context(), sessionInfo.StoreID, purchaseOrderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, notes)
+}
+
+// ClosePurchaseOrder closes a purchase order without waiting for outstanding quantities
+func (h *PurchaseOrderHandler) ClosePurchaseOrder(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchaseOrderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid purchase order ID"})
+		return
+	}
+
+	err = h.purchaseOrderService.ClosePurchaseOrder(c.Request.Context(), sessionInfo.StoreID, purchaseOrderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "purchase order closed successfully"})
+}
+
+// CancelPurchaseOrder cancels an open purchase order
+func (h *PurchaseOrderHandler) CancelPurchaseOrder(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	purchaseOrderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid purchase order ID"})
+		return
+	}
+
+	err = h.purchaseOrderService.CancelPurchaseOrder(c.Request.Context(), sessionInfo.StoreID, purchaseOrderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "purchase order cancelled successfully"})
+}
@@ -159,7 +159,7 @@ func (h *StockTransferHandler) GetTransfers(c *gin.Context) {
 	c.JSON(http.StatusOK, transfers)
 }
 
-// ReceiveTransfer marks a transfer as received and adds stock
+// ReceiveTransfer records a (possibly partial) receipt and adds stock
 func (h *StockTransferHandler) ReceiveTransfer(c *gin.Context) {
 	token := extractBearerToken(c)
 	if token == "" {
@@ -192,14 +192,14 @@ func (h *StockTransferHandler) ReceiveTransfer(c *gin.Context) {
 		return
 	}
 
-	err = h.stockTransferService.ReceiveTransfer(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, transferID, *sessionInfo.StaffID, req.Items)
+	transfer, err := h.stockTransferService.ReceiveTransfer(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, transferID, *sessionInfo.StaffID, &req)
 	if err != nil {
 		fmt.Println("error :: ", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "transfer received successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "transfer received successfully", "transfer": transfer})
 }
 
 // GetPendingTransfers gets transfers waiting to be received
@@ -230,6 +230,74 @@ func (h *StockTransferHandler) GetPendingTransfers(c *gin.Context) {
 	c.JSON(http.StatusOK, transfers)
 }
 
+// GetOutgoingTransfers gets transfers waiting to be dispatched by the current branch
+func (h *StockTransferHandler) GetOutgoingTransfers(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	transfers, err := h.stockTransferService.GetOutgoingTransfers(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfers)
+}
+
+// DispatchTransfer confirms send counts and deducts stock from the sending branch
+func (h *StockTransferHandler) DispatchTransfer(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	transferID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transfer ID"})
+		return
+	}
+
+	var req domain.DispatchStockTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer, err := h.stockTransferService.DispatchTransfer(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, transferID, *sessionInfo.StaffID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, transfer)
+}
+
 // CancelTransfer cancels a stock transfer
 func (h *StockTransferHandler) CancelTransfer(c *gin.Context) {
 	token := extractBearerToken(c)
@@ -261,3 +329,102 @@ func (h *StockTransferHandler) CancelTransfer(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "transfer cancelled successfully"})
 }
+
+// GetReceipts lists the receipts recorded against a transfer
+func (h *StockTransferHandler) GetReceipts(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	transferID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transfer ID"})
+		return
+	}
+
+	receipts, err := h.stockTransferService.GetReceipts(c.Request.Context(), sessionInfo.StoreID, transferID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, receipts)
+}
+
+// GetDiscrepancies lists transfer discrepancies sent from or to the current branch
+func (h *StockTransferHandler) GetDiscrepancies(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	status := domain.DiscrepancyStatus(c.Query("status"))
+
+	discrepancies, err := h.stockTransferService.GetDiscrepancies(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, discrepancies)
+}
+
+// ResolveDiscrepancy resolves missing transfer units from the sending branch
+func (h *StockTransferHandler) ResolveDiscrepancy(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.BranchID == nil || sessionInfo.StaffID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please select a branch first"})
+		return
+	}
+
+	discrepancyID, err := strconv.ParseInt(c.Param("discrepancy_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid discrepancy ID"})
+		return
+	}
+
+	var req domain.ResolveDiscrepancyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	discrepancy, err := h.stockTransferService.ResolveDiscrepancy(c.Request.Context(), sessionInfo.StoreID, *sessionInfo.BranchID, discrepancyID, *sessionInfo.StaffID, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, discrepancy)
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/service"
+)
+
+type StoreExportHandler struct {
+	exportService  service.StoreExportService
+	appAuthService service.AppAuthService
+}
+
+func NewStoreExportHandler(exportService service.StoreExportService, appAuthService service.AppAuthService) *StoreExportHandler {
+	return &StoreExportHandler{
+		exportService:  exportService,
+		appAuthService: appAuthService,
+	}
+}
+
+// ExportStore downloads all of the store's data as a zip of ?format=csv (default)
+// or jsonl files with a manifest (store owner only)
+func (h *StoreExportHandler) ExportStore(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if sessionInfo.StaffID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "please verify staff PIN first"})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	format, err := domain.ParseStoreExportFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := fmt.Sprintf("store-%d-export-%s-%s.zip", sessionInfo.StoreID, format, time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	if _, err := h.exportService.Export(c.Request.Context(), sessionInfo.StoreID, format, c.Writer); err != nil {
+		// Headers are already sent; the client sees a truncated zip
+		_ = c.Error(err)
+	}
+}
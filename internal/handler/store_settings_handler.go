@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/service"
+)
+
+type StoreSettingsHandler struct {
+	storeSettingsService service.StoreSettingsService
+	appAuthService       service.AppAuthService
+}
+
+func NewStoreSettingsHandler(storeSettingsService service.StoreSettingsService, appAuthService service.AppAuthService) *StoreSettingsHandler {
+	return &StoreSettingsHandler{
+		storeSettingsService: storeSettingsService,
+		appAuthService:       appAuthService,
+	}
+}
+
+// GetSettings returns the current store's settings
+func (h *StoreSettingsHandler) GetSettings(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.storeSettingsService.GetSettings(c.Request.Context(), sessionInfo.StoreID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateSettings replaces the current store's settings
+func (h *StoreSettingsHandler) UpdateSettings(c *gin.Context) {
+	token := extractBearerToken(c)
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "session token required"})
+		return
+	}
+
+	sessionInfo, err := h.appAuthService.ValidateSession(c.Request.Context(), token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !sessionInfo.IsManager {
+		c.JSON(http.StatusForbidden, gin.H{"error": "manager permission required"})
+		return
+	}
+
+	var req domain.UpdateStoreSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.storeSettingsService.UpdateSettings(c.Request.Context(), sessionInfo.StoreID, &req)
+	if err != nil {
+		if errors.Is(err, service.ErrExpiryMonthsRequired) || errors.Is(err, service.ErrWalletRatesRequired) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
@@ -0,0 +1,396 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mini-membership/api/internal/domain"
+)
+
+// openingStockReasonCode marks the ADJUST movements an opening stock import writes
+const openingStockReasonCode = "OPENING_STOCK"
+
+// CSVImportRepository writes validated CSV rows. Each import runs in one
+// transaction that is committed only when it is not a dry run and no row failed.
+type CSVImportRepository interface {
+	ImportCustomersTx(ctx context.Context, storeID int64, rows []domain.CustomerImportRow, dryRun bool) (*domain.ImportResult, error)
+	ImportProductsTx(ctx context.Context, storeID int64, rows []domain.ProductImportRow, dryRun bool) (*domain.ImportResult, error)
+	ImportStockTx(ctx context.Context, storeID int64, rows []domain.StockImportRow, changedBy *int64, dryRun bool) (*domain.ImportResult, error)
+}
+
+type csvImportRepository struct {
+	db *sqlx.DB
+}
+
+func NewCSVImportRepository(db *sqlx.DB) CSVImportRepository {
+	return &csvImportRepository{db: db}
+}
+
+// finishImport commits the import unless it is a dry run or a row failed, in
+// which case the caller's deferred rollback discards everything
+func finishImport(tx *sql.Tx, result *domain.ImportResult, dryRun bool) error {
+	if dryRun || len(result.Errors) > 0 {
+		return nil
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	result.Committed = true
+	return nil
+}
+
+// ImportCustomersTx creates or updates customers by customer_code. Blank fields
+// keep the customer's current value; a merged customer cannot be imported into.
+func (r *csvImportRepository) ImportCustomersTx(ctx context.Context, storeID int64, rows []domain.CustomerImportRow, dryRun bool) (*domain.ImportResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &domain.ImportResult{}
+	for _, row := range rows {
+		var id int64
+		var mergedIntoID sql.NullInt64
+		err := tx.QueryRowContext(ctx, `
+			SELECT id, merged_into_id FROM customers
+			WHERE store_id = $1 AND customer_code = $2
+			FOR UPDATE
+		`, storeID, row.CustomerCode).Scan(&id, &mergedIntoID)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = tx.QueryRowContext(ctx, `
+				INSERT INTO customers (store_id, customer_code, full_name, phone, phone_last4, email, is_active)
+				VALUES ($1, $2, $3, $4, $5, $6, COALESCE($7, true))
+				RETURNING id
+			`, storeID, row.CustomerCode, row.FullName, row.Phone, row.PhoneLast4, row.Email, row.IsActive).Scan(&id)
+			if err != nil {
+				return nil, err
+			}
+			result.AddRow(domain.ImportRowResult{Row: row.Row, Key: row.CustomerCode, Action: domain.ImportRowCreated, ID: id})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if mergedIntoID.Valid {
+			result.AddError(row.Row, "customer_code", fmt.Sprintf("customer was merged into customer %d", mergedIntoID.Int64))
+			continue
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			UPDATE customers SET
+				full_name = COALESCE($1, full_name),
+				phone = COALESCE($2, phone),
+				phone_last4 = COALESCE($3, phone_last4),
+				email = COALESCE($4, email),
+				is_active = COALESCE($5, is_active),
+				updated_at = NOW()
+			WHERE id = $6 AND (
+				full_name IS DISTINCT FROM COALESCE($1, full_name)
+				OR phone IS DISTINCT FROM COALESCE($2, phone)
+				OR phone_last4 IS DISTINCT FROM COALESCE($3, phone_last4)
+				OR email IS DISTINCT FROM COALESCE($4, email)
+				OR is_active IS DISTINCT FROM COALESCE($5, is_active)
+			)
+		`, row.FullName, row.Phone, row.PhoneLast4, row.Email, row.IsActive, id)
+		if err != nil {
+			return nil, err
+		}
+		action := domain.ImportRowUnchanged
+		if n, _ := res.RowsAffected(); n > 0 {
+			action = domain.ImportRowUpdated
+		}
+		result.AddRow(domain.ImportRowResult{Row: row.Row, Key: row.CustomerCode, Action: action, ID: id})
+	}
+
+	if err := finishImport(tx, result, dryRun); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ImportProductsTx creates or updates products by SKU, creating any category
+// the rows name that the store does not have yet. Blank fields keep the
+// product's current value.
+func (r *csvImportRepository) ImportProductsTx(ctx context.Context, storeID int64, rows []domain.ProductImportRow, dryRun bool) (*domain.ImportResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &domain.ImportResult{}
+	categories := make(map[string]int64)
+	for _, row := range rows {
+		var categoryID *int64
+		if row.CategoryName != nil {
+			id, ok := categories[*row.CategoryName]
+			if !ok {
+				err := tx.QueryRowContext(ctx, `
+					SELECT id FROM categories WHERE store_id = $1 AND category_name = $2
+				`, storeID, *row.CategoryName).Scan(&id)
+				if errors.Is(err, sql.ErrNoRows) {
+					err = tx.QueryRowContext(ctx, `
+						INSERT INTO categories (store_id, category_name) VALUES ($1, $2) RETURNING id
+					`, storeID, *row.CategoryName).Scan(&id)
+					result.CategoriesCreated++
+				}
+				if err != nil {
+					return nil, err
+				}
+				categories[*row.CategoryName] = id
+			}
+			categoryID = &id
+		}
+
+		var id int64
+		err := tx.QueryRowContext(ctx, `
+			SELECT id FROM products WHERE store_id = $1 AND sku = $2 FOR UPDATE
+		`, storeID, row.SKU).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			if row.ProductName == nil {
+				result.AddError(row.Row, "product_name", "product_name is required for a new product")
+				continue
+			}
+			err = tx.QueryRowContext(ctx, `
+				INSERT INTO products (store_id, category_id, product_name, sku, barcode, base_price, points_to_redeem, is_active)
+				VALUES ($1, $2, $3, $4, $5, COALESCE($6, 0), $7, COALESCE($8, true))
+				RETURNING id
+			`, storeID, categoryID, *row.ProductName, row.SKU, row.Barcode, row.BasePrice, row.PointsToRedeem, row.IsActive).Scan(&id)
+			if err != nil {
+				return nil, err
+			}
+			result.AddRow(domain.ImportRowResult{Row: row.Row, Key: row.SKU, Action: domain.ImportRowCreated, ID: id})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := tx.ExecContext(ctx, `
+			UPDATE products SET
+				category_id = COALESCE($1, category_id),
+				product_name = COALESCE($2, product_name),
+				barcode = COALESCE($3, barcode),
+				base_price = COALESCE($4, base_price),
+				points_to_redeem = COALESCE($5, points_to_redeem),
+				is_active = COALESCE($6, is_active),
+				updated_at = NOW()
+			WHERE id = $7 AND (
+				category_id IS DISTINCT FROM COALESCE($1, category_id)
+				OR product_name IS DISTINCT FROM COALESCE($2, product_name)
+				OR barcode IS DISTINCT FROM COALESCE($3, barcode)
+				OR base_price IS DISTINCT FROM COALESCE($4, base_price)
+				OR points_to_redeem IS DISTINCT FROM COALESCE($5, points_to_redeem)
+				OR is_active IS DISTINCT FROM COALESCE($6, is_active)
+			)
+		`, categoryID, row.ProductName, row.Barcode, row.BasePrice, row.PointsToRedeem, row.IsActive, id)
+		if err != nil {
+			return nil, err
+		}
+		action := domain.ImportRowUnchanged
+		if n, _ := res.RowsAffected(); n > 0 {
+			action = domain.ImportRowUpdated
+		}
+		result.AddRow(domain.ImportRowResult{Row: row.Row, Key: row.SKU, Action: action, ID: id})
+	}
+
+	if err := finishImport(tx, result, dryRun); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importBranch is a branch an opening stock row names
+type importBranch struct {
+	ID   int64
+	Name string
+}
+
+// importProduct is a product an opening stock row names by SKU
+type importProduct struct {
+	ID        int64
+	TrackLots bool
+}
+
+// ImportStockTx sets each branch product's on_stock to the row's count. The
+// difference is booked as an OPENING_STOCK ADJUST movement: added stock goes
+// into the row's lot at its unit cost, removed stock leaves lots first-expiry-first-out.
+func (r *csvImportRepository) ImportStockTx(ctx context.Context, storeID int64, rows []domain.StockImportRow, changedBy *int64, dryRun bool) (*domain.ImportResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &domain.ImportResult{}
+	branches := make(map[string]*importBranch)
+	products := make(map[string]*importProduct)
+	seen := make(map[[2]int64]int)
+	reasonCode := openingStockReasonCode
+	reason := "Opening stock import"
+
+	for _, row := range rows {
+		branch, err := r.importBranch(ctx, tx, storeID, row, branches)
+		if err != nil {
+			return nil, err
+		}
+		if branch == nil {
+			if row.BranchID != nil {
+				result.AddError(row.Row, "branch_id", fmt.Sprintf("no branch with ID %d", *row.BranchID))
+			} else {
+				result.AddError(row.Row, "branch_name", fmt.Sprintf("no branch named %q", *row.BranchName))
+			}
+			continue
+		}
+
+		product, ok := products[row.SKU]
+		if !ok {
+			product = &importProduct{}
+			err := tx.QueryRowContext(ctx, `
+				SELECT id, track_lots FROM products WHERE store_id = $1 AND sku = $2
+			`, storeID, row.SKU).Scan(&product.ID, &product.TrackLots)
+			if errors.Is(err, sql.ErrNoRows) {
+				product = nil
+			} else if err != nil {
+				return nil, err
+			}
+			products[row.SKU] = product
+		}
+		if product == nil {
+			result.AddError(row.Row, "sku", fmt.Sprintf("no product with SKU %q", row.SKU))
+			continue
+		}
+
+		key := [2]int64{branch.ID, product.ID}
+		if first, ok := seen[key]; ok {
+			result.AddError(row.Row, "", fmt.Sprintf("duplicates row %d for the same branch and product", first))
+			continue
+		}
+		seen[key] = row.Row
+
+		expiry, err := parseLotExpiry(row.ExpiryDate)
+		if err != nil {
+			result.AddError(row.Row, "expiry_date", err.Error())
+			continue
+		}
+
+		exists := true
+		var onStock, reorderLevel int
+		err = tx.QueryRowContext(ctx, `
+			SELECT on_stock, reorder_level FROM branch_products
+			WHERE store_id = $1 AND branch_id = $2 AND product_id = $3
+			FOR UPDATE
+		`, storeID, branch.ID, product.ID).Scan(&onStock, &reorderLevel)
+		if errors.Is(err, sql.ErrNoRows) {
+			exists = false
+		} else if err != nil {
+			return nil, err
+		}
+
+		delta := row.OnStock - onStock
+		if delta > 0 && product.TrackLots && row.LotNumber == nil {
+			result.AddError(row.Row, "lot_number", "product tracks lots: lot_number is required when stock goes up")
+			continue
+		}
+
+		rowResult := domain.ImportRowResult{
+			Row:    row.Row,
+			Key:    branch.Name + " / " + row.SKU,
+			Action: domain.ImportRowUnchanged,
+			ID:     product.ID,
+		}
+		switch {
+		case !exists:
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO branch_products (store_id, branch_id, product_id, on_stock, reorder_level)
+				VALUES ($1, $2, $3, 0, COALESCE($4, 0))
+			`, storeID, branch.ID, product.ID, row.ReorderLevel)
+			rowResult.Action = domain.ImportRowCreated
+		case row.ReorderLevel != nil && *row.ReorderLevel != reorderLevel:
+			_, err = tx.ExecContext(ctx, `
+				UPDATE branch_products SET reorder_level = $1, updated_at = NOW()
+				WHERE store_id = $2 AND branch_id = $3 AND product_id = $4
+			`, *row.ReorderLevel, storeID, branch.ID, product.ID)
+			rowResult.Action = domain.ImportRowUpdated
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if delta != 0 {
+			_, err := moveBranchStock(ctx, tx, branchStockMove{
+				StoreID:        storeID,
+				BranchID:       branch.ID,
+				ProductID:      product.ID,
+				QuantityChange: delta,
+				UnitCost:       row.UnitCost,
+				MovementType:   domain.MovementTypeAdjust,
+				ReasonCode:     &reasonCode,
+				Reason:         &reason,
+				ChangedBy:      changedBy,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if delta > 0 && row.LotNumber != nil {
+				_, err = addToLot(ctx, tx, lotMove{
+					StoreID:      storeID,
+					BranchID:     &branch.ID,
+					ProductID:    product.ID,
+					LotNumber:    *row.LotNumber,
+					ExpiryDate:   expiry,
+					Quantity:     delta,
+					MovementType: domain.MovementTypeAdjust,
+					ChangedBy:    changedBy,
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+			if rowResult.Action == domain.ImportRowUnchanged {
+				rowResult.Action = domain.ImportRowUpdated
+			}
+		}
+		result.AddRow(rowResult)
+	}
+
+	if err := finishImport(tx, result, dryRun); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importBranch looks up the row's branch by ID or name, caching lookups in
+// branches. It returns nil when the store has no such branch.
+func (r *csvImportRepository) importBranch(ctx context.Context, tx *sql.Tx, storeID int64, row domain.StockImportRow, branches map[string]*importBranch) (*importBranch, error) {
+	var cacheKey string
+	var query string
+	var arg interface{}
+	if row.BranchID != nil {
+		cacheKey = "id:" + strconv.FormatInt(*row.BranchID, 10)
+		query = `SELECT id, branch_name FROM branches WHERE store_id = $1 AND id = $2`
+		arg = *row.BranchID
+	} else {
+		cacheKey = "name:" + *row.BranchName
+		query = `SELECT id, branch_name FROM branches WHERE store_id = $1 AND branch_name = $2`
+		arg = *row.BranchName
+	}
+	if branch, ok := branches[cacheKey]; ok {
+		return branch, nil
+	}
+
+	branch := &importBranch{}
+	err := tx.QueryRowContext(ctx, query, storeID, arg).Scan(&branch.ID, &branch.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		branch = nil
+	} else if err != nil {
+		return nil, err
+	}
+	branches[cacheKey] = branch
+	return branch, nil
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type CustomerPortalRepository interface {
+	FindCustomerByPhone(ctx context.Context, storeID int64, phoneKey string) (*PortalCustomer, error)
+	GetCustomer(ctx context.Context, storeID, customerID int64) (*PortalCustomer, error)
+	CreateOTPWithinLimit(ctx context.Context, otp *CustomerOTP, since time.Time, limit int) (bool, error)
+	GetLatestOTP(ctx context.Context, storeID int64, phoneKey string) (*CustomerOTP, error)
+	ConsumeOTPAttempt(ctx context.Context, otpID int64, maxAttempts int) (*string, error)
+	MarkOTPVerified(ctx context.Context, otpID int64) (bool, error)
+	CreateSession(ctx context.Context, storeID, customerID int64, token string, expiresAt time.Time) error
+	GetSession(ctx context.Context, token string) (*CustomerSession, error)
+	RevokeSession(ctx context.Context, token string) error
+}
+
+// PortalCustomer is an active customer as seen from the self-service portal
+type PortalCustomer struct {
+	ID           int64
+	CustomerCode sql.NullString
+	FullName     sql.NullString
+	Phone        string
+	StoreName    string
+}
+
+// CustomerOTP is a one-time password sent to a phone. CustomerID is nil when
+// the phone matched no customer; no SMS is sent for those.
+type CustomerOTP struct {
+	ID         int64
+	StoreID    int64
+	CustomerID *int64
+	PhoneKey   string
+	CodeHash   string
+	Attempts   int
+	ExpiresAt  time.Time
+	VerifiedAt *time.Time
+}
+
+type CustomerSession struct {
+	StoreID    int64
+	CustomerID int64
+	ExpiresAt  time.Time
+}
+
+type customerPortalRepository struct {
+	db *sqlx.DB
+}
+
+func NewCustomerPortalRepository(db *sqlx.DB) CustomerPortalRepository {
+	return &customerPortalRepository{db: db}
+}
+
+const portalCustomerSelect = `
+	SELECT c.id, c.customer_code, c.full_name, COALESCE(c.phone, ''), s.store_name
+	FROM customers c
+	JOIN stores s ON s.id = c.store_id AND s.is_active = true
+`
+
+func scanPortalCustomer(row *sql.Row) (*PortalCustomer, error) {
+	var c PortalCustomer
+	err := row.Scan(&c.ID, &c.CustomerCode, &c.FullName, &c.Phone, &c.StoreName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// FindCustomerByPhone returns the store's oldest active customer with the phone
+// key, nil when there is none
+func (r *customerPortalRepository) FindCustomerByPhone(ctx context.Context, storeID int64, phoneKey string) (*PortalCustomer, error) {
+	return scanPortalCustomer(r.db.QueryRowContext(ctx, portalCustomerSelect+`
+		WHERE c.store_id = $1 AND c.is_active = true AND c.phone IS NOT NULL
+			AND `+customerPhoneKeySQL+` = $2
+		ORDER BY c.created_at, c.id
+		LIMIT 1
+	`, storeID, phoneKey))
+}
+
+func (r *customerPortalRepository) GetCustomer(ctx context.Context, storeID, customerID int64) (*PortalCustomer, error) {
+	return scanPortalCustomer(r.db.QueryRowContext(ctx, portalCustomerSelect+`
+		WHERE c.store_id = $1 AND c.id = $2 AND c.is_active = true
+	`, storeID, customerID))
+}
+
+// CreateOTPWithinLimit stores the OTP unless the phone key already has limit OTPs
+// created since since, returning false in that case. Requests for the same phone
+// take a transaction-level advisory lock, so concurrent requests are counted one
+// after another and cannot overshoot the limit.
+func (r *customerPortalRepository) CreateOTPWithinLimit(ctx context.Context, otp *CustomerOTP, since time.Time, limit int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		SELECT pg_advisory_xact_lock(hashtext('customer_otps'), hashtext($1::text || ':' || $2))
+	`, otp.StoreID, otp.PhoneKey)
+	if err != nil {
+		return false, err
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM customer_otps
+		WHERE store_id = $1 AND phone_key = $2 AND created_at >= $3
+	`, otp.StoreID, otp.PhoneKey, since).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	if count >= limit {
+		return false, nil
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO customer_otps (store_id, customer_id, phone_key, code_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, otp.StoreID, otp.CustomerID, otp.PhoneKey, otp.CodeHash, otp.ExpiresAt).Scan(&otp.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// GetLatestOTP returns the newest OTP sent to the phone key, nil when none was sent
+func (r *customerPortalRepository) GetLatestOTP(ctx context.Context, storeID int64, phoneKey string) (*CustomerOTP, error) {
+	var otp CustomerOTP
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, store_id, customer_id, phone_key, code_hash, attempts, expires_at, verified_at
+		FROM customer_otps
+		WHERE store_id = $1 AND phone_key = $2
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`, storeID, phoneKey).Scan(&otp.ID, &otp.StoreID, &otp.CustomerID, &otp.PhoneKey,
+		&otp.CodeHash, &otp.Attempts, &otp.ExpiresAt, &otp.VerifiedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &otp, nil
+}
+
+// ConsumeOTPAttempt uses up one of the OTP's guesses and returns its code hash
+// to check the guess against, nil when the OTP is used, expired or out of
+// guesses. Taking the attempt before the comparison means concurrent guesses
+// cannot exceed maxAttempts.
+func (r *customerPortalRepository) ConsumeOTPAttempt(ctx context.Context, otpID int64, maxAttempts int) (*string, error) {
+	var codeHash string
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE customer_otps SET attempts = attempts + 1
+		WHERE id = $1 AND attempts < $2 AND verified_at IS NULL AND expires_at > NOW()
+		RETURNING code_hash
+	`, otpID, maxAttempts).Scan(&codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &codeHash, nil
+}
+
+// MarkOTPVerified uses up the OTP; it returns false when it was already used
+func (r *customerPortalRepository) MarkOTPVerified(ctx context.Context, otpID int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE customer_otps SET verified_at = NOW() WHERE id = $1 AND verified_at IS NULL
+	`, otpID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (r *customerPortalRepository) CreateSession(ctx context.Context, storeID, customerID int64, token string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO customer_sessions (store_id, customer_id, session_token, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, storeID, customerID, token, expiresAt)
+	return err
+}
+
+// GetSession returns the live session for the token, nil when it is unknown,
+// expired, revoked or its customer is no longer active
+func (r *customerPortalRepository) GetSession(ctx context.Context, token string) (*CustomerSession, error) {
+	var session CustomerSession
+	err := r.db.QueryRowContext(ctx, `
+		SELECT cs.store_id, cs.customer_id, cs.expires_at
+		FROM customer_sessions cs
+		JOIN customers c ON c.id = cs.customer_id AND c.store_id = cs.store_id AND c.is_active = true
+		WHERE cs.session_token = $1 AND cs.revoked_at IS NULL AND cs.expires_at > NOW()
+	`, token).Scan(&session.StoreID, &session.CustomerID, &session.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *customerPortalRepository) RevokeSession(ctx context.Context, token string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE customer_sessions SET revoked_at = NOW() WHERE session_token = $1`, token)
+	return err
+}
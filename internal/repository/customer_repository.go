@@ -0,0 +1,332 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mini-membership/api/internal/domain"
+)
+
+type CustomerRepository interface {
+	FindDuplicateCandidates(ctx context.Context, storeID int64) ([]DuplicateCandidate, error)
+	MergeCustomersTx(ctx context.Context, storeID, survivorID int64, mergeIDs []int64, mergedBy int64, note *string) (*MergeResult, error)
+	GetMerges(ctx context.Context, storeID int64, customerID *int64, limit, offset int) ([]domain.CustomerMerge, int, error)
+}
+
+// DuplicateCandidate is an active customer whose phone key is shared with
+// another active customer of the store. Names are not compared here; the
+// service splits each phone's candidates by name similarity before offering
+// them for merge.
+type DuplicateCandidate struct {
+	ID            int64
+	CustomerCode  sql.NullString
+	FullName      sql.NullString
+	Phone         string
+	PhoneKey      string
+	ProductPoints int
+	WalletBalance int
+	OrderCount    int
+	CreatedAt     time.Time
+}
+
+// MergeResult is the outcome of MergeCustomersTx
+type MergeResult struct {
+	Merges      []domain.CustomerMerge
+	Tier        *domain.CustomerTier
+	TierChanged bool
+}
+
+type customerRepository struct {
+	db *sqlx.DB
+}
+
+func NewCustomerRepository(db *sqlx.DB) CustomerRepository {
+	return &customerRepository{db: db}
+}
+
+// customerPhoneKeySQL is the phone of customer c reduced to digits, with +66
+// numbers in local 0 form. It matches idx_customers_store_phone_key.
+const customerPhoneKeySQL = `
+	CASE
+		WHEN regexp_replace(c.phone, '\D', '', 'g') ~ '^66[0-9]{9}$'
+			THEN '0' || substr(regexp_replace(c.phone, '\D', '', 'g'), 3)
+		ELSE regexp_replace(c.phone, '\D', '', 'g')
+	END`
+
+// FindDuplicateCandidates returns the store's active customers that share a
+// phone key, grouped by key and oldest first
+func (r *customerRepository) FindDuplicateCandidates(ctx context.Context, storeID int64) ([]DuplicateCandidate, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		WITH keyed AS (
+			SELECT c.id, c.customer_code, c.full_name, c.phone, c.created_at,
+				`+customerPhoneKeySQL+` AS phone_key
+			FROM customers c
+			WHERE c.store_id = $1 AND c.is_active = true AND c.phone IS NOT NULL
+		)
+		SELECT k.id, k.customer_code, k.full_name, k.phone, k.phone_key,
+			COALESCE((SELECT SUM(cpp.points) FROM customer_product_points cpp
+				WHERE cpp.store_id = $1 AND cpp.customer_id = k.id), 0) AS product_points,
+			COALESCE((SELECT cw.balance FROM customer_wallets cw
+				WHERE cw.store_id = $1 AND cw.customer_id = k.id), 0) AS wallet_balance,
+			(SELECT COUNT(*) FROM orders o WHERE o.store_id = $1 AND o.customer_id = k.id) AS order_count,
+			k.created_at
+		FROM keyed k
+		WHERE k.phone_key IN (
+			SELECT phone_key FROM keyed WHERE phone_key <> '' GROUP BY phone_key HAVING COUNT(*) > 1
+		)
+		ORDER BY k.phone_key, k.created_at, k.id
+	`, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []DuplicateCandidate
+	for rows.Next() {
+		var d DuplicateCandidate
+		if err := rows.Scan(&d.ID, &d.CustomerCode, &d.FullName, &d.Phone, &d.PhoneKey,
+			&d.ProductPoints, &d.WalletBalance, &d.OrderCount, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, d)
+	}
+	return candidates, rows.Err()
+}
+
+// MergeCustomersTx merges each of mergeIDs into survivorID in one transaction.
+// Product points are summed per product, the wallet balance is added to the
+// survivor's, and orders, point transactions, redemptions, earn lots and wallet
+// transactions are re-pointed to the survivor. Each merged customer is left
+// inactive with merged_into_id set and an audit row in customer_merges. The
+// survivor's tier is evaluated once everything has moved.
+func (r *customerRepository) MergeCustomersTx(ctx context.Context, storeID, survivorID int64, mergeIDs []int64, mergedBy int64, note *string) (*MergeResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Lock every customer involved in id order so concurrent merges cannot deadlock
+	type lockedCustomer struct {
+		code, name, phone sql.NullString
+		active            bool
+	}
+	ids := append([]int64{survivorID}, mergeIDs...)
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, customer_code, full_name, phone, is_active
+		FROM customers
+		WHERE store_id = $1 AND id = ANY($2)
+		ORDER BY id
+		FOR UPDATE
+	`, storeID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	locked := make(map[int64]lockedCustomer, len(ids))
+	for rows.Next() {
+		var id int64
+		var lc lockedCustomer
+		if err := rows.Scan(&id, &lc.code, &lc.name, &lc.phone, &lc.active); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		locked[id] = lc
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		lc, ok := locked[id]
+		if !ok {
+			return nil, fmt.Errorf("customer %d not found", id)
+		}
+		if !lc.active {
+			return nil, fmt.Errorf("customer %d is inactive or already merged", id)
+		}
+	}
+
+	result := &MergeResult{}
+	for _, mergedID := range mergeIDs {
+		lc := locked[mergedID]
+		m := domain.CustomerMerge{
+			SurvivorID: survivorID,
+			MergedID:   mergedID,
+			Note:       note,
+			MergedBy:   &mergedBy,
+		}
+		if lc.code.Valid {
+			m.MergedCustomerCode = &lc.code.String
+		}
+		if lc.name.Valid {
+			m.MergedFullName = &lc.name.String
+		}
+		if lc.phone.Valid {
+			m.MergedPhone = &lc.phone.String
+		}
+
+		// 1. Sum product points into the survivor's balances
+		err = tx.QueryRowContext(ctx, `
+			SELECT COALESCE(SUM(points), 0) FROM customer_product_points
+			WHERE store_id = $1 AND customer_id = $2
+		`, storeID, mergedID).Scan(&m.ProductPointsMoved)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO customer_product_points (store_id, customer_id, product_id, points, total_points)
+			SELECT store_id, $2, product_id, points, total_points
+			FROM customer_product_points
+			WHERE store_id = $1 AND customer_id = $3
+			ON CONFLICT (store_id, customer_id, product_id)
+			DO UPDATE SET
+				points = customer_product_points.points + EXCLUDED.points,
+				total_points = customer_product_points.total_points + EXCLUDED.total_points,
+				updated_at = NOW()
+		`, storeID, survivorID, mergedID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			DELETE FROM customer_product_points WHERE store_id = $1 AND customer_id = $2
+		`, storeID, mergedID)
+		if err != nil {
+			return nil, err
+		}
+
+		// 2. Move the wallet balance
+		var walletBalance, walletLifetime int
+		err = tx.QueryRowContext(ctx, `
+			DELETE FROM customer_wallets WHERE store_id = $1 AND customer_id = $2
+			RETURNING balance, lifetime_earned
+		`, storeID, mergedID).Scan(&walletBalance, &walletLifetime)
+		switch {
+		case err == nil:
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO customer_wallets (store_id, customer_id, balance, lifetime_earned)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (store_id, customer_id)
+				DO UPDATE SET
+					balance = customer_wallets.balance + $3,
+					lifetime_earned = customer_wallets.lifetime_earned + $4,
+					updated_at = NOW()
+			`, storeID, survivorID, walletBalance, walletLifetime)
+			if err != nil {
+				return nil, err
+			}
+			m.WalletPointsMoved = walletBalance
+		case !errors.Is(err, sql.ErrNoRows):
+			return nil, err
+		}
+
+		// 3. Re-point history to the survivor
+		moves := []struct {
+			table string
+			count *int
+		}{
+			{"orders", &m.OrdersMoved},
+			{"point_transactions", &m.PointTransactionsMoved},
+			{"point_redemptions", &m.RedemptionsMoved},
+			{"point_earn_lots", nil},
+			{"wallet_transactions", nil},
+			{"legacy_member_map", nil},
+		}
+		for _, mv := range moves {
+			res, err := tx.ExecContext(ctx, `
+				UPDATE `+mv.table+` SET customer_id = $2 WHERE store_id = $1 AND customer_id = $3
+			`, storeID, survivorID, mergedID)
+			if err != nil {
+				return nil, err
+			}
+			if mv.count != nil {
+				n, err := res.RowsAffected()
+				if err != nil {
+					return nil, err
+				}
+				*mv.count = int(n)
+			}
+		}
+
+		// 4. Retire the merged record; its tier history stays with it
+		_, err = tx.ExecContext(ctx, `
+			UPDATE customers
+			SET is_active = false, merged_into_id = $2, merged_at = NOW(), tier_id = NULL
+			WHERE id = $1
+		`, mergedID, survivorID)
+		if err != nil {
+			return nil, err
+		}
+
+		// 5. Audit
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO customer_merges (
+				store_id, survivor_id, merged_id, merged_customer_code, merged_full_name, merged_phone,
+				product_points_moved, wallet_points_moved, orders_moved, point_transactions_moved,
+				redemptions_moved, note, merged_by
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			RETURNING id, created_at
+		`, storeID, survivorID, mergedID, m.MergedCustomerCode, m.MergedFullName, m.MergedPhone,
+			m.ProductPointsMoved, m.WalletPointsMoved, m.OrdersMoved, m.PointTransactionsMoved,
+			m.RedemptionsMoved, note, mergedBy).Scan(&m.ID, &m.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		result.Merges = append(result.Merges, m)
+	}
+
+	// 6. The combined points may reach a higher tier
+	result.Tier, result.TierChanged, err = evaluateCustomerTier(ctx, tx, storeID, survivorID, domain.TierChangeReasonMerge)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetMerges lists merge audit records, newest first, optionally those where
+// customerID is the survivor or the merged customer
+func (r *customerRepository) GetMerges(ctx context.Context, storeID int64, customerID *int64, limit, offset int) ([]domain.CustomerMerge, int, error) {
+	where := `WHERE m.store_id = $1 AND ($2::BIGINT IS NULL OR m.survivor_id = $2 OR m.merged_id = $2)`
+
+	var total int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM customer_merges m `+where, storeID, customerID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT m.id, m.survivor_id, m.merged_id, m.merged_customer_code, m.merged_full_name, m.merged_phone,
+			m.product_points_moved, m.wallet_points_moved, m.orders_moved, m.point_transactions_moved,
+			m.redemptions_moved, m.note, m.merged_by, s.email, m.created_at
+		FROM customer_merges m
+		LEFT JOIN staff_accounts s ON s.id = m.merged_by
+		`+where+`
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $3 OFFSET $4
+	`, storeID, customerID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	merges := []domain.CustomerMerge{}
+	for rows.Next() {
+		var m domain.CustomerMerge
+		if err := rows.Scan(&m.ID, &m.SurvivorID, &m.MergedID, &m.MergedCustomerCode, &m.MergedFullName, &m.MergedPhone,
+			&m.ProductPointsMoved, &m.WalletPointsMoved, &m.OrdersMoved, &m.PointTransactionsMoved,
+			&m.RedemptionsMoved, &m.Note, &m.MergedBy, &m.MergedByName, &m.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		merges = append(merges, m)
+	}
+	return merges, total, rows.Err()
+}
@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mini-membership/api/internal/domain"
+)
+
+type EarnRuleRepository interface {
+	List(ctx context.Context, storeID int64, activeOnly bool) ([]domain.EarnRule, error)
+	GetByID(ctx context.Context, storeID, ruleID int64) (*domain.EarnRule, error)
+	Create(ctx context.Context, storeID int64, createdBy *int64, req *domain.EarnRuleRequest) (int64, error)
+	Update(ctx context.Context, storeID, ruleID int64, req *domain.EarnRuleRequest) error
+	Delete(ctx context.Context, storeID, ruleID int64) error
+	GetProducts(ctx context.Context, storeID int64, productIDs []int64) ([]domain.EarnProductInfo, error)
+}
+
+type earnRuleRepository struct {
+	db *sqlx.DB
+}
+
+func NewEarnRuleRepository(db *sqlx.DB) EarnRuleRepository {
+	return &earnRuleRepository{db: db}
+}
+
+const earnRuleSelect = `
+	SELECT r.id, r.rule_name, r.rule_type, r.points_per_unit, r.baht_per_point::FLOAT8, r.multiplier::FLOAT8,
+		r.product_id, p.product_name, r.category_id, c.category_name, r.promotion_id, r.tier_code,
+		r.days_of_week, to_char(r.start_time, 'HH24:MI'), to_char(r.end_time, 'HH24:MI'),
+		r.starts_at, r.ends_at, r.priority, r.is_active, r.created_at, r.updated_at
+	FROM loyalty_earn_rules r
+	LEFT JOIN products p ON p.id = r.product_id
+	LEFT JOIN categories c ON c.id = r.category_id
+`
+
+func scanEarnRule(scan func(dest ...interface{}) error) (domain.EarnRule, error) {
+	var rule domain.EarnRule
+	var days pq.Int64Array
+	err := scan(
+		&rule.ID, &rule.RuleName, &rule.RuleType, &rule.PointsPerUnit, &rule.BahtPerPoint, &rule.Multiplier,
+		&rule.ProductID, &rule.ProductName, &rule.CategoryID, &rule.CategoryName, &rule.PromotionID, &rule.TierCode,
+		&days, &rule.StartTime, &rule.EndTime,
+		&rule.StartsAt, &rule.EndsAt, &rule.Priority, &rule.IsActive, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	for _, d := range days {
+		rule.DaysOfWeek = append(rule.DaysOfWeek, int(d))
+	}
+	return rule, err
+}
+
+// List returns the store's earn rules, most specific and highest priority first
+func (r *earnRuleRepository) List(ctx context.Context, storeID int64, activeOnly bool) ([]domain.EarnRule, error) {
+	rows, err := r.db.QueryContext(ctx, earnRuleSelect+`
+		WHERE r.store_id = $1 AND ($2 = false OR r.is_active = true)
+		ORDER BY r.rule_type, r.product_id IS NULL, r.category_id IS NULL, r.priority DESC, r.id
+	`, storeID, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []domain.EarnRule{}
+	for rows.Next() {
+		rule, err := scanEarnRule(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *earnRuleRepository) GetByID(ctx context.Context, storeID, ruleID int64) (*domain.EarnRule, error) {
+	rule, err := scanEarnRule(r.db.QueryRowContext(ctx, earnRuleSelect+`
+		WHERE r.store_id = $1 AND r.id = $2
+	`, storeID, ruleID).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *earnRuleRepository) Create(ctx context.Context, storeID int64, createdBy *int64, req *domain.EarnRuleRequest) (int64, error) {
+	isActive := req.IsActive == nil || *req.IsActive
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO loyalty_earn_rules (
+			store_id, rule_name, rule_type, points_per_unit, baht_per_point, multiplier,
+			product_id, category_id, promotion_id, tier_code, days_of_week, start_time, end_time,
+			starts_at, ends_at, priority, is_active, created_by
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12::TIME, $13::TIME, $14, $15, $16, $17, $18)
+		RETURNING id
+	`, storeID, req.RuleName, req.RuleType, req.PointsPerUnit, req.BahtPerPoint, req.Multiplier,
+		req.ProductID, req.CategoryID, req.PromotionID, req.TierCode, daysOfWeekArray(req.DaysOfWeek), req.StartTime, req.EndTime,
+		req.StartsAt, req.EndsAt, req.Priority, isActive, createdBy,
+	).Scan(&id)
+	return id, err
+}
+
+// Update replaces every field of the rule; IsActive keeps its current value when omitted
+func (r *earnRuleRepository) Update(ctx context.Context, storeID, ruleID int64, req *domain.EarnRuleRequest) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE loyalty_earn_rules SET
+			rule_name = $3, rule_type = $4, points_per_unit = $5, baht_per_point = $6, multiplier = $7,
+			product_id = $8, category_id = $9, promotion_id = $10, tier_code = $11, days_of_week = $12,
+			start_time = $13::TIME, end_time = $14::TIME, starts_at = $15, ends_at = $16, priority = $17,
+			is_active = COALESCE($18, is_active)
+		WHERE store_id = $1 AND id = $2
+	`, storeID, ruleID, req.RuleName, req.RuleType, req.PointsPerUnit, req.BahtPerPoint, req.Multiplier,
+		req.ProductID, req.CategoryID, req.PromotionID, req.TierCode, daysOfWeekArray(req.DaysOfWeek),
+		req.StartTime, req.EndTime, req.StartsAt, req.EndsAt, req.Priority, req.IsActive,
+	)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("earn rule not found")
+	}
+	return nil
+}
+
+func (r *earnRuleRepository) Delete(ctx context.Context, storeID, ruleID int64) error {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM loyalty_earn_rules WHERE store_id = $1 AND id = $2
+	`, storeID, ruleID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("earn rule not found")
+	}
+	return nil
+}
+
+// GetProducts returns the name and category of the store's products in productIDs
+func (r *earnRuleRepository) GetProducts(ctx context.Context, storeID int64, productIDs []int64) ([]domain.EarnProductInfo, error) {
+	var products []domain.EarnProductInfo
+	err := r.db.SelectContext(ctx, &products, `
+		SELECT id, product_name, category_id FROM products
+		WHERE store_id = $1 AND id = ANY($2)
+	`, storeID, pq.Array(productIDs))
+	if err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func daysOfWeekArray(days []int) interface{} {
+	if len(days) == 0 {
+		return nil
+	}
+	arr := make(pq.Int64Array, len(days))
+	for i, d := range days {
+		arr[i] = int64(d)
+	}
+	return arr
+}
@@ -3,19 +3,40 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"errors"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/mini-membership/api/internal/domain"
+	"github.com/shopspring/decimal"
 )
 
 type InventoryRepository interface {
 	CreateMovement(ctx context.Context, movement *domain.InventoryMovement) (*domain.InventoryMovement, error)
-	GetMovementsByBranch(ctx context.Context, storeID, branchID int64, limit, offset int) ([]domain.InventoryMovementResponse, error)
-	GetLowStockItems(ctx context.Context, storeID, branchID int64, threshold int) (*domain.LowStockResponse, error)
+	GetMovementsByBranch(ctx context.Context, storeID, branchID int64, filter *domain.InventoryMovementFilter, limit, offset int) ([]domain.InventoryMovementResponse, error)
+	StreamMovements(ctx context.Context, storeID, branchID int64, filter *domain.InventoryMovementFilter, fn func(*domain.InventoryMovementResponse) error) error
+	GetLowStockItems(ctx context.Context, storeID, branchID int64) (*domain.LowStockResponse, error)
+	UpdateReorderSettings(ctx context.Context, storeID, branchID int64, req *domain.UpdateReorderSettingsRequest) error
+	GetReplenishmentCandidates(ctx context.Context, storeID, branchID int64, salesWindowDays int) ([]domain.ReplenishmentCandidate, error)
 	GetBranchProductStock(ctx context.Context, branchID, productID int64) (int, error)
-	UpdateBranchProductStock(ctx context.Context, storeID, branchID, productID int64, newStock int) error
-	AdjustStock(ctx context.Context, storeID, branchID, productID int64, quantityChange int, movementType domain.MovementType, reason, note *string, changedBy int64) error
+	UpdateBranchProductStock(ctx context.Context, storeID, branchID, productID int64, newStock int, reason *string, changedBy *int64) error
+	GetStockDrift(ctx context.Context, storeID, branchID *int64) ([]domain.StockDriftItem, error)
+	UpdateProductNegativeStockPolicy(ctx context.Context, storeID, productID int64, policy *domain.NegativeStockPolicy) error
+	GetValuation(ctx context.Context, storeID int64, branchID *int64) ([]domain.InventoryValuationRow, error)
+	GetLots(ctx context.Context, storeID int64, branchID, productID *int64, expiringBefore *time.Time) ([]domain.StockLot, error)
+	GetLotByID(ctx context.Context, storeID, lotID int64) (*domain.StockLot, error)
+	WriteOffLot(ctx context.Context, storeID, lotID int64, quantity int, reasonCode, reason, note *string, changedBy int64, approvedBy *int64) error
+	UpdateProductLotTracking(ctx context.Context, storeID, productID int64, trackLots bool) error
+	GetOversells(ctx context.Context, storeID int64, branchID *int64, from, to *time.Time, limit, offset int) (*domain.OversellReportResponse, error)
+	CorrectStockDrift(ctx context.Context, storeID, branchID, productID int64, changedBy *int64) (int, error)
+	AdjustStock(ctx context.Context, storeID, branchID, productID int64, quantityChange int, movementType domain.MovementType, reasonCode, reason, note *string, changedBy int64, approvedBy *int64) error
+	GetProductBasePrice(ctx context.Context, storeID, productID int64) (*float64, error)
+	GetAverageCost(ctx context.Context, storeID int64, branchID, warehouseID *int64, productID int64) (*decimal.Decimal, error)
+	GetAdjustmentReasons(ctx context.Context, storeID int64, activeOnly bool) ([]domain.StockAdjustmentReason, error)
+	GetAdjustmentReasonByCode(ctx context.Context, storeID int64, code string) (*domain.StockAdjustmentReason, error)
+	GetAdjustmentReasonByID(ctx context.Context, storeID, reasonID int64) (*domain.StockAdjustmentReason, error)
+	CreateAdjustmentReason(ctx context.Context, storeID int64, req *domain.CreateStockAdjustmentReasonRequest) (*domain.StockAdjustmentReason, error)
+	UpdateAdjustmentReason(ctx context.Context, storeID, reasonID int64, req *domain.UpdateStockAdjustmentReasonRequest) error
 }
 
 type inventoryRepository struct {
@@ -46,19 +67,71 @@ func (r *inventoryRepository) CreateMovement(ctx context.Context, movement *doma
 	return movement, nil
 }
 
-func (r *inventoryRepository) GetMovementsByBranch(ctx context.Context, storeID, branchID int64, limit, offset int) ([]domain.InventoryMovementResponse, error) {
+// movementQuery selects branch movements matching a filter. The running balance is
+// the product's ledger total up to and including each movement, so it is computed
+// over the product's full history before the other filters are applied.
+func movementQuery(storeID, branchID int64, f *domain.InventoryMovementFilter, order string) (string, []interface{}) {
 	query := `
-		SELECT 
+		WITH ledger AS (
+			SELECT im.*,
+				SUM(im.quantity_change) OVER (PARTITION BY im.product_id ORDER BY im.created_at, im.id) AS running_balance
+			FROM inventory_movements im
+			WHERE im.store_id = $1 AND im.branch_id = $2
+				AND ($3::BIGINT IS NULL OR im.product_id = $3)
+		)
+		SELECT
 			im.id, im.product_id, p.product_name, im.movement_type, im.quantity_change,
-			im.from_stock_count, im.to_stock_count, im.reason, im.note, s.email as changed_by_name, im.created_at
-		FROM inventory_movements im
+			im.from_stock_count, im.to_stock_count, im.reason_code, im.reason, im.note,
+			s.email as changed_by_name, a.email as approved_by_name,
+			im.unit_cost, im.reference_table, im.reference_id, im.running_balance, im.created_at
+		FROM ledger im
 		JOIN products p ON im.product_id = p.id
 		LEFT JOIN staff_accounts s ON im.changed_by = s.id
-		WHERE im.store_id = $1 AND im.branch_id = $2
-		ORDER BY im.created_at DESC
-		LIMIT $3 OFFSET $4
-	`
-	rows, err := r.db.QueryContext(ctx, query, storeID, branchID, limit, offset)
+		LEFT JOIN staff_accounts a ON im.approved_by = a.id
+		WHERE ($4 = '' OR im.movement_type = $4)
+			AND ($5::BIGINT IS NULL OR im.changed_by = $5)
+			AND ($6::TIMESTAMPTZ IS NULL OR im.created_at >= $6)
+			AND ($7::TIMESTAMPTZ IS NULL OR im.created_at < $7)
+			AND ($8 = '' OR im.reference_table = $8)
+			AND ($9::BIGINT IS NULL OR im.reference_id = $9)
+		ORDER BY im.created_at ` + order + `, im.id ` + order
+	args := []interface{}{
+		storeID, branchID, f.ProductID, f.MovementType, f.StaffID,
+		f.From, f.To, f.ReferenceTable, f.ReferenceID,
+	}
+	return query, args
+}
+
+func scanMovement(rows *sql.Rows) (domain.InventoryMovementResponse, error) {
+	var m domain.InventoryMovementResponse
+	var changedByName, approvedByName sql.NullString
+	var runningBalance int
+	err := rows.Scan(
+		&m.ID, &m.ProductID, &m.ProductName, &m.MovementType, &m.QuantityChange,
+		&m.FromStockCount, &m.ToStockCount, &m.ReasonCode, &m.Reason, &m.Note,
+		&changedByName, &approvedByName,
+		&m.UnitCost, &m.ReferenceTable, &m.ReferenceID, &runningBalance, &m.CreatedAt,
+	)
+	if err != nil {
+		return m, err
+	}
+	if changedByName.Valid {
+		m.ChangedByName = &changedByName.String
+	}
+	if approvedByName.Valid {
+		m.ApprovedByName = &approvedByName.String
+	}
+	m.RunningBalance = &runningBalance
+	return m, nil
+}
+
+// GetMovementsByBranch returns a page of filtered movements, newest first
+func (r *inventoryRepository) GetMovementsByBranch(ctx context.Context, storeID, branchID int64, filter *domain.InventoryMovementFilter, limit, offset int) ([]domain.InventoryMovementResponse, error) {
+	query, args := movementQuery(storeID, branchID, filter, "DESC")
+	query += ` LIMIT $10 OFFSET $11`
+	args = append(args, limit, offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -66,38 +139,54 @@ func (r *inventoryRepository) GetMovementsByBranch(ctx context.Context, storeID,
 
 	var movements []domain.InventoryMovementResponse
 	for rows.Next() {
-		var m domain.InventoryMovementResponse
-		var changedByName sql.NullString
-		err := rows.Scan(
-			&m.ID, &m.ProductID, &m.ProductName, &m.MovementType, &m.QuantityChange,
-			&m.FromStockCount, &m.ToStockCount, &m.Reason, &m.Note, &changedByName, &m.CreatedAt,
-		)
+		m, err := scanMovement(rows)
 		if err != nil {
 			return nil, err
 		}
-		if changedByName.Valid {
-			m.ChangedByName = &changedByName.String
-		}
 		movements = append(movements, m)
 	}
-	return movements, nil
+	return movements, rows.Err()
 }
 
-func (r *inventoryRepository) GetLowStockItems(ctx context.Context, storeID, branchID int64, threshold int) (*domain.LowStockResponse, error) {
+// StreamMovements calls fn for every filtered movement, oldest first, without
+// loading the result into memory
+func (r *inventoryRepository) StreamMovements(ctx context.Context, storeID, branchID int64, filter *domain.InventoryMovementFilter, fn func(*domain.InventoryMovementResponse) error) error {
+	query, args := movementQuery(storeID, branchID, filter, "ASC")
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m, err := scanMovement(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(&m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetLowStockItems returns active products at or below their own reorder level
+func (r *inventoryRepository) GetLowStockItems(ctx context.Context, storeID, branchID int64) (*domain.LowStockResponse, error) {
 	query := `
 		SELECT 
-			bp.product_id, p.product_name, c.category_name, bp.on_stock, bp.reorder_level, p.base_price
+			bp.product_id, p.product_name, COALESCE(c.category_name, ''), bp.on_stock, bp.reorder_level, p.base_price
 		FROM branch_products bp
 		JOIN products p ON bp.product_id = p.id
-		JOIN categories c ON p.category_id = c.id
+		LEFT JOIN categories c ON p.category_id = c.id
 		WHERE bp.store_id = $1 AND bp.branch_id = $2 
 			AND bp.is_active = true
-			AND (bp.on_stock <= bp.reorder_level OR bp.on_stock <= $3)
-		ORDER BY bp.on_stock ASC, p.product_name ASC
+			AND p.is_active = true
+			AND bp.on_stock <= bp.reorder_level
+		ORDER BY (bp.on_stock - bp.reorder_level) ASC, p.product_name ASC
 	`
-	rows, err := r.db.QueryContext(ctx, query, storeID, branchID, threshold)
+	rows, err := r.db.QueryContext(ctx, query, storeID, branchID)
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -112,14 +201,99 @@ func (r *inventoryRepository) GetLowStockItems(ctx context.Context, storeID, bra
 		items = append(items, item)
 	}
 
-	fmt.Println(items)
-
 	return &domain.LowStockResponse{
 		Items:      items,
 		TotalCount: len(items),
 	}, nil
 }
 
+func (r *inventoryRepository) UpdateReorderSettings(ctx context.Context, storeID, branchID int64, req *domain.UpdateReorderSettingsRequest) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO branch_products (store_id, branch_id, product_id, on_stock, reorder_level, lead_time_days)
+		VALUES ($1, $2, $3, 0, $4, $5)
+		ON CONFLICT (branch_id, product_id)
+		DO UPDATE SET reorder_level = $4, lead_time_days = $5, updated_at = NOW()
+	`, storeID, branchID, req.ProductID, req.ReorderLevel, req.LeadTimeDays)
+	return err
+}
+
+// GetReplenishmentCandidates returns every active branch product with its sales over the
+// window (net of cancellations), stock already on its way from open transfers and
+// purchase orders, and the most recent purchase cost
+func (r *inventoryRepository) GetReplenishmentCandidates(ctx context.Context, storeID, branchID int64, salesWindowDays int) ([]domain.ReplenishmentCandidate, error) {
+	query := `
+		SELECT
+			bp.product_id, p.product_name, COALESCE(c.category_name, ''),
+			bp.on_stock, bp.reorder_level, bp.lead_time_days,
+			COALESCE(sales.sold_qty, 0),
+			COALESCE(transfers.incoming_qty, 0) + COALESCE(pos.incoming_qty, 0),
+			COALESCE(last_cost.unit_cost, 0)
+		FROM branch_products bp
+		JOIN products p ON bp.product_id = p.id
+		LEFT JOIN categories c ON p.category_id = c.id
+		LEFT JOIN (
+			SELECT product_id, -SUM(quantity_change) AS sold_qty
+			FROM inventory_movements
+			WHERE store_id = $1 AND branch_id = $2
+				AND movement_type IN ('SALE', 'CANCEL_SALE', 'REDEEM')
+				AND created_at >= NOW() - make_interval(days => $3)
+			GROUP BY product_id
+		) sales ON sales.product_id = bp.product_id
+		LEFT JOIN (
+			SELECT sti.product_id,
+				SUM(CASE
+					WHEN st.status IN ('CREATED', 'PICKING') THEN sti.requested_count
+					ELSE GREATEST(sti.send_count - sti.receive_count, 0)
+				END) AS incoming_qty
+			FROM stock_transfer_items sti
+			JOIN stock_transfers st ON sti.stock_transfer_id = st.id
+			WHERE st.store_id = $1 AND st.to_branch_id = $2
+				AND st.status IN ('CREATED', 'PICKING', 'SENT', 'PARTIALLY_RECEIVED')
+			GROUP BY sti.product_id
+		) transfers ON transfers.product_id = bp.product_id
+		LEFT JOIN (
+			SELECT poi.product_id, SUM(poi.ordered_qty - poi.received_qty) AS incoming_qty
+			FROM purchase_order_items poi
+			JOIN purchase_orders po ON poi.purchase_order_id = po.id
+			WHERE po.store_id = $1 AND po.branch_id = $2
+				AND po.status IN ('OPEN', 'PARTIALLY_RECEIVED')
+			GROUP BY poi.product_id
+		) pos ON pos.product_id = bp.product_id
+		LEFT JOIN LATERAL (
+			SELECT poi.unit_cost
+			FROM purchase_order_items poi
+			JOIN purchase_orders po ON poi.purchase_order_id = po.id
+			WHERE po.store_id = $1 AND poi.product_id = bp.product_id
+			ORDER BY po.created_at DESC
+			LIMIT 1
+		) last_cost ON true
+		WHERE bp.store_id = $1 AND bp.branch_id = $2
+			AND bp.is_active = true
+			AND p.is_active = true
+		ORDER BY p.product_name ASC
+	`
+	rows, err := r.db.QueryContext(ctx, query, storeID, branchID, salesWindowDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []domain.ReplenishmentCandidate
+	for rows.Next() {
+		var cand domain.ReplenishmentCandidate
+		err := rows.Scan(
+			&cand.ProductID, &cand.ProductName, &cand.CategoryName,
+			&cand.OnStock, &cand.ReorderLevel, &cand.LeadTimeDays,
+			&cand.SoldQty, &cand.IncomingQty, &cand.LastUnitCost,
+		)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, cand)
+	}
+	return candidates, rows.Err()
+}
+
 func (r *inventoryRepository) GetBranchProductStock(ctx context.Context, branchID, productID int64) (int, error) {
 	var stock int
 	err := r.db.QueryRowContext(ctx, `
@@ -131,55 +305,580 @@ func (r *inventoryRepository) GetBranchProductStock(ctx context.Context, branchI
 	return stock, err
 }
 
-func (r *inventoryRepository) UpdateBranchProductStock(ctx context.Context, storeID, branchID, productID int64, newStock int) error {
-	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO branch_products (store_id, branch_id, product_id, on_stock)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (branch_id, product_id) 
-		DO UPDATE SET on_stock = $4, updated_at = NOW()
-	`, storeID, branchID, productID, newStock)
-	return err
-}
-
-func (r *inventoryRepository) AdjustStock(ctx context.Context, storeID, branchID, productID int64, quantityChange int, movementType domain.MovementType, reason, note *string, changedBy int64) error {
+// UpdateBranchProductStock sets on_stock to an absolute value and records the
+// difference as an ADJUST movement so the ledger stays in step
+func (r *inventoryRepository) UpdateBranchProductStock(ctx context.Context, storeID, branchID, productID int64, newStock int, reason *string, changedBy *int64) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Get current stock
 	var currentStock int
 	err = tx.QueryRowContext(ctx, `
-		SELECT COALESCE(on_stock, 0) FROM branch_products WHERE branch_id = $1 AND product_id = $2
-	`, branchID, productID).Scan(&currentStock)
+		SELECT on_stock FROM branch_products
+		WHERE store_id = $1 AND branch_id = $2 AND product_id = $3
+		FOR UPDATE
+	`, storeID, branchID, productID).Scan(&currentStock)
 	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
 
-	newStock := currentStock + quantityChange
+	if delta := newStock - currentStock; delta != 0 {
+		_, err = moveBranchStock(ctx, tx, branchStockMove{
+			StoreID:        storeID,
+			BranchID:       branchID,
+			ProductID:      productID,
+			QuantityChange: delta,
+			MovementType:   domain.MovementTypeAdjust,
+			Reason:         reason,
+			ChangedBy:      changedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
 
-	// Update branch_products
-	_, err = tx.ExecContext(ctx, `
-		INSERT INTO branch_products (store_id, branch_id, product_id, on_stock)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (branch_id, product_id) 
-		DO UPDATE SET on_stock = $4, updated_at = NOW()
-	`, storeID, branchID, productID, newStock)
+	return tx.Commit()
+}
+
+func (r *inventoryRepository) AdjustStock(ctx context.Context, storeID, branchID, productID int64, quantityChange int, movementType domain.MovementType, reasonCode, reason, note *string, changedBy int64, approvedBy *int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// Create inventory movement record
+	_, err = moveBranchStock(ctx, tx, branchStockMove{
+		StoreID:        storeID,
+		BranchID:       branchID,
+		ProductID:      productID,
+		QuantityChange: quantityChange,
+		MovementType:   movementType,
+		ReasonCode:     reasonCode,
+		Reason:         reason,
+		Note:           note,
+		ChangedBy:      &changedBy,
+		ApprovedBy:     approvedBy,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetProductBasePrice returns nil if the product does not belong to the store
+func (r *inventoryRepository) GetProductBasePrice(ctx context.Context, storeID, productID int64) (*float64, error) {
+	var price float64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT base_price FROM products WHERE id = $1 AND store_id = $2
+	`, productID, storeID).Scan(&price)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
+
+// GetAverageCost returns the product's moving-average cost at a branch or warehouse,
+// zero when the location has never stocked it, or nil if the product does not belong
+// to the store
+func (r *inventoryRepository) GetAverageCost(ctx context.Context, storeID int64, branchID, warehouseID *int64, productID int64) (*decimal.Decimal, error) {
+	var cost decimal.Decimal
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(bp.avg_cost, wp.avg_cost, 0)
+		FROM products p
+		LEFT JOIN branch_products bp ON bp.product_id = p.id AND bp.branch_id = $3
+		LEFT JOIN warehouse_products wp ON wp.product_id = p.id AND wp.warehouse_id = $4
+		WHERE p.id = $1 AND p.store_id = $2
+	`, productID, storeID, branchID, warehouseID).Scan(&cost)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cost, nil
+}
+
+const adjustmentReasonSelect = `
+	SELECT id, store_id, code, label, movement_type, is_active, created_at
+	FROM stock_adjustment_reasons
+`
+
+func scanAdjustmentReason(row interface{ Scan(...interface{}) error }) (*domain.StockAdjustmentReason, error) {
+	var reason domain.StockAdjustmentReason
+	var storeID sql.NullInt64
+	err := row.Scan(&reason.ID, &storeID, &reason.Code, &reason.Label, &reason.MovementType, &reason.IsActive, &reason.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if storeID.Valid {
+		reason.StoreID = &storeID.Int64
+	}
+	return &reason, nil
+}
+
+// GetAdjustmentReasons returns the store's catalogue: its own codes plus any
+// system default whose code the store has not overridden
+func (r *inventoryRepository) GetAdjustmentReasons(ctx context.Context, storeID int64, activeOnly bool) ([]domain.StockAdjustmentReason, error) {
+	query := adjustmentReasonSelect + `
+		WHERE (store_id = $1 OR (store_id IS NULL AND code NOT IN (
+			SELECT code FROM stock_adjustment_reasons WHERE store_id = $1
+		)))
+		AND ($2 = false OR is_active = true)
+		ORDER BY movement_type, code
+	`
+	rows, err := r.db.QueryContext(ctx, query, storeID, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reasons []domain.StockAdjustmentReason
+	for rows.Next() {
+		reason, err := scanAdjustmentReason(rows)
+		if err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, *reason)
+	}
+	return reasons, rows.Err()
+}
+
+// GetAdjustmentReasonByCode prefers the store's own code over the system default
+func (r *inventoryRepository) GetAdjustmentReasonByCode(ctx context.Context, storeID int64, code string) (*domain.StockAdjustmentReason, error) {
+	query := adjustmentReasonSelect + `
+		WHERE code = $2 AND (store_id = $1 OR store_id IS NULL)
+		ORDER BY store_id NULLS LAST
+		LIMIT 1
+	`
+	reason, err := scanAdjustmentReason(r.db.QueryRowContext(ctx, query, storeID, code))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return reason, err
+}
+
+// GetAdjustmentReasonByID only returns the store's own codes; system defaults are read-only
+func (r *inventoryRepository) GetAdjustmentReasonByID(ctx context.Context, storeID, reasonID int64) (*domain.StockAdjustmentReason, error) {
+	query := adjustmentReasonSelect + ` WHERE id = $1 AND store_id = $2`
+	reason, err := scanAdjustmentReason(r.db.QueryRowContext(ctx, query, reasonID, storeID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return reason, err
+}
+
+func (r *inventoryRepository) CreateAdjustmentReason(ctx context.Context, storeID int64, req *domain.CreateStockAdjustmentReasonRequest) (*domain.StockAdjustmentReason, error) {
+	query := `
+		INSERT INTO stock_adjustment_reasons (store_id, code, label, movement_type)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, store_id, code, label, movement_type, is_active, created_at
+	`
+	return scanAdjustmentReason(r.db.QueryRowContext(ctx, query, storeID, req.Code, req.Label, req.MovementType))
+}
+
+func (r *inventoryRepository) UpdateAdjustmentReason(ctx context.Context, storeID, reasonID int64, req *domain.UpdateStockAdjustmentReasonRequest) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE stock_adjustment_reasons SET label = $1, is_active = $2
+		WHERE id = $3 AND store_id = $4
+	`, req.Label, req.IsActive, reasonID, storeID)
+	return err
+}
+
+const reconcileReasonCode = "RECONCILE"
+
+// GetStockDrift replays inventory_movements per branch product and returns the
+// products whose on_stock does not equal the sum of their movements.
+// A nil storeID checks every store.
+func (r *inventoryRepository) GetStockDrift(ctx context.Context, storeID, branchID *int64) ([]domain.StockDriftItem, error) {
+	query := `
+		SELECT
+			bp.store_id, bp.branch_id, b.branch_name, bp.product_id, p.product_name,
+			bp.on_stock, COALESCE(ledger.ledger_qty, 0), COALESCE(ledger.movement_count, 0),
+			last_movement.to_stock_count
+		FROM branch_products bp
+		JOIN branches b ON bp.branch_id = b.id
+		JOIN products p ON bp.product_id = p.id
+		LEFT JOIN (
+			SELECT store_id, branch_id, product_id,
+				SUM(quantity_change) AS ledger_qty, COUNT(*) AS movement_count
+			FROM inventory_movements
+			GROUP BY store_id, branch_id, product_id
+		) ledger ON ledger.store_id = bp.store_id AND ledger.branch_id = bp.branch_id AND ledger.product_id = bp.product_id
+		LEFT JOIN LATERAL (
+			SELECT im.to_stock_count
+			FROM inventory_movements im
+			WHERE im.store_id = bp.store_id AND im.branch_id = bp.branch_id AND im.product_id = bp.product_id
+			ORDER BY im.created_at DESC, im.id DESC
+			LIMIT 1
+		) last_movement ON true
+		WHERE ($1::BIGINT IS NULL OR bp.store_id = $1)
+			AND ($2::BIGINT IS NULL OR bp.branch_id = $2)
+			AND bp.on_stock <> COALESCE(ledger.ledger_qty, 0)
+		ORDER BY bp.store_id, b.branch_name, p.product_name
+	`
+	rows, err := r.db.QueryContext(ctx, query, storeID, branchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []domain.StockDriftItem{}
+	for rows.Next() {
+		var item domain.StockDriftItem
+		var lastToStock sql.NullInt64
+		err := rows.Scan(
+			&item.StoreID, &item.BranchID, &item.BranchName, &item.ProductID, &item.ProductName,
+			&item.OnStock, &item.LedgerQty, &item.MovementCount, &lastToStock,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if lastToStock.Valid {
+			v := int(lastToStock.Int64)
+			item.LastToStockCount = &v
+		}
+		item.Drift = item.OnStock - item.LedgerQty
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// CorrectStockDrift re-checks a branch product under lock and, if it still drifts,
+// writes an ADJUST movement for the difference without touching on_stock.
+// It returns the quantity written, or 0 if the product no longer drifts.
+func (r *inventoryRepository) CorrectStockDrift(ctx context.Context, storeID, branchID, productID int64, changedBy *int64) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var onStock int
+	err = tx.QueryRowContext(ctx, `
+		SELECT on_stock FROM branch_products
+		WHERE store_id = $1 AND branch_id = $2 AND product_id = $3
+		FOR UPDATE
+	`, storeID, branchID, productID).Scan(&onStock)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var ledgerQty int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(quantity_change), 0) FROM inventory_movements
+		WHERE store_id = $1 AND branch_id = $2 AND product_id = $3
+	`, storeID, branchID, productID).Scan(&ledgerQty)
+	if err != nil {
+		return 0, err
+	}
+
+	drift := onStock - ledgerQty
+	if drift == 0 {
+		return 0, nil
+	}
+
+	reasonCode := reconcileReasonCode
+	reason := "Ledger reconciliation"
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO inventory_movements (
 			store_id, branch_id, product_id, movement_type, quantity_change,
-			from_stock_count, to_stock_count, reason, note, changed_by
+			from_stock_count, to_stock_count, reason_code, reason, changed_by
 		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`, storeID, branchID, productID, movementType, quantityChange, currentStock, newStock, reason, note, changedBy)
+	`, storeID, branchID, productID, domain.MovementTypeAdjust, drift,
+		ledgerQty, onStock, reasonCode, reason, changedBy,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return drift, nil
+}
+
+func (r *inventoryRepository) UpdateProductNegativeStockPolicy(ctx context.Context, storeID, productID int64, policy *domain.NegativeStockPolicy) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE products SET negative_stock_policy = $1, updated_at = NOW()
+		WHERE id = $2 AND store_id = $3
+	`, policy, productID, storeID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("product not found")
+	}
+	return nil
+}
+
+// GetOversells lists SALE movements that left the product below zero
+func (r *inventoryRepository) GetOversells(ctx context.Context, storeID int64, branchID *int64, from, to *time.Time, limit, offset int) (*domain.OversellReportResponse, error) {
+	where := `
+		WHERE im.store_id = $1
+			AND im.movement_type = 'SALE'
+			AND im.to_stock_count < 0
+			AND ($2::BIGINT IS NULL OR im.branch_id = $2)
+			AND ($3::TIMESTAMPTZ IS NULL OR im.created_at >= $3)
+			AND ($4::TIMESTAMPTZ IS NULL OR im.created_at < $4)
+	`
+
+	var total int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM inventory_movements im `+where, storeID, branchID, from, to).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			im.id, CASE WHEN im.reference_table = 'orders' THEN im.reference_id END,
+			im.branch_id, b.branch_name, im.product_id, p.product_name,
+			-im.quantity_change, COALESCE(im.from_stock_count, 0), im.to_stock_count,
+			s.email, im.created_at
+		FROM inventory_movements im
+		JOIN branches b ON im.branch_id = b.id
+		JOIN products p ON im.product_id = p.id
+		LEFT JOIN staff_accounts s ON im.changed_by = s.id
+	` + where + `
+		ORDER BY im.created_at DESC
+		LIMIT $5 OFFSET $6
+	`
+	rows, err := r.db.QueryContext(ctx, query, storeID, branchID, from, to, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []domain.OversellItem{}
+	for rows.Next() {
+		var item domain.OversellItem
+		var orderID sql.NullInt64
+		var staffName sql.NullString
+		err := rows.Scan(
+			&item.MovementID, &orderID,
+			&item.BranchID, &item.BranchName, &item.ProductID, &item.ProductName,
+			&item.Quantity, &item.FromStockCount, &item.ToStockCount,
+			&staffName, &item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if orderID.Valid {
+			item.OrderID = &orderID.Int64
+		}
+		if staffName.Valid {
+			item.StaffName = &staffName.String
+		}
+		// Only the part of the sale that went below zero was oversold
+		item.OversoldQty = -item.ToStockCount
+		if item.FromStockCount < 0 {
+			item.OversoldQty = item.Quantity
+		}
+		items = append(items, item)
+	}
+
+	return &domain.OversellReportResponse{Items: items, TotalCount: total}, rows.Err()
+}
+
+// GetValuation totals positive stock on hand per branch and category
+func (r *inventoryRepository) GetValuation(ctx context.Context, storeID int64, branchID *int64) ([]domain.InventoryValuationRow, error) {
+	query := `
+		SELECT
+			bp.branch_id, b.branch_name, c.id, COALESCE(c.category_name, 'Uncategorized'),
+			COUNT(*),
+			SUM(bp.on_stock),
+			ROUND(SUM(bp.on_stock * bp.avg_cost), 2),
+			ROUND(SUM(bp.on_stock * p.base_price), 2)
+		FROM branch_products bp
+		JOIN branches b ON bp.branch_id = b.id
+		JOIN products p ON bp.product_id = p.id
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE bp.store_id = $1
+			AND ($2::BIGINT IS NULL OR bp.branch_id = $2)
+			AND bp.on_stock > 0
+		GROUP BY bp.branch_id, b.branch_name, c.id, c.category_name
+		ORDER BY b.branch_name, c.category_name NULLS LAST
+	`
+	rows, err := r.db.QueryContext(ctx, query, storeID, branchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []domain.InventoryValuationRow{}
+	for rows.Next() {
+		var row domain.InventoryValuationRow
+		var categoryID sql.NullInt64
+		err := rows.Scan(
+			&row.BranchID, &row.BranchName, &categoryID, &row.CategoryName,
+			&row.ProductCount, &row.OnStock, &row.StockValue, &row.RetailValue,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if categoryID.Valid {
+			row.CategoryID = &categoryID.Int64
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+const stockLotSelect = `
+	SELECT
+		sl.id, sl.branch_id, sl.warehouse_id, COALESCE(b.branch_name, w.warehouse_name, ''),
+		sl.product_id, p.product_name, sl.lot_number, sl.expiry_date, sl.expiry_date - CURRENT_DATE,
+		sl.quantity, ROUND(sl.quantity * COALESCE(bp.avg_cost, wp.avg_cost, 0), 2), sl.created_at
+	FROM stock_lots sl
+	JOIN products p ON sl.product_id = p.id
+	LEFT JOIN branches b ON sl.branch_id = b.id
+	LEFT JOIN warehouses w ON sl.warehouse_id = w.id
+	LEFT JOIN branch_products bp ON bp.branch_id = sl.branch_id AND bp.product_id = sl.product_id
+	LEFT JOIN warehouse_products wp ON wp.warehouse_id = sl.warehouse_id AND wp.product_id = sl.product_id
+`
+
+func scanStockLot(row interface{ Scan(...interface{}) error }) (domain.StockLot, error) {
+	var lot domain.StockLot
+	var daysToExpiry sql.NullInt64
+	err := row.Scan(
+		&lot.ID, &lot.BranchID, &lot.WarehouseID, &lot.LocationName,
+		&lot.ProductID, &lot.ProductName, &lot.LotNumber, &lot.ExpiryDate, &daysToExpiry,
+		&lot.Quantity, &lot.StockValue, &lot.CreatedAt,
+	)
+	if daysToExpiry.Valid {
+		days := int(daysToExpiry.Int64)
+		lot.DaysToExpiry = &days
+	}
+	return lot, err
+}
+
+// GetLots lists lots with stock left, earliest expiry first. A nil branchID covers
+// every branch and warehouse; expiringBefore limits the list to lots with an
+// expiry date before that day.
+func (r *inventoryRepository) GetLots(ctx context.Context, storeID int64, branchID, productID *int64, expiringBefore *time.Time) ([]domain.StockLot, error) {
+	rows, err := r.db.QueryContext(ctx, stockLotSelect+`
+		WHERE sl.store_id = $1 AND sl.quantity > 0
+			AND ($2::BIGINT IS NULL OR sl.branch_id = $2)
+			AND ($3::BIGINT IS NULL OR sl.product_id = $3)
+			AND ($4::DATE IS NULL OR sl.expiry_date < $4)
+		ORDER BY sl.expiry_date ASC NULLS LAST, p.product_name ASC, sl.id ASC
+	`, storeID, branchID, productID, expiringBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lots := []domain.StockLot{}
+	for rows.Next() {
+		lot, err := scanStockLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+func (r *inventoryRepository) GetLotByID(ctx context.Context, storeID, lotID int64) (*domain.StockLot, error) {
+	lot, err := scanStockLot(r.db.QueryRowContext(ctx, stockLotSelect+`
+		WHERE sl.id = $1 AND sl.store_id = $2
+	`, lotID, storeID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &lot, nil
+}
+
+// WriteOffLot removes quantity from one lot with a DAMAGE movement at the lot's
+// branch or warehouse
+func (r *inventoryRepository) WriteOffLot(ctx context.Context, storeID, lotID int64, quantity int, reasonCode, reason, note *string, changedBy int64, approvedBy *int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var branchID, warehouseID sql.NullInt64
+	var productID int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT branch_id, warehouse_id, product_id FROM stock_lots
+		WHERE id = $1 AND store_id = $2
+	`, lotID, storeID).Scan(&branchID, &warehouseID, &productID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("lot not found")
+		}
+		return err
+	}
+
+	refTable := "stock_lots"
+	if branchID.Valid {
+		_, err = moveBranchStock(ctx, tx, branchStockMove{
+			StoreID:        storeID,
+			BranchID:       branchID.Int64,
+			ProductID:      productID,
+			QuantityChange: -quantity,
+			MovementType:   domain.MovementTypeDamage,
+			ReasonCode:     reasonCode,
+			Reason:         reason,
+			Note:           note,
+			ChangedBy:      &changedBy,
+			ApprovedBy:     approvedBy,
+			ReferenceTable: &refTable,
+			ReferenceID:    &lotID,
+			LotID:          &lotID,
+		})
+	} else {
+		_, err = moveWarehouseStock(ctx, tx, warehouseStockMove{
+			StoreID:        storeID,
+			WarehouseID:    warehouseID.Int64,
+			ProductID:      productID,
+			QuantityChange: -quantity,
+			MovementType:   domain.MovementTypeDamage,
+			Reason:         reason,
+			Note:           note,
+			ChangedBy:      &changedBy,
+			ReferenceTable: &refTable,
+			ReferenceID:    &lotID,
+			LotID:          &lotID,
+		})
+	}
 	if err != nil {
 		return err
 	}
 
 	return tx.Commit()
 }
+
+func (r *inventoryRepository) UpdateProductLotTracking(ctx context.Context, storeID, productID int64, trackLots bool) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE products SET track_lots = $1, updated_at = NOW()
+		WHERE id = $2 AND store_id = $3
+	`, trackLots, productID, storeID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("product not found")
+	}
+	return nil
+}
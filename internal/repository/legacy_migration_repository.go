@@ -0,0 +1,371 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mini-membership/api/internal/domain"
+)
+
+type LegacyMigrationRepository interface {
+	ListMembers(ctx context.Context) ([]domain.Member, error)
+	ProductInStore(ctx context.Context, storeID, productID int64) (bool, error)
+	MigrateMemberTx(ctx context.Context, opts *domain.LegacyMigrationOptions, member *domain.Member) (*domain.LegacyMemberResult, error)
+}
+
+type legacyMigrationRepository struct {
+	db *sqlx.DB
+}
+
+func NewLegacyMigrationRepository(db *sqlx.DB) LegacyMigrationRepository {
+	return &legacyMigrationRepository{db: db}
+}
+
+// legacyTransaction is a v1 member_point_transactions row not yet replayed
+type legacyTransaction struct {
+	ID          string
+	Action      string
+	ProductType domain.ProductType
+	Points      int
+	ReceiptText sql.NullString
+	CreatedAt   time.Time
+	StaffEmail  sql.NullString
+	Branch      sql.NullString
+}
+
+// ListMembers returns every v1 member, oldest first
+func (r *legacyMigrationRepository) ListMembers(ctx context.Context) ([]domain.Member, error) {
+	var members []domain.Member
+	err := r.db.SelectContext(ctx, &members, `
+		SELECT id, old_id, name, last4, total_points, milestone_score, points_1_0_liter, points_1_5_liter,
+			branch, status, membership_number, registration_receipt_number, welcome_bonus_claimed,
+			registered_by_staff, created_at, updated_at
+		FROM members
+		ORDER BY created_at, id
+	`)
+	return members, err
+}
+
+func (r *legacyMigrationRepository) ProductInStore(ctx context.Context, storeID, productID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND store_id = $2)
+	`, productID, storeID).Scan(&exists)
+	return exists, err
+}
+
+// MigrateMemberTx copies one v1 member into the store in a single transaction,
+// rolled back at the end of a dry run:
+//  1. maps the member to a customer, creating one or linking the customer whose
+//     code is the membership number
+//  2. replays v1 transactions not yet copied into point_transactions, keeping
+//     their dates, branch (by name) and staff (by email)
+//  3. moves each liter bucket's product points by the change in the v1 balance
+//     since the last run, booking a CORRECTION adjustment for any part of it
+//     the replayed history does not explain
+func (r *legacyMigrationRepository) MigrateMemberTx(ctx context.Context, opts *domain.LegacyMigrationOptions, member *domain.Member) (*domain.LegacyMemberResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &domain.LegacyMemberResult{
+		MemberID:         member.ID,
+		MembershipNumber: member.MembershipNumber,
+		Name:             member.Name,
+	}
+
+	// 1. Map the member to a customer
+	var mappedStoreID int64
+	var migrated1_0, migrated1_5 int
+	err = tx.QueryRowContext(ctx, `
+		SELECT store_id, customer_id, points_1_0_liter, points_1_5_liter
+		FROM legacy_member_map WHERE member_id = $1
+		FOR UPDATE
+	`, member.ID).Scan(&mappedStoreID, &result.CustomerID, &migrated1_0, &migrated1_5)
+	switch {
+	case err == nil:
+		if mappedStoreID != opts.StoreID {
+			return nil, fmt.Errorf("member was migrated to store %d", mappedStoreID)
+		}
+		// Maps written before merges re-pointed them may name a merged-away customer
+		mappedID := result.CustomerID
+		if result.CustomerID, err = resolveMergedCustomer(ctx, tx, opts.StoreID, mappedID); err != nil {
+			return nil, err
+		}
+		if result.CustomerID != mappedID {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE legacy_member_map SET customer_id = $2 WHERE member_id = $1
+			`, member.ID, result.CustomerID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		result.Action, err = r.mapCustomer(ctx, tx, opts.StoreID, member, &result.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	// 2. Replay history not copied yet
+	history, err := r.pendingTransactions(ctx, tx, member)
+	if err != nil {
+		return nil, err
+	}
+	buckets := []*domain.LegacyBucketDiff{
+		{ProductType: domain.ProductType1_0Liter, ProductID: opts.Product1_0LiterID, LegacyBalance: member.Points1_0Liter},
+		{ProductType: domain.ProductType1_5Liter, ProductID: opts.Product1_5LiterID, LegacyBalance: member.Points1_5Liter},
+	}
+	earned := make([]int, len(buckets))
+	refTable := "member_point_transactions"
+	for _, t := range history {
+		i := 0
+		if t.ProductType == domain.ProductType1_5Liter {
+			i = 1
+		}
+		change := t.Points
+		if t.Action == string(domain.ActionRedeem) {
+			change = -t.Points
+		} else {
+			earned[i] += t.Points
+		}
+		var pointTransactionID int64
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO point_transactions (
+				store_id, branch_id, customer_id, transaction_type, points_change,
+				reference_table, product_id, note, staff_id, created_at
+			) VALUES (
+				$1,
+				(SELECT id FROM branches WHERE store_id = $1 AND lower(branch_name) = lower($2) ORDER BY id LIMIT 1),
+				$3, $4, $5, $6, $7, $8,
+				(SELECT id FROM staff_accounts WHERE store_id = $1 AND email = $9::CITEXT ORDER BY id LIMIT 1),
+				$10
+			)
+			RETURNING id
+		`, opts.StoreID, t.Branch, result.CustomerID, t.Action, change,
+			refTable, buckets[i].ProductID, t.ReceiptText, t.StaffEmail, t.CreatedAt).Scan(&pointTransactionID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO legacy_point_transaction_map (legacy_transaction_id, member_id, point_transaction_id)
+			VALUES ($1, $2, $3)
+		`, t.ID, member.ID, pointTransactionID)
+		if err != nil {
+			return nil, err
+		}
+		buckets[i].HistoryRows++
+		buckets[i].HistoryAdded += change
+	}
+
+	// 3. Carry the change in each v1 balance into the product points
+	migrated := []int{migrated1_0, migrated1_5}
+	changed := len(history) > 0
+	for i, b := range buckets {
+		delta := b.LegacyBalance - migrated[i]
+		b.Correction = delta - b.HistoryAdded
+		lifetime := earned[i]
+		if b.Correction > 0 {
+			lifetime += b.Correction
+		}
+		if delta != 0 {
+			changed = true
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			SELECT COALESCE((SELECT points FROM customer_product_points
+				WHERE store_id = $1 AND customer_id = $2 AND product_id = $3), 0)
+		`, opts.StoreID, result.CustomerID, b.ProductID).Scan(&b.BalanceBefore)
+		if err != nil {
+			return nil, err
+		}
+		b.BalanceAfter, err = r.moveProductPoints(ctx, tx, opts.StoreID, result.CustomerID, b.ProductID, delta, lifetime, b.BalanceBefore)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.ProductType, err)
+		}
+
+		if b.Correction != 0 {
+			reason := string(domain.PointAdjustCorrection)
+			note := "Legacy v1 balance carried over"
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO point_transactions (
+					store_id, customer_id, transaction_type, points_change,
+					reference_table, product_id, note, reason_code
+				) VALUES ($1, $2, 'ADJUST', $3, 'legacy_member_map', $4, $5, $6)
+			`, opts.StoreID, result.CustomerID, b.Correction, b.ProductID, note, reason)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result.Buckets = append(result.Buckets, *b)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE legacy_member_map SET points_1_0_liter = $2, points_1_5_liter = $3 WHERE member_id = $1
+	`, member.ID, member.Points1_0Liter, member.Points1_5Liter)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.Action == "" {
+		result.Action = domain.LegacyMemberUnchanged
+		if changed {
+			result.Action = domain.LegacyMemberUpdated
+		}
+	}
+	if changed {
+		if _, _, err := evaluateCustomerTier(ctx, tx, opts.StoreID, result.CustomerID, domain.TierChangeReasonEvaluation); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.DryRun {
+		return result, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// mapCustomer links the member to the store's customer whose code is the
+// membership number, or creates a customer for them, and records the mapping.
+// A customer merged into another links the member to the survivor instead.
+func (r *legacyMigrationRepository) mapCustomer(ctx context.Context, tx *sql.Tx, storeID int64, member *domain.Member, customerID *int64) (domain.LegacyMemberAction, error) {
+	code := member.ID.String()
+	if member.MembershipNumber != nil && strings.TrimSpace(*member.MembershipNumber) != "" {
+		code = strings.TrimSpace(*member.MembershipNumber)
+	}
+
+	action := domain.LegacyMemberLinked
+	err := tx.QueryRowContext(ctx, `
+		SELECT id FROM customers WHERE store_id = $1 AND customer_code = $2
+	`, storeID, code).Scan(customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		action = domain.LegacyMemberCreated
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO customers (store_id, customer_code, full_name, phone_last4, is_active, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, storeID, code, member.Name, member.Last4, member.Status == "active", member.CreatedAt).Scan(customerID)
+	} else if err == nil {
+		*customerID, err = resolveMergedCustomer(ctx, tx, storeID, *customerID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO legacy_member_map (member_id, store_id, customer_id) VALUES ($1, $2, $3)
+	`, member.ID, storeID, *customerID)
+	if err != nil {
+		return "", err
+	}
+	return action, nil
+}
+
+// resolveMergedCustomer follows merged_into_id from the customer to the one
+// that absorbed it, returning customerID itself when it was never merged
+func resolveMergedCustomer(ctx context.Context, tx *sql.Tx, storeID, customerID int64) (int64, error) {
+	var survivorID int64
+	err := tx.QueryRowContext(ctx, `
+		WITH RECURSIVE chain AS (
+			SELECT id, merged_into_id, 0 AS depth FROM customers WHERE id = $1 AND store_id = $2
+			UNION ALL
+			SELECT c.id, c.merged_into_id, chain.depth + 1
+			FROM customers c
+			JOIN chain ON c.id = chain.merged_into_id
+			WHERE c.store_id = $2 AND chain.depth < 16
+		)
+		SELECT id FROM chain WHERE merged_into_id IS NULL
+	`, customerID, storeID).Scan(&survivorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("customer %d was merged into a customer that cannot be found", customerID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return survivorID, nil
+}
+
+// pendingTransactions returns the member's v1 transactions not yet replayed, oldest first
+func (r *legacyMigrationRepository) pendingTransactions(ctx context.Context, tx *sql.Tx, member *domain.Member) ([]legacyTransaction, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT t.id, t.action, t.product_type, t.points, t.receipt_text, t.created_at, su.email, su.branch
+		FROM member_point_transactions t
+		LEFT JOIN staff_users su ON su.id = t.staff_user_id
+		LEFT JOIN legacy_point_transaction_map m ON m.legacy_transaction_id = t.id
+		WHERE t.member_id = $1 AND m.legacy_transaction_id IS NULL
+		ORDER BY t.created_at, t.id
+	`, member.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []legacyTransaction
+	for rows.Next() {
+		var t legacyTransaction
+		if err := rows.Scan(&t.ID, &t.Action, &t.ProductType, &t.Points, &t.ReceiptText,
+			&t.CreatedAt, &t.StaffEmail, &t.Branch); err != nil {
+			return nil, err
+		}
+		history = append(history, t)
+	}
+	return history, rows.Err()
+}
+
+// moveProductPoints changes the customer's points for the product by delta and
+// the lifetime total by lifetime, returning the new balance. Added points open
+// an earn lot; removed points come out of the oldest lots and fail when the v2
+// balance has already been spent below them.
+func (r *legacyMigrationRepository) moveProductPoints(ctx context.Context, tx *sql.Tx, storeID, customerID, productID int64, delta, lifetime, balance int) (int, error) {
+	if delta < 0 {
+		err := tx.QueryRowContext(ctx, `
+			UPDATE customer_product_points
+			SET points = points + $4, total_points = total_points + $5, updated_at = NOW()
+			WHERE store_id = $1 AND customer_id = $2 AND product_id = $3 AND points >= $6
+			RETURNING points
+		`, storeID, customerID, productID, delta, lifetime, -delta).Scan(&balance)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("v1 balance dropped by %d but only %d points remain in v2", -delta, balance)
+		}
+		if err != nil {
+			return 0, err
+		}
+		return balance, consumePointLots(ctx, tx, storeID, customerID, productID, -delta, nil, nil, nil)
+	}
+	if delta == 0 && lifetime == 0 {
+		return balance, nil
+	}
+
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO customer_product_points (store_id, customer_id, product_id, points, total_points)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (store_id, customer_id, product_id)
+		DO UPDATE SET
+			points = customer_product_points.points + $4,
+			total_points = customer_product_points.total_points + $5,
+			updated_at = NOW()
+		RETURNING points
+	`, storeID, customerID, productID, delta, lifetime).Scan(&balance)
+	if err != nil {
+		return 0, err
+	}
+	if delta > 0 {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO point_earn_lots (store_id, customer_id, product_id, points_earned, points_remaining, reference_table)
+			VALUES ($1, $2, $3, $4, $4, 'legacy_member_map')
+		`, storeID, customerID, productID, delta)
+	}
+	return balance, err
+}
@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mini-membership/api/internal/domain"
+)
+
+type MembershipTierRepository interface {
+	List(ctx context.Context, storeID int64, activeOnly bool) ([]domain.MembershipTier, error)
+	GetByID(ctx context.Context, storeID, tierID int64) (*domain.MembershipTier, error)
+	Create(ctx context.Context, storeID int64, req *domain.MembershipTierRequest) (int64, error)
+	Update(ctx context.Context, storeID, tierID int64, req *domain.MembershipTierRequest) error
+	Delete(ctx context.Context, storeID, tierID int64) error
+	GetCustomerTier(ctx context.Context, storeID, customerID int64) (*domain.CustomerTier, error)
+	GetTierHistory(ctx context.Context, storeID, customerID int64, limit, offset int) ([]domain.TierChange, int, error)
+	EvaluateTiers(ctx context.Context, storeID *int64) (*domain.EvaluateTiersResult, error)
+}
+
+type membershipTierRepository struct {
+	db *sqlx.DB
+}
+
+func NewMembershipTierRepository(db *sqlx.DB) MembershipTierRepository {
+	return &membershipTierRepository{db: db}
+}
+
+const membershipTierSelect = `
+	SELECT id, tier_code, tier_name, min_points, earn_multiplier::FLOAT8, is_active, created_at, updated_at
+	FROM membership_tiers
+`
+
+func scanMembershipTier(scan func(dest ...interface{}) error) (domain.MembershipTier, error) {
+	var tier domain.MembershipTier
+	err := scan(
+		&tier.ID, &tier.TierCode, &tier.TierName, &tier.MinPoints, &tier.EarnMultiplier,
+		&tier.IsActive, &tier.CreatedAt, &tier.UpdatedAt,
+	)
+	return tier, err
+}
+
+// List returns the store's tiers, lowest first
+func (r *membershipTierRepository) List(ctx context.Context, storeID int64, activeOnly bool) ([]domain.MembershipTier, error) {
+	rows, err := r.db.QueryContext(ctx, membershipTierSelect+`
+		WHERE store_id = $1 AND ($2 = false OR is_active = true)
+		ORDER BY min_points, id
+	`, storeID, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tiers := []domain.MembershipTier{}
+	for rows.Next() {
+		tier, err := scanMembershipTier(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		tiers = append(tiers, tier)
+	}
+	return tiers, rows.Err()
+}
+
+func (r *membershipTierRepository) GetByID(ctx context.Context, storeID, tierID int64) (*domain.MembershipTier, error) {
+	tier, err := scanMembershipTier(r.db.QueryRowContext(ctx, membershipTierSelect+`
+		WHERE store_id = $1 AND id = $2
+	`, storeID, tierID).Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tier, nil
+}
+
+func (r *membershipTierRepository) Create(ctx context.Context, storeID int64, req *domain.MembershipTierRequest) (int64, error) {
+	isActive := req.IsActive == nil || *req.IsActive
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO membership_tiers (store_id, tier_code, tier_name, min_points, earn_multiplier, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, storeID, req.TierCode, req.TierName, req.MinPoints, req.EarnMultiplier, isActive).Scan(&id)
+	return id, err
+}
+
+// Update replaces every field of the tier; IsActive keeps its current value when omitted
+func (r *membershipTierRepository) Update(ctx context.Context, storeID, tierID int64, req *domain.MembershipTierRequest) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE membership_tiers SET
+			tier_code = $3, tier_name = $4, min_points = $5, earn_multiplier = $6,
+			is_active = COALESCE($7, is_active)
+		WHERE store_id = $1 AND id = $2
+	`, storeID, tierID, req.TierCode, req.TierName, req.MinPoints, req.EarnMultiplier, req.IsActive)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("tier not found")
+	}
+	return nil
+}
+
+// Delete removes the tier; customers holding it have no tier until they are
+// next evaluated
+func (r *membershipTierRepository) Delete(ctx context.Context, storeID, tierID int64) error {
+	result, err := r.db.ExecContext(ctx, `
+		DELETE FROM membership_tiers WHERE store_id = $1 AND id = $2
+	`, storeID, tierID)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return errors.New("tier not found")
+	}
+	return nil
+}
+
+// GetCustomerTier returns the customer's current tier, nil when they have none
+func (r *membershipTierRepository) GetCustomerTier(ctx context.Context, storeID, customerID int64) (*domain.CustomerTier, error) {
+	var tier domain.CustomerTier
+	err := r.db.QueryRowContext(ctx, `
+		SELECT t.id, t.tier_code, t.tier_name, t.earn_multiplier::FLOAT8
+		FROM customers c
+		JOIN membership_tiers t ON t.id = c.tier_id
+		WHERE c.store_id = $1 AND c.id = $2
+	`, storeID, customerID).Scan(&tier.TierID, &tier.TierCode, &tier.TierName, &tier.EarnMultiplier)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tier, nil
+}
+
+// GetTierHistory returns the customer's tier changes, newest first
+func (r *membershipTierRepository) GetTierHistory(ctx context.Context, storeID, customerID int64, limit, offset int) ([]domain.TierChange, int, error) {
+	var total int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM customer_tier_changes WHERE store_id = $1 AND customer_id = $2
+	`, storeID, customerID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, customer_id, from_tier_code, to_tier_code, qualifying_points, reason, created_at
+		FROM customer_tier_changes
+		WHERE store_id = $1 AND customer_id = $2
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, storeID, customerID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	history := []domain.TierChange{}
+	for rows.Next() {
+		var change domain.TierChange
+		if err := rows.Scan(
+			&change.ID, &change.CustomerID, &change.FromTierCode, &change.ToTierCode,
+			&change.QualifyingPoints, &change.Reason, &change.CreatedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+		history = append(history, change)
+	}
+	return history, total, rows.Err()
+}
+
+// EvaluateTiers re-evaluates every active customer of the store, or of every
+// store with tiers when storeID is nil. Run it regularly when tiers use a
+// rolling window, so customers drop a tier once old points leave the window.
+func (r *membershipTierRepository) EvaluateTiers(ctx context.Context, storeID *int64) (*domain.EvaluateTiersResult, error) {
+	type customerRef struct {
+		StoreID    int64 `db:"store_id"`
+		CustomerID int64 `db:"id"`
+	}
+	var customers []customerRef
+	err := r.db.SelectContext(ctx, &customers, `
+		SELECT c.store_id, c.id
+		FROM customers c
+		WHERE c.is_active = true
+			AND ($1::BIGINT IS NULL OR c.store_id = $1)
+			AND (c.tier_id IS NOT NULL OR EXISTS (SELECT 1 FROM membership_tiers t WHERE t.store_id = c.store_id))
+		ORDER BY c.store_id, c.id
+	`, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.EvaluateTiersResult{RunAt: time.Now()}
+	for _, c := range customers {
+		changed, err := r.evaluateCustomer(ctx, c.StoreID, c.CustomerID)
+		if err != nil {
+			return nil, fmt.Errorf("evaluate tier for customer %d: %w", c.CustomerID, err)
+		}
+		result.CustomersEvaluated++
+		if changed {
+			result.TiersChanged++
+		}
+	}
+	return result, nil
+}
+
+func (r *membershipTierRepository) evaluateCustomer(ctx context.Context, storeID, customerID int64) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, changed, err := evaluateCustomerTier(ctx, tx, storeID, customerID, domain.TierChangeReasonEvaluation)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// promotionTierOpenSQL is true when the promotion p is open to the customer
+// bound to customerParam: the promotion has no tier, or the customer's tier has
+// at least the min_points of the promotion's tier
+func promotionTierOpenSQL(customerParam string) string {
+	return `(p.tier_code IS NULL OR EXISTS (
+		SELECT 1 FROM customers cu
+		JOIN membership_tiers ct ON ct.id = cu.tier_id
+		JOIN membership_tiers rt ON rt.store_id = p.store_id AND rt.tier_code = p.tier_code
+		WHERE cu.id = ` + customerParam + ` AND ct.min_points >= rt.min_points
+	))`
+}
+
+// evaluateCustomerTier moves the customer to the tier their qualifying points
+// reach, recording a tier change when it differs from their current tier. It
+// returns the customer's tier afterwards (nil for none) and whether it changed.
+// Qualifying points are lifetime stamp and wallet points unless the store sets
+// a tier window; within a window, points tied to cancelled orders don't count.
+func evaluateCustomerTier(ctx context.Context, q txQuerier, storeID, customerID int64, reason domain.TierChangeReason) (*domain.CustomerTier, bool, error) {
+	var currentID sql.NullInt64
+	var currentCode sql.NullString
+	var windowMonths sql.NullInt64
+	err := q.QueryRowContext(ctx, `
+		SELECT c.tier_id, t.tier_code, ss.tier_window_months
+		FROM customers c
+		LEFT JOIN membership_tiers t ON t.id = c.tier_id
+		LEFT JOIN store_settings ss ON ss.store_id = c.store_id
+		WHERE c.id = $1 AND c.store_id = $2
+		FOR UPDATE OF c
+	`, customerID, storeID).Scan(&currentID, &currentCode, &windowMonths)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, errors.New("customer not found")
+		}
+		return nil, false, err
+	}
+
+	var qualifying int
+	if windowMonths.Valid {
+		err = q.QueryRowContext(ctx, `
+			SELECT
+				(SELECT COALESCE(SUM(pt.points_change), 0) FROM point_transactions pt
+				WHERE pt.store_id = $1 AND pt.customer_id = $2
+					AND pt.transaction_type IN ('EARN', 'ADJUST') AND pt.points_change > 0
+					AND pt.created_at >= NOW() - make_interval(months => $3)
+					AND NOT EXISTS (SELECT 1 FROM orders o WHERE pt.reference_table = 'orders'
+						AND o.id = pt.reference_id AND o.status = 'CANCELLED'))
+				+ (SELECT COALESCE(SUM(wt.points_change), 0) FROM wallet_transactions wt
+				WHERE wt.store_id = $1 AND wt.customer_id = $2
+					AND wt.transaction_type IN ('EARN', 'ADJUST') AND wt.points_change > 0
+					AND wt.created_at >= NOW() - make_interval(months => $3)
+					AND NOT EXISTS (SELECT 1 FROM orders o WHERE wt.reference_table = 'orders'
+						AND o.id = wt.reference_id AND o.status = 'CANCELLED'))
+		`, storeID, customerID, windowMonths.Int64).Scan(&qualifying)
+	} else {
+		err = q.QueryRowContext(ctx, `
+			SELECT
+				(SELECT COALESCE(SUM(total_points), 0) FROM customer_product_points
+				WHERE store_id = $1 AND customer_id = $2)
+				+ (SELECT COALESCE(SUM(lifetime_earned), 0) FROM customer_wallets
+				WHERE store_id = $1 AND customer_id = $2)
+		`, storeID, customerID).Scan(&qualifying)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var tier *domain.CustomerTier
+	var next domain.CustomerTier
+	err = q.QueryRowContext(ctx, `
+		SELECT id, tier_code, tier_name, earn_multiplier::FLOAT8
+		FROM membership_tiers
+		WHERE store_id = $1 AND is_active = true AND min_points <= $2
+		ORDER BY min_points DESC, id
+		LIMIT 1
+	`, storeID, qualifying).Scan(&next.TierID, &next.TierCode, &next.TierName, &next.EarnMultiplier)
+	switch {
+	case err == nil:
+		tier = &next
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, false, err
+	}
+
+	if (tier == nil && !currentID.Valid) || (tier != nil && currentID.Valid && tier.TierID == currentID.Int64) {
+		return tier, false, nil
+	}
+
+	var tierID *int64
+	var toCode *string
+	if tier != nil {
+		tierID, toCode = &tier.TierID, &tier.TierCode
+	}
+	_, err = q.ExecContext(ctx, `
+		UPDATE customers SET tier_id = $1, tier_updated_at = NOW() WHERE id = $2
+	`, tierID, customerID)
+	if err != nil {
+		return nil, false, err
+	}
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO customer_tier_changes (store_id, customer_id, from_tier_code, to_tier_code, qualifying_points, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, storeID, customerID, currentCode, toCode, qualifying, reason)
+	if err != nil {
+		return nil, false, err
+	}
+	return tier, true, nil
+}
@@ -3,10 +3,12 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/mini-membership/api/internal/domain"
 	"github.com/shopspring/decimal"
 )
 
@@ -29,10 +31,14 @@ type BranchProductInfo struct {
 }
 
 type CustomerSearchResult struct {
-	ID           int64          `db:"id"`
-	CustomerCode sql.NullString `db:"customer_code"`
-	FullName     sql.NullString `db:"full_name"`
-	PhoneLast4   sql.NullString `db:"phone_last4"`
+	ID             int64           `db:"id"`
+	CustomerCode   sql.NullString  `db:"customer_code"`
+	FullName       sql.NullString  `db:"full_name"`
+	PhoneLast4     sql.NullString  `db:"phone_last4"`
+	TierID         sql.NullInt64   `db:"tier_id"`
+	TierCode       sql.NullString  `db:"tier_code"`
+	TierName       sql.NullString  `db:"tier_name"`
+	EarnMultiplier sql.NullFloat64 `db:"earn_multiplier"`
 }
 
 type OrderCreate struct {
@@ -48,6 +54,24 @@ type OrderCreate struct {
 	ChangeAmount  decimal.Decimal
 	Payments      []PaymentCreate
 	PromotionID   *int64
+	// Points the customer earns, one entry per product
+	Points []OrderPointsCreate
+	// Wallet is set when the order spends or earns wallet points
+	Wallet *OrderWalletCreate
+}
+
+// OrderWalletCreate is the wallet points an order spends and earns. A PAYMENT
+// redemption is also one of the order's WALLET payments.
+type OrderWalletCreate struct {
+	RedeemPoints int
+	RedeemAmount decimal.Decimal
+	RedeemedAs   string
+	EarnPoints   int
+}
+
+type OrderPointsCreate struct {
+	ProductID int64
+	Points    int
 }
 
 type OrderItemCreate struct {
@@ -62,9 +86,39 @@ type PaymentCreate struct {
 }
 
 type OrderResult struct {
-	OrderID   int64
-	Status    string
-	CreatedAt time.Time
+	OrderID       int64
+	Status        string
+	CreatedAt     time.Time
+	StockWarnings []StockWarning
+	Points        []OrderPointsResult
+	// Tier is the customer's tier after the order, evaluated when they earned points
+	Tier        *domain.CustomerTier
+	TierChanged bool
+	Wallet      *OrderWalletResult
+}
+
+// OrderWalletResult is the wallet points an order spent and earned and the
+// customer's new wallet balance
+type OrderWalletResult struct {
+	PointsRedeemed int
+	PointsEarned   int
+	Balance        int
+}
+
+// OrderPointsResult is the points earned for a product and the customer's new balance
+type OrderPointsResult struct {
+	ProductID int64
+	Points    int
+	Balance   int
+}
+
+// StockWarning records an item sold below zero under ALLOW_WITH_WARNING
+type StockWarning struct {
+	ProductID   int64
+	ProductName string
+	Quantity    int
+	FromStock   int
+	ToStock     int
 }
 
 type OrderWithItems struct {
@@ -122,10 +176,12 @@ func (r *orderRepository) GetProductsByBranch(ctx context.Context, storeID, bran
 func (r *orderRepository) SearchCustomersByLast4(ctx context.Context, storeID int64, last4 string) ([]CustomerSearchResult, error) {
 	var customers []CustomerSearchResult
 	query := `
-		SELECT id, customer_code, full_name, phone_last4
-		FROM customers
-		WHERE store_id = $1 AND phone_last4 = $2 AND is_active = true
-		ORDER BY full_name
+		SELECT c.id, c.customer_code, c.full_name, c.phone_last4,
+			t.id AS tier_id, t.tier_code, t.tier_name, t.earn_multiplier::FLOAT8 AS earn_multiplier
+		FROM customers c
+		LEFT JOIN membership_tiers t ON t.id = c.tier_id
+		WHERE c.store_id = $1 AND c.phone_last4 = $2 AND c.is_active = true
+		ORDER BY c.full_name
 	`
 
 	err := r.db.SelectContext(ctx, &customers, query, storeID, last4)
@@ -143,6 +199,34 @@ func (r *orderRepository) CreateOrderTx(ctx context.Context, order *OrderCreate)
 	defer tx.Rollback()
 
 	now := time.Now()
+	var stockWarnings []StockWarning
+
+	if order.CustomerID != nil {
+		var customerExists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1 AND store_id = $2)`, *order.CustomerID, order.StoreID).Scan(&customerExists)
+		if err != nil {
+			return nil, err
+		}
+		if !customerExists {
+			return nil, errors.New("customer not found")
+		}
+	}
+
+	if order.PromotionID != nil {
+		var open bool
+		err = tx.QueryRowContext(ctx, `
+			SELECT `+promotionTierOpenSQL("$3")+` FROM promotions p WHERE p.id = $1 AND p.store_id = $2
+		`, *order.PromotionID, order.StoreID, order.CustomerID).Scan(&open)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, errors.New("promotion not found")
+			}
+			return nil, err
+		}
+		if !open {
+			return nil, errors.New("promotion is only for members of a higher tier")
+		}
+	}
 
 	// 1. Create order
 	orderQuery := `
@@ -168,55 +252,60 @@ func (r *orderRepository) CreateOrderTx(ctx context.Context, order *OrderCreate)
 	}
 
 	// 2. Create order items and deduct stock
+	refTable := "orders"
 	for _, item := range order.Items {
-		// Get current stock
-		var currentStock int
-		var branchProductID int64
-		stockQuery := `SELECT id, on_stock FROM branch_products WHERE store_id = $1 AND branch_id = $2 AND product_id = $3 FOR UPDATE`
-		err = tx.QueryRowContext(ctx, stockQuery, order.StoreID, order.BranchID, item.ProductID).Scan(&branchProductID, &currentStock)
-		if err == sql.ErrNoRows {
-			// Create branch_product if not exists
-			insertBPQuery := `
-				INSERT INTO branch_products (store_id, branch_id, product_id, on_stock, is_active, created_at, updated_at)
-				VALUES ($1, $2, $3, 0, true, $4, $4)
-				RETURNING id, on_stock
+		// The sale is booked whatever the stock; a negative result is then
+		// checked against the product override first, then the store policy
+		moved, err := moveBranchStock(ctx, tx, branchStockMove{
+			StoreID:        order.StoreID,
+			BranchID:       order.BranchID,
+			ProductID:      item.ProductID,
+			QuantityChange: -item.Quantity,
+			MovementType:   domain.MovementTypeSale,
+			ChangedBy:      &order.StaffID,
+			ReferenceTable: &refTable,
+			ReferenceID:    &orderID,
+			AllowNegative:  true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if moved.ToStock < 0 {
+			var productName string
+			var policy domain.NegativeStockPolicy
+			policyQuery := `
+				SELECT p.product_name, COALESCE(p.negative_stock_policy, ss.negative_stock_policy, $3)
+				FROM products p
+				LEFT JOIN store_settings ss ON ss.store_id = p.store_id
+				WHERE p.id = $1 AND p.store_id = $2
 			`
-			err = tx.QueryRowContext(ctx, insertBPQuery, order.StoreID, order.BranchID, item.ProductID, now).Scan(&branchProductID, &currentStock)
+			err = tx.QueryRowContext(ctx, policyQuery, item.ProductID, order.StoreID, domain.NegativeStockPolicyAllowWithWarning).Scan(&productName, &policy)
 			if err != nil {
 				return nil, err
 			}
-		} else if err != nil {
-			return nil, err
-		}
 
-		newStock := currentStock - item.Quantity
-		if newStock < 0 {
-			newStock = 0
+			switch policy {
+			case domain.NegativeStockPolicyBlock:
+				return nil, fmt.Errorf("%w: %s has %d on stock, order needs %d", ErrInsufficientStock, productName, moved.FromStock, item.Quantity)
+			case domain.NegativeStockPolicyAllowWithWarning:
+				stockWarnings = append(stockWarnings, StockWarning{
+					ProductID:   item.ProductID,
+					ProductName: productName,
+					Quantity:    item.Quantity,
+					FromStock:   moved.FromStock,
+					ToStock:     moved.ToStock,
+				})
+			}
 		}
 
-		// Insert order item
+		// Insert order item with its cost of goods at the average cost the sale was booked at
+		costTotal := moved.UnitCost.Mul(decimal.NewFromInt(int64(item.Quantity))).Round(2)
 		itemQuery := `
-			INSERT INTO order_items (order_id, product_id, quantity, price, from_stock_count, to_stock_count, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
-		`
-		_, err = tx.ExecContext(ctx, itemQuery, orderID, item.ProductID, item.Quantity, item.Price, currentStock, newStock, now)
-		if err != nil {
-			return nil, err
-		}
-
-		// Update stock
-		updateStockQuery := `UPDATE branch_products SET on_stock = $1, updated_at = $2 WHERE id = $3`
-		_, err = tx.ExecContext(ctx, updateStockQuery, newStock, now, branchProductID)
-		if err != nil {
-			return nil, err
-		}
-
-		// Log inventory movement
-		movementQuery := `
-			INSERT INTO inventory_movements (store_id, branch_id, product_id, movement_type, quantity_change, from_stock_count, to_stock_count, changed_by, reference_table, reference_id, created_at)
-			VALUES ($1, $2, $3, 'SALE', $4, $5, $6, $7, 'orders', $8, $9)
+			INSERT INTO order_items (order_id, product_id, quantity, price, unit_cost, cost_total, from_stock_count, to_stock_count, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $9)
 		`
-		_, err = tx.ExecContext(ctx, movementQuery, order.StoreID, order.BranchID, item.ProductID, -item.Quantity, currentStock, newStock, order.StaffID, orderID, now)
+		_, err = tx.ExecContext(ctx, itemQuery, orderID, item.ProductID, item.Quantity, item.Price, moved.UnitCost, costTotal, moved.FromStock, moved.ToStock, now)
 		if err != nil {
 			return nil, err
 		}
@@ -259,14 +348,96 @@ func (r *orderRepository) CreateOrderTx(ctx context.Context, order *OrderCreate)
 		}
 	}
 
+	// 6. Earn points for the customer
+	var points []OrderPointsResult
+	if order.CustomerID != nil {
+		refTable := "orders"
+		staffID := order.StaffID
+		for _, p := range order.Points {
+			balance, err := changeProductPoints(ctx, tx, pointChange{
+				StoreID:         order.StoreID,
+				BranchID:        &order.BranchID,
+				CustomerID:      *order.CustomerID,
+				ProductID:       p.ProductID,
+				PointsChange:    p.Points,
+				TransactionType: "EARN",
+				ReferenceTable:  &refTable,
+				ReferenceID:     &orderID,
+				StaffID:         &staffID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			points = append(points, OrderPointsResult{ProductID: p.ProductID, Points: p.Points, Balance: balance})
+		}
+	}
+
+	// 7. Spend and earn wallet points; redeeming first means points earned on
+	// this order cannot pay for it
+	var wallet *OrderWalletResult
+	if order.CustomerID != nil && order.Wallet != nil {
+		refTable := "orders"
+		staffID := order.StaffID
+		wallet = &OrderWalletResult{}
+		if order.Wallet.RedeemPoints > 0 {
+			wallet.Balance, err = changeWallet(ctx, tx, walletChange{
+				StoreID:         order.StoreID,
+				BranchID:        &order.BranchID,
+				CustomerID:      *order.CustomerID,
+				PointsChange:    -order.Wallet.RedeemPoints,
+				TransactionType: "REDEEM",
+				Amount:          &order.Wallet.RedeemAmount,
+				RedeemedAs:      &order.Wallet.RedeemedAs,
+				ReferenceTable:  &refTable,
+				ReferenceID:     &orderID,
+				StaffID:         &staffID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			wallet.PointsRedeemed = order.Wallet.RedeemPoints
+		}
+		if order.Wallet.EarnPoints > 0 {
+			wallet.Balance, err = changeWallet(ctx, tx, walletChange{
+				StoreID:         order.StoreID,
+				BranchID:        &order.BranchID,
+				CustomerID:      *order.CustomerID,
+				PointsChange:    order.Wallet.EarnPoints,
+				TransactionType: "EARN",
+				ReferenceTable:  &refTable,
+				ReferenceID:     &orderID,
+				StaffID:         &staffID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			wallet.PointsEarned = order.Wallet.EarnPoints
+		}
+	}
+
+	// 8. Move the customer to the tier their points now reach
+	var tier *domain.CustomerTier
+	var tierChanged bool
+	if order.CustomerID != nil && (len(points) > 0 || (wallet != nil && wallet.PointsEarned > 0)) {
+		tier, tierChanged, err = evaluateCustomerTier(ctx, tx, order.StoreID, *order.CustomerID, domain.TierChangeReasonOrder)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
 	return &OrderResult{
-		OrderID:   orderID,
-		Status:    "PAID",
-		CreatedAt: now,
+		OrderID:       orderID,
+		Status:        "PAID",
+		CreatedAt:     now,
+		StockWarnings: stockWarnings,
+		Points:        points,
+		Tier:          tier,
+		TierChanged:   tierChanged,
+		Wallet:        wallet,
 	}, nil
 }
 
@@ -344,26 +515,142 @@ func (r *orderRepository) getOrderItems(ctx context.Context, orderID int64) ([]O
 	return items, nil
 }
 
+// CancelOrder marks a paid order cancelled and, in the same transaction, takes
+// back the stamp and wallet points the order earned, refunds the wallet points
+// it spent and re-evaluates the customer's tier. It fails with
+// ErrInsufficientPoints when the customer has already spent the points earned.
 func (r *orderRepository) CancelOrder(ctx context.Context, storeID, orderID int64, reason string, cancelledBy *int64) error {
-	query := `
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var branchID int64
+	var customerID sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+		SELECT branch_id, customer_id FROM orders
+		WHERE id = $1 AND store_id = $2 AND status = 'PAID'
+		FOR UPDATE
+	`, orderID, storeID).Scan(&branchID, &customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("order not found or already cancelled")
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
 		UPDATE orders 
 		SET status = 'CANCELLED', 
 			cancel_reason = $1, 
 			cancelled_by = $2, 
 			cancelled_at = NOW(),
 			updated_at = NOW()
-		WHERE id = $3 AND store_id = $4 AND status = 'PAID'
-	`
-	result, err := r.db.ExecContext(ctx, query, reason, cancelledBy, orderID, storeID)
+		WHERE id = $3
+	`, reason, cancelledBy, orderID)
 	if err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
+
+	if customerID.Valid {
+		reversed, err := reverseOrderPoints(ctx, tx, storeID, branchID, customerID.Int64, orderID, cancelledBy)
+		if err != nil {
+			return err
+		}
+		if reversed {
+			if _, _, err := evaluateCustomerTier(ctx, tx, storeID, customerID.Int64, domain.TierChangeReasonOrder); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// reverseOrderPoints writes an offsetting ADJUST for every wallet and stamp
+// point transaction recorded against the order, refunding spent points before
+// taking back earned ones. It reports whether anything was reversed.
+func reverseOrderPoints(ctx context.Context, tx *sqlx.Tx, storeID, branchID, customerID, orderID int64, staffID *int64) (bool, error) {
+	refTable := "orders"
+	note := fmt.Sprintf("Order #%d cancelled", orderID)
+
+	var walletChanges []int
+	err := tx.SelectContext(ctx, &walletChanges, `
+		SELECT points_change FROM wallet_transactions
+		WHERE store_id = $1 AND customer_id = $2 AND reference_table = 'orders' AND reference_id = $3
+		ORDER BY points_change, id
+	`, storeID, customerID, orderID)
 	if err != nil {
-		return err
+		return false, err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("order not found or already cancelled")
+
+	var pointChanges []struct {
+		ID           int64 `db:"id"`
+		ProductID    int64 `db:"product_id"`
+		PointsChange int   `db:"points_change"`
+	}
+	err = tx.SelectContext(ctx, &pointChanges, `
+		SELECT id, product_id, points_change FROM point_transactions
+		WHERE store_id = $1 AND customer_id = $2 AND reference_table = 'orders' AND reference_id = $3
+			AND product_id IS NOT NULL
+		ORDER BY points_change, id
+	`, storeID, customerID, orderID)
+	if err != nil {
+		return false, err
 	}
-	return nil
+
+	for _, change := range walletChanges {
+		if change == 0 {
+			continue
+		}
+		_, err := changeWallet(ctx, tx, walletChange{
+			StoreID:         storeID,
+			BranchID:        &branchID,
+			CustomerID:      customerID,
+			PointsChange:    -change,
+			TransactionType: "ADJUST",
+			ReferenceTable:  &refTable,
+			ReferenceID:     &orderID,
+			Note:            &note,
+			StaffID:         staffID,
+			Restore:         change < 0,
+			Revoke:          change > 0,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	reasonCode := "CORRECTION"
+	for _, p := range pointChanges {
+		if p.PointsChange == 0 {
+			continue
+		}
+		var returnLotsOf *int64
+		if p.PointsChange < 0 {
+			returnLotsOf = &p.ID
+		}
+		_, err := changeProductPoints(ctx, tx, pointChange{
+			StoreID:         storeID,
+			BranchID:        &branchID,
+			CustomerID:      customerID,
+			ProductID:       p.ProductID,
+			PointsChange:    -p.PointsChange,
+			TransactionType: "ADJUST",
+			ReferenceTable:  &refTable,
+			ReferenceID:     &orderID,
+			Note:            &note,
+			ReasonCode:      &reasonCode,
+			StaffID:         staffID,
+			Restore:         p.PointsChange < 0,
+			ReturnLotsOf:    returnLotsOf,
+			Revoke:          p.PointsChange > 0,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return len(walletChanges) > 0 || len(pointChanges) > 0, nil
 }
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrInsufficientPoints is returned when a point change would take a customer's
+// product points below zero
+var ErrInsufficientPoints = errors.New("insufficient points")
+
+// pointLotExpirySQL is the expiry time of the earn lot l under the store settings
+// ss, NULL when points do not expire. Year ends are taken in Thai local time.
+const pointLotExpirySQL = `
+	CASE ss.points_expiry_mode
+		WHEN 'FIXED_PERIOD' THEN l.earned_at + make_interval(months => ss.points_expiry_months)
+		WHEN 'END_OF_YEAR' THEN (date_trunc('year', l.earned_at AT TIME ZONE 'Asia/Bangkok') + INTERVAL '1 year') AT TIME ZONE 'Asia/Bangkok'
+	END`
+
+// pointChange describes a single change to a customer's points for one product.
+// A positive change also adds to the lifetime total and opens an earn lot; a
+// negative change consumes the oldest lots first, recording what it drew from
+// each, and fails with ErrInsufficientPoints rather than going below zero.
+// BranchID is nil for store-level changes such as expiry. Restore marks a
+// positive change that gives back points deducted earlier, which leaves the
+// lifetime total alone; with ReturnLotsOf set to the deducting transaction the
+// points go back into the lots it drew from and keep their expiry. Revoke
+// marks a negative change that takes back points earned earlier, which lowers
+// the lifetime total too and consumes the lot opened for the same reference
+// first. ReasonCode is set on manual adjustments.
+type pointChange struct {
+	StoreID         int64
+	BranchID        *int64
+	CustomerID      int64
+	ProductID       int64
+	PointsChange    int
+	TransactionType string
+	ReferenceTable  *string
+	ReferenceID     *int64
+	Note            *string
+	ReasonCode      *string
+	StaffID         *int64
+	Restore         bool
+	ReturnLotsOf    *int64
+	Revoke          bool
+}
+
+// changeProductPoints applies the change to customer_product_points, records the
+// point transaction and returns the customer's new balance for the product
+func changeProductPoints(ctx context.Context, q txQuerier, c pointChange) (int, error) {
+	var balance int
+	var err error
+	if c.PointsChange >= 0 {
+		lifetimeChange := c.PointsChange
+		if c.Restore {
+			lifetimeChange = 0
+		}
+		err = q.QueryRowContext(ctx, `
+			INSERT INTO customer_product_points (store_id, customer_id, product_id, points, total_points)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (store_id, customer_id, product_id)
+			DO UPDATE SET
+				points = customer_product_points.points + $4,
+				total_points = customer_product_points.total_points + $5,
+				updated_at = NOW()
+			RETURNING points
+		`, c.StoreID, c.CustomerID, c.ProductID, c.PointsChange, lifetimeChange).Scan(&balance)
+	} else {
+		lifetimeChange := 0
+		if c.Revoke {
+			lifetimeChange = c.PointsChange
+		}
+		err = q.QueryRowContext(ctx, `
+			UPDATE customer_product_points
+			SET points = points + $4, total_points = GREATEST(total_points + $6, 0), updated_at = NOW()
+			WHERE store_id = $1 AND customer_id = $2 AND product_id = $3 AND points >= $5
+			RETURNING points
+		`, c.StoreID, c.CustomerID, c.ProductID, c.PointsChange, -c.PointsChange, lifetimeChange).Scan(&balance)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInsufficientPoints
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var transactionID int64
+	err = q.QueryRowContext(ctx, `
+		INSERT INTO point_transactions (
+			store_id, branch_id, customer_id, transaction_type, points_change,
+			reference_table, reference_id, product_id, note, reason_code, staff_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`, c.StoreID, c.BranchID, c.CustomerID, c.TransactionType, c.PointsChange,
+		c.ReferenceTable, c.ReferenceID, c.ProductID, c.Note, c.ReasonCode, c.StaffID).Scan(&transactionID)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case c.PointsChange > 0:
+		err = addPointLot(ctx, q, c)
+	case c.PointsChange < 0:
+		var refTable *string
+		var refID *int64
+		if c.Revoke {
+			refTable, refID = c.ReferenceTable, c.ReferenceID
+		}
+		err = consumePointLots(ctx, q, c.StoreID, c.CustomerID, c.ProductID, -c.PointsChange, refTable, refID, &transactionID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// addPointLot opens an earn lot for a positive change. A restore returns what
+// it can to the lots the deducting transaction drew from; the rest, left by
+// deductions made before draws were recorded, opens a lot dated like the
+// oldest lot that had been drawn on by then.
+func addPointLot(ctx context.Context, q txQuerier, c pointChange) error {
+	points := c.PointsChange
+	if c.Restore && c.ReturnLotsOf != nil {
+		var returned int
+		err := q.QueryRowContext(ctx, `
+			WITH drawn AS (
+				SELECT d.lot_id, LEAST(SUM(d.points), l.points_earned - l.points_remaining) AS points
+				FROM point_lot_draws d
+				JOIN point_earn_lots l ON l.id = d.lot_id
+				WHERE d.point_transaction_id = $1
+				GROUP BY d.lot_id, l.points_earned, l.points_remaining
+			), returned AS (
+				UPDATE point_earn_lots l
+				SET points_remaining = l.points_remaining + d.points
+				FROM drawn d
+				WHERE l.id = d.lot_id AND d.points > 0
+				RETURNING d.points
+			)
+			SELECT COALESCE(SUM(points), 0) FROM returned
+		`, *c.ReturnLotsOf).Scan(&returned)
+		if err != nil {
+			return err
+		}
+		points -= returned
+		if points <= 0 {
+			return nil
+		}
+
+		_, err = q.ExecContext(ctx, `
+			INSERT INTO point_earn_lots (store_id, customer_id, product_id, earned_at, points_earned, points_remaining, reference_table, reference_id)
+			VALUES ($1, $2, $3, COALESCE((
+				SELECT MIN(l.earned_at) FROM point_earn_lots l
+				WHERE l.store_id = $1 AND l.customer_id = $2 AND l.product_id = $3
+					AND l.points_remaining < l.points_earned
+					AND l.earned_at <= (SELECT created_at FROM point_transactions WHERE id = $7)
+			), NOW()), $4, $4, $5, $6)
+		`, c.StoreID, c.CustomerID, c.ProductID, points, c.ReferenceTable, c.ReferenceID, *c.ReturnLotsOf)
+		return err
+	}
+
+	_, err := q.ExecContext(ctx, `
+		INSERT INTO point_earn_lots (store_id, customer_id, product_id, points_earned, points_remaining, reference_table, reference_id)
+		VALUES ($1, $2, $3, $4, $4, $5, $6)
+	`, c.StoreID, c.CustomerID, c.ProductID, points, c.ReferenceTable, c.ReferenceID)
+	return err
+}
+
+// consumePointLots takes points from the customer's open earn lots for the
+// product, oldest first. When refTable and refID are set the lots opened for
+// that reference are taken before any others. With transactionID set, what is
+// taken from each lot is recorded against that point transaction so it can be
+// returned. Points the lots cannot cover are ignored, so a balance that
+// predates lot tracking can still be spent. Callers hold the
+// customer_product_points row lock, which serialises changes to the lots.
+func consumePointLots(ctx context.Context, q txQuerier, storeID, customerID, productID int64, points int, refTable *string, refID *int64, transactionID *int64) error {
+	_, err := q.ExecContext(ctx, `
+		WITH open_lots AS (
+			SELECT id, points_remaining,
+				SUM(points_remaining) OVER (
+					ORDER BY (reference_table = $5::TEXT AND reference_id = $6::BIGINT) IS TRUE DESC, earned_at, id
+				) - points_remaining AS taken_before
+			FROM point_earn_lots
+			WHERE store_id = $1 AND customer_id = $2 AND product_id = $3 AND points_remaining > 0
+		), taken AS (
+			UPDATE point_earn_lots l
+			SET points_remaining = l.points_remaining - LEAST(o.points_remaining, $4 - o.taken_before)
+			FROM open_lots o
+			WHERE l.id = o.id AND o.taken_before < $4
+			RETURNING l.id, LEAST(o.points_remaining, $4 - o.taken_before) AS points
+		)
+		INSERT INTO point_lot_draws (point_transaction_id, lot_id, points)
+		SELECT $7::BIGINT, id, points FROM taken WHERE $7::BIGINT IS NOT NULL
+	`, storeID, customerID, productID, points, refTable, refID, transactionID)
+	return err
+}
@@ -4,21 +4,35 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/mini-membership/api/internal/domain"
 )
 
 type PointsRepository interface {
 	GetCustomerProductPoints(ctx context.Context, storeID, customerID int64) ([]domain.CustomerProductPointsInfo, error)
-	GetProductPoints(ctx context.Context, storeID, customerID, productID int64) (*domain.CustomerProductPoints, error)
-	CreateOrUpdateProductPoints(ctx context.Context, storeID, customerID, productID int64, pointsToAdd int) error
-	DeductProductPoints(ctx context.Context, storeID, customerID, productID int64, pointsToDeduct int) error
-	CreatePointTransaction(ctx context.Context, tx *domain.PointTransaction) error
-	CreateRedemption(ctx context.Context, redemption *domain.PointRedemption) (int64, error)
 	GetRedeemableProducts(ctx context.Context, storeID, branchID int64) ([]domain.RedeemableProduct, error)
-	GetProductPointsToRedeem(ctx context.Context, productID int64) (*int, error)
 	GetPointHistory(ctx context.Context, storeID, customerID int64, limit, offset int) ([]domain.PointHistoryItem, int, error)
+	RedeemTx(ctx context.Context, redemption *domain.PointRedemption) (*RedemptionResult, error)
+	ReverseRedemptionTx(ctx context.Context, storeID, redemptionID, reversedBy, approvedBy int64, reason string) (*domain.ReverseRedemptionResponse, error)
+	AdjustPointsTx(ctx context.Context, storeID, branchID, staffID int64, req *domain.AdjustPointsRequest) (int, error)
+	GetAdjustments(ctx context.Context, storeID int64, f *domain.PointAdjustmentFilter, limit, offset int) (*domain.PointAdjustmentReportResponse, error)
+	GetExpiringPoints(ctx context.Context, storeID int64, customerID *int64, before time.Time, limit, offset int) ([]domain.ExpiringPointsItem, int, error)
+	ExpireDuePoints(ctx context.Context, storeID *int64, asOf time.Time) (*domain.ExpirePointsResult, error)
+	GetWallet(ctx context.Context, storeID, customerID int64) (*domain.WalletBalance, error)
+	GetWalletHistory(ctx context.Context, storeID, customerID int64, limit, offset int) ([]domain.WalletTransaction, int, error)
+}
+
+// RedemptionResult is the outcome of a redemption made in RedeemTx
+type RedemptionResult struct {
+	RedemptionID    int64
+	PointsUsed      int
+	RemainingPoints int
+	ProductName     string
+	RemainingStock  int
 }
 
 type pointsRepository struct {
@@ -54,86 +68,6 @@ func (r *pointsRepository) GetCustomerProductPoints(ctx context.Context, storeID
 	return results, nil
 }
 
-func (r *pointsRepository) GetProductPoints(ctx context.Context, storeID, customerID, productID int64) (*domain.CustomerProductPoints, error) {
-	var points domain.CustomerProductPoints
-	query := `
-		SELECT id, store_id, customer_id, product_id, points, total_points, created_at, updated_at
-		FROM customer_product_points
-		WHERE store_id = $1 AND customer_id = $2 AND product_id = $3
-	`
-	err := r.db.GetContext(ctx, &points, query, storeID, customerID, productID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
-		return nil, err
-	}
-	return &points, nil
-}
-
-func (r *pointsRepository) CreateOrUpdateProductPoints(ctx context.Context, storeID, customerID, productID int64, pointsToAdd int) error {
-	query := `
-		INSERT INTO customer_product_points (store_id, customer_id, product_id, points, total_points)
-		VALUES ($1, $2, $3, $4, $4)
-		ON CONFLICT (store_id, customer_id, product_id)
-		DO UPDATE SET 
-			points = customer_product_points.points + $4,
-			total_points = customer_product_points.total_points + $4,
-			updated_at = NOW()
-	`
-	_, err := r.db.ExecContext(ctx, query, storeID, customerID, productID, pointsToAdd)
-	return err
-}
-
-func (r *pointsRepository) DeductProductPoints(ctx context.Context, storeID, customerID, productID int64, pointsToDeduct int) error {
-	query := `
-		UPDATE customer_product_points
-		SET points = points - $4, updated_at = NOW()
-		WHERE store_id = $1 AND customer_id = $2 AND product_id = $3 AND points >= $4
-	`
-	result, err := r.db.ExecContext(ctx, query, storeID, customerID, productID, pointsToDeduct)
-	if err != nil {
-		return err
-	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return errors.New("insufficient points or customer/product not found")
-	}
-	return nil
-}
-
-func (r *pointsRepository) CreatePointTransaction(ctx context.Context, tx *domain.PointTransaction) error {
-	query := `
-		INSERT INTO point_transactions (
-			store_id, branch_id, customer_id, transaction_type, points_change,
-			reference_table, reference_id, product_id, note, staff_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-	`
-	_, err := r.db.ExecContext(ctx, query,
-		tx.StoreID, tx.BranchID, tx.CustomerID, tx.TransactionType, tx.PointsChange,
-		tx.ReferenceTable, tx.ReferenceID, tx.ProductID, tx.Note, tx.StaffID,
-	)
-	return err
-}
-
-func (r *pointsRepository) CreateRedemption(ctx context.Context, redemption *domain.PointRedemption) (int64, error) {
-	query := `
-		INSERT INTO point_redemptions (
-			store_id, branch_id, customer_id, product_id, points_used, quantity, staff_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id
-	`
-	var id int64
-	err := r.db.QueryRowContext(ctx, query,
-		redemption.StoreID, redemption.BranchID, redemption.CustomerID,
-		redemption.ProductID, redemption.PointsUsed, redemption.Quantity, redemption.StaffID,
-	).Scan(&id)
-	return id, err
-}
-
 func (r *pointsRepository) GetRedeemableProducts(ctx context.Context, storeID, branchID int64) ([]domain.RedeemableProduct, error) {
 	query := `
 		SELECT 
@@ -160,23 +94,6 @@ func (r *pointsRepository) GetRedeemableProducts(ctx context.Context, storeID, b
 	return products, nil
 }
 
-func (r *pointsRepository) GetProductPointsToRedeem(ctx context.Context, productID int64) (*int, error) {
-	var pointsToRedeem sql.NullInt64
-	query := `SELECT points_to_redeem FROM products WHERE id = $1 AND is_active = true`
-	err := r.db.GetContext(ctx, &pointsToRedeem, query, productID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, errors.New("product not found")
-		}
-		return nil, err
-	}
-	if !pointsToRedeem.Valid {
-		return nil, errors.New("product is not redeemable")
-	}
-	points := int(pointsToRedeem.Int64)
-	return &points, nil
-}
-
 func (r *pointsRepository) GetPointHistory(ctx context.Context, storeID, customerID int64, limit, offset int) ([]domain.PointHistoryItem, int, error) {
 	var total int
 	countQuery := `
@@ -209,3 +126,532 @@ func (r *pointsRepository) GetPointHistory(ctx context.Context, storeID, custome
 	}
 	return history, total, nil
 }
+
+// GetWallet returns the customer's wallet, empty when they have never earned wallet points
+func (r *pointsRepository) GetWallet(ctx context.Context, storeID, customerID int64) (*domain.WalletBalance, error) {
+	var wallet domain.WalletBalance
+	err := r.db.GetContext(ctx, &wallet, `
+		SELECT balance, lifetime_earned FROM customer_wallets
+		WHERE store_id = $1 AND customer_id = $2
+	`, storeID, customerID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *pointsRepository) GetWalletHistory(ctx context.Context, storeID, customerID int64, limit, offset int) ([]domain.WalletTransaction, int, error) {
+	var total int
+	err := r.db.GetContext(ctx, &total, `
+		SELECT COUNT(*) FROM wallet_transactions
+		WHERE store_id = $1 AND customer_id = $2
+	`, storeID, customerID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var history []domain.WalletTransaction
+	err = r.db.SelectContext(ctx, &history, `
+		SELECT id, branch_id, transaction_type, points_change, balance_after,
+			amount::FLOAT8 AS amount, redeemed_as, reference_table, reference_id, note, created_at
+		FROM wallet_transactions
+		WHERE store_id = $1 AND customer_id = $2
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, storeID, customerID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return history, total, nil
+}
+
+// RedeemTx redeems Quantity of the product for the customer in one transaction:
+// it locks the customer's points for the product, takes the free product out of
+// branch stock with a REDEEM movement and records the redemption and its
+// point transaction. PointsUsed is worked out from the product.
+func (r *pointsRepository) RedeemTx(ctx context.Context, redemption *domain.PointRedemption) (*RedemptionResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &RedemptionResult{}
+	var pointsToRedeem sql.NullInt64
+	err = tx.QueryRowContext(ctx, `
+		SELECT product_name, points_to_redeem FROM products
+		WHERE id = $1 AND store_id = $2 AND is_active = true
+	`, redemption.ProductID, redemption.StoreID).Scan(&result.ProductName, &pointsToRedeem)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("product not found")
+		}
+		return nil, err
+	}
+	if !pointsToRedeem.Valid || pointsToRedeem.Int64 <= 0 {
+		return nil, errors.New("product is not redeemable")
+	}
+	result.PointsUsed = int(pointsToRedeem.Int64) * redemption.Quantity
+
+	// Lock the customer's points for this product before checking the balance
+	var currentPoints int
+	err = tx.QueryRowContext(ctx, `
+		SELECT points FROM customer_product_points
+		WHERE store_id = $1 AND customer_id = $2 AND product_id = $3
+		FOR UPDATE
+	`, redemption.StoreID, redemption.CustomerID, redemption.ProductID).Scan(&currentPoints)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if currentPoints < result.PointsUsed {
+		return nil, fmt.Errorf("แต้มไม่เพียงพอ: ต้องการ %d แต้ม, มี %d แต้ม", result.PointsUsed, currentPoints)
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO point_redemptions (
+			store_id, branch_id, customer_id, product_id, points_used, quantity, shift_id, staff_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, redemption.StoreID, redemption.BranchID, redemption.CustomerID, redemption.ProductID,
+		result.PointsUsed, redemption.Quantity, redemption.ShiftID, redemption.StaffID,
+	).Scan(&result.RedemptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	refTable := "point_redemptions"
+	moved, err := moveBranchStock(ctx, tx, branchStockMove{
+		StoreID:        redemption.StoreID,
+		BranchID:       redemption.BranchID,
+		ProductID:      redemption.ProductID,
+		QuantityChange: -redemption.Quantity,
+		MovementType:   domain.MovementTypeRedeem,
+		ChangedBy:      redemption.StaffID,
+		ReferenceTable: &refTable,
+		ReferenceID:    &result.RedemptionID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.RemainingStock = moved.ToStock
+
+	result.RemainingPoints, err = changeProductPoints(ctx, tx, pointChange{
+		StoreID:         redemption.StoreID,
+		BranchID:        &redemption.BranchID,
+		CustomerID:      redemption.CustomerID,
+		ProductID:       redemption.ProductID,
+		PointsChange:    -result.PointsUsed,
+		TransactionType: "REDEEM",
+		ReferenceTable:  &refTable,
+		ReferenceID:     &result.RedemptionID,
+		StaffID:         redemption.StaffID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ReverseRedemptionTx undoes a completed redemption in one transaction: it returns
+// any stock the redemption took to the branch and its lots, gives the points back
+// with a REVERSAL transaction and marks the redemption REVERSED. The restored
+// points go back into the earn lots the redemption drew from, so they keep
+// their original expiry.
+func (r *pointsRepository) ReverseRedemptionTx(ctx context.Context, storeID, redemptionID, reversedBy, approvedBy int64, reason string) (*domain.ReverseRedemptionResponse, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var redemption domain.PointRedemption
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, branch_id, customer_id, product_id, points_used, quantity, status
+		FROM point_redemptions
+		WHERE id = $1 AND store_id = $2
+		FOR UPDATE
+	`, redemptionID, storeID).Scan(
+		&redemption.ID, &redemption.BranchID, &redemption.CustomerID, &redemption.ProductID,
+		&redemption.PointsUsed, &redemption.Quantity, &redemption.Status,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.New("redemption not found")
+		}
+		return nil, err
+	}
+	if redemption.Status != domain.RedemptionStatusCompleted {
+		return nil, fmt.Errorf("redemption is %s and cannot be reversed", redemption.Status)
+	}
+
+	refTable := "point_redemptions"
+	resp := &domain.ReverseRedemptionResponse{
+		RedemptionID:   redemptionID,
+		Status:         domain.RedemptionStatusReversed,
+		PointsRestored: redemption.PointsUsed,
+	}
+
+	// Redemptions made before stock was deducted have no REDEEM movement to undo
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(-SUM(quantity_change), 0) FROM inventory_movements
+		WHERE store_id = $1 AND reference_table = $2 AND reference_id = $3 AND movement_type = $4
+	`, storeID, refTable, redemptionID, domain.MovementTypeRedeem).Scan(&resp.StockReturned)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StockReturned > 0 {
+		_, err = moveBranchStock(ctx, tx, branchStockMove{
+			StoreID:        storeID,
+			BranchID:       redemption.BranchID,
+			ProductID:      redemption.ProductID,
+			QuantityChange: resp.StockReturned,
+			MovementType:   domain.MovementTypeCancelRedeem,
+			Reason:         &reason,
+			ChangedBy:      &reversedBy,
+			ApprovedBy:     &approvedBy,
+			ReferenceTable: &refTable,
+			ReferenceID:    &redemptionID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = returnTakenLots(ctx, tx, lotMove{
+			StoreID:        storeID,
+			BranchID:       &redemption.BranchID,
+			ProductID:      redemption.ProductID,
+			MovementType:   domain.MovementTypeCancelRedeem,
+			ChangedBy:      &reversedBy,
+			ReferenceTable: &refTable,
+			ReferenceID:    &redemptionID,
+		}, domain.MovementTypeRedeem)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var redeemTransactionID int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT id FROM point_transactions
+		WHERE store_id = $1 AND reference_table = $2 AND reference_id = $3 AND transaction_type = 'REDEEM'
+		ORDER BY id LIMIT 1
+	`, storeID, refTable, redemptionID).Scan(&redeemTransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.RemainingPoints, err = changeProductPoints(ctx, tx, pointChange{
+		StoreID:         storeID,
+		BranchID:        &redemption.BranchID,
+		CustomerID:      redemption.CustomerID,
+		ProductID:       redemption.ProductID,
+		PointsChange:    redemption.PointsUsed,
+		TransactionType: "REVERSAL",
+		ReferenceTable:  &refTable,
+		ReferenceID:     &redemptionID,
+		Note:            &reason,
+		StaffID:         &reversedBy,
+		Restore:         true,
+		ReturnLotsOf:    &redeemTransactionID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE point_redemptions
+		SET status = $1, reversed_by = $2, approved_by = $3, reversed_at = NOW(), reversal_reason = $4
+		WHERE id = $5
+	`, domain.RedemptionStatusReversed, reversedBy, approvedBy, reason, redemptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// AdjustPointsTx applies a manual ADJUST transaction to the customer's points for
+// the product, re-evaluates their tier and returns the new balance. Granted
+// points count towards the lifetime total like earned points; removals fail
+// with ErrInsufficientPoints rather than going below zero.
+func (r *pointsRepository) AdjustPointsTx(ctx context.Context, storeID, branchID, staffID int64, req *domain.AdjustPointsRequest) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var customerExists, productExists bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT
+			EXISTS (SELECT 1 FROM customers WHERE id = $1 AND store_id = $3),
+			EXISTS (SELECT 1 FROM products WHERE id = $2 AND store_id = $3)
+	`, req.CustomerID, req.ProductID, storeID).Scan(&customerExists, &productExists)
+	if err != nil {
+		return 0, err
+	}
+	if !customerExists {
+		return 0, errors.New("customer not found")
+	}
+	if !productExists {
+		return 0, errors.New("product not found")
+	}
+
+	reasonCode := string(req.ReasonCode)
+	balance, err := changeProductPoints(ctx, tx, pointChange{
+		StoreID:         storeID,
+		BranchID:        &branchID,
+		CustomerID:      req.CustomerID,
+		ProductID:       req.ProductID,
+		PointsChange:    req.Points,
+		TransactionType: "ADJUST",
+		Note:            &req.Note,
+		ReasonCode:      &reasonCode,
+		StaffID:         &staffID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if _, _, err := evaluateCustomerTier(ctx, tx, storeID, req.CustomerID, domain.TierChangeReasonAdjust); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// GetAdjustments returns manual ADJUST point transactions, newest first, with
+// totals per branch and staff member across everything matching the filter
+func (r *pointsRepository) GetAdjustments(ctx context.Context, storeID int64, f *domain.PointAdjustmentFilter, limit, offset int) (*domain.PointAdjustmentReportResponse, error) {
+	where := `
+		WHERE pt.store_id = $1
+			AND pt.transaction_type = 'ADJUST'
+			AND ($2::BIGINT IS NULL OR pt.branch_id = $2)
+			AND ($3::BIGINT IS NULL OR pt.staff_id = $3)
+			AND ($4::TIMESTAMPTZ IS NULL OR pt.created_at >= $4)
+			AND ($5::TIMESTAMPTZ IS NULL OR pt.created_at < $5)
+	`
+	args := []interface{}{storeID, f.BranchID, f.StaffID, f.From, f.To}
+
+	report := &domain.PointAdjustmentReportResponse{
+		Summary: []domain.PointAdjustmentSummary{},
+		Items:   []domain.PointAdjustmentItem{},
+	}
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+			pt.branch_id, b.branch_name, pt.staff_id, s.email,
+			COUNT(*),
+			COALESCE(SUM(pt.points_change) FILTER (WHERE pt.points_change > 0), 0),
+			COALESCE(-SUM(pt.points_change) FILTER (WHERE pt.points_change < 0), 0)
+		FROM point_transactions pt
+		LEFT JOIN branches b ON pt.branch_id = b.id
+		LEFT JOIN staff_accounts s ON pt.staff_id = s.id
+	`+where+`
+		GROUP BY pt.branch_id, b.branch_name, pt.staff_id, s.email
+		ORDER BY b.branch_name NULLS LAST, s.email NULLS LAST
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sum domain.PointAdjustmentSummary
+		if err := rows.Scan(
+			&sum.BranchID, &sum.BranchName, &sum.StaffID, &sum.StaffName,
+			&sum.Adjustments, &sum.PointsAdded, &sum.PointsRemoved,
+		); err != nil {
+			return nil, err
+		}
+		report.Summary = append(report.Summary, sum)
+		report.TotalCount += sum.Adjustments
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.QueryContext(ctx, `
+		SELECT
+			pt.id, pt.branch_id, b.branch_name, pt.staff_id, s.email,
+			pt.customer_id, c.full_name, c.customer_code, pt.product_id, p.product_name,
+			pt.points_change, COALESCE(pt.reason_code, ''), pt.note, pt.created_at
+		FROM point_transactions pt
+		JOIN customers c ON pt.customer_id = c.id
+		LEFT JOIN branches b ON pt.branch_id = b.id
+		LEFT JOIN staff_accounts s ON pt.staff_id = s.id
+		LEFT JOIN products p ON pt.product_id = p.id
+	`+where+`
+		ORDER BY pt.created_at DESC, pt.id DESC
+		LIMIT $6 OFFSET $7
+	`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item domain.PointAdjustmentItem
+		if err := rows.Scan(
+			&item.TransactionID, &item.BranchID, &item.BranchName, &item.StaffID, &item.StaffName,
+			&item.CustomerID, &item.CustomerName, &item.CustomerCode, &item.ProductID, &item.ProductName,
+			&item.PointsChange, &item.ReasonCode, &item.Note, &item.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		report.Items = append(report.Items, item)
+	}
+	return report, rows.Err()
+}
+
+// GetExpiringPoints returns open earn lots that expire by before, grouped by
+// customer, product and expiry time, soonest first
+func (r *pointsRepository) GetExpiringPoints(ctx context.Context, storeID int64, customerID *int64, before time.Time, limit, offset int) ([]domain.ExpiringPointsItem, int, error) {
+	query := `
+		WITH due AS (
+			SELECT l.customer_id, l.product_id, l.points_remaining, ` + pointLotExpirySQL + ` AS expires_at
+			FROM point_earn_lots l
+			JOIN store_settings ss ON ss.store_id = l.store_id
+			WHERE l.store_id = $1 AND l.points_remaining > 0
+				AND ($2::BIGINT IS NULL OR l.customer_id = $2)
+		)
+		SELECT
+			d.customer_id,
+			c.full_name AS customer_name,
+			c.customer_code,
+			d.product_id,
+			p.product_name,
+			SUM(d.points_remaining) AS points,
+			d.expires_at,
+			COUNT(*) OVER () AS total
+		FROM due d
+		JOIN customers c ON c.id = d.customer_id
+		JOIN products p ON p.id = d.product_id
+		WHERE d.expires_at <= $3
+		GROUP BY d.customer_id, c.full_name, c.customer_code, d.product_id, p.product_name, d.expires_at
+		ORDER BY d.expires_at, c.full_name NULLS LAST, p.product_name
+		LIMIT $4 OFFSET $5
+	`
+	var rows []struct {
+		domain.ExpiringPointsItem
+		Total int `db:"total"`
+	}
+	err := r.db.SelectContext(ctx, &rows, query, storeID, customerID, before, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	items := make([]domain.ExpiringPointsItem, len(rows))
+	total := 0
+	for i, row := range rows {
+		items[i] = row.ExpiringPointsItem
+		total = row.Total
+	}
+	return items, total, nil
+}
+
+// ExpireDuePoints expires every open earn lot whose expiry time has passed,
+// one customer and product at a time. Expired lots are always a customer's
+// oldest, so the EXPIRE deduction consumes exactly those lots.
+func (r *pointsRepository) ExpireDuePoints(ctx context.Context, storeID *int64, asOf time.Time) (*domain.ExpirePointsResult, error) {
+	type dueBalance struct {
+		StoreID    int64         `db:"store_id"`
+		CustomerID int64         `db:"customer_id"`
+		ProductID  int64         `db:"product_id"`
+		LotIDs     pq.Int64Array `db:"lot_ids"`
+	}
+	var due []dueBalance
+	err := r.db.SelectContext(ctx, &due, `
+		SELECT l.store_id, l.customer_id, l.product_id, array_agg(l.id ORDER BY l.id) AS lot_ids
+		FROM point_earn_lots l
+		JOIN store_settings ss ON ss.store_id = l.store_id
+		WHERE l.points_remaining > 0
+			AND ($1::BIGINT IS NULL OR l.store_id = $1)
+			AND `+pointLotExpirySQL+` <= $2
+		GROUP BY l.store_id, l.customer_id, l.product_id
+	`, storeID, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.ExpirePointsResult{RunAt: asOf}
+	for _, d := range due {
+		expired, err := r.expireBalance(ctx, d.StoreID, d.CustomerID, d.ProductID, d.LotIDs)
+		if err != nil {
+			return nil, fmt.Errorf("expire points for customer %d product %d: %w", d.CustomerID, d.ProductID, err)
+		}
+		if expired > 0 {
+			result.BalancesExpired++
+			result.PointsExpired += expired
+		}
+	}
+	return result, nil
+}
+
+func (r *pointsRepository) expireBalance(ctx context.Context, storeID, customerID, productID int64, lotIDs []int64) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var balance int
+	err = tx.QueryRowContext(ctx, `
+		SELECT points FROM customer_product_points
+		WHERE store_id = $1 AND customer_id = $2 AND product_id = $3
+		FOR UPDATE
+	`, storeID, customerID, productID).Scan(&balance)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	// Re-read under the lock; a redemption may have used some of the lots since
+	var duePoints int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(points_remaining), 0) FROM point_earn_lots WHERE id = ANY($1)
+	`, pq.Array(lotIDs)).Scan(&duePoints)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := duePoints
+	if expired > balance {
+		expired = balance
+	}
+	if expired > 0 {
+		note := "points expired"
+		_, err = changeProductPoints(ctx, tx, pointChange{
+			StoreID:         storeID,
+			CustomerID:      customerID,
+			ProductID:       productID,
+			PointsChange:    -expired,
+			TransactionType: "EXPIRE",
+			Note:            &note,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Close the lots even when the balance could not cover them
+	_, err = tx.ExecContext(ctx, `
+		UPDATE point_earn_lots SET points_remaining = 0 WHERE id = ANY($1) AND points_remaining > 0
+	`, pq.Array(lotIDs))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
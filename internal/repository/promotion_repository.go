@@ -10,8 +10,9 @@ import (
 )
 
 type PromotionRepository interface {
-	GetActivePromotions(ctx context.Context, storeID, branchID int64) ([]domain.PromotionResponse, error)
+	GetActivePromotions(ctx context.Context, storeID, branchID int64, customerID *int64) ([]domain.PromotionResponse, error)
 	GetPromotionByID(ctx context.Context, storeID, promotionID int64) (*domain.PromotionResponse, error)
+	IsOpenToCustomer(ctx context.Context, storeID, promotionID int64, customerID *int64) (bool, error)
 	GetPromotionProducts(ctx context.Context, promotionID int64) ([]domain.PromotionProduct, error)
 }
 
@@ -38,9 +39,12 @@ type promotionRow struct {
 	OldPriceSet           decimal.NullDecimal `db:"old_price_set"`
 	CountConditionProduct sql.NullInt64   `db:"count_condition_product"`
 	ProductCount          int             `db:"product_count"`
+	TierCode              sql.NullString  `db:"tier_code"`
 }
 
-func (r *promotionRepository) GetActivePromotions(ctx context.Context, storeID, branchID int64) ([]domain.PromotionResponse, error) {
+// GetActivePromotions returns the branch's running promotions; tier-only
+// promotions are included when customerID's tier qualifies for them
+func (r *promotionRepository) GetActivePromotions(ctx context.Context, storeID, branchID int64, customerID *int64) ([]domain.PromotionResponse, error) {
 	query := `
 		SELECT 
 			p.id,
@@ -56,7 +60,8 @@ func (r *promotionRepository) GetActivePromotions(ctx context.Context, storeID,
 			pc.total_price_set_discount,
 			pc.old_price_set,
 			pc.count_condition_product,
-			COALESCE((SELECT COUNT(*) FROM promotion_products pp WHERE pp.promotion_id = p.id), 0) as product_count
+			COALESCE((SELECT COUNT(*) FROM promotion_products pp WHERE pp.promotion_id = p.id), 0) as product_count,
+			p.tier_code
 		FROM promotions p
 		JOIN promotion_types pt ON pt.id = p.promotion_type_id
 		LEFT JOIN promotion_configs pc ON pc.promotion_id = p.id
@@ -68,11 +73,12 @@ func (r *promotionRepository) GetActivePromotions(ctx context.Context, storeID,
 				SELECT 1 FROM promotion_type_branches ptb 
 				WHERE ptb.promotion_type_id = pt.id AND ptb.branch_id = $2
 			)
+			AND `+promotionTierOpenSQL("$3")+`
 		ORDER BY p.id
 	`
 
 	var rows []promotionRow
-	if err := r.db.SelectContext(ctx, &rows, query, storeID, branchID); err != nil {
+	if err := r.db.SelectContext(ctx, &rows, query, storeID, branchID, customerID); err != nil {
 		return nil, err
 	}
 
@@ -99,6 +105,9 @@ func (r *promotionRepository) GetActivePromotions(ctx context.Context, storeID,
 		if row.EndsAt.Valid {
 			promo.EndsAt = &row.EndsAt.Time
 		}
+		if row.TierCode.Valid {
+			promo.TierCode = &row.TierCode.String
+		}
 		if row.PercentDiscount.Valid {
 			v, _ := row.PercentDiscount.Decimal.Float64()
 			promo.Config.PercentDiscount = &v
@@ -149,7 +158,8 @@ func (r *promotionRepository) GetPromotionByID(ctx context.Context, storeID, pro
 			pc.total_price_set_discount,
 			pc.old_price_set,
 			pc.count_condition_product,
-			COALESCE((SELECT COUNT(*) FROM promotion_products pp WHERE pp.promotion_id = p.id), 0) as product_count
+			COALESCE((SELECT COUNT(*) FROM promotion_products pp WHERE pp.promotion_id = p.id), 0) as product_count,
+			p.tier_code
 		FROM promotions p
 		JOIN promotion_types pt ON pt.id = p.promotion_type_id
 		LEFT JOIN promotion_configs pc ON pc.promotion_id = p.id
@@ -185,6 +195,9 @@ func (r *promotionRepository) GetPromotionByID(ctx context.Context, storeID, pro
 	if row.EndsAt.Valid {
 		promo.EndsAt = &row.EndsAt.Time
 	}
+	if row.TierCode.Valid {
+		promo.TierCode = &row.TierCode.String
+	}
 	if row.PercentDiscount.Valid {
 		v, _ := row.PercentDiscount.Decimal.Float64()
 		promo.Config.PercentDiscount = &v
@@ -215,6 +228,19 @@ func (r *promotionRepository) GetPromotionByID(ctx context.Context, storeID, pro
 	return promo, nil
 }
 
+// IsOpenToCustomer reports whether the promotion has no tier or customerID's
+// tier qualifies for it; a tier-only promotion is never open without a customer
+func (r *promotionRepository) IsOpenToCustomer(ctx context.Context, storeID, promotionID int64, customerID *int64) (bool, error) {
+	var open bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT `+promotionTierOpenSQL("$3")+` FROM promotions p WHERE p.store_id = $1 AND p.id = $2
+	`, storeID, promotionID, customerID).Scan(&open)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return open, err
+}
+
 func (r *promotionRepository) GetPromotionProducts(ctx context.Context, promotionID int64) ([]domain.PromotionProduct, error) {
 	query := `
 		SELECT 
@@ -0,0 +1,476 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+type PurchaseOrderRepository interface {
+	CreateSupplier(ctx context.Context, storeID int64, req *domain.CreateSupplierRequest) (*domain.Supplier, error)
+	UpdateSupplier(ctx context.Context, storeID, supplierID int64, req *domain.UpdateSupplierRequest) error
+	GetSupplierByID(ctx context.Context, storeID, supplierID int64) (*domain.Supplier, error)
+	GetSuppliers(ctx context.Context, storeID int64) ([]domain.Supplier, error)
+	Create(ctx context.Context, storeID, branchID, createdBy int64, req *domain.CreatePurchaseOrderRequest) (int64, error)
+	GetByID(ctx context.Context, storeID, purchaseOrderID int64) (*domain.PurchaseOrderResponse, error)
+	List(ctx context.Context, storeID int64, branchID *int64, status domain.PurchaseOrderStatus, limit, offset int) (*domain.PurchaseOrderListResponse, error)
+	UpdateStatus(ctx context.Context, storeID, purchaseOrderID int64, status domain.PurchaseOrderStatus) error
+	ReceiveGoods(ctx context.Context, storeID, purchaseOrderID, receivedBy int64, req *domain.ReceiveGoodsRequest) (int64, error)
+	GetGoodsReceivedNotes(ctx context.Context, storeID, purchaseOrderID int64) ([]domain.GoodsReceivedNoteResponse, error)
+}
+
+type purchaseOrderRepository struct {
+	db *sqlx.DB
+}
+
+func NewPurchaseOrderRepository(db *sqlx.DB) PurchaseOrderRepository {
+	return &purchaseOrderRepository{db: db}
+}
+
+func (r *purchaseOrderRepository) CreateSupplier(ctx context.Context, storeID int64, req *domain.CreateSupplierRequest) (*domain.Supplier, error) {
+	var s domain.Supplier
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO suppliers (store_id, supplier_name, contact_name, phone, email, note)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, store_id, supplier_name, contact_name, phone, email, note, is_active, created_at, updated_at
+	`, storeID, req.SupplierName, req.ContactName, req.Phone, req.Email, req.Note).Scan(
+		&s.ID, &s.StoreID, &s.SupplierName, &s.ContactName, &s.Phone, &s.Email, &s.Note,
+		&s.IsActive, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *purchaseOrderRepository) UpdateSupplier(ctx context.Context, storeID, supplierID int64, req *domain.UpdateSupplierRequest) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE suppliers
+		SET supplier_name = $1, contact_name = $2, phone = $3, email = $4, note = $5, is_active = $6, updated_at = NOW()
+		WHERE id = $7 AND store_id = $8
+	`, req.SupplierName, req.ContactName, req.Phone, req.Email, req.Note, req.IsActive, supplierID, storeID)
+	return err
+}
+
+func (r *purchaseOrderRepository) GetSupplierByID(ctx context.Context, storeID, supplierID int64) (*domain.Supplier, error) {
+	var s domain.Supplier
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, store_id, supplier_name, contact_name, phone, email, note, is_active, created_at, updated_at
+		FROM suppliers
+		WHERE id = $1 AND store_id = $2
+	`, supplierID, storeID).Scan(
+		&s.ID, &s.StoreID, &s.SupplierName, &s.ContactName, &s.Phone, &s.Email, &s.Note,
+		&s.IsActive, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *purchaseOrderRepository) GetSuppliers(ctx context.Context, storeID int64) ([]domain.Supplier, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, store_id, supplier_name, contact_name, phone, email, note, is_active, created_at, updated_at
+		FROM suppliers
+		WHERE store_id = $1
+		ORDER BY is_active DESC, supplier_name ASC
+	`, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suppliers []domain.Supplier
+	for rows.Next() {
+		var s domain.Supplier
+		err := rows.Scan(
+			&s.ID, &s.StoreID, &s.SupplierName, &s.ContactName, &s.Phone, &s.Email, &s.Note,
+			&s.IsActive, &s.CreatedAt, &s.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, s)
+	}
+	return suppliers, nil
+}
+
+func (r *purchaseOrderRepository) Create(ctx context.Context, storeID, branchID, createdBy int64, req *domain.CreatePurchaseOrderRequest) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var purchaseOrderID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO purchase_orders (store_id, supplier_id, branch_id, status, expected_at, note, created_by)
+		VALUES ($1, $2, $3, 'OPEN', $4, $5, $6)
+		RETURNING id
+	`, storeID, req.SupplierID, branchID, req.ExpectedAt, req.Note, createdBy).Scan(&purchaseOrderID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, item := range req.Items {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO purchase_order_items (purchase_order_id, product_id, ordered_qty, unit_cost)
+			VALUES ($1, $2, $3, $4)
+		`, purchaseOrderID, item.ProductID, item.OrderedQty, item.UnitCost)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return purchaseOrderID, nil
+}
+
+const purchaseOrderSelect = `
+	SELECT
+		po.id, po.supplier_id, s.supplier_name, po.branch_id, b.branch_name, po.status,
+		po.expected_at, po.note, cs.email,
+		COALESCE((SELECT SUM(poi.ordered_qty * poi.unit_cost) FROM purchase_order_items poi WHERE poi.purchase_order_id = po.id), 0),
+		po.closed_at, po.created_at
+	FROM purchase_orders po
+	JOIN suppliers s ON po.supplier_id = s.id
+	JOIN branches b ON po.branch_id = b.id
+	LEFT JOIN staff_accounts cs ON po.created_by = cs.id
+`
+
+func scanPurchaseOrder(row interface{ Scan(...interface{}) error }) (*domain.PurchaseOrderResponse, error) {
+	var po domain.PurchaseOrderResponse
+	var createdByName sql.NullString
+	err := row.Scan(
+		&po.ID, &po.SupplierID, &po.SupplierName, &po.BranchID, &po.BranchName, &po.Status,
+		&po.ExpectedAt, &po.Note, &createdByName, &po.TotalCost, &po.ClosedAt, &po.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if createdByName.Valid {
+		po.CreatedByName = &createdByName.String
+	}
+	return &po, nil
+}
+
+func (r *purchaseOrderRepository) GetByID(ctx context.Context, storeID, purchaseOrderID int64) (*domain.PurchaseOrderResponse, error) {
+	po, err := scanPurchaseOrder(r.db.QueryRowContext(ctx, purchaseOrderSelect+`
+		WHERE po.id = $1 AND po.store_id = $2
+	`, purchaseOrderID, storeID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	items, err := r.getItems(ctx, po.ID)
+	if err != nil {
+		return nil, err
+	}
+	po.Items = items
+
+	return po, nil
+}
+
+func (r *purchaseOrderRepository) List(ctx context.Context, storeID int64, branchID *int64, status domain.PurchaseOrderStatus, limit, offset int) (*domain.PurchaseOrderListResponse, error) {
+	var total int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM purchase_orders po
+		WHERE po.store_id = $1
+			AND ($2::BIGINT IS NULL OR po.branch_id = $2)
+			AND ($3 = '' OR po.status = $3)
+	`, storeID, branchID, status).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, purchaseOrderSelect+`
+		WHERE po.store_id = $1
+			AND ($2::BIGINT IS NULL OR po.branch_id = $2)
+			AND ($3 = '' OR po.status = $3)
+		ORDER BY po.created_at DESC
+		LIMIT $4 OFFSET $5
+	`, storeID, branchID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var purchaseOrders []domain.PurchaseOrderResponse
+	for rows.Next() {
+		po, err := scanPurchaseOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		purchaseOrders = append(purchaseOrders, *po)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range purchaseOrders {
+		items, err := r.getItems(ctx, purchaseOrders[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		purchaseOrders[i].Items = items
+	}
+
+	return &domain.PurchaseOrderListResponse{
+		PurchaseOrders: purchaseOrders,
+		Total:          total,
+	}, nil
+}
+
+func (r *purchaseOrderRepository) getItems(ctx context.Context, purchaseOrderID int64) ([]domain.PurchaseOrderItemResponse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT poi.id, poi.product_id, p.product_name, poi.ordered_qty, poi.received_qty,
+			GREATEST(poi.ordered_qty - poi.received_qty, 0), poi.unit_cost
+		FROM purchase_order_items poi
+		JOIN products p ON poi.product_id = p.id
+		WHERE poi.purchase_order_id = $1
+		ORDER BY p.product_name ASC
+	`, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []domain.PurchaseOrderItemResponse
+	for rows.Next() {
+		var item domain.PurchaseOrderItemResponse
+		err := rows.Scan(
+			&item.ID, &item.ProductID, &item.ProductName, &item.OrderedQty, &item.ReceivedQty,
+			&item.OutstandingQty, &item.UnitCost,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (r *purchaseOrderRepository) UpdateStatus(ctx context.Context, storeID, purchaseOrderID int64, status domain.PurchaseOrderStatus) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE purchase_orders
+		SET status = $1,
+			closed_at = CASE WHEN $1 IN ('CLOSED', 'CANCELLED') THEN NOW() ELSE closed_at END,
+			updated_at = NOW()
+		WHERE id = $2 AND store_id = $3
+	`, status, purchaseOrderID, storeID)
+	return err
+}
+
+// ReceiveGoods records a goods received note, adds the quantities to the purchase order's
+// branch with RECEIVE movements and moves the purchase order to PARTIALLY_RECEIVED or CLOSED
+func (r *purchaseOrderRepository) ReceiveGoods(ctx context.Context, storeID, purchaseOrderID, receivedBy int64, req *domain.ReceiveGoodsRequest) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var branchID int64
+	var status domain.PurchaseOrderStatus
+	err = tx.QueryRowContext(ctx, `
+		SELECT branch_id, status FROM purchase_orders
+		WHERE id = $1 AND store_id = $2
+		FOR UPDATE
+	`, purchaseOrderID, storeID).Scan(&branchID, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("purchase order not found")
+		}
+		return 0, err
+	}
+	if status != domain.PurchaseOrderStatusOpen && status != domain.PurchaseOrderStatusPartiallyReceived {
+		return 0, errors.New("purchase order is not open for receiving")
+	}
+
+	var grnID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO goods_received_notes (store_id, purchase_order_id, branch_id, supplier_ref, note, received_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, storeID, purchaseOrderID, branchID, req.SupplierRef, req.Note, receivedBy).Scan(&grnID)
+	if err != nil {
+		return 0, err
+	}
+
+	refTable := "goods_received_notes"
+	reason := fmt.Sprintf("Purchase order #%d", purchaseOrderID)
+
+	for _, item := range req.Items {
+		var orderedQty, receivedQty int
+		var unitCost decimal.Decimal
+		err = tx.QueryRowContext(ctx, `
+			UPDATE purchase_order_items
+			SET received_qty = received_qty + $1, updated_at = NOW()
+			WHERE purchase_order_id = $2 AND product_id = $3
+			RETURNING ordered_qty, received_qty, unit_cost
+		`, item.Quantity, purchaseOrderID, item.ProductID).Scan(&orderedQty, &receivedQty, &unitCost)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return 0, fmt.Errorf("product %d is not on this purchase order", item.ProductID)
+			}
+			return 0, err
+		}
+		if receivedQty > orderedQty {
+			return 0, fmt.Errorf("product %d: received %d exceeds ordered %d", item.ProductID, receivedQty, orderedQty)
+		}
+
+		if item.UnitCost != nil {
+			unitCost = *item.UnitCost
+		}
+
+		if err = checkLotNumber(ctx, tx, storeID, item.ProductID, item.LotNumber); err != nil {
+			return 0, err
+		}
+		expiryDate, err := parseLotExpiry(item.ExpiryDate)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO goods_received_note_items (grn_id, product_id, quantity, unit_cost, lot_number, expiry_date)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, grnID, item.ProductID, item.Quantity, unitCost, item.LotNumber, expiryDate)
+		if err != nil {
+			return 0, err
+		}
+
+		_, err = moveBranchStock(ctx, tx, branchStockMove{
+			StoreID:        storeID,
+			BranchID:       branchID,
+			ProductID:      item.ProductID,
+			QuantityChange: item.Quantity,
+			UnitCost:       &unitCost,
+			MovementType:   domain.MovementTypeReceive,
+			Reason:         &reason,
+			Note:           req.Note,
+			ChangedBy:      &receivedBy,
+			ReferenceTable: &refTable,
+			ReferenceID:    &grnID,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		if item.LotNumber != nil && *item.LotNumber != "" {
+			_, err = addToLot(ctx, tx, lotMove{
+				StoreID:        storeID,
+				BranchID:       &branchID,
+				ProductID:      item.ProductID,
+				LotNumber:      *item.LotNumber,
+				ExpiryDate:     expiryDate,
+				Quantity:       item.Quantity,
+				MovementType:   domain.MovementTypeReceive,
+				ChangedBy:      &receivedBy,
+				ReferenceTable: &refTable,
+				ReferenceID:    &grnID,
+			})
+			if err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	var outstanding int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM purchase_order_items
+		WHERE purchase_order_id = $1 AND received_qty < ordered_qty
+	`, purchaseOrderID).Scan(&outstanding)
+	if err != nil {
+		return 0, err
+	}
+
+	if outstanding == 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE purchase_orders SET status = 'CLOSED', closed_at = NOW(), updated_at = NOW()
+			WHERE id = $1
+		`, purchaseOrderID)
+	} else {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE purchase_orders SET status = 'PARTIALLY_RECEIVED', updated_at = NOW()
+			WHERE id = $1
+		`, purchaseOrderID)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return grnID, nil
+}
+
+func (r *purchaseOrderRepository) GetGoodsReceivedNotes(ctx context.Context, storeID, purchaseOrderID int64) ([]domain.GoodsReceivedNoteResponse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT g.id, g.purchase_order_id, g.branch_id, g.supplier_ref, g.note, s.email, g.created_at
+		FROM goods_received_notes g
+		LEFT JOIN staff_accounts s ON g.received_by = s.id
+		WHERE g.store_id = $1 AND g.purchase_order_id = $2
+		ORDER BY g.created_at ASC, g.id ASC
+	`, storeID, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []domain.GoodsReceivedNoteResponse
+	for rows.Next() {
+		var n domain.GoodsReceivedNoteResponse
+		var receivedByName sql.NullString
+		err := rows.Scan(&n.ID, &n.PurchaseOrderID, &n.BranchID, &n.SupplierRef, &n.Note, &receivedByName, &n.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if receivedByName.Valid {
+			n.ReceivedByName = &receivedByName.String
+		}
+		notes = append(notes, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range notes {
+		itemRows, err := r.db.QueryContext(ctx, `
+			SELECT gi.product_id, p.product_name, gi.quantity, gi.unit_cost, gi.lot_number, gi.expiry_date
+			FROM goods_received_note_items gi
+			JOIN products p ON gi.product_id = p.id
+			WHERE gi.grn_id = $1
+			ORDER BY p.product_name ASC
+		`, notes[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for itemRows.Next() {
+			var item domain.GoodsReceivedNoteItemResponse
+			if err := itemRows.Scan(&item.ProductID, &item.ProductName, &item.Quantity, &item.UnitCost, &item.LotNumber, &item.ExpiryDate); err != nil {
+				itemRows.Close()
+				return nil, err
+			}
+			notes[i].Items = append(notes[i].Items, item)
+		}
+		itemRows.Close()
+	}
+
+	return notes, nil
+}
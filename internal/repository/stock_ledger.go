@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInsufficientStock is returned when a stock movement would take on_stock below zero
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// txQuerier is satisfied by both *sql.Tx and *sqlx.Tx so the stock helpers
+// can run inside whichever transaction the caller opened
+type txQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// stockMoveResult is the stock before and after a move, the unit cost the
+// movement was booked at and the lots an outgoing move was drawn from
+type stockMoveResult struct {
+	FromStock int
+	ToStock   int
+	UnitCost  decimal.Decimal
+	Lots      []lotAllocation
+}
+
+// avgCostPlaces is the scale of the avg_cost and unit_cost columns
+const avgCostPlaces = 4
+
+// movingAverageCost blends incoming units into the current average cost.
+// Stock at or below zero carries no cost, so the incoming cost replaces it.
+func movingAverageCost(currentStock int, currentCost decimal.Decimal, quantity int, unitCost decimal.Decimal) decimal.Decimal {
+	if currentStock <= 0 {
+		return unitCost.Round(avgCostPlaces)
+	}
+	stock := decimal.NewFromInt(int64(currentStock))
+	incoming := decimal.NewFromInt(int64(quantity))
+	return stock.Mul(currentCost).Add(incoming.Mul(unitCost)).DivRound(stock.Add(incoming), avgCostPlaces)
+}
+
+// branchStockMove describes a single change to branch_products.on_stock.
+// UnitCost is the cost of incoming units; when nil the movement is booked at
+// the current average cost and the average is left unchanged.
+// Outgoing moves draw from LotID when set, otherwise first-expiry-first-out;
+// SkipLots leaves lots untouched. Incoming lots are added by the caller.
+type branchStockMove struct {
+	StoreID        int64
+	BranchID       int64
+	ProductID      int64
+	QuantityChange int
+	UnitCost       *decimal.Decimal
+	MovementType   domain.MovementType
+	ReasonCode     *string
+	Reason         *string
+	Note           *string
+	ChangedBy      *int64
+	ApprovedBy     *int64
+	ReferenceTable *string
+	ReferenceID    *int64
+	LotID          *int64
+	SkipLots       bool
+	AllowNegative  bool
+}
+
+// moveBranchStock locks the branch_products row, applies the quantity change,
+// maintains the moving-average cost and writes the matching inventory_movements record
+func moveBranchStock(ctx context.Context, q txQuerier, m branchStockMove) (stockMoveResult, error) {
+	var branchProductID int64
+	var currentStock int
+	var avgCost decimal.Decimal
+	err := q.QueryRowContext(ctx, `
+		SELECT id, on_stock, avg_cost FROM branch_products
+		WHERE store_id = $1 AND branch_id = $2 AND product_id = $3
+		FOR UPDATE
+	`, m.StoreID, m.BranchID, m.ProductID).Scan(&branchProductID, &currentStock, &avgCost)
+	if err == sql.ErrNoRows {
+		err = q.QueryRowContext(ctx, `
+			INSERT INTO branch_products (store_id, branch_id, product_id, on_stock)
+			VALUES ($1, $2, $3, 0)
+			ON CONFLICT (branch_id, product_id) DO UPDATE SET updated_at = NOW()
+			RETURNING id, on_stock, avg_cost
+		`, m.StoreID, m.BranchID, m.ProductID).Scan(&branchProductID, &currentStock, &avgCost)
+	}
+	if err != nil {
+		return stockMoveResult{}, err
+	}
+
+	newStock := currentStock + m.QuantityChange
+	if newStock < 0 && !m.AllowNegative {
+		return stockMoveResult{}, fmt.Errorf("%w: product %d has %d on stock, needs %d", ErrInsufficientStock, m.ProductID, currentStock, -m.QuantityChange)
+	}
+
+	unitCost := avgCost
+	if m.UnitCost != nil && m.QuantityChange > 0 {
+		unitCost = *m.UnitCost
+		avgCost = movingAverageCost(currentStock, avgCost, m.QuantityChange, unitCost)
+	}
+
+	_, err = q.ExecContext(ctx, `
+		UPDATE branch_products SET on_stock = $1, avg_cost = $2, updated_at = NOW() WHERE id = $3
+	`, newStock, avgCost, branchProductID)
+	if err != nil {
+		return stockMoveResult{}, err
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO inventory_movements (
+			store_id, branch_id, product_id, movement_type, quantity_change,
+			from_stock_count, to_stock_count, unit_cost, reason_code, reason, note, changed_by,
+			approved_by, reference_table, reference_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`, m.StoreID, m.BranchID, m.ProductID, m.MovementType, m.QuantityChange,
+		currentStock, newStock, unitCost, m.ReasonCode, m.Reason, m.Note, m.ChangedBy,
+		m.ApprovedBy, m.ReferenceTable, m.ReferenceID,
+	)
+	if err != nil {
+		return stockMoveResult{}, err
+	}
+
+	result := stockMoveResult{FromStock: currentStock, ToStock: newStock, UnitCost: unitCost}
+	if m.QuantityChange < 0 && !m.SkipLots {
+		result.Lots, err = takeFromLots(ctx, q, lotMove{
+			StoreID:        m.StoreID,
+			BranchID:       &m.BranchID,
+			ProductID:      m.ProductID,
+			LotID:          m.LotID,
+			Quantity:       -m.QuantityChange,
+			MovementType:   m.MovementType,
+			ChangedBy:      m.ChangedBy,
+			ReferenceTable: m.ReferenceTable,
+			ReferenceID:    m.ReferenceID,
+		})
+		if err != nil {
+			return stockMoveResult{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// warehouseStockMove describes a single change to warehouse_products.on_stock
+type warehouseStockMove struct {
+	StoreID        int64
+	WarehouseID    int64
+	ProductID      int64
+	QuantityChange int
+	UnitCost       *decimal.Decimal
+	MovementType   domain.MovementType
+	ReasonCode     *string
+	Reason         *string
+	Note           *string
+	ChangedBy      *int64
+	ApprovedBy     *int64
+	ReferenceTable *string
+	ReferenceID    *int64
+	LotID          *int64
+	SkipLots       bool
+	AllowNegative  bool
+}
+
+// moveWarehouseStock is the warehouse counterpart of moveBranchStock and writes
+// to warehouse_products and warehouse_movements
+func moveWarehouseStock(ctx context.Context, q txQuerier, m warehouseStockMove) (stockMoveResult, error) {
+	var warehouseProductID int64
+	var currentStock int
+	var avgCost decimal.Decimal
+	err := q.QueryRowContext(ctx, `
+		SELECT id, on_stock, avg_cost FROM warehouse_products
+		WHERE store_id = $1 AND warehouse_id = $2 AND product_id = $3
+		FOR UPDATE
+	`, m.StoreID, m.WarehouseID, m.ProductID).Scan(&warehouseProductID, &currentStock, &avgCost)
+	if err == sql.ErrNoRows {
+		err = q.QueryRowContext(ctx, `
+			INSERT INTO warehouse_products (store_id, warehouse_id, product_id, on_stock)
+			VALUES ($1, $2, $3, 0)
+			ON CONFLICT (warehouse_id, product_id) DO UPDATE SET updated_at = NOW()
+			RETURNING id, on_stock, avg_cost
+		`, m.StoreID, m.WarehouseID, m.ProductID).Scan(&warehouseProductID, &currentStock, &avgCost)
+	}
+	if err != nil {
+		return stockMoveResult{}, err
+	}
+
+	newStock := currentStock + m.QuantityChange
+	if newStock < 0 && !m.AllowNegative {
+		return stockMoveResult{}, fmt.Errorf("%w: product %d has %d on stock in warehouse, needs %d", ErrInsufficientStock, m.ProductID, currentStock, -m.QuantityChange)
+	}
+
+	unitCost := avgCost
+	if m.UnitCost != nil && m.QuantityChange > 0 {
+		unitCost = *m.UnitCost
+		avgCost = movingAverageCost(currentStock, avgCost, m.QuantityChange, unitCost)
+	}
+
+	_, err = q.ExecContext(ctx, `
+		UPDATE warehouse_products SET on_stock = $1, avg_cost = $2, updated_at = NOW() WHERE id = $3
+	`, newStock, avgCost, warehouseProductID)
+	if err != nil {
+		return stockMoveResult{}, err
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO warehouse_movements (
+			store_id, warehouse_id, product_id, movement_type, quantity_change,
+			from_stock_count, to_stock_count, unit_cost, reason_code, reason, note,
+			changed_by, approved_by, reference_table, reference_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`, m.StoreID, m.WarehouseID, m.ProductID, m.MovementType, m.QuantityChange,
+		currentStock, newStock, unitCost, m.ReasonCode, m.Reason, m.Note,
+		m.ChangedBy, m.ApprovedBy, m.ReferenceTable, m.ReferenceID,
+	)
+	if err != nil {
+		return stockMoveResult{}, err
+	}
+
+	result := stockMoveResult{FromStock: currentStock, ToStock: newStock, UnitCost: unitCost}
+	if m.QuantityChange < 0 && !m.SkipLots {
+		result.Lots, err = takeFromLots(ctx, q, lotMove{
+			StoreID:        m.StoreID,
+			WarehouseID:    &m.WarehouseID,
+			ProductID:      m.ProductID,
+			LotID:          m.LotID,
+			Quantity:       -m.QuantityChange,
+			MovementType:   m.MovementType,
+			ChangedBy:      m.ChangedBy,
+			ReferenceTable: m.ReferenceTable,
+			ReferenceID:    m.ReferenceID,
+		})
+		if err != nil {
+			return stockMoveResult{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// moveTransferSourceStock applies a stock change to whichever location a transfer was
+// sent from. Legacy transfers with neither a branch nor a warehouse source are skipped.
+func moveTransferSourceStock(ctx context.Context, q txQuerier, storeID int64, fromBranchID, fromWarehouseID sql.NullInt64, m branchStockMove) (stockMoveResult, error) {
+	switch {
+	case fromBranchID.Valid:
+		m.StoreID = storeID
+		m.BranchID = fromBranchID.Int64
+		return moveBranchStock(ctx, q, m)
+	case fromWarehouseID.Valid:
+		return moveWarehouseStock(ctx, q, warehouseStockMove{
+			StoreID:        storeID,
+			WarehouseID:    fromWarehouseID.Int64,
+			ProductID:      m.ProductID,
+			QuantityChange: m.QuantityChange,
+			UnitCost:       m.UnitCost,
+			MovementType:   m.MovementType,
+			Reason:         m.Reason,
+			Note:           m.Note,
+			ChangedBy:      m.ChangedBy,
+			ReferenceTable: m.ReferenceTable,
+			ReferenceID:    m.ReferenceID,
+			LotID:          m.LotID,
+			SkipLots:       m.SkipLots,
+			AllowNegative:  m.AllowNegative,
+		})
+	}
+	return stockMoveResult{}, nil
+}
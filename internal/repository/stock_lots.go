@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mini-membership/api/internal/domain"
+)
+
+// lotAllocation is the quantity taken from or added to a single lot
+type lotAllocation struct {
+	LotID      int64
+	LotNumber  string
+	ExpiryDate sql.NullTime
+	Quantity   int
+}
+
+// lotMove describes a change to the lots of one product at a branch or a
+// warehouse. Exactly one of BranchID and WarehouseID is set.
+type lotMove struct {
+	StoreID        int64
+	BranchID       *int64
+	WarehouseID    *int64
+	ProductID      int64
+	LotID          *int64
+	LotNumber      string
+	ExpiryDate     sql.NullTime
+	Quantity       int
+	MovementType   domain.MovementType
+	ChangedBy      *int64
+	ReferenceTable *string
+	ReferenceID    *int64
+}
+
+// parseLotExpiry parses an optional YYYY-MM-DD expiry date from a request
+func parseLotExpiry(s *string) (sql.NullTime, error) {
+	if s == nil || *s == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse("2006-01-02", *s)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("invalid expiry date %q, expected YYYY-MM-DD", *s)
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+// checkLotNumber rejects a receipt without a lot number for a product that tracks lots
+func checkLotNumber(ctx context.Context, q txQuerier, storeID, productID int64, lotNumber *string) error {
+	if lotNumber != nil && *lotNumber != "" {
+		return nil
+	}
+	var trackLots bool
+	err := q.QueryRowContext(ctx, `
+		SELECT track_lots FROM products WHERE id = $1 AND store_id = $2
+	`, productID, storeID).Scan(&trackLots)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if trackLots {
+		return fmt.Errorf("product %d tracks lots: lot_number is required", productID)
+	}
+	return nil
+}
+
+// addToLot adds m.Quantity to the named lot at the location, creating the lot on
+// first receipt. Callers hold the branch_products or warehouse_products row lock,
+// which serialises concurrent receipts of the same product.
+func addToLot(ctx context.Context, q txQuerier, m lotMove) (lotAllocation, error) {
+	alloc := lotAllocation{LotNumber: m.LotNumber, ExpiryDate: m.ExpiryDate, Quantity: m.Quantity}
+
+	err := q.QueryRowContext(ctx, `
+		SELECT id, expiry_date FROM stock_lots
+		WHERE store_id = $1 AND product_id = $2 AND lot_number = $3
+			AND branch_id IS NOT DISTINCT FROM $4::BIGINT AND warehouse_id IS NOT DISTINCT FROM $5::BIGINT
+		FOR UPDATE
+	`, m.StoreID, m.ProductID, m.LotNumber, m.BranchID, m.WarehouseID).Scan(&alloc.LotID, &alloc.ExpiryDate)
+	switch {
+	case err == sql.ErrNoRows:
+		err = q.QueryRowContext(ctx, `
+			INSERT INTO stock_lots (store_id, branch_id, warehouse_id, product_id, lot_number, expiry_date, quantity)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id
+		`, m.StoreID, m.BranchID, m.WarehouseID, m.ProductID, m.LotNumber, m.ExpiryDate, m.Quantity).Scan(&alloc.LotID)
+		if err != nil {
+			return lotAllocation{}, err
+		}
+	case err != nil:
+		return lotAllocation{}, err
+	default:
+		// The first expiry date recorded for a lot wins
+		if !alloc.ExpiryDate.Valid {
+			alloc.ExpiryDate = m.ExpiryDate
+		}
+		_, err = q.ExecContext(ctx, `
+			UPDATE stock_lots SET quantity = quantity + $1, expiry_date = $2 WHERE id = $3
+		`, m.Quantity, alloc.ExpiryDate, alloc.LotID)
+		if err != nil {
+			return lotAllocation{}, err
+		}
+	}
+
+	if err := insertLotMovement(ctx, q, m, alloc.LotID, m.Quantity); err != nil {
+		return lotAllocation{}, err
+	}
+	return alloc, nil
+}
+
+// takeFromLots removes m.Quantity from the location's lots. With LotID set the
+// whole quantity must come from that lot; otherwise lots are drawn first-expiry-
+// first-out and any quantity the lots cannot cover is treated as untracked stock.
+func takeFromLots(ctx context.Context, q txQuerier, m lotMove) ([]lotAllocation, error) {
+	if m.LotID != nil {
+		var alloc lotAllocation
+		var available int
+		err := q.QueryRowContext(ctx, `
+			SELECT id, lot_number, expiry_date, quantity FROM stock_lots
+			WHERE id = $1 AND store_id = $2 AND product_id = $3
+				AND branch_id IS NOT DISTINCT FROM $4::BIGINT AND warehouse_id IS NOT DISTINCT FROM $5::BIGINT
+			FOR UPDATE
+		`, *m.LotID, m.StoreID, m.ProductID, m.BranchID, m.WarehouseID).Scan(&alloc.LotID, &alloc.LotNumber, &alloc.ExpiryDate, &available)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("lot %d not found at this location", *m.LotID)
+			}
+			return nil, err
+		}
+		if available < m.Quantity {
+			return nil, fmt.Errorf("%w: lot %s has %d left, needs %d", ErrInsufficientStock, alloc.LotNumber, available, m.Quantity)
+		}
+		alloc.Quantity = m.Quantity
+		if err := takeLotQuantity(ctx, q, m, alloc); err != nil {
+			return nil, err
+		}
+		return []lotAllocation{alloc}, nil
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, lot_number, expiry_date, quantity FROM stock_lots
+		WHERE store_id = $1 AND product_id = $2 AND quantity > 0
+			AND branch_id IS NOT DISTINCT FROM $3::BIGINT AND warehouse_id IS NOT DISTINCT FROM $4::BIGINT
+		ORDER BY expiry_date ASC NULLS LAST, id ASC
+		FOR UPDATE
+	`, m.StoreID, m.ProductID, m.BranchID, m.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+
+	var allocations []lotAllocation
+	remaining := m.Quantity
+	for rows.Next() && remaining > 0 {
+		var alloc lotAllocation
+		var available int
+		if err := rows.Scan(&alloc.LotID, &alloc.LotNumber, &alloc.ExpiryDate, &available); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		alloc.Quantity = available
+		if alloc.Quantity > remaining {
+			alloc.Quantity = remaining
+		}
+		remaining -= alloc.Quantity
+		allocations = append(allocations, alloc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, alloc := range allocations {
+		if err := takeLotQuantity(ctx, q, m, alloc); err != nil {
+			return nil, err
+		}
+	}
+	return allocations, nil
+}
+
+func takeLotQuantity(ctx context.Context, q txQuerier, m lotMove, alloc lotAllocation) error {
+	_, err := q.ExecContext(ctx, `
+		UPDATE stock_lots SET quantity = quantity - $1 WHERE id = $2
+	`, alloc.Quantity, alloc.LotID)
+	if err != nil {
+		return err
+	}
+	return insertLotMovement(ctx, q, m, alloc.LotID, -alloc.Quantity)
+}
+
+func insertLotMovement(ctx context.Context, q txQuerier, m lotMove, lotID int64, quantityChange int) error {
+	_, err := q.ExecContext(ctx, `
+		INSERT INTO stock_lot_movements (
+			store_id, lot_id, movement_type, quantity_change, changed_by, reference_table, reference_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, m.StoreID, lotID, m.MovementType, quantityChange, m.ChangedBy, m.ReferenceTable, m.ReferenceID)
+	return err
+}
+
+// returnTakenLots puts back the quantities that earlier takenType movements for
+// m's reference drew from lots at m's location. Each lot gets back what was taken
+// from it, recorded as m.MovementType.
+func returnTakenLots(ctx context.Context, q txQuerier, m lotMove, takenType domain.MovementType) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT sl.lot_number, sl.expiry_date, -SUM(slm.quantity_change)
+		FROM stock_lot_movements slm
+		JOIN stock_lots sl ON sl.id = slm.lot_id
+		WHERE slm.store_id = $1 AND slm.reference_table = $2 AND slm.reference_id = $3
+			AND slm.movement_type = $4 AND sl.product_id = $5
+			AND sl.branch_id IS NOT DISTINCT FROM $6::BIGINT AND sl.warehouse_id IS NOT DISTINCT FROM $7::BIGINT
+		GROUP BY sl.id, sl.lot_number, sl.expiry_date
+		HAVING SUM(slm.quantity_change) < 0
+		ORDER BY sl.id
+	`, m.StoreID, m.ReferenceTable, m.ReferenceID, takenType, m.ProductID, m.BranchID, m.WarehouseID)
+	if err != nil {
+		return err
+	}
+
+	var allocations []lotAllocation
+	for rows.Next() {
+		var alloc lotAllocation
+		if err := rows.Scan(&alloc.LotNumber, &alloc.ExpiryDate, &alloc.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		allocations = append(allocations, alloc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, alloc := range allocations {
+		lm := m
+		lm.LotNumber = alloc.LotNumber
+		lm.ExpiryDate = alloc.ExpiryDate
+		lm.Quantity = alloc.Quantity
+		if _, err := addToLot(ctx, q, lm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
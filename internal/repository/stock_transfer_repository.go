@@ -3,9 +3,12 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/mini-membership/api/internal/domain"
+	"github.com/shopspring/decimal"
 )
 
 type StockTransferRepository interface {
@@ -13,9 +16,17 @@ type StockTransferRepository interface {
 	GetByID(ctx context.Context, storeID, transferID int64) (*domain.StockTransferResponse, error)
 	GetByBranch(ctx context.Context, storeID, branchID int64, limit, offset int) (*domain.StockTransferListResponse, error)
 	GetPendingTransfers(ctx context.Context, storeID, branchID int64) ([]domain.StockTransferResponse, error)
+	GetOutgoingTransfers(ctx context.Context, storeID, branchID int64) ([]domain.StockTransferResponse, error)
+	GetWarehouseQueue(ctx context.Context, storeID, warehouseID int64) ([]domain.StockTransferResponse, error)
+	UpdatePickedCounts(ctx context.Context, storeID, transferID int64, items []domain.PickStockTransferItemInput) error
 	UpdateStatus(ctx context.Context, storeID, transferID int64, status domain.StockTransferStatus, receivedBy *int64) error
 	UpdateReceiveCounts(ctx context.Context, transferID int64, items []domain.UpdateStockTransferItemInput) error
-	ReceiveAndAddStock(ctx context.Context, storeID, branchID, transferID int64, items []domain.UpdateStockTransferItemInput, receivedBy int64) error
+	DispatchAndDeductStock(ctx context.Context, storeID, transferID int64, items []domain.DispatchStockTransferItemInput, note *string, sentBy int64) error
+	ReceiveAndAddStock(ctx context.Context, storeID, branchID, transferID int64, items []domain.UpdateStockTransferItemInput, note *string, closeTransfer bool, receivedBy int64) error
+	GetReceipts(ctx context.Context, storeID, transferID int64) ([]domain.StockTransferReceiptResponse, error)
+	GetDiscrepancyByID(ctx context.Context, storeID, discrepancyID int64) (*domain.StockTransferDiscrepancy, error)
+	GetDiscrepancies(ctx context.Context, storeID int64, branchID, warehouseID *int64, status domain.DiscrepancyStatus) ([]domain.StockTransferDiscrepancy, error)
+	ResolveDiscrepancy(ctx context.Context, storeID, discrepancyID int64, resolution domain.DiscrepancyResolution, note *string, resolvedBy int64) error
 	GetTransferItems(ctx context.Context, transferID int64) ([]domain.StockTransferItemResponse, error)
 }
 
@@ -34,14 +45,14 @@ func (r *stockTransferRepository) Create(ctx context.Context, storeID int64, req
 	}
 	defer tx.Rollback()
 
-	// Create stock transfer request (status: CREATED - waiting for central to process)
+	// Create stock transfer request (status: CREATED - waiting for the source to dispatch)
 	var transfer domain.StockTransfer
 	err = tx.QueryRowContext(ctx, `
-		INSERT INTO stock_transfers (store_id, from_branch_id, to_branch_id, status, note)
-		VALUES ($1, $2, $3, 'CREATED', $4)
-		RETURNING id, store_id, from_branch_id, to_branch_id, status, sent_by, sent_at, note, created_at, updated_at
-	`, storeID, req.FromBranchID, req.ToBranchID, req.Note).Scan(
-		&transfer.ID, &transfer.StoreID, &transfer.FromBranchID, &transfer.ToBranchID,
+		INSERT INTO stock_transfers (store_id, from_branch_id, from_warehouse_id, to_branch_id, status, note)
+		VALUES ($1, $2, $3, $4, 'CREATED', $5)
+		RETURNING id, store_id, from_branch_id, from_warehouse_id, to_branch_id, status, sent_by, sent_at, note, created_at, updated_at
+	`, storeID, req.FromBranchID, req.FromWarehouseID, req.ToBranchID, req.Note).Scan(
+		&transfer.ID, &transfer.StoreID, &transfer.FromBranchID, &transfer.FromWarehouseID, &transfer.ToBranchID,
 		&transfer.Status, &transfer.SentBy, &transfer.SentAt, &transfer.Note,
 		&transfer.CreatedAt, &transfer.UpdatedAt,
 	)
@@ -52,13 +63,13 @@ func (r *stockTransferRepository) Create(ctx context.Context, storeID int64, req
 	// Create transfer items (requested quantities)
 	for _, item := range req.Items {
 		_, err = tx.ExecContext(ctx, `
-			INSERT INTO stock_transfer_items (stock_transfer_id, product_id, send_count)
-			VALUES ($1, $2, $3)
+			INSERT INTO stock_transfer_items (stock_transfer_id, product_id, requested_count, send_count)
+			VALUES ($1, $2, $3, $3)
 		`, transfer.ID, item.ProductID, item.SendCount)
 		if err != nil {
 			return nil, err
 		}
-		// Note: Stock is NOT reduced here - it will be reduced when central sends the goods
+		// Note: Stock is NOT reduced here - it will be reduced when the transfer is dispatched
 	}
 
 	if err = tx.Commit(); err != nil {
@@ -70,20 +81,23 @@ func (r *stockTransferRepository) Create(ctx context.Context, storeID int64, req
 
 func (r *stockTransferRepository) GetByID(ctx context.Context, storeID, transferID int64) (*domain.StockTransferResponse, error) {
 	var resp domain.StockTransferResponse
-	var sentByName, receivedByName, fromBranchName sql.NullString
+	var sentByName, receivedByName, fromBranchName, fromWarehouseName sql.NullString
 
 	err := r.db.QueryRowContext(ctx, `
 		SELECT 
-			st.id, st.from_branch_id, fb.branch_name, st.to_branch_id, tb.branch_name,
+			st.id, st.from_branch_id, fb.branch_name, st.from_warehouse_id, fw.warehouse_name,
+			st.to_branch_id, tb.branch_name,
 			st.status, ss.email, rs.email, st.sent_at, st.received_at, st.note, st.created_at
 		FROM stock_transfers st
 		LEFT JOIN branches fb ON st.from_branch_id = fb.id
+		LEFT JOIN warehouses fw ON st.from_warehouse_id = fw.id
 		JOIN branches tb ON st.to_branch_id = tb.id
 		LEFT JOIN staff_accounts ss ON st.sent_by = ss.id
 		LEFT JOIN staff_accounts rs ON st.received_by = rs.id
 		WHERE st.id = $1 AND st.store_id = $2
 	`, transferID, storeID).Scan(
-		&resp.ID, &resp.FromBranchID, &fromBranchName, &resp.ToBranchID, &resp.ToBranchName,
+		&resp.ID, &resp.FromBranchID, &fromBranchName, &resp.FromWarehouseID, &fromWarehouseName,
+		&resp.ToBranchID, &resp.ToBranchName,
 		&resp.Status, &sentByName, &receivedByName, &resp.SentAt, &resp.ReceivedAt, &resp.Note, &resp.CreatedAt,
 	)
 	if err != nil {
@@ -96,6 +110,9 @@ func (r *stockTransferRepository) GetByID(ctx context.Context, storeID, transfer
 	if fromBranchName.Valid {
 		resp.FromBranchName = &fromBranchName.String
 	}
+	if fromWarehouseName.Valid {
+		resp.FromWarehouseName = &fromWarehouseName.String
+	}
 	if sentByName.Valid {
 		resp.SentByName = &sentByName.String
 	}
@@ -127,10 +144,12 @@ func (r *stockTransferRepository) GetByBranch(ctx context.Context, storeID, bran
 	// Get transfers
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT 
-			st.id, st.from_branch_id, fb.branch_name, st.to_branch_id, tb.branch_name,
+			st.id, st.from_branch_id, fb.branch_name, st.from_warehouse_id, fw.warehouse_name,
+			st.to_branch_id, tb.branch_name,
 			st.status, ss.email, rs.email, st.sent_at, st.received_at, st.note, st.created_at
 		FROM stock_transfers st
 		LEFT JOIN branches fb ON st.from_branch_id = fb.id
+		LEFT JOIN warehouses fw ON st.from_warehouse_id = fw.id
 		JOIN branches tb ON st.to_branch_id = tb.id
 		LEFT JOIN staff_accounts ss ON st.sent_by = ss.id
 		LEFT JOIN staff_accounts rs ON st.received_by = rs.id
@@ -146,10 +165,11 @@ func (r *stockTransferRepository) GetByBranch(ctx context.Context, storeID, bran
 	var transfers []domain.StockTransferResponse
 	for rows.Next() {
 		var resp domain.StockTransferResponse
-		var sentByName, receivedByName, fromBranchName sql.NullString
+		var sentByName, receivedByName, fromBranchName, fromWarehouseName sql.NullString
 
 		err := rows.Scan(
-			&resp.ID, &resp.FromBranchID, &fromBranchName, &resp.ToBranchID, &resp.ToBranchName,
+			&resp.ID, &resp.FromBranchID, &fromBranchName, &resp.FromWarehouseID, &fromWarehouseName,
+			&resp.ToBranchID, &resp.ToBranchName,
 			&resp.Status, &sentByName, &receivedByName, &resp.SentAt, &resp.ReceivedAt, &resp.Note, &resp.CreatedAt,
 		)
 		if err != nil {
@@ -159,6 +179,9 @@ func (r *stockTransferRepository) GetByBranch(ctx context.Context, storeID, bran
 		if fromBranchName.Valid {
 			resp.FromBranchName = &fromBranchName.String
 		}
+		if fromWarehouseName.Valid {
+			resp.FromWarehouseName = &fromWarehouseName.String
+		}
 		if sentByName.Valid {
 			resp.SentByName = &sentByName.String
 		}
@@ -229,7 +252,7 @@ func (r *stockTransferRepository) UpdateReceiveCounts(ctx context.Context, trans
 
 func (r *stockTransferRepository) GetTransferItems(ctx context.Context, transferID int64) ([]domain.StockTransferItemResponse, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT sti.id, sti.product_id, p.product_name, sti.send_count, sti.receive_count
+		SELECT sti.id, sti.product_id, p.product_name, sti.requested_count, sti.picked_count, sti.send_count, sti.receive_count
 		FROM stock_transfer_items sti
 		JOIN products p ON sti.product_id = p.id
 		WHERE sti.stock_transfer_id = $1
@@ -242,7 +265,7 @@ func (r *stockTransferRepository) GetTransferItems(ctx context.Context, transfer
 	var items []domain.StockTransferItemResponse
 	for rows.Next() {
 		var item domain.StockTransferItemResponse
-		err := rows.Scan(&item.ID, &item.ProductID, &item.ProductName, &item.SendCount, &item.ReceiveCount)
+		err := rows.Scan(&item.ID, &item.ProductID, &item.ProductName, &item.RequestedCount, &item.PickedCount, &item.SendCount, &item.ReceiveCount)
 		if err != nil {
 			return nil, err
 		}
@@ -255,14 +278,16 @@ func (r *stockTransferRepository) GetTransferItems(ctx context.Context, transfer
 func (r *stockTransferRepository) GetPendingTransfers(ctx context.Context, storeID, branchID int64) ([]domain.StockTransferResponse, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT 
-			st.id, st.from_branch_id, fb.branch_name, st.to_branch_id, tb.branch_name,
+			st.id, st.from_branch_id, fb.branch_name, st.from_warehouse_id, fw.warehouse_name,
+			st.to_branch_id, tb.branch_name,
 			st.status, ss.email, rs.email, st.sent_at, st.received_at, st.note, st.created_at
 		FROM stock_transfers st
 		LEFT JOIN branches fb ON st.from_branch_id = fb.id
+		LEFT JOIN warehouses fw ON st.from_warehouse_id = fw.id
 		JOIN branches tb ON st.to_branch_id = tb.id
 		LEFT JOIN staff_accounts ss ON st.sent_by = ss.id
 		LEFT JOIN staff_accounts rs ON st.received_by = rs.id
-		WHERE st.store_id = $1 AND st.to_branch_id = $2 AND st.status IN ('CREATED', 'SENT')
+		WHERE st.store_id = $1 AND st.to_branch_id = $2 AND st.status IN ('CREATED', 'PICKING', 'SENT', 'PARTIALLY_RECEIVED')
 		ORDER BY st.created_at DESC
 	`, storeID, branchID)
 	if err != nil {
@@ -273,10 +298,79 @@ func (r *stockTransferRepository) GetPendingTransfers(ctx context.Context, store
 	var transfers []domain.StockTransferResponse
 	for rows.Next() {
 		var resp domain.StockTransferResponse
-		var sentByName, receivedByName, fromBranchName sql.NullString
+		var sentByName, receivedByName, fromBranchName, fromWarehouseName sql.NullString
+
+		err := rows.Scan(
+			&resp.ID, &resp.FromBranchID, &fromBranchName, &resp.FromWarehouseID, &fromWarehouseName,
+			&resp.ToBranchID, &resp.ToBranchName,
+			&resp.Status, &sentByName, &receivedByName, &resp.SentAt, &resp.ReceivedAt, &resp.Note, &resp.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if fromBranchName.Valid {
+			resp.FromBranchName = &fromBranchName.String
+		}
+		if fromWarehouseName.Valid {
+			resp.FromWarehouseName = &fromWarehouseName.String
+		}
+		if sentByName.Valid {
+			resp.SentByName = &sentByName.String
+		}
+		if receivedByName.Valid {
+			resp.ReceivedByName = &receivedByName.String
+		}
+
+		items, err := r.GetTransferItems(ctx, resp.ID)
+		if err != nil {
+			return nil, err
+		}
+		resp.Items = items
+
+		transfers = append(transfers, resp)
+	}
+
+	return transfers, nil
+}
+
+func (r *stockTransferRepository) GetOutgoingTransfers(ctx context.Context, storeID, branchID int64) ([]domain.StockTransferResponse, error) {
+	return r.listTransfers(ctx, `st.store_id = $1 AND st.from_branch_id = $2 AND st.status = 'CREATED'`, storeID, branchID)
+}
+
+func (r *stockTransferRepository) GetWarehouseQueue(ctx context.Context, storeID, warehouseID int64) ([]domain.StockTransferResponse, error) {
+	return r.listTransfers(ctx, `st.store_id = $1 AND st.from_warehouse_id = $2 AND st.status IN ('CREATED', 'PICKING')`, storeID, warehouseID)
+}
+
+// listTransfers returns transfers matching the given condition, oldest first, with their items
+func (r *stockTransferRepository) listTransfers(ctx context.Context, where string, args ...interface{}) ([]domain.StockTransferResponse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT 
+			st.id, st.from_branch_id, fb.branch_name, st.from_warehouse_id, fw.warehouse_name,
+			st.to_branch_id, tb.branch_name,
+			st.status, ss.email, rs.email, st.sent_at, st.received_at, st.note, st.created_at
+		FROM stock_transfers st
+		LEFT JOIN branches fb ON st.from_branch_id = fb.id
+		LEFT JOIN warehouses fw ON st.from_warehouse_id = fw.id
+		JOIN branches tb ON st.to_branch_id = tb.id
+		LEFT JOIN staff_accounts ss ON st.sent_by = ss.id
+		LEFT JOIN staff_accounts rs ON st.received_by = rs.id
+		WHERE `+where+`
+		ORDER BY st.created_at ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []domain.StockTransferResponse
+	for rows.Next() {
+		var resp domain.StockTransferResponse
+		var sentByName, receivedByName, fromBranchName, fromWarehouseName sql.NullString
 
 		err := rows.Scan(
-			&resp.ID, &resp.FromBranchID, &fromBranchName, &resp.ToBranchID, &resp.ToBranchName,
+			&resp.ID, &resp.FromBranchID, &fromBranchName, &resp.FromWarehouseID, &fromWarehouseName,
+			&resp.ToBranchID, &resp.ToBranchName,
 			&resp.Status, &sentByName, &receivedByName, &resp.SentAt, &resp.ReceivedAt, &resp.Note, &resp.CreatedAt,
 		)
 		if err != nil {
@@ -286,6 +380,9 @@ func (r *stockTransferRepository) GetPendingTransfers(ctx context.Context, store
 		if fromBranchName.Valid {
 			resp.FromBranchName = &fromBranchName.String
 		}
+		if fromWarehouseName.Valid {
+			resp.FromWarehouseName = &fromWarehouseName.String
+		}
 		if sentByName.Valid {
 			resp.SentByName = &sentByName.String
 		}
@@ -305,35 +402,327 @@ func (r *stockTransferRepository) GetPendingTransfers(ctx context.Context, store
 	return transfers, nil
 }
 
-func (r *stockTransferRepository) ReceiveAndAddStock(ctx context.Context, storeID, branchID, transferID int64, items []domain.UpdateStockTransferItemInput, receivedBy int64) error {
+func (r *stockTransferRepository) UpdatePickedCounts(ctx context.Context, storeID, transferID int64, items []domain.PickStockTransferItemInput) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	// Update receive counts and add stock for each item
+	var status domain.StockTransferStatus
+	err = tx.QueryRowContext(ctx, `
+		SELECT status FROM stock_transfers
+		WHERE id = $1 AND store_id = $2
+		FOR UPDATE
+	`, transferID, storeID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("transfer not found")
+		}
+		return err
+	}
+	if status != domain.StockTransferStatusCreated && status != domain.StockTransferStatusPicking {
+		return errors.New("transfer can no longer be picked")
+	}
+
 	for _, item := range items {
-		// Update receive count
+		res, err := tx.ExecContext(ctx, `
+			UPDATE stock_transfer_items 
+			SET picked_count = $1, updated_at = NOW()
+			WHERE stock_transfer_id = $2 AND product_id = $3
+		`, item.PickedCount, transferID, item.ProductID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("product %d is not part of this transfer", item.ProductID)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE stock_transfers SET status = 'PICKING', updated_at = NOW()
+		WHERE id = $1 AND store_id = $2
+	`, transferID, storeID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DispatchAndDeductStock confirms send counts and deducts them from the transfer's source,
+// either a branch (inventory_movements) or a warehouse (warehouse_movements)
+func (r *stockTransferRepository) DispatchAndDeductStock(ctx context.Context, storeID, transferID int64, items []domain.DispatchStockTransferItemInput, note *string, sentBy int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the transfer so it cannot be dispatched or cancelled twice
+	var fromBranchID, fromWarehouseID sql.NullInt64
+	var status domain.StockTransferStatus
+	err = tx.QueryRowContext(ctx, `
+		SELECT from_branch_id, from_warehouse_id, status FROM stock_transfers
+		WHERE id = $1 AND store_id = $2
+		FOR UPDATE
+	`, transferID, storeID).Scan(&fromBranchID, &fromWarehouseID, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("transfer not found")
+		}
+		return err
+	}
+	if status != domain.StockTransferStatusCreated && status != domain.StockTransferStatusPicking {
+		return errors.New("transfer is not waiting to be dispatched")
+	}
+
+	// Send count defaults to the picked count once picking started, otherwise the requested count
+	rows, err := tx.QueryContext(ctx, `
+		SELECT product_id, requested_count, picked_count FROM stock_transfer_items
+		WHERE stock_transfer_id = $1
+		ORDER BY product_id
+	`, transferID)
+	if err != nil {
+		return err
+	}
+	var productIDs []int64
+	sendCounts := make(map[int64]int)
+	for rows.Next() {
+		var productID int64
+		var requested, picked int
+		if err := rows.Scan(&productID, &requested, &picked); err != nil {
+			rows.Close()
+			return err
+		}
+		productIDs = append(productIDs, productID)
+		if status == domain.StockTransferStatusPicking {
+			sendCounts[productID] = picked
+		} else {
+			sendCounts[productID] = requested
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if _, ok := sendCounts[item.ProductID]; !ok {
+			return fmt.Errorf("product %d is not part of this transfer", item.ProductID)
+		}
+		if item.SendCount < 0 {
+			return fmt.Errorf("send count for product %d cannot be negative", item.ProductID)
+		}
+		sendCounts[item.ProductID] = item.SendCount
+	}
+
+	refTable := "stock_transfers"
+	reason := "Stock transfer dispatch"
+	for _, productID := range productIDs {
+		sendCount := sendCounts[productID]
+
 		_, err = tx.ExecContext(ctx, `
 			UPDATE stock_transfer_items 
-			SET receive_count = $1, updated_at = NOW()
+			SET send_count = $1, updated_at = NOW()
 			WHERE stock_transfer_id = $2 AND product_id = $3
-		`, item.ReceiveCount, transferID, item.ProductID)
+		`, sendCount, transferID, productID)
+		if err != nil {
+			return err
+		}
+
+		if sendCount == 0 {
+			continue
+		}
+
+		moved, err := moveTransferSourceStock(ctx, tx, storeID, fromBranchID, fromWarehouseID, branchStockMove{
+			ProductID:      productID,
+			QuantityChange: -sendCount,
+			MovementType:   domain.MovementTypeTransferOut,
+			Reason:         &reason,
+			Note:           note,
+			ChangedBy:      &sentBy,
+			ReferenceTable: &refTable,
+			ReferenceID:    &transferID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Goods travel at the source's average cost and arrive at the same cost
+		_, err = tx.ExecContext(ctx, `
+			UPDATE stock_transfer_items SET unit_cost = $1
+			WHERE stock_transfer_id = $2 AND product_id = $3
+		`, moved.UnitCost, transferID, productID)
+		if err != nil {
+			return err
+		}
+
+		// Remember which lots left the source so the receiving branch gets the same lots
+		for _, lot := range moved.Lots {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO stock_transfer_item_lots (stock_transfer_id, product_id, lot_number, expiry_date, send_count)
+				VALUES ($1, $2, $3, $4, $5)
+			`, transferID, productID, lot.LotNumber, lot.ExpiryDate, lot.Quantity)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE stock_transfers 
+		SET status = 'SENT', sent_by = $1, sent_at = NOW(), updated_at = NOW()
+		WHERE id = $2 AND store_id = $3
+	`, sentBy, transferID, storeID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReceiveAndAddStock records one receipt against a transfer, adding the received quantities
+// to the branch with TRANSFER_IN movements. The transfer closes when every item is fully
+// received or closeTransfer is set; any shortfall at close becomes a discrepancy record.
+func (r *stockTransferRepository) ReceiveAndAddStock(ctx context.Context, storeID, branchID, transferID int64, items []domain.UpdateStockTransferItemInput, note *string, closeTransfer bool, receivedBy int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the transfer so concurrent receipts are applied one after another
+	var status domain.StockTransferStatus
+	err = tx.QueryRowContext(ctx, `
+		SELECT status FROM stock_transfers
+		WHERE id = $1 AND store_id = $2 AND to_branch_id = $3
+		FOR UPDATE
+	`, transferID, storeID, branchID).Scan(&status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("transfer not found")
+		}
+		return err
+	}
+	if status != domain.StockTransferStatusSent && status != domain.StockTransferStatusPartiallyReceived {
+		return errors.New("transfer is not waiting to be received")
+	}
+
+	var receiptID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO stock_transfer_receipts (store_id, stock_transfer_id, received_by, note)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, storeID, transferID, receivedBy, note).Scan(&receiptID)
+	if err != nil {
+		return err
+	}
+
+	refTable := "stock_transfers"
+	reason := "Stock transfer receipt"
+
+	for _, item := range items {
+		if item.ReceiveCount < 0 {
+			return fmt.Errorf("receive count for product %d cannot be negative", item.ProductID)
+		}
+		if item.ReceiveCount == 0 {
+			continue
+		}
+
+		// Accumulate the running total; never accept more than was sent
+		var sendCount, receiveCount int
+		var unitCost decimal.NullDecimal
+		err = tx.QueryRowContext(ctx, `
+			UPDATE stock_transfer_items 
+			SET receive_count = receive_count + $1, updated_at = NOW()
+			WHERE stock_transfer_id = $2 AND product_id = $3
+			RETURNING send_count, receive_count, unit_cost
+		`, item.ReceiveCount, transferID, item.ProductID).Scan(&sendCount, &receiveCount, &unitCost)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("product %d is not part of this transfer", item.ProductID)
+			}
+			return err
+		}
+		if receiveCount > sendCount {
+			return fmt.Errorf("product %d: received %d exceeds sent %d", item.ProductID, receiveCount, sendCount)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO stock_transfer_receipt_items (receipt_id, product_id, receive_count)
+			VALUES ($1, $2, $3)
+		`, receiptID, item.ProductID, item.ReceiveCount)
+		if err != nil {
+			return err
+		}
+
+		// Add stock to branch_products with a TRANSFER_IN movement at the dispatch cost
+		var receivedCost *decimal.Decimal
+		if unitCost.Valid {
+			receivedCost = &unitCost.Decimal
+		}
+		_, err = moveBranchStock(ctx, tx, branchStockMove{
+			StoreID:        storeID,
+			BranchID:       branchID,
+			ProductID:      item.ProductID,
+			QuantityChange: item.ReceiveCount,
+			UnitCost:       receivedCost,
+			MovementType:   domain.MovementTypeTransferIn,
+			Reason:         &reason,
+			Note:           note,
+			ChangedBy:      &receivedBy,
+			ReferenceTable: &refTable,
+			ReferenceID:    &transferID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = receiveTransferLots(ctx, tx, storeID, branchID, transferID, item.ProductID, item.ReceiveCount, receivedBy)
 		if err != nil {
 			return err
 		}
+	}
 
-		// Add stock to branch_products (upsert)
+	var outstanding int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM stock_transfer_items
+		WHERE stock_transfer_id = $1 AND receive_count < send_count
+	`, transferID).Scan(&outstanding)
+	if err != nil {
+		return err
+	}
+
+	if outstanding > 0 && !closeTransfer {
 		_, err = tx.ExecContext(ctx, `
-			INSERT INTO branch_products (store_id, branch_id, product_id, on_stock)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (branch_id, product_id) 
-			DO UPDATE SET on_stock = branch_products.on_stock + $4, updated_at = NOW()
-		`, storeID, branchID, item.ProductID, item.ReceiveCount)
+			UPDATE stock_transfers SET status = 'PARTIALLY_RECEIVED', updated_at = NOW()
+			WHERE id = $1 AND store_id = $2
+		`, transferID, storeID)
 		if err != nil {
 			return err
 		}
+		return tx.Commit()
+	}
+
+	// Closing: record every shortfall so the missing units are accounted for
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO stock_transfer_discrepancies (
+			store_id, stock_transfer_id, product_id, send_count, receive_count, missing_count
+		)
+		SELECT $1, stock_transfer_id, product_id, send_count, receive_count, send_count - receive_count
+		FROM stock_transfer_items
+		WHERE stock_transfer_id = $2 AND receive_count < send_count
+	`, storeID, transferID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE stock_transfer_receipts SET is_final = true WHERE id = $1
+	`, receiptID)
+	if err != nil {
+		return err
 	}
 
 	// Update transfer status to RECEIVED
@@ -348,3 +737,384 @@ func (r *stockTransferRepository) ReceiveAndAddStock(ctx context.Context, storeI
 
 	return tx.Commit()
 }
+
+func (r *stockTransferRepository) GetReceipts(ctx context.Context, storeID, transferID int64) ([]domain.StockTransferReceiptResponse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT r.id, s.email, r.note, r.is_final, r.created_at
+		FROM stock_transfer_receipts r
+		LEFT JOIN staff_accounts s ON r.received_by = s.id
+		WHERE r.store_id = $1 AND r.stock_transfer_id = $2
+		ORDER BY r.created_at ASC, r.id ASC
+	`, storeID, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var receipts []domain.StockTransferReceiptResponse
+	for rows.Next() {
+		var receipt domain.StockTransferReceiptResponse
+		var receivedByName sql.NullString
+		if err := rows.Scan(&receipt.ID, &receivedByName, &receipt.Note, &receipt.IsFinal, &receipt.CreatedAt); err != nil {
+			return nil, err
+		}
+		if receivedByName.Valid {
+			receipt.ReceivedByName = &receivedByName.String
+		}
+		receipts = append(receipts, receipt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range receipts {
+		itemRows, err := r.db.QueryContext(ctx, `
+			SELECT ri.product_id, p.product_name, ri.receive_count
+			FROM stock_transfer_receipt_items ri
+			JOIN products p ON ri.product_id = p.id
+			WHERE ri.receipt_id = $1
+			ORDER BY p.product_name ASC
+		`, receipts[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		for itemRows.Next() {
+			var item domain.StockTransferReceiptItemResponse
+			if err := itemRows.Scan(&item.ProductID, &item.ProductName, &item.ReceiveCount); err != nil {
+				itemRows.Close()
+				return nil, err
+			}
+			receipts[i].Items = append(receipts[i].Items, item)
+		}
+		itemRows.Close()
+	}
+
+	return receipts, nil
+}
+
+const discrepancySelect = `
+	SELECT
+		d.id, d.stock_transfer_id, st.from_branch_id, fb.branch_name, st.from_warehouse_id, fw.warehouse_name,
+		st.to_branch_id, tb.branch_name, d.product_id, p.product_name,
+		d.send_count, d.receive_count, d.missing_count, d.status, d.resolution, d.resolution_note,
+		rs.email, d.resolved_at, d.resent_transfer_id, d.created_at
+	FROM stock_transfer_discrepancies d
+	JOIN stock_transfers st ON d.stock_transfer_id = st.id
+	LEFT JOIN branches fb ON st.from_branch_id = fb.id
+	LEFT JOIN warehouses fw ON st.from_warehouse_id = fw.id
+	JOIN branches tb ON st.to_branch_id = tb.id
+	JOIN products p ON d.product_id = p.id
+	LEFT JOIN staff_accounts rs ON d.resolved_by = rs.id
+`
+
+func scanDiscrepancy(row interface{ Scan(...interface{}) error }) (*domain.StockTransferDiscrepancy, error) {
+	var d domain.StockTransferDiscrepancy
+	var fromBranchName, fromWarehouseName, resolvedByName sql.NullString
+	err := row.Scan(
+		&d.ID, &d.StockTransferID, &d.FromBranchID, &fromBranchName, &d.FromWarehouseID, &fromWarehouseName,
+		&d.ToBranchID, &d.ToBranchName, &d.ProductID, &d.ProductName,
+		&d.SendCount, &d.ReceiveCount, &d.MissingCount, &d.Status, &d.Resolution, &d.ResolutionNote,
+		&resolvedByName, &d.ResolvedAt, &d.ResentTransferID, &d.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if fromBranchName.Valid {
+		d.FromBranchName = &fromBranchName.String
+	}
+	if fromWarehouseName.Valid {
+		d.FromWarehouseName = &fromWarehouseName.String
+	}
+	if resolvedByName.Valid {
+		d.ResolvedByName = &resolvedByName.String
+	}
+	return &d, nil
+}
+
+func (r *stockTransferRepository) GetDiscrepancyByID(ctx context.Context, storeID, discrepancyID int64) (*domain.StockTransferDiscrepancy, error) {
+	d, err := scanDiscrepancy(r.db.QueryRowContext(ctx, discrepancySelect+`
+		WHERE d.id = $1 AND d.store_id = $2
+	`, discrepancyID, storeID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+// GetDiscrepancies lists discrepancies where the branch is sender or receiver, or where the
+// warehouse is the sender. An empty status returns every discrepancy.
+func (r *stockTransferRepository) GetDiscrepancies(ctx context.Context, storeID int64, branchID, warehouseID *int64, status domain.DiscrepancyStatus) ([]domain.StockTransferDiscrepancy, error) {
+	rows, err := r.db.QueryContext(ctx, discrepancySelect+`
+		WHERE d.store_id = $1
+			AND ($2::BIGINT IS NULL OR st.from_branch_id = $2 OR st.to_branch_id = $2)
+			AND ($3::BIGINT IS NULL OR st.from_warehouse_id = $3)
+			AND ($4 = '' OR d.status = $4)
+		ORDER BY d.created_at DESC
+	`, storeID, branchID, warehouseID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discrepancies []domain.StockTransferDiscrepancy
+	for rows.Next() {
+		d, err := scanDiscrepancy(rows)
+		if err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, *d)
+	}
+	return discrepancies, nil
+}
+
+// ResolveDiscrepancy accounts for missing transfer units on the sending side. The units are
+// first returned to the sender with a TRANSFER_IN reversal, then written off as ISSUE (lost in
+// transit) or DAMAGE, or left in stock and sent again on a new transfer (re-sent).
+func (r *stockTransferRepository) ResolveDiscrepancy(ctx context.Context, storeID, discrepancyID int64, resolution domain.DiscrepancyResolution, note *string, resolvedBy int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var transferID, productID, toBranchID int64
+	var missingCount int
+	var status domain.DiscrepancyStatus
+	var fromBranchID, fromWarehouseID sql.NullInt64
+	var unitCost decimal.NullDecimal
+	err = tx.QueryRowContext(ctx, `
+		SELECT d.stock_transfer_id, d.product_id, d.missing_count, d.status,
+			st.from_branch_id, st.from_warehouse_id, st.to_branch_id, sti.unit_cost
+		FROM stock_transfer_discrepancies d
+		JOIN stock_transfers st ON d.stock_transfer_id = st.id
+		LEFT JOIN stock_transfer_items sti ON sti.stock_transfer_id = d.stock_transfer_id AND sti.product_id = d.product_id
+		WHERE d.id = $1 AND d.store_id = $2
+		FOR UPDATE OF d
+	`, discrepancyID, storeID).Scan(
+		&transferID, &productID, &missingCount, &status,
+		&fromBranchID, &fromWarehouseID, &toBranchID, &unitCost,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("discrepancy not found")
+		}
+		return err
+	}
+	if status != domain.DiscrepancyStatusOpen {
+		return errors.New("discrepancy is already resolved")
+	}
+
+	refTable := "stock_transfer_discrepancies"
+	reversalReason := "Transfer discrepancy reversal"
+	// The missing units return to the source at the cost they left at
+	var returnCost *decimal.Decimal
+	if unitCost.Valid {
+		returnCost = &unitCost.Decimal
+	}
+	_, err = moveTransferSourceStock(ctx, tx, storeID, fromBranchID, fromWarehouseID, branchStockMove{
+		ProductID:      productID,
+		QuantityChange: missingCount,
+		UnitCost:       returnCost,
+		MovementType:   domain.MovementTypeTransferIn,
+		Reason:         &reversalReason,
+		Note:           note,
+		ChangedBy:      &resolvedBy,
+		ReferenceTable: &refTable,
+		ReferenceID:    &discrepancyID,
+		SkipLots:       true,
+	})
+	if err != nil {
+		return err
+	}
+
+	var resentTransferID *int64
+	switch resolution {
+	case domain.DiscrepancyResolutionLostInTransit, domain.DiscrepancyResolutionDamaged:
+		movementType := domain.MovementTypeIssue
+		reason := "Lost in transit"
+		if resolution == domain.DiscrepancyResolutionDamaged {
+			movementType = domain.MovementTypeDamage
+			reason = "Damaged in transit"
+		}
+		_, err = moveTransferSourceStock(ctx, tx, storeID, fromBranchID, fromWarehouseID, branchStockMove{
+			ProductID:      productID,
+			QuantityChange: -missingCount,
+			MovementType:   movementType,
+			Reason:         &reason,
+			Note:           note,
+			ChangedBy:      &resolvedBy,
+			ReferenceTable: &refTable,
+			ReferenceID:    &discrepancyID,
+			SkipLots:       true,
+			AllowNegative:  true,
+		})
+		if err != nil {
+			return err
+		}
+	case domain.DiscrepancyResolutionResent:
+		// The returned units go back into their lots at the source and go out
+		// again on a new transfer from the same source
+		err = restoreUnreceivedLots(ctx, tx, storeID, fromBranchID, fromWarehouseID, transferID, productID, discrepancyID, resolvedBy)
+		if err != nil {
+			return err
+		}
+		resendNote := fmt.Sprintf("Re-send of transfer #%d", transferID)
+		var newTransferID int64
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO stock_transfers (store_id, from_branch_id, from_warehouse_id, to_branch_id, status, note)
+			VALUES ($1, $2, $3, $4, 'CREATED', $5)
+			RETURNING id
+		`, storeID, fromBranchID, fromWarehouseID, toBranchID, resendNote).Scan(&newTransferID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO stock_transfer_items (stock_transfer_id, product_id, requested_count, send_count)
+			VALUES ($1, $2, $3, $3)
+		`, newTransferID, productID, missingCount)
+		if err != nil {
+			return err
+		}
+		resentTransferID = &newTransferID
+	default:
+		return errors.New("invalid resolution")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE stock_transfer_discrepancies
+		SET status = 'RESOLVED', resolution = $1, resolution_note = $2, resolved_by = $3,
+			resolved_at = NOW(), resent_transfer_id = $4
+		WHERE id = $5
+	`, resolution, note, resolvedBy, resentTransferID, discrepancyID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// receiveTransferLots spreads a received quantity over the lots that were dispatched
+// for the product, earliest expiry first, and adds them to the receiving branch
+func receiveTransferLots(ctx context.Context, q txQuerier, storeID, branchID, transferID, productID int64, receiveCount int, receivedBy int64) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, lot_number, expiry_date, send_count - receive_count
+		FROM stock_transfer_item_lots
+		WHERE stock_transfer_id = $1 AND product_id = $2 AND receive_count < send_count
+		ORDER BY expiry_date ASC NULLS LAST, id ASC
+		FOR UPDATE
+	`, transferID, productID)
+	if err != nil {
+		return err
+	}
+
+	var itemLotIDs []int64
+	var allocations []lotAllocation
+	remaining := receiveCount
+	for rows.Next() && remaining > 0 {
+		var itemLotID int64
+		var alloc lotAllocation
+		var outstanding int
+		if err := rows.Scan(&itemLotID, &alloc.LotNumber, &alloc.ExpiryDate, &outstanding); err != nil {
+			rows.Close()
+			return err
+		}
+		alloc.Quantity = outstanding
+		if alloc.Quantity > remaining {
+			alloc.Quantity = remaining
+		}
+		remaining -= alloc.Quantity
+		itemLotIDs = append(itemLotIDs, itemLotID)
+		allocations = append(allocations, alloc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	refTable := "stock_transfers"
+	for i, alloc := range allocations {
+		_, err = q.ExecContext(ctx, `
+			UPDATE stock_transfer_item_lots SET receive_count = receive_count + $1 WHERE id = $2
+		`, alloc.Quantity, itemLotIDs[i])
+		if err != nil {
+			return err
+		}
+
+		_, err = addToLot(ctx, q, lotMove{
+			StoreID:        storeID,
+			BranchID:       &branchID,
+			ProductID:      productID,
+			LotNumber:      alloc.LotNumber,
+			ExpiryDate:     alloc.ExpiryDate,
+			Quantity:       alloc.Quantity,
+			MovementType:   domain.MovementTypeTransferIn,
+			ChangedBy:      &receivedBy,
+			ReferenceTable: &refTable,
+			ReferenceID:    &transferID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreUnreceivedLots puts the lot quantities that never arrived back at the
+// location the transfer was sent from
+func restoreUnreceivedLots(ctx context.Context, q txQuerier, storeID int64, fromBranchID, fromWarehouseID sql.NullInt64, transferID, productID, discrepancyID, resolvedBy int64) error {
+	if !fromBranchID.Valid && !fromWarehouseID.Valid {
+		return nil
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT lot_number, expiry_date, send_count - receive_count
+		FROM stock_transfer_item_lots
+		WHERE stock_transfer_id = $1 AND product_id = $2 AND receive_count < send_count
+		ORDER BY id ASC
+	`, transferID, productID)
+	if err != nil {
+		return err
+	}
+
+	var allocations []lotAllocation
+	for rows.Next() {
+		var alloc lotAllocation
+		if err := rows.Scan(&alloc.LotNumber, &alloc.ExpiryDate, &alloc.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		allocations = append(allocations, alloc)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	refTable := "stock_transfer_discrepancies"
+	m := lotMove{
+		StoreID:        storeID,
+		ProductID:      productID,
+		MovementType:   domain.MovementTypeTransferIn,
+		ChangedBy:      &resolvedBy,
+		ReferenceTable: &refTable,
+		ReferenceID:    &discrepancyID,
+	}
+	if fromBranchID.Valid {
+		m.BranchID = &fromBranchID.Int64
+	} else {
+		m.WarehouseID = &fromWarehouseID.Int64
+	}
+
+	for _, alloc := range allocations {
+		m.LotNumber = alloc.LotNumber
+		m.ExpiryDate = alloc.ExpiryDate
+		m.Quantity = alloc.Quantity
+		if _, err := addToLot(ctx, q, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,535 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mini-membership/api/internal/domain"
+)
+
+type StocktakeRepository interface {
+	Create(ctx context.Context, storeID, branchID, createdBy int64, req *domain.CreateStocktakeRequest) (int64, error)
+	GetByID(ctx context.Context, storeID, stocktakeID int64) (*domain.StocktakeResponse, error)
+	List(ctx context.Context, storeID int64, branchID *int64, status domain.StocktakeStatus, limit, offset int) (*domain.StocktakeListResponse, error)
+	SubmitCounts(ctx context.Context, storeID, stocktakeID, countedBy int64, items []domain.StocktakeCountInput) error
+	RequestRecount(ctx context.Context, storeID, stocktakeID int64, productIDs []int64) (int, error)
+	Post(ctx context.Context, storeID, stocktakeID, postedBy int64, zeroUncounted bool) error
+	Cancel(ctx context.Context, storeID, stocktakeID int64) error
+}
+
+type stocktakeRepository struct {
+	db *sqlx.DB
+}
+
+func NewStocktakeRepository(db *sqlx.DB) StocktakeRepository {
+	return &stocktakeRepository{db: db}
+}
+
+// Create starts a stocktake and freezes the branch's current on-hand quantity and
+// average cost for every product in scope
+func (r *stocktakeRepository) Create(ctx context.Context, storeID, branchID, createdBy int64, req *domain.CreateStocktakeRequest) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var inProgress bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM stocktakes WHERE branch_id = $1 AND status = 'COUNTING')
+	`, branchID).Scan(&inProgress)
+	if err != nil {
+		return 0, err
+	}
+	if inProgress {
+		return 0, errors.New("a stocktake is already in progress at this branch")
+	}
+
+	var stocktakeID int64
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO stocktakes (store_id, branch_id, count_type, recount_threshold, note, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, storeID, branchID, req.CountType, req.RecountThreshold, req.Note, createdBy).Scan(&stocktakeID)
+	if err != nil {
+		return 0, err
+	}
+
+	var categoryIDs interface{}
+	if req.CountType == domain.StocktakeTypeCycle {
+		for _, categoryID := range req.CategoryIDs {
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO stocktake_categories (stocktake_id, category_id)
+				SELECT $1, id FROM categories WHERE id = $2 AND store_id = $3
+				ON CONFLICT DO NOTHING
+			`, stocktakeID, categoryID, storeID)
+			if err != nil {
+				return 0, err
+			}
+		}
+		categoryIDs = pq.Array(req.CategoryIDs)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO stocktake_items (stocktake_id, product_id, snapshot_qty, snapshot_cost)
+		SELECT $1, bp.product_id, bp.on_stock, bp.avg_cost
+		FROM branch_products bp
+		JOIN products p ON bp.product_id = p.id
+		WHERE bp.store_id = $2 AND bp.branch_id = $3 AND bp.is_active = true
+			AND ($4::BIGINT[] IS NULL OR p.category_id = ANY($4))
+	`, stocktakeID, storeID, branchID, categoryIDs)
+	if err != nil {
+		return 0, err
+	}
+	itemCount, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if itemCount == 0 {
+		return 0, errors.New("no products to count")
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return stocktakeID, nil
+}
+
+const stocktakeSelect = `
+	SELECT
+		st.id, st.branch_id, b.branch_name, st.count_type, st.status,
+		ARRAY(SELECT sc.category_id FROM stocktake_categories sc WHERE sc.stocktake_id = st.id ORDER BY sc.category_id),
+		st.recount_threshold, st.note, cs.email, ps.email, st.posted_at,
+		agg.item_count, agg.counted_count, agg.recount_count, agg.net_variance, agg.variance_value,
+		st.created_at
+	FROM stocktakes st
+	JOIN branches b ON st.branch_id = b.id
+	LEFT JOIN staff_accounts cs ON st.created_by = cs.id
+	LEFT JOIN staff_accounts ps ON st.posted_by = ps.id
+	CROSS JOIN LATERAL (
+		SELECT
+			COUNT(*) AS item_count,
+			COUNT(*) FILTER (WHERE si.status = 'COUNTED') AS counted_count,
+			COUNT(*) FILTER (WHERE si.status = 'RECOUNT') AS recount_count,
+			COALESCE(SUM(si.counted_qty - si.snapshot_qty), 0) AS net_variance,
+			COALESCE(ROUND(SUM((si.counted_qty - si.snapshot_qty) * si.snapshot_cost), 2), 0) AS variance_value
+		FROM stocktake_items si
+		WHERE si.stocktake_id = st.id
+	) agg
+`
+
+func scanStocktake(row interface{ Scan(...interface{}) error }) (*domain.StocktakeResponse, error) {
+	var st domain.StocktakeResponse
+	var createdByName, postedByName sql.NullString
+	err := row.Scan(
+		&st.ID, &st.BranchID, &st.BranchName, &st.CountType, &st.Status,
+		pq.Array(&st.CategoryIDs),
+		&st.RecountThreshold, &st.Note, &createdByName, &postedByName, &st.PostedAt,
+		&st.ItemCount, &st.CountedCount, &st.RecountCount, &st.NetVariance, &st.VarianceValue,
+		&st.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if createdByName.Valid {
+		st.CreatedByName = &createdByName.String
+	}
+	if postedByName.Valid {
+		st.PostedByName = &postedByName.String
+	}
+	return &st, nil
+}
+
+func (r *stocktakeRepository) GetByID(ctx context.Context, storeID, stocktakeID int64) (*domain.StocktakeResponse, error) {
+	st, err := scanStocktake(r.db.QueryRowContext(ctx, stocktakeSelect+`
+		WHERE st.id = $1 AND st.store_id = $2
+	`, stocktakeID, storeID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	items, err := r.getItems(ctx, st.ID, st.RecountThreshold)
+	if err != nil {
+		return nil, err
+	}
+	st.Items = items
+
+	return st, nil
+}
+
+// List returns stocktakes without their items
+func (r *stocktakeRepository) List(ctx context.Context, storeID int64, branchID *int64, status domain.StocktakeStatus, limit, offset int) (*domain.StocktakeListResponse, error) {
+	var total int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM stocktakes st
+		WHERE st.store_id = $1
+			AND ($2::BIGINT IS NULL OR st.branch_id = $2)
+			AND ($3 = '' OR st.status = $3)
+	`, storeID, branchID, status).Scan(&total)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, stocktakeSelect+`
+		WHERE st.store_id = $1
+			AND ($2::BIGINT IS NULL OR st.branch_id = $2)
+			AND ($3 = '' OR st.status = $3)
+		ORDER BY st.created_at DESC
+		LIMIT $4 OFFSET $5
+	`, storeID, branchID, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stocktakes := []domain.StocktakeResponse{}
+	for rows.Next() {
+		st, err := scanStocktake(rows)
+		if err != nil {
+			return nil, err
+		}
+		stocktakes = append(stocktakes, *st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &domain.StocktakeListResponse{
+		Stocktakes: stocktakes,
+		Total:      total,
+	}, nil
+}
+
+func (r *stocktakeRepository) getItems(ctx context.Context, stocktakeID int64, recountThreshold *int) ([]domain.StocktakeItemResponse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT si.id, si.product_id, p.product_name, c.category_name, si.snapshot_qty, si.snapshot_cost,
+			si.counted_qty, si.round, si.status, si.adjusted_qty
+		FROM stocktake_items si
+		JOIN products p ON si.product_id = p.id
+		LEFT JOIN categories c ON p.category_id = c.id
+		WHERE si.stocktake_id = $1
+		ORDER BY c.category_name ASC, p.product_name ASC
+	`, stocktakeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []domain.StocktakeItemResponse
+	index := make(map[int64]int)
+	for rows.Next() {
+		var itemID int64
+		var snapshotCost float64
+		var item domain.StocktakeItemResponse
+		err := rows.Scan(
+			&itemID, &item.ProductID, &item.ProductName, &item.CategoryName, &item.SnapshotQty, &snapshotCost,
+			&item.CountedQty, &item.Round, &item.Status, &item.AdjustedQty,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if item.CountedQty != nil {
+			variance := *item.CountedQty - item.SnapshotQty
+			item.Variance = &variance
+			item.VarianceValue = math.Round(float64(variance)*snapshotCost*100) / 100
+			if recountThreshold != nil && (variance >= *recountThreshold || -variance >= *recountThreshold) {
+				item.OverThreshold = true
+			}
+		}
+		index[itemID] = len(items)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	countRows, err := r.db.QueryContext(ctx, `
+		SELECT sc.stocktake_item_id, sc.round, sc.counted_qty, s.email, sc.updated_at
+		FROM stocktake_counts sc
+		JOIN stocktake_items si ON sc.stocktake_item_id = si.id
+		LEFT JOIN staff_accounts s ON sc.counted_by = s.id
+		WHERE si.stocktake_id = $1
+		ORDER BY sc.round ASC, sc.updated_at ASC
+	`, stocktakeID)
+	if err != nil {
+		return nil, err
+	}
+	defer countRows.Close()
+
+	for countRows.Next() {
+		var itemID int64
+		var entry domain.StocktakeCountEntry
+		var countedByName sql.NullString
+		if err := countRows.Scan(&itemID, &entry.Round, &entry.CountedQty, &countedByName, &entry.CountedAt); err != nil {
+			return nil, err
+		}
+		if countedByName.Valid {
+			entry.CountedByName = &countedByName.String
+		}
+		if i, ok := index[itemID]; ok {
+			items[i].Counts = append(items[i].Counts, entry)
+		}
+	}
+
+	return items, countRows.Err()
+}
+
+// lockCounting locks a stocktake and checks it is still being counted
+func lockCounting(ctx context.Context, q txQuerier, storeID, stocktakeID int64) (int64, *int, error) {
+	var branchID int64
+	var recountThreshold *int
+	var status domain.StocktakeStatus
+	err := q.QueryRowContext(ctx, `
+		SELECT branch_id, recount_threshold, status FROM stocktakes
+		WHERE id = $1 AND store_id = $2
+		FOR UPDATE
+	`, stocktakeID, storeID).Scan(&branchID, &recountThreshold, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil, errors.New("stocktake not found")
+		}
+		return 0, nil, err
+	}
+	if status != domain.StocktakeStatusCounting {
+		return 0, nil, errors.New("stocktake is no longer being counted")
+	}
+	return branchID, recountThreshold, nil
+}
+
+// SubmitCounts records one counter's quantities in each item's current round and
+// sets the item's counted quantity to the total of all counters in that round
+func (r *stocktakeRepository) SubmitCounts(ctx context.Context, storeID, stocktakeID, countedBy int64, items []domain.StocktakeCountInput) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, _, err = lockCounting(ctx, tx, storeID, stocktakeID); err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		var itemID int64
+		var round int
+		err = tx.QueryRowContext(ctx, `
+			SELECT id, round FROM stocktake_items
+			WHERE stocktake_id = $1 AND product_id = $2
+			FOR UPDATE
+		`, stocktakeID, item.ProductID).Scan(&itemID, &round)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("product %d is not part of this stocktake", item.ProductID)
+			}
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO stocktake_counts (stocktake_item_id, round, counted_qty, counted_by)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (stocktake_item_id, round, counted_by)
+			DO UPDATE SET counted_qty = EXCLUDED.counted_qty
+		`, itemID, round, *item.CountedQty, countedBy)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE stocktake_items
+			SET counted_qty = (
+					SELECT SUM(counted_qty) FROM stocktake_counts
+					WHERE stocktake_item_id = $1 AND round = $2
+				),
+				status = 'COUNTED'
+			WHERE id = $1
+		`, itemID, round)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RequestRecount opens a new round for counted items, clearing their counted
+// quantity. With no product IDs, every item whose variance reaches the
+// stocktake's recount threshold is selected.
+func (r *stocktakeRepository) RequestRecount(ctx context.Context, storeID, stocktakeID int64, productIDs []int64) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, recountThreshold, err := lockCounting(ctx, tx, storeID, stocktakeID)
+	if err != nil {
+		return 0, err
+	}
+	if len(productIDs) == 0 && recountThreshold == nil {
+		return 0, errors.New("stocktake has no recount threshold; name the products to recount")
+	}
+
+	var selected interface{}
+	if len(productIDs) > 0 {
+		selected = pq.Array(productIDs)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE stocktake_items
+		SET round = round + 1, counted_qty = NULL, status = 'RECOUNT'
+		WHERE stocktake_id = $1 AND status = 'COUNTED'
+			AND (
+				($2::BIGINT[] IS NOT NULL AND product_id = ANY($2))
+				OR ($2::BIGINT[] IS NULL AND ABS(counted_qty - snapshot_qty) >= $3)
+			)
+	`, stocktakeID, selected, recountThreshold)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
+
+// Post applies each item's variance against its frozen snapshot as an ADJUST
+// movement on top of current stock and closes the stocktake
+func (r *stocktakeRepository) Post(ctx context.Context, storeID, stocktakeID, postedBy int64, zeroUncounted bool) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	branchID, _, err := lockCounting(ctx, tx, storeID, stocktakeID)
+	if err != nil {
+		return err
+	}
+
+	var pending, recount int
+	err = tx.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE status = 'PENDING'),
+			COUNT(*) FILTER (WHERE status = 'RECOUNT')
+		FROM stocktake_items WHERE stocktake_id = $1
+	`, stocktakeID).Scan(&pending, &recount)
+	if err != nil {
+		return err
+	}
+	if recount > 0 {
+		return fmt.Errorf("%d items are waiting for a recount", recount)
+	}
+	if pending > 0 {
+		if !zeroUncounted {
+			return fmt.Errorf("%d items have not been counted", pending)
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE stocktake_items SET counted_qty = 0, status = 'COUNTED'
+			WHERE stocktake_id = $1 AND status = 'PENDING'
+		`, stocktakeID)
+		if err != nil {
+			return err
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, product_id, counted_qty - snapshot_qty
+		FROM stocktake_items
+		WHERE stocktake_id = $1 AND counted_qty <> snapshot_qty
+		ORDER BY product_id
+	`, stocktakeID)
+	if err != nil {
+		return err
+	}
+	type variance struct {
+		itemID    int64
+		productID int64
+		quantity  int
+	}
+	var variances []variance
+	for rows.Next() {
+		var v variance
+		if err := rows.Scan(&v.itemID, &v.productID, &v.quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		variances = append(variances, v)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	refTable := "stocktakes"
+	reasonCode := "STOCKTAKE"
+	reason := fmt.Sprintf("Stocktake #%d", stocktakeID)
+	for _, v := range variances {
+		// Sales since the snapshot may already have taken stock below the variance
+		_, err = moveBranchStock(ctx, tx, branchStockMove{
+			StoreID:        storeID,
+			BranchID:       branchID,
+			ProductID:      v.productID,
+			QuantityChange: v.quantity,
+			MovementType:   domain.MovementTypeAdjust,
+			ReasonCode:     &reasonCode,
+			Reason:         &reason,
+			ChangedBy:      &postedBy,
+			ApprovedBy:     &postedBy,
+			ReferenceTable: &refTable,
+			ReferenceID:    &stocktakeID,
+			AllowNegative:  true,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE stocktake_items SET adjusted_qty = $1 WHERE id = $2
+		`, v.quantity, v.itemID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE stocktakes SET status = 'POSTED', posted_by = $1, posted_at = NOW()
+		WHERE id = $2
+	`, postedBy, stocktakeID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *stocktakeRepository) Cancel(ctx context.Context, storeID, stocktakeID int64) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE stocktakes SET status = 'CANCELLED', cancelled_at = NOW()
+		WHERE id = $1 AND store_id = $2 AND status = 'COUNTING'
+	`, stocktakeID, storeID)
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("stocktake not found or no longer being counted")
+	}
+	return nil
+}
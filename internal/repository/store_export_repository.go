@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mini-membership/api/internal/domain"
+)
+
+// storeExportTable is a table holding store data and the condition selecting
+// one store's rows from it, $1 being the store ID
+type storeExportTable struct {
+	Name  string
+	Scope string
+}
+
+// storeExportTables lists every table holding store data, parents before
+// children, so a restore can load them in this order
+var storeExportTables = []storeExportTable{
+	{"stores", "id = $1"},
+	{"store_settings", "store_id = $1"},
+	{"branches", "store_id = $1"},
+	{"staff_accounts", "store_id = $1"},
+	{"membership_tiers", "store_id = $1"},
+	{"customers", "store_id = $1"},
+	{"categories", "store_id = $1"},
+	{"products", "store_id = $1"},
+	{"branch_products", "store_id = $1"},
+	{"warehouses", "store_id = $1"},
+	{"warehouse_products", "store_id = $1"},
+	{"suppliers", "store_id = $1"},
+	{"stock_adjustment_reasons", "store_id = $1"},
+	{"shifts", "store_id = $1"},
+	{"shift_cash_movements", "store_id = $1"},
+	{"shift_stock_counts", "store_id = $1"},
+	{"shift_stock_count_items", "shift_stock_count_id IN (SELECT id FROM shift_stock_counts WHERE store_id = $1)"},
+	{"promotion_types", "store_id = $1"},
+	{"promotion_type_branches", "store_id = $1"},
+	{"promotions", "store_id = $1"},
+	{"promotion_configs", "promotion_id IN (SELECT id FROM promotions WHERE store_id = $1)"},
+	{"promotion_products", "promotion_id IN (SELECT id FROM promotions WHERE store_id = $1)"},
+	{"loyalty_earn_rules", "store_id = $1"},
+	{"orders", "store_id = $1"},
+	{"order_items", "order_id IN (SELECT id FROM orders WHERE store_id = $1)"},
+	{"order_promotions", "order_id IN (SELECT id FROM orders WHERE store_id = $1)"},
+	{"payments", "order_id IN (SELECT id FROM orders WHERE store_id = $1)"},
+	{"payment_attachments", "payment_id IN (SELECT p.id FROM payments p JOIN orders o ON o.id = p.order_id WHERE o.store_id = $1)"},
+	{"inventory_movements", "store_id = $1"},
+	{"warehouse_movements", "store_id = $1"},
+	{"stock_lots", "store_id = $1"},
+	{"stock_lot_movements", "store_id = $1"},
+	{"stock_transfers", "store_id = $1"},
+	{"stock_transfer_items", "stock_transfer_id IN (SELECT id FROM stock_transfers WHERE store_id = $1)"},
+	{"stock_transfer_item_lots", "stock_transfer_id IN (SELECT id FROM stock_transfers WHERE store_id = $1)"},
+	{"stock_transfer_receipts", "store_id = $1"},
+	{"stock_transfer_receipt_items", "receipt_id IN (SELECT id FROM stock_transfer_receipts WHERE store_id = $1)"},
+	{"stock_transfer_discrepancies", "store_id = $1"},
+	{"purchase_orders", "store_id = $1"},
+	{"purchase_order_items", "purchase_order_id IN (SELECT id FROM purchase_orders WHERE store_id = $1)"},
+	{"goods_received_notes", "store_id = $1"},
+	{"goods_received_note_items", "grn_id IN (SELECT id FROM goods_received_notes WHERE store_id = $1)"},
+	{"stocktakes", "store_id = $1"},
+	{"stocktake_categories", "stocktake_id IN (SELECT id FROM stocktakes WHERE store_id = $1)"},
+	{"stocktake_items", "stocktake_id IN (SELECT id FROM stocktakes WHERE store_id = $1)"},
+	{"stocktake_counts", "stocktake_item_id IN (SELECT i.id FROM stocktake_items i JOIN stocktakes s ON s.id = i.stocktake_id WHERE s.store_id = $1)"},
+	{"customer_product_points", "store_id = $1"},
+	{"point_transactions", "store_id = $1"},
+	{"point_redemptions", "store_id = $1"},
+	{"point_earn_lots", "store_id = $1"},
+	{"point_lot_draws", "lot_id IN (SELECT id FROM point_earn_lots WHERE store_id = $1)"},
+	{"customer_tier_changes", "store_id = $1"},
+	{"customer_wallets", "store_id = $1"},
+	{"wallet_transactions", "store_id = $1"},
+	{"customer_merges", "store_id = $1"},
+	{"legacy_member_map", "store_id = $1"},
+	{"legacy_point_transaction_map", "point_transaction_id IN (SELECT id FROM point_transactions WHERE store_id = $1)"},
+}
+
+// StoreExportExcludedTables hold sign-in tokens and codes, which are never
+// exported; a restored store starts with everyone signed out
+var StoreExportExcludedTables = []string{"app_sessions", "customer_otps", "customer_sessions"}
+
+// restoreDeferredColumns reference rows of their own table. They are loaded
+// as NULL and filled in once the whole table is in.
+var restoreDeferredColumns = map[string][]string{
+	"customers": {"merged_into_id"},
+}
+
+// StoreExportWriter receives a store export one table at a time. Values are
+// PostgreSQL text; an invalid NullString is NULL.
+type StoreExportWriter interface {
+	Table(name string, columns []string) error
+	Row(values []sql.NullString) error
+}
+
+// StoreTableReader yields the rows of one table being restored, returning
+// io.EOF after the last row
+type StoreTableReader interface {
+	Columns() []string
+	Next() ([]sql.NullString, error)
+	Close() error
+}
+
+type StoreExportRepository interface {
+	GetStoreName(ctx context.Context, storeID int64) (*string, error)
+	ExportStoreTx(ctx context.Context, storeID int64, w StoreExportWriter) error
+	RestoreStoreTx(ctx context.Context, storeID int64, open func(table string) (StoreTableReader, error), dryRun bool) ([]domain.StoreRestoreTable, error)
+}
+
+type storeExportRepository struct {
+	db *sqlx.DB
+}
+
+func NewStoreExportRepository(db *sqlx.DB) StoreExportRepository {
+	return &storeExportRepository{db: db}
+}
+
+func (r *storeExportRepository) GetStoreName(ctx context.Context, storeID int64) (*string, error) {
+	var name string
+	err := r.db.QueryRowContext(ctx, `SELECT store_name FROM stores WHERE id = $1`, storeID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &name, nil
+}
+
+// tableColumn is a column of a table in the connected database
+type tableColumn struct {
+	Name string
+}
+
+func tableColumns(ctx context.Context, q txQuerier, table string) ([]tableColumn, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position
+	`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []tableColumn
+	for rows.Next() {
+		var c tableColumn
+		if err := rows.Scan(&c.Name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist, run the migrations first", table)
+	}
+	return columns, nil
+}
+
+// ExportStoreTx streams every store table to w from one read-only snapshot,
+// so the tables are consistent with each other even while the store trades
+func (r *storeExportRepository) ExportStoreTx(ctx context.Context, storeID int64, w StoreExportWriter) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, t := range storeExportTables {
+		if err := exportTable(ctx, tx, storeID, t, w); err != nil {
+			return fmt.Errorf("export %s: %w", t.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func exportTable(ctx context.Context, tx *sql.Tx, storeID int64, t storeExportTable, w StoreExportWriter) error {
+	columns, err := tableColumns(ctx, tx, t.Name)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(columns))
+	selects := make([]string, len(columns))
+	for i, c := range columns {
+		names[i] = c.Name
+		selects[i] = pq.QuoteIdentifier(c.Name) + "::text"
+	}
+	if err := w.Table(t.Name, names); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(
+		`SELECT %s FROM %s WHERE %s ORDER BY 1`,
+		strings.Join(selects, ", "), pq.QuoteIdentifier(t.Name), t.Scope,
+	), storeID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if err := w.Row(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// RestoreStoreTx loads an exported store into this database with its original
+// IDs, all or nothing, and moves each table's ID sequence past the restored
+// rows. The store must not exist yet; tables open returns nil for are skipped.
+func (r *storeExportRepository) RestoreStoreTx(ctx context.Context, storeID int64, open func(table string) (StoreTableReader, error), dryRun bool) ([]domain.StoreRestoreTable, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM stores WHERE id = $1)`, storeID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("store %d already exists in this database", storeID)
+	}
+
+	var restored []domain.StoreRestoreTable
+	for _, t := range storeExportTables {
+		reader, err := open(t.Name)
+		if err != nil {
+			return nil, err
+		}
+		if reader == nil {
+			continue
+		}
+		n, err := restoreTable(ctx, tx, t.Name, reader)
+		reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("restore %s: %w", t.Name, err)
+		}
+		restored = append(restored, domain.StoreRestoreTable{Name: t.Name, Rows: n})
+	}
+
+	if dryRun {
+		return restored, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+func restoreTable(ctx context.Context, tx *sql.Tx, table string, reader StoreTableReader) (int, error) {
+	columns, err := tableColumns(ctx, tx, table)
+	if err != nil {
+		return 0, err
+	}
+	known := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		known[c.Name] = true
+	}
+
+	names := reader.Columns()
+	quoted := make([]string, len(names))
+	params := make([]string, len(names))
+	idIndex := -1
+	deferred := make(map[int]bool)
+	for i, name := range names {
+		if !known[name] {
+			return 0, fmt.Errorf("column %q does not exist, run the migrations first", name)
+		}
+		quoted[i] = pq.QuoteIdentifier(name)
+		params[i] = fmt.Sprintf("$%d", i+1)
+		if name == "id" {
+			idIndex = i
+		}
+		for _, d := range restoreDeferredColumns[table] {
+			if name == d {
+				deferred[i] = true
+			}
+		}
+	}
+	if len(deferred) > 0 && idIndex < 0 {
+		return 0, errors.New("column \"id\" is required")
+	}
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(
+		`INSERT INTO %s (%s) VALUES (%s)`,
+		pq.QuoteIdentifier(table), strings.Join(quoted, ", "), strings.Join(params, ", "),
+	))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	type deferredValue struct {
+		id     string
+		column string
+		value  string
+	}
+	var later []deferredValue
+	count := 0
+	args := make([]interface{}, len(names))
+	for {
+		values, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(values) != len(names) {
+			return 0, fmt.Errorf("row %d has %d values, expected %d", count+1, len(values), len(names))
+		}
+		for i, v := range values {
+			switch {
+			case v.Valid && deferred[i]:
+				later = append(later, deferredValue{id: values[idIndex].String, column: names[i], value: v.String})
+				args[i] = nil
+			case v.Valid:
+				args[i] = v.String
+			default:
+				args[i] = nil
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return 0, fmt.Errorf("row %d: %w", count+1, err)
+		}
+		count++
+	}
+
+	for _, d := range later {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`UPDATE %s SET %s = $1 WHERE id = $2`, pq.QuoteIdentifier(table), pq.QuoteIdentifier(d.column),
+		), d.value, d.id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if known["id"] && count > 0 {
+		_, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`SELECT setval(pg_get_serial_sequence($1, 'id'), (SELECT MAX(id) FROM %s))`, pq.QuoteIdentifier(table),
+		), table)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
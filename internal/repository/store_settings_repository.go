@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mini-membership/api/internal/domain"
+)
+
+type StoreSettingsRepository interface {
+	GetByStore(ctx context.Context, storeID int64) (*domain.StoreSettings, error)
+	Upsert(ctx context.Context, storeID int64, req *domain.UpdateStoreSettingsRequest) error
+}
+
+type storeSettingsRepository struct {
+	db *sqlx.DB
+}
+
+func NewStoreSettingsRepository(db *sqlx.DB) StoreSettingsRepository {
+	return &storeSettingsRepository{db: db}
+}
+
+// GetByStore returns default (empty) settings when the store has never saved any
+func (r *storeSettingsRepository) GetByStore(ctx context.Context, storeID int64) (*domain.StoreSettings, error) {
+	settings := domain.StoreSettings{
+		StoreID:             storeID,
+		NegativeStockPolicy: domain.NegativeStockPolicyAllowWithWarning,
+		PointsExpiryMode:    domain.PointsExpiryNone,
+		LoyaltyMode:         domain.LoyaltyModeStamps,
+	}
+	var approvalQty, expiryMonths, tierWindow sql.NullInt64
+	var approvalValue, bahtPerPoint, pointValue sql.NullFloat64
+	err := r.db.QueryRowContext(ctx, `
+		SELECT adjustment_approval_qty, adjustment_approval_value, negative_stock_policy,
+			points_expiry_mode, points_expiry_months, tier_window_months,
+			loyalty_mode, wallet_baht_per_point::FLOAT8, wallet_point_value::FLOAT8, updated_at
+		FROM store_settings WHERE store_id = $1
+	`, storeID).Scan(&approvalQty, &approvalValue, &settings.NegativeStockPolicy,
+		&settings.PointsExpiryMode, &expiryMonths, &tierWindow,
+		&settings.LoyaltyMode, &bahtPerPoint, &pointValue, &settings.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if approvalQty.Valid {
+		qty := int(approvalQty.Int64)
+		settings.AdjustmentApprovalQty = &qty
+	}
+	if approvalValue.Valid {
+		settings.AdjustmentApprovalValue = &approvalValue.Float64
+	}
+	if expiryMonths.Valid {
+		months := int(expiryMonths.Int64)
+		settings.PointsExpiryMonths = &months
+	}
+	if tierWindow.Valid {
+		months := int(tierWindow.Int64)
+		settings.TierWindowMonths = &months
+	}
+	if bahtPerPoint.Valid {
+		settings.WalletBahtPerPoint = &bahtPerPoint.Float64
+	}
+	if pointValue.Valid {
+		settings.WalletPointValue = &pointValue.Float64
+	}
+	return &settings, nil
+}
+
+func (r *storeSettingsRepository) Upsert(ctx context.Context, storeID int64, req *domain.UpdateStoreSettingsRequest) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO store_settings (
+			store_id, adjustment_approval_qty, adjustment_approval_value, negative_stock_policy,
+			points_expiry_mode, points_expiry_months, tier_window_months,
+			loyalty_mode, wallet_baht_per_point, wallet_point_value
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (store_id)
+		DO UPDATE SET adjustment_approval_qty = $2, adjustment_approval_value = $3, negative_stock_policy = $4,
+			points_expiry_mode = $5, points_expiry_months = $6, tier_window_months = $7,
+			loyalty_mode = $8, wallet_baht_per_point = $9, wallet_point_value = $10
+	`, storeID, req.AdjustmentApprovalQty, req.AdjustmentApprovalValue, req.NegativeStockPolicy,
+		req.PointsExpiryMode, req.PointsExpiryMonths, req.TierWindowMonths,
+		req.LoyaltyMode, req.WalletBahtPerPoint, req.WalletPointValue)
+	return err
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+// walletChange describes a single change to a customer's store-wide wallet.
+// A positive change adds to the lifetime total; a negative change fails with
+// ErrInsufficientPoints rather than going below zero. Restore marks a positive
+// change that gives back points spent earlier, which leaves the lifetime total
+// alone; Revoke marks a negative change that takes back points earned earlier,
+// which lowers the lifetime total too.
+// Amount and RedeemedAs are set on redemptions and record the baht the points
+// covered on the order.
+type walletChange struct {
+	StoreID         int64
+	BranchID        *int64
+	CustomerID      int64
+	PointsChange    int
+	TransactionType string
+	Amount          *decimal.Decimal
+	RedeemedAs      *string
+	ReferenceTable  *string
+	ReferenceID     *int64
+	Note            *string
+	StaffID         *int64
+	Restore         bool
+	Revoke          bool
+}
+
+// changeWallet applies the change to customer_wallets, records the wallet
+// transaction and returns the customer's new wallet balance
+func changeWallet(ctx context.Context, q txQuerier, c walletChange) (int, error) {
+	var balance int
+	var err error
+	if c.PointsChange >= 0 {
+		lifetimeChange := c.PointsChange
+		if c.Restore {
+			lifetimeChange = 0
+		}
+		err = q.QueryRowContext(ctx, `
+			INSERT INTO customer_wallets (store_id, customer_id, balance, lifetime_earned)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (store_id, customer_id)
+			DO UPDATE SET
+				balance = customer_wallets.balance + $3,
+				lifetime_earned = customer_wallets.lifetime_earned + $4,
+				updated_at = NOW()
+			RETURNING balance
+		`, c.StoreID, c.CustomerID, c.PointsChange, lifetimeChange).Scan(&balance)
+	} else {
+		lifetimeChange := 0
+		if c.Revoke {
+			lifetimeChange = c.PointsChange
+		}
+		err = q.QueryRowContext(ctx, `
+			UPDATE customer_wallets
+			SET balance = balance + $3, lifetime_earned = GREATEST(lifetime_earned + $5, 0), updated_at = NOW()
+			WHERE store_id = $1 AND customer_id = $2 AND balance >= $4
+			RETURNING balance
+		`, c.StoreID, c.CustomerID, c.PointsChange, -c.PointsChange, lifetimeChange).Scan(&balance)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInsufficientPoints
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO wallet_transactions (
+			store_id, branch_id, customer_id, transaction_type, points_change, balance_after,
+			amount, redeemed_as, reference_table, reference_id, note, staff_id
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, c.StoreID, c.BranchID, c.CustomerID, c.TransactionType, c.PointsChange, balance,
+		c.Amount, c.RedeemedAs, c.ReferenceTable, c.ReferenceID, c.Note, c.StaffID)
+	if err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
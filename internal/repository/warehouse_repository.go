@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/shopspring/decimal"
+)
+
+type WarehouseRepository interface {
+	Create(ctx context.Context, storeID int64, req *domain.CreateWarehouseRequest) (*domain.Warehouse, error)
+	GetByID(ctx context.Context, storeID, warehouseID int64) (*domain.Warehouse, error)
+	GetByStore(ctx context.Context, storeID int64) ([]domain.Warehouse, error)
+	GetDefault(ctx context.Context, storeID int64) (*domain.Warehouse, error)
+	GetStock(ctx context.Context, storeID, warehouseID int64) (*domain.WarehouseStockResponse, error)
+	GetMovements(ctx context.Context, storeID, warehouseID int64, limit, offset int) ([]domain.InventoryMovementResponse, error)
+	AdjustStock(ctx context.Context, storeID, warehouseID, productID int64, quantityChange int, unitCost *decimal.Decimal, lotNumber, expiryDate *string, movementType domain.MovementType, reasonCode, reason, note *string, changedBy int64, approvedBy *int64) error
+	GetShortShipped(ctx context.Context, storeID, warehouseID int64, limit, offset int) (*domain.ShortShippedResponse, error)
+}
+
+type warehouseRepository struct {
+	db *sqlx.DB
+}
+
+func NewWarehouseRepository(db *sqlx.DB) WarehouseRepository {
+	return &warehouseRepository{db: db}
+}
+
+func (r *warehouseRepository) Create(ctx context.Context, storeID int64, req *domain.CreateWarehouseRequest) (*domain.Warehouse, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Only one default warehouse per store
+	if req.IsDefault {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE warehouses SET is_default = false, updated_at = NOW()
+			WHERE store_id = $1 AND is_default = true
+		`, storeID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var w domain.Warehouse
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO warehouses (store_id, warehouse_name, is_default)
+		VALUES ($1, $2, $3)
+		RETURNING id, store_id, warehouse_name, is_default, is_active, created_at, updated_at
+	`, storeID, req.WarehouseName, req.IsDefault).Scan(
+		&w.ID, &w.StoreID, &w.WarehouseName, &w.IsDefault, &w.IsActive, &w.CreatedAt, &w.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+func (r *warehouseRepository) GetByID(ctx context.Context, storeID, warehouseID int64) (*domain.Warehouse, error) {
+	var w domain.Warehouse
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, store_id, warehouse_name, is_default, is_active, created_at, updated_at
+		FROM warehouses
+		WHERE id = $1 AND store_id = $2
+	`, warehouseID, storeID).Scan(
+		&w.ID, &w.StoreID, &w.WarehouseName, &w.IsDefault, &w.IsActive, &w.CreatedAt, &w.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *warehouseRepository) GetByStore(ctx context.Context, storeID int64) ([]domain.Warehouse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, store_id, warehouse_name, is_default, is_active, created_at, updated_at
+		FROM warehouses
+		WHERE store_id = $1
+		ORDER BY is_default DESC, warehouse_name ASC
+	`, storeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var warehouses []domain.Warehouse
+	for rows.Next() {
+		var w domain.Warehouse
+		err := rows.Scan(&w.ID, &w.StoreID, &w.WarehouseName, &w.IsDefault, &w.IsActive, &w.CreatedAt, &w.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, w)
+	}
+	return warehouses, nil
+}
+
+func (r *warehouseRepository) GetDefault(ctx context.Context, storeID int64) (*domain.Warehouse, error) {
+	var w domain.Warehouse
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, store_id, warehouse_name, is_default, is_active, created_at, updated_at
+		FROM warehouses
+		WHERE store_id = $1 AND is_default = true AND is_active = true
+	`, storeID).Scan(
+		&w.ID, &w.StoreID, &w.WarehouseName, &w.IsDefault, &w.IsActive, &w.CreatedAt, &w.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (r *warehouseRepository) GetStock(ctx context.Context, storeID, warehouseID int64) (*domain.WarehouseStockResponse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT wp.product_id, p.product_name, c.category_name, wp.on_stock, wp.reorder_level
+		FROM warehouse_products wp
+		JOIN products p ON wp.product_id = p.id
+		JOIN categories c ON p.category_id = c.id
+		WHERE wp.store_id = $1 AND wp.warehouse_id = $2 AND wp.is_active = true
+		ORDER BY c.category_name ASC, p.product_name ASC
+	`, storeID, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []domain.WarehouseStockItem
+	for rows.Next() {
+		var item domain.WarehouseStockItem
+		err := rows.Scan(&item.ProductID, &item.ProductName, &item.CategoryName, &item.OnStock, &item.ReorderLevel)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return &domain.WarehouseStockResponse{
+		WarehouseID: warehouseID,
+		Items:       items,
+		TotalCount:  len(items),
+	}, nil
+}
+
+func (r *warehouseRepository) GetMovements(ctx context.Context, storeID, warehouseID int64, limit, offset int) ([]domain.InventoryMovementResponse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+			wm.id, wm.product_id, p.product_name, wm.movement_type, wm.quantity_change,
+			wm.from_stock_count, wm.to_stock_count, wm.reason_code, wm.reason, wm.note,
+			s.email as changed_by_name, a.email as approved_by_name, wm.created_at
+		FROM warehouse_movements wm
+		JOIN products p ON wm.product_id = p.id
+		LEFT JOIN staff_accounts s ON wm.changed_by = s.id
+		LEFT JOIN staff_accounts a ON wm.approved_by = a.id
+		WHERE wm.store_id = $1 AND wm.warehouse_id = $2
+		ORDER BY wm.created_at DESC
+		LIMIT $3 OFFSET $4
+	`, storeID, warehouseID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []domain.InventoryMovementResponse
+	for rows.Next() {
+		var m domain.InventoryMovementResponse
+		var changedByName, approvedByName sql.NullString
+		err := rows.Scan(
+			&m.ID, &m.ProductID, &m.ProductName, &m.MovementType, &m.QuantityChange,
+			&m.FromStockCount, &m.ToStockCount, &m.ReasonCode, &m.Reason, &m.Note,
+			&changedByName, &approvedByName, &m.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if changedByName.Valid {
+			m.ChangedByName = &changedByName.String
+		}
+		if approvedByName.Valid {
+			m.ApprovedByName = &approvedByName.String
+		}
+		movements = append(movements, m)
+	}
+	return movements, nil
+}
+
+func (r *warehouseRepository) AdjustStock(ctx context.Context, storeID, warehouseID, productID int64, quantityChange int, unitCost *decimal.Decimal, lotNumber, expiryDate *string, movementType domain.MovementType, reasonCode, reason, note *string, changedBy int64, approvedBy *int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if movementType == domain.MovementTypeReceive {
+		if err = checkLotNumber(ctx, tx, storeID, productID, lotNumber); err != nil {
+			return err
+		}
+	}
+	expiry, err := parseLotExpiry(expiryDate)
+	if err != nil {
+		return err
+	}
+
+	_, err = moveWarehouseStock(ctx, tx, warehouseStockMove{
+		StoreID:        storeID,
+		WarehouseID:    warehouseID,
+		ProductID:      productID,
+		QuantityChange: quantityChange,
+		UnitCost:       unitCost,
+		MovementType:   movementType,
+		ReasonCode:     reasonCode,
+		Reason:         reason,
+		Note:           note,
+		ChangedBy:      &changedBy,
+		ApprovedBy:     approvedBy,
+	})
+	if err != nil {
+		return err
+	}
+
+	if quantityChange > 0 && lotNumber != nil && *lotNumber != "" {
+		_, err = addToLot(ctx, tx, lotMove{
+			StoreID:      storeID,
+			WarehouseID:  &warehouseID,
+			ProductID:    productID,
+			LotNumber:    *lotNumber,
+			ExpiryDate:   expiry,
+			Quantity:     quantityChange,
+			MovementType: movementType,
+			ChangedBy:    &changedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *warehouseRepository) GetShortShipped(ctx context.Context, storeID, warehouseID int64, limit, offset int) (*domain.ShortShippedResponse, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+			st.id, st.to_branch_id, tb.branch_name, sti.product_id, p.product_name,
+			sti.requested_count, sti.send_count, sti.requested_count - sti.send_count, st.sent_at
+		FROM stock_transfer_items sti
+		JOIN stock_transfers st ON sti.stock_transfer_id = st.id
+		JOIN branches tb ON st.to_branch_id = tb.id
+		JOIN products p ON sti.product_id = p.id
+		WHERE st.store_id = $1 AND st.from_warehouse_id = $2
+			AND st.status IN ('SENT', 'PARTIALLY_RECEIVED', 'RECEIVED')
+			AND sti.send_count < sti.requested_count
+		ORDER BY st.sent_at DESC, st.id DESC, p.product_name ASC
+		LIMIT $3 OFFSET $4
+	`, storeID, warehouseID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []domain.ShortShippedItem
+	for rows.Next() {
+		var item domain.ShortShippedItem
+		err := rows.Scan(
+			&item.TransferID, &item.ToBranchID, &item.ToBranchName, &item.ProductID, &item.ProductName,
+			&item.RequestedCount, &item.SendCount, &item.ShortCount, &item.SentAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return &domain.ShortShippedResponse{
+		WarehouseID: warehouseID,
+		Items:       items,
+		TotalCount:  len(items),
+	}, nil
+}
@@ -19,6 +19,7 @@ type AppAuthService interface {
 	LoginStore(ctx context.Context, req *domain.AppLoginRequest) (*domain.AppLoginResponse, error)
 	ValidateSession(ctx context.Context, token string) (*domain.AppSessionInfo, error)
 	VerifyPin(ctx context.Context, token string, req *domain.AppPinVerifyRequest) (*domain.AppPinVerifyResponse, error)
+	VerifyManagerPin(ctx context.Context, storeID int64, pin string) (int64, error)
 	RegisterBusiness(ctx context.Context, req *domain.AppRegisterRequest) (*domain.AppRegisterResponse, error)
 	Logout(ctx context.Context, token string) error
 }
@@ -147,6 +148,7 @@ func (s *appAuthService) ValidateSession(ctx context.Context, token string) (*do
 		staff, err := s.repo.GetStaffByID(ctx, session.StoreID, session.StaffID.Int64)
 		if err == nil && staff != nil {
 			info.StaffID = &staff.ID
+			info.IsManager = staff.IsStoreMaster
 			if staff.Email.Valid {
 				info.StaffName = &staff.Email.String
 			}
@@ -197,6 +199,19 @@ func (s *appAuthService) VerifyPin(ctx context.Context, token string, req *domai
 	}, nil
 }
 
+// VerifyManagerPin checks a PIN belongs to a manager of the store and returns their staff ID.
+// Unlike VerifyPin it does not switch the session's staff.
+func (s *appAuthService) VerifyManagerPin(ctx context.Context, storeID int64, pin string) (int64, error) {
+	staff, err := s.repo.GetStaffByPinAndStore(ctx, hashPin(pin), storeID)
+	if err != nil {
+		return 0, err
+	}
+	if staff == nil || !staff.IsStoreMaster {
+		return 0, errors.New("invalid manager PIN")
+	}
+	return staff.ID, nil
+}
+
 func (s *appAuthService) RegisterBusiness(ctx context.Context, req *domain.AppRegisterRequest) (*domain.AppRegisterResponse, error) {
 	now := time.Now()
 
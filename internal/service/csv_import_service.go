@@ -0,0 +1,409 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+	"github.com/shopspring/decimal"
+)
+
+// importMaxRows caps a CSV import, which is written in a single transaction
+const importMaxRows = 5000
+
+type CSVImportService interface {
+	Import(ctx context.Context, opts *domain.ImportOptions, r io.Reader) (*domain.ImportResult, error)
+}
+
+type csvImportService struct {
+	repo repository.CSVImportRepository
+}
+
+func NewCSVImportService(repo repository.CSVImportRepository) CSVImportService {
+	return &csvImportService{repo: repo}
+}
+
+// importCSV is a CSV file with its columns looked up by lower-cased header.
+// Columns the import does not know, such as the id and timestamps of an
+// exported table, are ignored.
+type importCSV struct {
+	columns map[string]int
+	rows    []importCSVRow
+}
+
+type importCSVRow struct {
+	line  int
+	cells []string
+}
+
+// readImportCSV reads a whole CSV file, skipping a UTF-8 byte order mark and blank rows
+func readImportCSV(r io.Reader) (*importCSV, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		_, _ = br.Discard(3)
+	}
+
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	file := &importCSV{columns: make(map[string]int, len(header))}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := file.columns[name]; ok {
+			return nil, fmt.Errorf("column %q appears more than once", name)
+		}
+		file.columns[name] = i
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		file.rows = append(file.rows, importCSVRow{line: line, cells: record})
+		if len(file.rows) > importMaxRows {
+			return nil, fmt.Errorf("CSV file has more than %d rows, split it into smaller files", importMaxRows)
+		}
+	}
+	return file, nil
+}
+
+func (f *importCSV) has(column string) bool {
+	_, ok := f.columns[column]
+	return ok
+}
+
+// require returns an error naming the first of the columns the file lacks
+func (f *importCSV) require(columns ...string) error {
+	for _, column := range columns {
+		if !f.has(column) {
+			return fmt.Errorf("missing required column %q", column)
+		}
+	}
+	return nil
+}
+
+// importRowReader reads typed cells from one CSV row, recording any problem
+// on the import result. Blank and missing cells read as nil.
+type importRowReader struct {
+	file   *importCSV
+	row    importCSVRow
+	result *domain.ImportResult
+	failed bool
+}
+
+func (p *importRowReader) fail(column, message string) {
+	p.result.AddError(p.row.line, column, message)
+	p.failed = true
+}
+
+func (p *importRowReader) text(column string) *string {
+	i, ok := p.file.columns[column]
+	if !ok || i >= len(p.row.cells) {
+		return nil
+	}
+	v := strings.TrimSpace(p.row.cells[i])
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+func (p *importRowReader) required(column string) string {
+	v := p.text(column)
+	if v == nil {
+		p.fail(column, column+" is required")
+		return ""
+	}
+	return *v
+}
+
+func (p *importRowReader) integer(column string, min int) *int {
+	v := p.text(column)
+	if v == nil {
+		return nil
+	}
+	n, err := strconv.Atoi(*v)
+	if err != nil {
+		p.fail(column, fmt.Sprintf("%q is not a whole number", *v))
+		return nil
+	}
+	if n < min {
+		p.fail(column, fmt.Sprintf("must be at least %d", min))
+		return nil
+	}
+	return &n
+}
+
+func (p *importRowReader) amount(column string) *float64 {
+	v := p.text(column)
+	if v == nil {
+		return nil
+	}
+	n, err := strconv.ParseFloat(strings.ReplaceAll(*v, ",", ""), 64)
+	if err != nil {
+		p.fail(column, fmt.Sprintf("%q is not a number", *v))
+		return nil
+	}
+	if n < 0 {
+		p.fail(column, "cannot be negative")
+		return nil
+	}
+	return &n
+}
+
+// cost reads a non-negative unit cost, kept exact to carry into the average cost
+func (p *importRowReader) cost(column string) *decimal.Decimal {
+	v := p.text(column)
+	if v == nil {
+		return nil
+	}
+	n, err := decimal.NewFromString(strings.ReplaceAll(*v, ",", ""))
+	if err != nil {
+		p.fail(column, fmt.Sprintf("%q is not a number", *v))
+		return nil
+	}
+	if n.IsNegative() {
+		p.fail(column, "cannot be negative")
+		return nil
+	}
+	return &n
+}
+
+func (p *importRowReader) boolean(column string) *bool {
+	v := p.text(column)
+	if v == nil {
+		return nil
+	}
+	var b bool
+	switch strings.ToLower(*v) {
+	case "true", "t", "yes", "y", "1":
+		b = true
+	case "false", "f", "no", "n", "0":
+		b = false
+	default:
+		p.fail(column, fmt.Sprintf("%q is not true or false", *v))
+		return nil
+	}
+	return &b
+}
+
+// Import validates every row of the CSV and writes the valid ones in one
+// transaction. Nothing is committed on a dry run or when any row is invalid,
+// so the result doubles as a preview of what the import would do.
+func (s *csvImportService) Import(ctx context.Context, opts *domain.ImportOptions, r io.Reader) (*domain.ImportResult, error) {
+	if opts.StoreID <= 0 {
+		return nil, errors.New("store is required")
+	}
+
+	file, err := readImportCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.ImportResult{
+		Kind:      opts.Kind,
+		DryRun:    opts.DryRun,
+		TotalRows: len(file.rows),
+		Rows:      []domain.ImportRowResult{},
+		Errors:    []domain.ImportRowError{},
+	}
+
+	var written *domain.ImportResult
+	switch opts.Kind {
+	case domain.ImportKindCustomers:
+		rows, err := parseCustomerRows(file, result)
+		if err != nil {
+			return nil, err
+		}
+		written, err = s.repo.ImportCustomersTx(ctx, opts.StoreID, rows, opts.DryRun || len(result.Errors) > 0)
+		if err != nil {
+			return nil, err
+		}
+	case domain.ImportKindProducts:
+		rows, err := parseProductRows(file, result)
+		if err != nil {
+			return nil, err
+		}
+		written, err = s.repo.ImportProductsTx(ctx, opts.StoreID, rows, opts.DryRun || len(result.Errors) > 0)
+		if err != nil {
+			return nil, err
+		}
+	case domain.ImportKindStock:
+		rows, err := parseStockRows(file, result)
+		if err != nil {
+			return nil, err
+		}
+		written, err = s.repo.ImportStockTx(ctx, opts.StoreID, rows, opts.StaffID, opts.DryRun || len(result.Errors) > 0)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown import kind %q, expected customers, products or stock", opts.Kind)
+	}
+
+	for _, row := range written.Rows {
+		result.AddRow(row)
+	}
+	result.Errors = append(result.Errors, written.Errors...)
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+	result.CategoriesCreated = written.CategoriesCreated
+	result.Committed = written.Committed
+	return result, nil
+}
+
+// parseCustomerRows reads customer_code, full_name, phone, email and is_active
+func parseCustomerRows(file *importCSV, result *domain.ImportResult) ([]domain.CustomerImportRow, error) {
+	if err := file.require("customer_code"); err != nil {
+		return nil, err
+	}
+
+	var rows []domain.CustomerImportRow
+	seen := make(map[string]int)
+	for _, r := range file.rows {
+		p := &importRowReader{file: file, row: r, result: result}
+		row := domain.CustomerImportRow{
+			Row:          r.line,
+			CustomerCode: p.required("customer_code"),
+			FullName:     p.text("full_name"),
+			Phone:        p.text("phone"),
+			Email:        p.text("email"),
+			IsActive:     p.boolean("is_active"),
+		}
+		if row.Phone != nil {
+			key := phoneKey(*row.Phone)
+			if len(key) < 9 {
+				p.fail("phone", fmt.Sprintf("%q is not a valid phone number", *row.Phone))
+			} else {
+				last4 := key[len(key)-4:]
+				row.PhoneLast4 = &last4
+			}
+		}
+		if row.Email != nil {
+			if _, err := mail.ParseAddress(*row.Email); err != nil {
+				p.fail("email", fmt.Sprintf("%q is not a valid email address", *row.Email))
+			}
+		}
+		if row.CustomerCode != "" {
+			if first, ok := seen[row.CustomerCode]; ok {
+				p.fail("customer_code", fmt.Sprintf("duplicates row %d", first))
+			} else {
+				seen[row.CustomerCode] = r.line
+			}
+		}
+		if !p.failed {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// parseProductRows reads sku, product_name, category_name, barcode,
+// base_price, points_to_redeem and is_active
+func parseProductRows(file *importCSV, result *domain.ImportResult) ([]domain.ProductImportRow, error) {
+	if err := file.require("sku"); err != nil {
+		return nil, err
+	}
+
+	var rows []domain.ProductImportRow
+	seen := make(map[string]int)
+	for _, r := range file.rows {
+		p := &importRowReader{file: file, row: r, result: result}
+		row := domain.ProductImportRow{
+			Row:            r.line,
+			SKU:            p.required("sku"),
+			ProductName:    p.text("product_name"),
+			CategoryName:   p.text("category_name"),
+			Barcode:        p.text("barcode"),
+			BasePrice:      p.amount("base_price"),
+			PointsToRedeem: p.integer("points_to_redeem", 1),
+			IsActive:       p.boolean("is_active"),
+		}
+		if row.SKU != "" {
+			if first, ok := seen[row.SKU]; ok {
+				p.fail("sku", fmt.Sprintf("duplicates row %d", first))
+			} else {
+				seen[row.SKU] = r.line
+			}
+		}
+		if !p.failed {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// parseStockRows reads branch_id or branch_name, sku, on_stock, reorder_level,
+// unit_cost, lot_number and expiry_date
+func parseStockRows(file *importCSV, result *domain.ImportResult) ([]domain.StockImportRow, error) {
+	if !file.has("branch_id") && !file.has("branch_name") {
+		return nil, errors.New(`missing required column "branch_id" or "branch_name"`)
+	}
+	if err := file.require("sku", "on_stock"); err != nil {
+		return nil, err
+	}
+
+	var rows []domain.StockImportRow
+	for _, r := range file.rows {
+		p := &importRowReader{file: file, row: r, result: result}
+		row := domain.StockImportRow{
+			Row:          r.line,
+			BranchName:   p.text("branch_name"),
+			SKU:          p.required("sku"),
+			ReorderLevel: p.integer("reorder_level", 0),
+			UnitCost:     p.cost("unit_cost"),
+			LotNumber:    p.text("lot_number"),
+			ExpiryDate:   p.text("expiry_date"),
+		}
+		if id := p.integer("branch_id", 1); id != nil {
+			branchID := int64(*id)
+			row.BranchID = &branchID
+		} else if row.BranchName == nil && !p.failed {
+			p.fail("", "branch_id or branch_name is required")
+		}
+		if onStock := p.integer("on_stock", 0); onStock != nil {
+			row.OnStock = *onStock
+		} else if p.text("on_stock") == nil {
+			p.fail("on_stock", "on_stock is required")
+		}
+		if row.ExpiryDate != nil {
+			if _, err := time.Parse("2006-01-02", *row.ExpiryDate); err != nil {
+				p.fail("expiry_date", fmt.Sprintf("%q is not a date, expected YYYY-MM-DD", *row.ExpiryDate))
+			}
+		}
+		if !p.failed {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
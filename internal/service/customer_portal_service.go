@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+	"github.com/mini-membership/api/pkg/sms"
+)
+
+const (
+	otpTTL             = 5 * time.Minute
+	otpMaxAttempts     = 5
+	otpRequestLimit    = 3
+	otpRequestWindow   = 15 * time.Minute
+	customerSessionTTL = 30 * time.Minute
+)
+
+var (
+	// ErrTooManyOTPRequests is returned when a phone has asked for too many OTPs recently
+	ErrTooManyOTPRequests = errors.New("too many OTP requests, please try again later")
+	// ErrInvalidPhone is returned for a phone number too short to match a customer
+	ErrInvalidPhone = errors.New("invalid phone number")
+	// ErrInvalidOTP covers a wrong, expired or used code and an unknown phone alike
+	ErrInvalidOTP = errors.New("invalid or expired OTP")
+	// ErrInvalidCustomerSession is returned for an unknown, expired or revoked session token
+	ErrInvalidCustomerSession = errors.New("invalid or expired session")
+)
+
+type CustomerPortalService interface {
+	RequestOTP(ctx context.Context, storeID int64, req *domain.CustomerOTPRequest) (*domain.CustomerOTPRequestResponse, error)
+	VerifyOTP(ctx context.Context, storeID int64, req *domain.CustomerOTPVerifyRequest) (*domain.CustomerSessionResponse, error)
+	ValidateSession(ctx context.Context, token string) (*domain.CustomerSessionInfo, error)
+	Logout(ctx context.Context, token string) error
+	GetPoints(ctx context.Context, session *domain.CustomerSessionInfo) (*domain.CustomerPortalPointsResponse, error)
+	GetHistory(ctx context.Context, session *domain.CustomerSessionInfo, page, limit int) (*domain.GetPointHistoryResponse, error)
+	GetRewards(ctx context.Context, session *domain.CustomerSessionInfo) (*domain.CustomerRewardsResponse, error)
+}
+
+type customerPortalService struct {
+	repo         repository.CustomerPortalRepository
+	pointsRepo   repository.PointsRepository
+	tierRepo     repository.MembershipTierRepository
+	settingsRepo repository.StoreSettingsRepository
+	smsSender    sms.Sender
+}
+
+func NewCustomerPortalService(
+	repo repository.CustomerPortalRepository,
+	pointsRepo repository.PointsRepository,
+	tierRepo repository.MembershipTierRepository,
+	settingsRepo repository.StoreSettingsRepository,
+	smsSender sms.Sender,
+) CustomerPortalService {
+	return &customerPortalService{
+		repo:         repo,
+		pointsRepo:   pointsRepo,
+		tierRepo:     tierRepo,
+		settingsRepo: settingsRepo,
+		smsSender:    smsSender,
+	}
+}
+
+// phoneKey reduces a phone number to digits with +66 numbers in local 0 form,
+// the same key customers are matched on
+func phoneKey(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	key := b.String()
+	if len(key) == 11 && strings.HasPrefix(key, "66") {
+		key = "0" + key[2:]
+	}
+	return key
+}
+
+// hashOTP ties the code to the OTP's store and phone so a hash cannot be
+// replayed against another number
+func hashOTP(storeID int64, phoneKey, code string) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d:%s:%s", storeID, phoneKey, code)))
+	return hex.EncodeToString(hash[:])
+}
+
+// RequestOTP sends a six-digit code to the phone when it belongs to one of the
+// store's customers. The response does not say whether it did.
+func (s *customerPortalService) RequestOTP(ctx context.Context, storeID int64, req *domain.CustomerOTPRequest) (*domain.CustomerOTPRequestResponse, error) {
+	key := phoneKey(req.Phone)
+	if len(key) < 9 {
+		return nil, ErrInvalidPhone
+	}
+
+	customer, err := s.repo.FindCustomerByPhone(ctx, storeID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return nil, err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	otp := &repository.CustomerOTP{
+		StoreID:   storeID,
+		PhoneKey:  key,
+		CodeHash:  hashOTP(storeID, key, code),
+		ExpiresAt: time.Now().Add(otpTTL),
+	}
+	if customer != nil {
+		otp.CustomerID = &customer.ID
+	}
+	created, err := s.repo.CreateOTPWithinLimit(ctx, otp, time.Now().Add(-otpRequestWindow), otpRequestLimit)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrTooManyOTPRequests
+	}
+
+	if customer != nil {
+		message := fmt.Sprintf("รหัส OTP สำหรับดูแต้ม %s คือ %s (ใช้ได้ %d นาที)", customer.StoreName, code, int(otpTTL.Minutes()))
+		// A failed send must look the same as an unknown phone, so it is
+		// only logged
+		if err := s.smsSender.Send(ctx, customer.Phone, message); err != nil {
+			log.Printf("customer portal: failed to send OTP %d: %v", otp.ID, err)
+		}
+	}
+
+	return &domain.CustomerOTPRequestResponse{
+		Message:   "หากหมายเลขนี้เป็นสมาชิก ระบบได้ส่งรหัส OTP ทาง SMS แล้ว",
+		ExpiresIn: int(otpTTL.Seconds()),
+	}, nil
+}
+
+// VerifyOTP checks the code against the phone's latest OTP and opens a
+// customer session. Each OTP allows otpMaxAttempts guesses and one use.
+func (s *customerPortalService) VerifyOTP(ctx context.Context, storeID int64, req *domain.CustomerOTPVerifyRequest) (*domain.CustomerSessionResponse, error) {
+	key := phoneKey(req.Phone)
+	otp, err := s.repo.GetLatestOTP(ctx, storeID, key)
+	if err != nil {
+		return nil, err
+	}
+	if otp == nil || otp.CustomerID == nil {
+		return nil, ErrInvalidOTP
+	}
+
+	codeHash, err := s.repo.ConsumeOTPAttempt(ctx, otp.ID, otpMaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if codeHash == nil || subtle.ConstantTimeCompare([]byte(hashOTP(storeID, key, req.Code)), []byte(*codeHash)) != 1 {
+		return nil, ErrInvalidOTP
+	}
+
+	ok, err := s.repo.MarkOTPVerified(ctx, otp.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidOTP
+	}
+
+	customer, err := s.repo.GetCustomer(ctx, storeID, *otp.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if customer == nil {
+		return nil, ErrInvalidOTP
+	}
+
+	token, err := repository.GenerateSessionToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(customerSessionTTL)
+	if err := s.repo.CreateSession(ctx, storeID, customer.ID, token, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return &domain.CustomerSessionResponse{
+		SessionToken: token,
+		ExpiresAt:    expiresAt,
+		CustomerName: customer.FullName.String,
+		StoreName:    customer.StoreName,
+	}, nil
+}
+
+func (s *customerPortalService) ValidateSession(ctx context.Context, token string) (*domain.CustomerSessionInfo, error) {
+	session, err := s.repo.GetSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrInvalidCustomerSession
+	}
+	return &domain.CustomerSessionInfo{
+		StoreID:    session.StoreID,
+		CustomerID: session.CustomerID,
+		ExpiresAt:  session.ExpiresAt,
+	}, nil
+}
+
+func (s *customerPortalService) Logout(ctx context.Context, token string) error {
+	return s.repo.RevokeSession(ctx, token)
+}
+
+// GetPoints returns the session customer's product points, tier and wallet
+func (s *customerPortalService) GetPoints(ctx context.Context, session *domain.CustomerSessionInfo) (*domain.CustomerPortalPointsResponse, error) {
+	customer, err := s.repo.GetCustomer(ctx, session.StoreID, session.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if customer == nil {
+		return nil, ErrInvalidCustomerSession
+	}
+
+	products, err := s.pointsRepo.GetCustomerProductPoints(ctx, session.StoreID, session.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	if products == nil {
+		products = []domain.CustomerProductPointsInfo{}
+	}
+	tier, err := s.tierRepo.GetCustomerTier(ctx, session.StoreID, session.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &domain.CustomerPortalPointsResponse{
+		CustomerName: customer.FullName.String,
+		CustomerCode: customer.CustomerCode.String,
+		Tier:         tier,
+		Products:     products,
+	}
+
+	settings, err := s.settingsRepo.GetByStore(ctx, session.StoreID)
+	if err != nil {
+		return nil, err
+	}
+	if settings.LoyaltyMode.UsesWallet() {
+		resp.Wallet, err = s.pointsRepo.GetWallet(ctx, session.StoreID, session.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		if settings.WalletPointValue != nil {
+			resp.Wallet.Value = math.Round(float64(resp.Wallet.Balance)**settings.WalletPointValue*100) / 100
+		}
+	}
+	return resp, nil
+}
+
+// GetHistory returns the session customer's point transactions, newest first
+func (s *customerPortalService) GetHistory(ctx context.Context, session *domain.CustomerSessionInfo, page, limit int) (*domain.GetPointHistoryResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	history, total, err := s.pointsRepo.GetPointHistory(ctx, session.StoreID, session.CustomerID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		history = []domain.PointHistoryItem{}
+	}
+	return &domain.GetPointHistoryResponse{
+		CustomerID: session.CustomerID,
+		History:    history,
+		Total:      total,
+	}, nil
+}
+
+// GetRewards lists the store's redeemable products with the customer's points
+// for each, rewards they can already claim first
+func (s *customerPortalService) GetRewards(ctx context.Context, session *domain.CustomerSessionInfo) (*domain.CustomerRewardsResponse, error) {
+	products, err := s.pointsRepo.GetRedeemableProducts(ctx, session.StoreID, 0)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := s.pointsRepo.GetCustomerProductPoints(ctx, session.StoreID, session.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	points := make(map[int64]int, len(balances))
+	for _, b := range balances {
+		points[b.ProductID] = b.Points
+	}
+
+	var ready, pending []domain.CustomerReward
+	for _, p := range products {
+		reward := domain.CustomerReward{
+			ProductID:      p.ID,
+			ProductName:    p.ProductName,
+			CategoryName:   p.CategoryName,
+			ImagePath:      p.ImagePath,
+			PointsToRedeem: p.PointsToRedeem,
+			Points:         points[p.ID],
+			CanRedeem:      points[p.ID] >= p.PointsToRedeem,
+		}
+		if reward.CanRedeem {
+			ready = append(ready, reward)
+		} else {
+			pending = append(pending, reward)
+		}
+	}
+
+	return &domain.CustomerRewardsResponse{
+		Rewards: append(append([]domain.CustomerReward{}, ready...), pending...),
+	}, nil
+}
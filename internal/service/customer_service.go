@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+)
+
+// similarNameThreshold is the name similarity at which two customers with the
+// same phone number are treated as the same person
+const similarNameThreshold = 0.8
+
+type CustomerService interface {
+	FindDuplicates(ctx context.Context, storeID int64, includeDifferentNames bool, limit, offset int) (*domain.DuplicateCustomersResponse, error)
+	MergeCustomers(ctx context.Context, storeID, staffID int64, req *domain.MergeCustomersRequest) (*domain.MergeCustomersResponse, error)
+	GetMerges(ctx context.Context, storeID int64, customerID *int64, limit, offset int) (*domain.CustomerMergeListResponse, error)
+}
+
+type customerService struct {
+	repo         repository.CustomerRepository
+	pointsRepo   repository.PointsRepository
+	settingsRepo repository.StoreSettingsRepository
+}
+
+func NewCustomerService(repo repository.CustomerRepository, pointsRepo repository.PointsRepository, settingsRepo repository.StoreSettingsRepository) CustomerService {
+	return &customerService{
+		repo:         repo,
+		pointsRepo:   pointsRepo,
+		settingsRepo: settingsRepo,
+	}
+}
+
+// FindDuplicates groups active customers that share a phone number and a similar
+// name. Each phone's customers are split, oldest first, into clusters around the
+// first customer whose name they resemble, so people sharing a household phone
+// land in different groups; the oldest record of a cluster is its suggested
+// survivor. With includeDifferentNames, each phone is returned as one group instead,
+// with the oldest record as survivor and customers ranked by how closely their
+// name matches it. Groups of one customer are dropped.
+func (s *customerService) FindDuplicates(ctx context.Context, storeID int64, includeDifferentNames bool, limit, offset int) (*domain.DuplicateCustomersResponse, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	candidates, err := s.repo.FindDuplicateCandidates(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []domain.DuplicateCustomerGroup{}
+	for start := 0; start < len(candidates); {
+		end := start
+		for end < len(candidates) && candidates[end].PhoneKey == candidates[start].PhoneKey {
+			end++
+		}
+
+		phone := candidates[start:end]
+		if includeDifferentNames {
+			groups = appendDuplicateGroup(groups, phone, true)
+		} else {
+			var clusters [][]repository.DuplicateCandidate
+			for _, c := range phone {
+				placed := false
+				for i, cluster := range clusters {
+					if nameSimilarity(cluster[0].FullName.String, c.FullName.String) >= similarNameThreshold {
+						clusters[i] = append(cluster, c)
+						placed = true
+						break
+					}
+				}
+				if !placed {
+					clusters = append(clusters, []repository.DuplicateCandidate{c})
+				}
+			}
+			for _, cluster := range clusters {
+				groups = appendDuplicateGroup(groups, cluster, false)
+			}
+		}
+		start = end
+	}
+
+	total := len(groups)
+	if offset > total {
+		offset = total
+	}
+	if offset+limit < total {
+		groups = groups[offset : offset+limit]
+	} else {
+		groups = groups[offset:]
+	}
+
+	return &domain.DuplicateCustomersResponse{
+		Groups:     groups,
+		TotalCount: total,
+	}, nil
+}
+
+// appendDuplicateGroup adds members as one group when there are at least two,
+// with the first, oldest member as the suggested survivor. When ranked is set the
+// others are ordered by name similarity to the survivor, closest first.
+func appendDuplicateGroup(groups []domain.DuplicateCustomerGroup, members []repository.DuplicateCandidate, ranked bool) []domain.DuplicateCustomerGroup {
+	if len(members) < 2 {
+		return groups
+	}
+
+	survivor := members[0]
+	group := domain.DuplicateCustomerGroup{
+		PhoneKey:            survivor.PhoneKey,
+		SuggestedSurvivorID: survivor.ID,
+		NamesMatch:          true,
+	}
+	for _, c := range members {
+		similarity := nameSimilarity(survivor.FullName.String, c.FullName.String)
+		if c.ID == survivor.ID {
+			similarity = 1
+		}
+		similar := similarity >= similarNameThreshold
+		if !similar {
+			group.NamesMatch = false
+		}
+		d := domain.DuplicateCustomer{
+			ID:             c.ID,
+			Phone:          c.Phone,
+			ProductPoints:  c.ProductPoints,
+			WalletBalance:  c.WalletBalance,
+			OrderCount:     c.OrderCount,
+			NameSimilarity: math.Round(similarity*100) / 100,
+			SimilarName:    similar,
+			CreatedAt:      c.CreatedAt,
+		}
+		if c.CustomerCode.Valid {
+			d.CustomerCode = &c.CustomerCode.String
+		}
+		if c.FullName.Valid {
+			d.FullName = &c.FullName.String
+		}
+		group.Customers = append(group.Customers, d)
+	}
+	if ranked {
+		others := group.Customers[1:]
+		sort.SliceStable(others, func(i, j int) bool {
+			return others[i].NameSimilarity > others[j].NameSimilarity
+		})
+	}
+	return append(groups, group)
+}
+
+// nameSimilarity scores two names from 0 to 1 by edit distance, ignoring case
+// and spacing. A name that contains the other, such as a first name against a
+// full name, scores at the threshold.
+func nameSimilarity(a, b string) float64 {
+	a = strings.ToLower(strings.Join(strings.Fields(a), " "))
+	b = strings.ToLower(strings.Join(strings.Fields(b), " "))
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	score := 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+
+	if score < similarNameThreshold && (strings.Contains(a, b) || strings.Contains(b, a)) {
+		score = similarNameThreshold
+	}
+	return score
+}
+
+// MergeCustomers merges duplicate customers into the survivor and returns the
+// survivor's combined balances
+func (s *customerService) MergeCustomers(ctx context.Context, storeID, staffID int64, req *domain.MergeCustomersRequest) (*domain.MergeCustomersResponse, error) {
+	seen := map[int64]bool{}
+	var mergeIDs []int64
+	for _, id := range req.MergeIDs {
+		if id == req.SurvivorID {
+			return nil, errors.New("survivor_id cannot also be merged")
+		}
+		if id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		mergeIDs = append(mergeIDs, id)
+	}
+	if len(mergeIDs) == 0 {
+		return nil, errors.New("merge_ids must list at least one other customer")
+	}
+	if req.Note != nil {
+		note := strings.TrimSpace(*req.Note)
+		req.Note = &note
+		if note == "" {
+			req.Note = nil
+		}
+	}
+
+	result, err := s.repo.MergeCustomersTx(ctx, storeID, req.SurvivorID, mergeIDs, staffID, req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := s.pointsRepo.GetCustomerProductPoints(ctx, storeID, req.SurvivorID)
+	if err != nil {
+		return nil, err
+	}
+	resp := &domain.MergeCustomersResponse{
+		SurvivorID:  req.SurvivorID,
+		Merges:      result.Merges,
+		Products:    products,
+		Tier:        result.Tier,
+		TierChanged: result.TierChanged,
+		Message:     fmt.Sprintf("รวมลูกค้า %d รายการเข้ากับลูกค้า #%d สำเร็จ", len(result.Merges), req.SurvivorID),
+	}
+
+	settings, err := s.settingsRepo.GetByStore(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	if settings.LoyaltyMode.UsesWallet() {
+		resp.Wallet, err = s.pointsRepo.GetWallet(ctx, storeID, req.SurvivorID)
+		if err != nil {
+			return nil, err
+		}
+		if settings.WalletPointValue != nil {
+			resp.Wallet.Value = math.Round(float64(resp.Wallet.Balance)**settings.WalletPointValue*100) / 100
+		}
+	}
+	return resp, nil
+}
+
+func (s *customerService) GetMerges(ctx context.Context, storeID int64, customerID *int64, limit, offset int) (*domain.CustomerMergeListResponse, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	merges, total, err := s.repo.GetMerges(ctx, storeID, customerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.CustomerMergeListResponse{
+		Merges:     merges,
+		TotalCount: total,
+	}, nil
+}
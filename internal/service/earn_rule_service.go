@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+)
+
+// storeLocation is the local time earn rule days and happy hours are matched in
+var storeLocation = time.FixedZone("ICT", 7*60*60)
+
+type EarnRuleService interface {
+	GetRules(ctx context.Context, storeID int64) (*domain.EarnRuleListResponse, error)
+	CreateRule(ctx context.Context, storeID int64, staffID *int64, req *domain.EarnRuleRequest) (*domain.EarnRule, error)
+	UpdateRule(ctx context.Context, storeID, ruleID int64, req *domain.EarnRuleRequest) (*domain.EarnRule, error)
+	DeleteRule(ctx context.Context, storeID, ruleID int64) error
+	PreviewEarn(ctx context.Context, storeID int64, req *domain.PreviewEarnRequest) (*domain.EarnPreviewResponse, error)
+}
+
+type earnRuleService struct {
+	repo     repository.EarnRuleRepository
+	tierRepo repository.MembershipTierRepository
+}
+
+func NewEarnRuleService(repo repository.EarnRuleRepository, tierRepo repository.MembershipTierRepository) EarnRuleService {
+	return &earnRuleService{
+		repo:     repo,
+		tierRepo: tierRepo,
+	}
+}
+
+func (s *earnRuleService) GetRules(ctx context.Context, storeID int64) (*domain.EarnRuleListResponse, error) {
+	rules, err := s.repo.List(ctx, storeID, false)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.EarnRuleListResponse{Rules: rules}, nil
+}
+
+func (s *earnRuleService) CreateRule(ctx context.Context, storeID int64, staffID *int64, req *domain.EarnRuleRequest) (*domain.EarnRule, error) {
+	if err := s.validateRule(ctx, storeID, req); err != nil {
+		return nil, err
+	}
+	ruleID, err := s.repo.Create(ctx, storeID, staffID, req)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, storeID, ruleID)
+}
+
+func (s *earnRuleService) UpdateRule(ctx context.Context, storeID, ruleID int64, req *domain.EarnRuleRequest) (*domain.EarnRule, error) {
+	if err := s.validateRule(ctx, storeID, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, storeID, ruleID, req); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, storeID, ruleID)
+}
+
+func (s *earnRuleService) DeleteRule(ctx context.Context, storeID, ruleID int64) error {
+	return s.repo.Delete(ctx, storeID, ruleID)
+}
+
+// validateRule checks the amount for the rule type, clears amounts for other types
+// and checks the conditions are consistent
+func (s *earnRuleService) validateRule(ctx context.Context, storeID int64, req *domain.EarnRuleRequest) error {
+	switch req.RuleType {
+	case domain.EarnRuleTypePerUnit:
+		if req.PointsPerUnit == nil {
+			return errors.New("points_per_unit is required for a PER_UNIT rule")
+		}
+		req.BahtPerPoint, req.Multiplier = nil, nil
+	case domain.EarnRuleTypePerBaht:
+		if req.BahtPerPoint == nil {
+			return errors.New("baht_per_point is required for a PER_BAHT rule")
+		}
+		req.PointsPerUnit, req.Multiplier = nil, nil
+	case domain.EarnRuleTypeMultiplier:
+		if req.Multiplier == nil {
+			return errors.New("multiplier is required for a MULTIPLIER rule")
+		}
+		req.PointsPerUnit, req.BahtPerPoint = nil, nil
+	}
+
+	if req.ProductID != nil && req.CategoryID != nil {
+		return errors.New("a rule can match a product or a category, not both")
+	}
+	if (req.StartTime == nil) != (req.EndTime == nil) {
+		return errors.New("start_time and end_time must be set together")
+	}
+	if req.StartTime != nil && *req.StartTime == *req.EndTime {
+		return errors.New("start_time and end_time must differ")
+	}
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+
+	if req.TierCode != nil {
+		tiers, err := s.tierRepo.List(ctx, storeID, false)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, t := range tiers {
+			if t.TierCode == *req.TierCode {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.New("tier not found")
+		}
+	}
+
+	if req.ProductID != nil {
+		products, err := s.repo.GetProducts(ctx, storeID, []int64{*req.ProductID})
+		if err != nil {
+			return err
+		}
+		if len(products) == 0 {
+			return errors.New("product not found")
+		}
+	}
+	return nil
+}
+
+// PreviewEarn returns what a cart would earn if it were checked out now
+func (s *earnRuleService) PreviewEarn(ctx context.Context, storeID int64, req *domain.PreviewEarnRequest) (*domain.EarnPreviewResponse, error) {
+	var tier *domain.CustomerTier
+	if req.CustomerID != nil {
+		var err error
+		tier, err = s.tierRepo.GetCustomerTier(ctx, storeID, *req.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return calculateEarnPoints(ctx, s.repo, storeID, req.Items, req.PromotionID, tier, time.Now())
+}
+
+// calculateEarnPoints applies the store's active earn rules to the order lines.
+// tier is the customer's tier, nil when they have none; its earn multiplier
+// stacks with MULTIPLIER rules, including under the default rule.
+func calculateEarnPoints(ctx context.Context, repo repository.EarnRuleRepository, storeID int64, items []domain.OrderItemRequest, promotionID *int64, tier *domain.CustomerTier, at time.Time) (*domain.EarnPreviewResponse, error) {
+	rules, err := repo.List(ctx, storeID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]int64, len(items))
+	for i, item := range items {
+		productIDs[i] = item.ProductID
+	}
+	products, err := repo.GetProducts(ctx, storeID, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	productByID := make(map[int64]domain.EarnProductInfo, len(products))
+	for _, p := range products {
+		productByID[p.ProductID] = p
+	}
+
+	var tierCode *string
+	if tier != nil {
+		tierCode = &tier.TierCode
+	}
+
+	resp := &domain.EarnPreviewResponse{
+		DefaultRule: len(rules) == 0,
+		Tier:        tier,
+		Items:       make([]domain.EarnPreviewItem, 0, len(items)),
+	}
+	for _, item := range items {
+		product, ok := productByID[item.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("product %d not found", item.ProductID)
+		}
+		line := domain.EarnPreviewItem{
+			ProductID:   item.ProductID,
+			ProductName: product.ProductName,
+			Quantity:    item.Quantity,
+			LineTotal:   math.Round(item.Price*float64(item.Quantity)*100) / 100,
+			Multiplier:  1,
+		}
+
+		if resp.DefaultRule {
+			line.BasePoints = item.Quantity
+		} else {
+			var base *domain.EarnRule
+			for i := range rules {
+				rule := &rules[i]
+				if !earnRuleMatches(rule, product, promotionID, tierCode, at) {
+					continue
+				}
+				switch rule.RuleType {
+				case domain.EarnRuleTypeMultiplier:
+					line.Multiplier *= *rule.Multiplier
+					line.AppliedRules = append(line.AppliedRules, rule.RuleName)
+				default:
+					if base == nil || betterBaseRule(rule, base) {
+						base = rule
+					}
+				}
+			}
+			if base != nil {
+				switch base.RuleType {
+				case domain.EarnRuleTypePerUnit:
+					line.BasePoints = *base.PointsPerUnit * item.Quantity
+				case domain.EarnRuleTypePerBaht:
+					line.BasePoints = int(math.Floor(line.LineTotal/(*base.BahtPerPoint) + 1e-9))
+				}
+				line.AppliedRules = append([]string{base.RuleName}, line.AppliedRules...)
+			}
+		}
+
+		if tier != nil && tier.EarnMultiplier != 1 {
+			line.Multiplier *= tier.EarnMultiplier
+			line.AppliedRules = append(line.AppliedRules, tier.TierName)
+		}
+
+		line.Points = int(math.Floor(float64(line.BasePoints)*line.Multiplier + 1e-9))
+		resp.TotalPoints += line.Points
+		resp.Items = append(resp.Items, line)
+	}
+	return resp, nil
+}
+
+// betterBaseRule reports whether a should win over b: a product rule beats a
+// category rule, which beats a store-wide rule, then the higher priority wins
+func betterBaseRule(a, b *domain.EarnRule) bool {
+	if ra, rb := earnRuleSpecificity(a), earnRuleSpecificity(b); ra != rb {
+		return ra > rb
+	}
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	return a.ID < b.ID
+}
+
+func earnRuleSpecificity(rule *domain.EarnRule) int {
+	switch {
+	case rule.ProductID != nil:
+		return 2
+	case rule.CategoryID != nil:
+		return 1
+	}
+	return 0
+}
+
+// earnRuleMatches reports whether every condition on the rule holds for the product at the given time
+func earnRuleMatches(rule *domain.EarnRule, product domain.EarnProductInfo, promotionID *int64, tierCode *string, at time.Time) bool {
+	if rule.ProductID != nil && *rule.ProductID != product.ProductID {
+		return false
+	}
+	if rule.CategoryID != nil && (product.CategoryID == nil || *rule.CategoryID != *product.CategoryID) {
+		return false
+	}
+	if rule.PromotionID != nil && (promotionID == nil || *rule.PromotionID != *promotionID) {
+		return false
+	}
+	if rule.TierCode != nil && (tierCode == nil || *rule.TierCode != *tierCode) {
+		return false
+	}
+	if rule.StartsAt != nil && at.Before(*rule.StartsAt) {
+		return false
+	}
+	if rule.EndsAt != nil && !at.Before(*rule.EndsAt) {
+		return false
+	}
+
+	local := at.In(storeLocation)
+	if len(rule.DaysOfWeek) > 0 {
+		found := false
+		for _, d := range rule.DaysOfWeek {
+			if time.Weekday(d) == local.Weekday() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.StartTime != nil && rule.EndTime != nil {
+		start, errStart := time.Parse("15:04", *rule.StartTime)
+		end, errEnd := time.Parse("15:04", *rule.EndTime)
+		if errStart != nil || errEnd != nil {
+			return false
+		}
+		now := local.Hour()*60 + local.Minute()
+		from := start.Hour()*60 + start.Minute()
+		to := end.Hour()*60 + end.Minute()
+		if from < to {
+			return now >= from && now < to
+		}
+		// The window wraps past midnight
+		return now >= from || now < to
+	}
+	return true
+}
@@ -3,58 +3,557 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mini-membership/api/internal/domain"
 	"github.com/mini-membership/api/internal/repository"
+	"github.com/mini-membership/api/pkg/export"
+	"github.com/shopspring/decimal"
 )
 
-const DefaultLowStockThreshold = 10
+const (
+	DefaultSalesWindowDays = 28
+	DefaultCoverDays       = 14
+)
+
+// ErrManagerApprovalRequired is returned when an adjustment reaches the store's
+// approval threshold and no manager has approved it
+var ErrManagerApprovalRequired = errors.New("manager approval required for this adjustment")
 
 type InventoryService interface {
-	AdjustStock(ctx context.Context, storeID, branchID, staffID int64, req *domain.AdjustStockRequest) error
-	GetMovements(ctx context.Context, storeID, branchID int64, limit, offset int) ([]domain.InventoryMovementResponse, error)
+	AdjustStock(ctx context.Context, storeID, branchID, staffID int64, approvedBy *int64, req *domain.AdjustStockRequest) error
+	GetAdjustmentReasons(ctx context.Context, storeID int64, activeOnly bool) ([]domain.StockAdjustmentReason, error)
+	CreateAdjustmentReason(ctx context.Context, storeID int64, req *domain.CreateStockAdjustmentReasonRequest) (*domain.StockAdjustmentReason, error)
+	UpdateAdjustmentReason(ctx context.Context, storeID, reasonID int64, req *domain.UpdateStockAdjustmentReasonRequest) (*domain.StockAdjustmentReason, error)
+	GetMovements(ctx context.Context, storeID, branchID int64, filter *domain.InventoryMovementFilter, limit, offset int) ([]domain.InventoryMovementResponse, error)
+	ExportMovements(ctx context.Context, storeID, branchID int64, filter *domain.InventoryMovementFilter, w export.Writer) error
 	GetLowStockItems(ctx context.Context, storeID, branchID int64) (*domain.LowStockResponse, error)
+	UpdateReorderSettings(ctx context.Context, storeID, branchID int64, req *domain.UpdateReorderSettingsRequest) error
+	GetReplenishmentSuggestions(ctx context.Context, storeID, branchID int64, salesWindowDays, coverDays int) (*domain.ReplenishmentResponse, error)
+	CreateReplenishmentOrder(ctx context.Context, storeID, branchID, staffID int64, req *domain.CreateReplenishmentOrderRequest) (*domain.ReplenishmentOrderResponse, error)
+	UpdateProductNegativeStockPolicy(ctx context.Context, storeID, productID int64, req *domain.UpdateProductNegativeStockPolicyRequest) error
+	GetOversells(ctx context.Context, storeID int64, branchID *int64, from, to *time.Time, limit, offset int) (*domain.OversellReportResponse, error)
+	GetValuation(ctx context.Context, storeID int64, branchID *int64) (*domain.InventoryValuationResponse, error)
+	GetLots(ctx context.Context, storeID int64, branchID, productID *int64) (*domain.StockLotListResponse, error)
+	GetExpiringLots(ctx context.Context, storeID int64, branchID *int64, days int) (*domain.ExpiringLotsResponse, error)
+	WriteOffLot(ctx context.Context, storeID, branchID, staffID int64, approvedBy *int64, lotID int64, req *domain.WriteOffLotRequest) error
+	UpdateProductLotTracking(ctx context.Context, storeID, productID int64, req *domain.UpdateProductLotTrackingRequest) error
 }
 
 type inventoryService struct {
-	repo repository.InventoryRepository
+	repo                 repository.InventoryRepository
+	settingsRepo         repository.StoreSettingsRepository
+	stockTransferService StockTransferService
+	purchaseOrderService PurchaseOrderService
 }
 
-func NewInventoryService(repo repository.InventoryRepository) InventoryService {
-	return &inventoryService{repo: repo}
+func NewInventoryService(repo repository.InventoryRepository, settingsRepo repository.StoreSettingsRepository, stockTransferService StockTransferService, purchaseOrderService PurchaseOrderService) InventoryService {
+	return &inventoryService{
+		repo:                 repo,
+		settingsRepo:         settingsRepo,
+		stockTransferService: stockTransferService,
+		purchaseOrderService: purchaseOrderService,
+	}
 }
 
-func (s *inventoryService) AdjustStock(ctx context.Context, storeID, branchID, staffID int64, req *domain.AdjustStockRequest) error {
-	if req.Quantity <= 0 {
-		return errors.New("quantity must be positive")
+// AdjustStock applies a signed stock adjustment. approvedBy is the manager who
+// approved it, if any; adjustments at or above the store's threshold need one.
+func (s *inventoryService) AdjustStock(ctx context.Context, storeID, branchID, staffID int64, approvedBy *int64, req *domain.AdjustStockRequest) error {
+	if req.Quantity == 0 {
+		return errors.New("quantity must not be zero")
+	}
+
+	reason, err := s.repo.GetAdjustmentReasonByCode(ctx, storeID, req.ReasonCode)
+	if err != nil {
+		return err
+	}
+	if reason == nil || !reason.IsActive {
+		return errors.New("unknown reason code")
+	}
+
+	movementType := reason.MovementType
+	if req.MovementType != "" && req.MovementType != movementType {
+		return fmt.Errorf("reason code %s is recorded as %s", reason.Code, movementType)
+	}
+
+	// Damage and issues only ever take stock out; ADJUST goes either way
+	if movementType != domain.MovementTypeAdjust && req.Quantity > 0 {
+		return fmt.Errorf("%s adjustments must have a negative quantity", movementType)
 	}
 
-	// For ADJUST type, we reduce stock (negative quantity change)
-	quantityChange := -req.Quantity
+	cost, err := s.repo.GetAverageCost(ctx, storeID, &branchID, nil, req.ProductID)
+	if err != nil {
+		return err
+	}
+	if cost == nil {
+		return errors.New("product not found")
+	}
+
+	if approvedBy == nil {
+		quantity := req.Quantity
+		if quantity < 0 {
+			quantity = -quantity
+		}
+		if err := checkApprovalThreshold(ctx, s.settingsRepo, storeID, quantity, *cost); err != nil {
+			return err
+		}
+	}
+
+	// Free-text reason falls back to the catalogue label
+	reasonText := reason.Label
+	if req.Reason != "" {
+		reasonText = req.Reason
+	}
+
+	var note *string
+	if req.Note != "" {
+		note = &req.Note
+	}
 
 	return s.repo.AdjustStock(
 		ctx,
 		storeID,
 		branchID,
 		req.ProductID,
-		quantityChange,
-		domain.MovementTypeAdjust,
-		&req.Reason,
-		&req.Note,
+		req.Quantity,
+		movementType,
+		&reason.Code,
+		&reasonText,
+		note,
 		staffID,
+		approvedBy,
 	)
 }
 
-func (s *inventoryService) GetMovements(ctx context.Context, storeID, branchID int64, limit, offset int) ([]domain.InventoryMovementResponse, error) {
+func (s *inventoryService) GetAdjustmentReasons(ctx context.Context, storeID int64, activeOnly bool) ([]domain.StockAdjustmentReason, error) {
+	return s.repo.GetAdjustmentReasons(ctx, storeID, activeOnly)
+}
+
+func (s *inventoryService) CreateAdjustmentReason(ctx context.Context, storeID int64, req *domain.CreateStockAdjustmentReasonRequest) (*domain.StockAdjustmentReason, error) {
+	req.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	if req.Code == "" {
+		return nil, errors.New("code is required")
+	}
+
+	existing, err := s.repo.GetAdjustmentReasonByCode(ctx, storeID, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	// A store may override a system default, but not duplicate its own code
+	if existing != nil && existing.StoreID != nil {
+		return nil, errors.New("reason code already exists")
+	}
+
+	return s.repo.CreateAdjustmentReason(ctx, storeID, req)
+}
+
+func (s *inventoryService) UpdateAdjustmentReason(ctx context.Context, storeID, reasonID int64, req *domain.UpdateStockAdjustmentReasonRequest) (*domain.StockAdjustmentReason, error) {
+	reason, err := s.repo.GetAdjustmentReasonByID(ctx, storeID, reasonID)
+	if err != nil {
+		return nil, err
+	}
+	if reason == nil {
+		return nil, errors.New("reason code not found")
+	}
+
+	if err := s.repo.UpdateAdjustmentReason(ctx, storeID, reasonID, req); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetAdjustmentReasonByID(ctx, storeID, reasonID)
+}
+
+func (s *inventoryService) GetMovements(ctx context.Context, storeID, branchID int64, filter *domain.InventoryMovementFilter, limit, offset int) ([]domain.InventoryMovementResponse, error) {
 	if limit <= 0 {
 		limit = 20
 	}
 	if limit > 100 {
 		limit = 100
 	}
-	return s.repo.GetMovementsByBranch(ctx, storeID, branchID, limit, offset)
+	return s.repo.GetMovementsByBranch(ctx, storeID, branchID, filter, limit, offset)
+}
+
+// ExportMovements writes every filtered movement, oldest first, as one row each
+func (s *inventoryService) ExportMovements(ctx context.Context, storeID, branchID int64, filter *domain.InventoryMovementFilter, w export.Writer) error {
+	err := w.WriteRow([]string{
+		"movement_id", "created_at", "product_id", "product_name", "movement_type",
+		"quantity_change", "from_stock", "to_stock", "running_balance", "unit_cost",
+		"reason_code", "reason", "note", "changed_by", "approved_by",
+		"reference_table", "reference_id",
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.repo.StreamMovements(ctx, storeID, branchID, filter, func(m *domain.InventoryMovementResponse) error {
+		return w.WriteRow([]string{
+			strconv.FormatInt(m.ID, 10),
+			m.CreatedAt.Format(time.RFC3339),
+			strconv.FormatInt(m.ProductID, 10),
+			m.ProductName,
+			string(m.MovementType),
+			strconv.Itoa(m.QuantityChange),
+			formatOptionalInt(m.FromStockCount),
+			formatOptionalInt(m.ToStockCount),
+			formatOptionalInt(m.RunningBalance),
+			formatOptionalFloat(m.UnitCost),
+			formatOptionalString(m.ReasonCode),
+			formatOptionalString(m.Reason),
+			formatOptionalString(m.Note),
+			formatOptionalString(m.ChangedByName),
+			formatOptionalString(m.ApprovedByName),
+			formatOptionalString(m.ReferenceTable),
+			formatOptionalInt64(m.ReferenceID),
+		})
+	})
+}
+
+func formatOptionalString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func formatOptionalInt(i *int) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.Itoa(*i)
+}
+
+func formatOptionalInt64(i *int64) string {
+	if i == nil {
+		return ""
+	}
+	return strconv.FormatInt(*i, 10)
+}
+
+func formatOptionalFloat(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', 2, 64)
 }
 
 func (s *inventoryService) GetLowStockItems(ctx context.Context, storeID, branchID int64) (*domain.LowStockResponse, error) {
-	return s.repo.GetLowStockItems(ctx, storeID, branchID, DefaultLowStockThreshold)
+	return s.repo.GetLowStockItems(ctx, storeID, branchID)
+}
+
+func (s *inventoryService) UpdateReorderSettings(ctx context.Context, storeID, branchID int64, req *domain.UpdateReorderSettingsRequest) error {
+	price, err := s.repo.GetProductBasePrice(ctx, storeID, req.ProductID)
+	if err != nil {
+		return err
+	}
+	if price == nil {
+		return errors.New("product not found")
+	}
+	return s.repo.UpdateReorderSettings(ctx, storeID, branchID, req)
+}
+
+// GetReplenishmentSuggestions suggests order quantities from sales velocity over the
+// window and each product's lead time. A product is suggested once stock on hand plus
+// stock already incoming falls to its reorder point (reorder level + lead time demand);
+// the quantity brings it back up to the reorder point plus coverDays of demand.
+func (s *inventoryService) GetReplenishmentSuggestions(ctx context.Context, storeID, branchID int64, salesWindowDays, coverDays int) (*domain.ReplenishmentResponse, error) {
+	if salesWindowDays <= 0 {
+		salesWindowDays = DefaultSalesWindowDays
+	}
+	if salesWindowDays > 365 {
+		salesWindowDays = 365
+	}
+	if coverDays < 0 {
+		coverDays = DefaultCoverDays
+	}
+
+	candidates, err := s.repo.GetReplenishmentCandidates(ctx, storeID, branchID, salesWindowDays)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []domain.ReplenishmentSuggestion{}
+	for _, cand := range candidates {
+		sold := cand.SoldQty
+		if sold < 0 {
+			sold = 0
+		}
+		avgDailySales := float64(sold) / float64(salesWindowDays)
+
+		reorderPoint := cand.ReorderLevel + int(math.Ceil(avgDailySales*float64(cand.LeadTimeDays)))
+		available := cand.OnStock + cand.IncomingQty
+		if available > reorderPoint {
+			continue
+		}
+
+		target := reorderPoint + int(math.Ceil(avgDailySales*float64(coverDays)))
+		suggested := target - available
+		if suggested <= 0 {
+			continue
+		}
+
+		items = append(items, domain.ReplenishmentSuggestion{
+			ProductID:     cand.ProductID,
+			ProductName:   cand.ProductName,
+			CategoryName:  cand.CategoryName,
+			OnStock:       cand.OnStock,
+			IncomingQty:   cand.IncomingQty,
+			ReorderLevel:  cand.ReorderLevel,
+			LeadTimeDays:  cand.LeadTimeDays,
+			AvgDailySales: math.Round(avgDailySales*100) / 100,
+			ReorderPoint:  reorderPoint,
+			SuggestedQty:  suggested,
+		})
+	}
+
+	return &domain.ReplenishmentResponse{
+		SalesWindowDays: salesWindowDays,
+		CoverDays:       coverDays,
+		Items:           items,
+	}, nil
+}
+
+// CreateReplenishmentOrder places the suggestions (or the given items) as a withdrawal
+// from a warehouse or as a purchase order delivered to the branch
+func (s *inventoryService) CreateReplenishmentOrder(ctx context.Context, storeID, branchID, staffID int64, req *domain.CreateReplenishmentOrderRequest) (*domain.ReplenishmentOrderResponse, error) {
+	if req.Source == domain.ReplenishmentSourcePurchaseOrder && req.SupplierID == nil {
+		return nil, errors.New("supplier_id is required for a purchase order")
+	}
+
+	coverDays := DefaultCoverDays
+	if req.CoverDays != nil {
+		coverDays = *req.CoverDays
+	}
+
+	items := req.Items
+	var lastCosts map[int64]float64
+	if len(items) == 0 || req.Source == domain.ReplenishmentSourcePurchaseOrder {
+		suggestions, err := s.GetReplenishmentSuggestions(ctx, storeID, branchID, req.SalesWindowDays, coverDays)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			for _, suggestion := range suggestions.Items {
+				items = append(items, domain.WithdrawItemInput{ProductID: suggestion.ProductID, Quantity: suggestion.SuggestedQty})
+			}
+		}
+
+		if req.Source == domain.ReplenishmentSourcePurchaseOrder {
+			candidates, err := s.repo.GetReplenishmentCandidates(ctx, storeID, branchID, suggestions.SalesWindowDays)
+			if err != nil {
+				return nil, err
+			}
+			lastCosts = make(map[int64]float64, len(candidates))
+			for _, cand := range candidates {
+				lastCosts[cand.ProductID] = cand.LastUnitCost
+			}
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.New("nothing to replenish")
+	}
+
+	resp := &domain.ReplenishmentOrderResponse{Source: req.Source}
+
+	switch req.Source {
+	case domain.ReplenishmentSourceWarehouse:
+		transfer, err := s.stockTransferService.WithdrawGoods(ctx, storeID, branchID, staffID, &domain.WithdrawGoodsRequest{
+			WarehouseID: req.WarehouseID,
+			Items:       items,
+			Note:        req.Note,
+		})
+		if err != nil {
+			return nil, err
+		}
+		resp.Transfer = transfer
+
+	case domain.ReplenishmentSourcePurchaseOrder:
+		poReq := &domain.CreatePurchaseOrderRequest{
+			SupplierID: *req.SupplierID,
+			BranchID:   &branchID,
+			Note:       req.Note,
+			Items:      make([]domain.CreatePurchaseOrderItemInput, len(items)),
+		}
+		// Price each line at the last cost paid for the product; goods receipts can override it
+		for i, item := range items {
+			poReq.Items[i] = domain.CreatePurchaseOrderItemInput{
+				ProductID:  item.ProductID,
+				OrderedQty: item.Quantity,
+				UnitCost:   lastCosts[item.ProductID],
+			}
+		}
+
+		po, err := s.purchaseOrderService.CreatePurchaseOrder(ctx, storeID, branchID, staffID, poReq)
+		if err != nil {
+			return nil, err
+		}
+		resp.PurchaseOrder = po
+
+	default:
+		return nil, errors.New("invalid replenishment source")
+	}
+
+	return resp, nil
+}
+
+func (s *inventoryService) UpdateProductNegativeStockPolicy(ctx context.Context, storeID, productID int64, req *domain.UpdateProductNegativeStockPolicyRequest) error {
+	return s.repo.UpdateProductNegativeStockPolicy(ctx, storeID, productID, req.Policy)
+}
+
+func (s *inventoryService) GetOversells(ctx context.Context, storeID int64, branchID *int64, from, to *time.Time, limit, offset int) (*domain.OversellReportResponse, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.GetOversells(ctx, storeID, branchID, from, to, limit, offset)
+}
+
+func (s *inventoryService) GetValuation(ctx context.Context, storeID int64, branchID *int64) (*domain.InventoryValuationResponse, error) {
+	rows, err := s.repo.GetValuation(ctx, storeID, branchID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &domain.InventoryValuationResponse{Rows: rows}
+	for _, row := range rows {
+		resp.TotalOnStock += row.OnStock
+		resp.TotalStockValue += row.StockValue
+		resp.TotalRetailValue += row.RetailValue
+	}
+	resp.TotalStockValue = math.Round(resp.TotalStockValue*100) / 100
+	resp.TotalRetailValue = math.Round(resp.TotalRetailValue*100) / 100
+
+	return resp, nil
+}
+
+// checkApprovalThreshold returns ErrManagerApprovalRequired when an unapproved
+// adjustment of quantity units reaches either of the store's thresholds. The value is
+// taken at the location's moving-average cost, so it matches the stock valuation.
+// Branch and warehouse adjustments share it.
+func checkApprovalThreshold(ctx context.Context, settingsRepo repository.StoreSettingsRepository, storeID int64, quantity int, unitCost decimal.Decimal) error {
+	settings, err := settingsRepo.GetByStore(ctx, storeID)
+	if err != nil {
+		return err
+	}
+	if settings.AdjustmentApprovalQty != nil && quantity >= *settings.AdjustmentApprovalQty {
+		return ErrManagerApprovalRequired
+	}
+	if settings.AdjustmentApprovalValue != nil && unitCost.Mul(decimal.NewFromInt(int64(quantity))).GreaterThanOrEqual(decimal.NewFromFloat(*settings.AdjustmentApprovalValue)) {
+		return ErrManagerApprovalRequired
+	}
+	return nil
+}
+
+func (s *inventoryService) GetLots(ctx context.Context, storeID int64, branchID, productID *int64) (*domain.StockLotListResponse, error) {
+	lots, err := s.repo.GetLots(ctx, storeID, branchID, productID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.StockLotListResponse{Lots: lots, TotalCount: len(lots)}, nil
+}
+
+// GetExpiringLots returns lots that expire within the next days days, including
+// lots that have already expired
+func (s *inventoryService) GetExpiringLots(ctx context.Context, storeID int64, branchID *int64, days int) (*domain.ExpiringLotsResponse, error) {
+	if days <= 0 {
+		days = domain.DefaultExpiryWarningDays
+	}
+	if days > 365 {
+		days = 365
+	}
+
+	now := time.Now()
+	before := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, days+1)
+	lots, err := s.repo.GetLots(ctx, storeID, branchID, nil, &before)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &domain.ExpiringLotsResponse{Days: days, Lots: lots}
+	for _, lot := range lots {
+		resp.TotalQuantity += lot.Quantity
+		resp.TotalValue += lot.StockValue
+	}
+	resp.TotalValue = math.Round(resp.TotalValue*100) / 100
+
+	return resp, nil
+}
+
+// WriteOffLot writes off stock from a single lot as DAMAGE. Branch lots can only be
+// written off from their own branch; warehouse lots need a manager. The store's
+// adjustment approval thresholds apply as they do for AdjustStock.
+func (s *inventoryService) WriteOffLot(ctx context.Context, storeID, branchID, staffID int64, approvedBy *int64, lotID int64, req *domain.WriteOffLotRequest) error {
+	lot, err := s.repo.GetLotByID(ctx, storeID, lotID)
+	if err != nil {
+		return err
+	}
+	if lot == nil {
+		return errors.New("lot not found")
+	}
+	if lot.BranchID != nil && *lot.BranchID != branchID {
+		return errors.New("lot is not held at this branch")
+	}
+	if lot.WarehouseID != nil && approvedBy == nil {
+		return ErrManagerApprovalRequired
+	}
+
+	quantity := lot.Quantity
+	if req.Quantity != nil {
+		quantity = *req.Quantity
+	}
+	if quantity <= 0 {
+		return errors.New("lot has no stock left")
+	}
+	if quantity > lot.Quantity {
+		return fmt.Errorf("lot %s has only %d left", lot.LotNumber, lot.Quantity)
+	}
+
+	code := req.ReasonCode
+	if code == "" {
+		code = "EXPIRED"
+	}
+	reason, err := s.repo.GetAdjustmentReasonByCode(ctx, storeID, code)
+	if err != nil {
+		return err
+	}
+	if reason == nil || !reason.IsActive {
+		return errors.New("unknown reason code")
+	}
+	if reason.MovementType != domain.MovementTypeDamage {
+		return fmt.Errorf("reason code %s is not a write-off reason", reason.Code)
+	}
+
+	if approvedBy == nil {
+		cost, err := s.repo.GetAverageCost(ctx, storeID, lot.BranchID, lot.WarehouseID, lot.ProductID)
+		if err != nil {
+			return err
+		}
+		if cost == nil {
+			return errors.New("product not found")
+		}
+		if err := checkApprovalThreshold(ctx, s.settingsRepo, storeID, quantity, *cost); err != nil {
+			return err
+		}
+	}
+
+	reasonText := fmt.Sprintf("%s (lot %s)", reason.Label, lot.LotNumber)
+	if req.Reason != "" {
+		reasonText = req.Reason
+	}
+
+	var note *string
+	if req.Note != "" {
+		note = &req.Note
+	}
+
+	return s.repo.WriteOffLot(ctx, storeID, lotID, quantity, &reason.Code, &reasonText, note, staffID, approvedBy)
+}
+
+func (s *inventoryService) UpdateProductLotTracking(ctx context.Context, storeID, productID int64, req *domain.UpdateProductLotTrackingRequest) error {
+	return s.repo.UpdateProductLotTracking(ctx, storeID, productID, *req.TrackLots)
 }
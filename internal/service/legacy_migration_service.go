@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+)
+
+type LegacyMigrationService interface {
+	Migrate(ctx context.Context, opts *domain.LegacyMigrationOptions) (*domain.LegacyMigrationResult, error)
+}
+
+type legacyMigrationService struct {
+	repo repository.LegacyMigrationRepository
+}
+
+func NewLegacyMigrationService(repo repository.LegacyMigrationRepository) LegacyMigrationService {
+	return &legacyMigrationService{repo: repo}
+}
+
+// Migrate copies every v1 member into the store one member at a time. A member
+// that fails is reported and skipped; running again picks up where it left off.
+func (s *legacyMigrationService) Migrate(ctx context.Context, opts *domain.LegacyMigrationOptions) (*domain.LegacyMigrationResult, error) {
+	if opts.StoreID <= 0 {
+		return nil, errors.New("store is required")
+	}
+	if opts.Product1_0LiterID <= 0 || opts.Product1_5LiterID <= 0 {
+		return nil, errors.New("products for both the 1.0L and 1.5L buckets are required")
+	}
+	if opts.Product1_0LiterID == opts.Product1_5LiterID {
+		return nil, errors.New("the 1.0L and 1.5L buckets must map to different products")
+	}
+	for _, productID := range []int64{opts.Product1_0LiterID, opts.Product1_5LiterID} {
+		ok, err := s.repo.ProductInStore(ctx, opts.StoreID, productID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("product %d not found in store %d", productID, opts.StoreID)
+		}
+	}
+
+	members, err := s.repo.ListMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.LegacyMigrationResult{
+		DryRun:  opts.DryRun,
+		Members: []domain.LegacyMemberResult{},
+	}
+	for i := range members {
+		member := &members[i]
+		memberResult, err := s.repo.MigrateMemberTx(ctx, opts, member)
+		if err != nil {
+			memberResult = &domain.LegacyMemberResult{
+				MemberID:         member.ID,
+				MembershipNumber: member.MembershipNumber,
+				Name:             member.Name,
+				Action:           domain.LegacyMemberFailed,
+				Error:            err.Error(),
+			}
+		}
+
+		switch memberResult.Action {
+		case domain.LegacyMemberCreated:
+			result.Created++
+		case domain.LegacyMemberLinked:
+			result.Linked++
+		case domain.LegacyMemberUpdated:
+			result.Updated++
+		case domain.LegacyMemberUnchanged:
+			result.Unchanged++
+		case domain.LegacyMemberFailed:
+			result.Failed++
+		}
+		for _, b := range memberResult.Buckets {
+			result.HistoryRows += b.HistoryRows
+		}
+		result.Members = append(result.Members, *memberResult)
+	}
+	return result, nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+)
+
+type MembershipTierService interface {
+	GetTiers(ctx context.Context, storeID int64) (*domain.MembershipTierListResponse, error)
+	CreateTier(ctx context.Context, storeID int64, req *domain.MembershipTierRequest) (*domain.MembershipTier, error)
+	UpdateTier(ctx context.Context, storeID, tierID int64, req *domain.MembershipTierRequest) (*domain.MembershipTier, error)
+	DeleteTier(ctx context.Context, storeID, tierID int64) error
+	GetTierHistory(ctx context.Context, storeID, customerID int64, limit, offset int) (*domain.TierHistoryResponse, error)
+	EvaluateTiers(ctx context.Context, storeID *int64) (*domain.EvaluateTiersResult, error)
+}
+
+type membershipTierService struct {
+	repo         repository.MembershipTierRepository
+	settingsRepo repository.StoreSettingsRepository
+}
+
+func NewMembershipTierService(repo repository.MembershipTierRepository, settingsRepo repository.StoreSettingsRepository) MembershipTierService {
+	return &membershipTierService{
+		repo:         repo,
+		settingsRepo: settingsRepo,
+	}
+}
+
+func (s *membershipTierService) GetTiers(ctx context.Context, storeID int64) (*domain.MembershipTierListResponse, error) {
+	tiers, err := s.repo.List(ctx, storeID, false)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.settingsRepo.GetByStore(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.MembershipTierListResponse{
+		Tiers:        tiers,
+		WindowMonths: settings.TierWindowMonths,
+	}, nil
+}
+
+func (s *membershipTierService) CreateTier(ctx context.Context, storeID int64, req *domain.MembershipTierRequest) (*domain.MembershipTier, error) {
+	if err := s.validateTier(ctx, storeID, 0, req); err != nil {
+		return nil, err
+	}
+	tierID, err := s.repo.Create(ctx, storeID, req)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, storeID, tierID)
+}
+
+func (s *membershipTierService) UpdateTier(ctx context.Context, storeID, tierID int64, req *domain.MembershipTierRequest) (*domain.MembershipTier, error) {
+	if err := s.validateTier(ctx, storeID, tierID, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, storeID, tierID, req); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, storeID, tierID)
+}
+
+func (s *membershipTierService) DeleteTier(ctx context.Context, storeID, tierID int64) error {
+	return s.repo.Delete(ctx, storeID, tierID)
+}
+
+// validateTier defaults the earn multiplier and checks the code and threshold are
+// not already used by another of the store's tiers
+func (s *membershipTierService) validateTier(ctx context.Context, storeID, tierID int64, req *domain.MembershipTierRequest) error {
+	req.TierCode = strings.ToUpper(strings.TrimSpace(req.TierCode))
+	req.TierName = strings.TrimSpace(req.TierName)
+	if req.TierCode == "" || req.TierName == "" {
+		return errors.New("tier_code and tier_name are required")
+	}
+	if req.EarnMultiplier == nil {
+		multiplier := 1.0
+		req.EarnMultiplier = &multiplier
+	}
+
+	tiers, err := s.repo.List(ctx, storeID, false)
+	if err != nil {
+		return err
+	}
+	for _, t := range tiers {
+		if t.ID == tierID {
+			continue
+		}
+		if t.TierCode == req.TierCode {
+			return errors.New("tier_code is already used by another tier")
+		}
+		if t.MinPoints == req.MinPoints {
+			return errors.New("min_points is already used by another tier")
+		}
+	}
+	return nil
+}
+
+// GetTierHistory returns the customer's current tier and their tier changes
+func (s *membershipTierService) GetTierHistory(ctx context.Context, storeID, customerID int64, limit, offset int) (*domain.TierHistoryResponse, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	tier, err := s.repo.GetCustomerTier(ctx, storeID, customerID)
+	if err != nil {
+		return nil, err
+	}
+	history, total, err := s.repo.GetTierHistory(ctx, storeID, customerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TierHistoryResponse{
+		CustomerID: customerID,
+		Tier:       tier,
+		History:    history,
+		Total:      total,
+	}, nil
+}
+
+// EvaluateTiers re-evaluates every customer's tier; storeID nil runs every store
+func (s *membershipTierService) EvaluateTiers(ctx context.Context, storeID *int64) (*domain.EvaluateTiersResult, error) {
+	return s.repo.EvaluateTiers(ctx, storeID)
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/mini-membership/api/internal/domain"
 	"github.com/mini-membership/api/internal/repository"
@@ -19,11 +21,19 @@ type OrderService interface {
 }
 
 type orderService struct {
-	repo repository.OrderRepository
+	repo         repository.OrderRepository
+	earnRuleRepo repository.EarnRuleRepository
+	tierRepo     repository.MembershipTierRepository
+	settingsRepo repository.StoreSettingsRepository
 }
 
-func NewOrderService(repo repository.OrderRepository) OrderService {
-	return &orderService{repo: repo}
+func NewOrderService(repo repository.OrderRepository, earnRuleRepo repository.EarnRuleRepository, tierRepo repository.MembershipTierRepository, settingsRepo repository.StoreSettingsRepository) OrderService {
+	return &orderService{
+		repo:         repo,
+		earnRuleRepo: earnRuleRepo,
+		tierRepo:     tierRepo,
+		settingsRepo: settingsRepo,
+	}
 }
 
 func (s *orderService) ListProducts(ctx context.Context, storeID, branchID int64) (*domain.ListProductsResponse, error) {
@@ -76,6 +86,14 @@ func (s *orderService) SearchCustomers(ctx context.Context, storeID int64, last4
 		if c.PhoneLast4.Valid {
 			result[i].PhoneLast4 = c.PhoneLast4.String
 		}
+		if c.TierID.Valid {
+			result[i].Tier = &domain.CustomerTier{
+				TierID:         c.TierID.Int64,
+				TierCode:       c.TierCode.String,
+				TierName:       c.TierName.String,
+				EarnMultiplier: c.EarnMultiplier.Float64,
+			}
+		}
 	}
 
 	return &domain.SearchCustomersResponse{Customers: result}, nil
@@ -86,7 +104,39 @@ func (s *orderService) CreateOrder(ctx context.Context, storeID, branchID, shift
 		return nil, errors.New("order must have at least one item")
 	}
 
-	if len(req.Payments) == 0 {
+	if req.CustomerID != nil && *req.CustomerID <= 0 {
+		req.CustomerID = nil
+	}
+
+	settings, err := s.settingsRepo.GetByStore(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wallet points spent on the order are worth the store's point value each
+	totalPrice := decimal.NewFromFloat(req.TotalPrice)
+	var walletAmount decimal.Decimal
+	if req.Wallet != nil {
+		if !settings.LoyaltyMode.UsesWallet() {
+			return nil, errors.New("points wallet is not enabled for this store")
+		}
+		if req.CustomerID == nil {
+			return nil, errors.New("customer_id is required to spend wallet points")
+		}
+		walletAmount = decimal.NewFromInt(int64(req.Wallet.Points)).Mul(decimal.NewFromFloat(*settings.WalletPointValue)).Round(2)
+		switch req.Wallet.RedeemAs {
+		case domain.WalletRedeemAsPayment:
+			if walletAmount.GreaterThan(totalPrice) {
+				return nil, errors.New("wallet payment is more than the order total")
+			}
+		case domain.WalletRedeemAsDiscount:
+			if walletAmount.GreaterThan(decimal.NewFromFloat(req.DiscountTotal)) {
+				return nil, errors.New("discount_total must include the wallet discount")
+			}
+		}
+	}
+
+	if len(req.Payments) == 0 && (req.Wallet == nil || req.Wallet.RedeemAs != domain.WalletRedeemAsPayment) {
 		return nil, errors.New("order must have at least one payment")
 	}
 
@@ -95,7 +145,9 @@ func (s *orderService) CreateOrder(ctx context.Context, storeID, branchID, shift
 	for _, p := range req.Payments {
 		totalPayment = totalPayment.Add(decimal.NewFromFloat(p.Amount))
 	}
-	totalPrice := decimal.NewFromFloat(req.TotalPrice)
+	if req.Wallet != nil && req.Wallet.RedeemAs == domain.WalletRedeemAsPayment {
+		totalPayment = totalPayment.Add(walletAmount)
+	}
 	if totalPayment.LessThan(totalPrice) {
 		return nil, errors.New("payment amount is less than total price")
 	}
@@ -117,6 +169,55 @@ func (s *orderService) CreateOrder(ctx context.Context, storeID, branchID, shift
 			Amount: decimal.NewFromFloat(p.Amount),
 		}
 	}
+	if req.Wallet != nil && req.Wallet.RedeemAs == domain.WalletRedeemAsPayment {
+		payments = append(payments, repository.PaymentCreate{Method: "WALLET", Amount: walletAmount})
+	}
+
+	// Points are worked out up front and written in the order's transaction
+	var earned *domain.EarnPreviewResponse
+	var points []repository.OrderPointsCreate
+	var wallet *repository.OrderWalletCreate
+	if req.CustomerID != nil {
+		// The tier in force before this order sets its earn multiplier
+		tier, err := s.tierRepo.GetCustomerTier(ctx, storeID, *req.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		if settings.LoyaltyMode.EarnsStamps() {
+			earned, err = calculateEarnPoints(ctx, s.earnRuleRepo, storeID, req.Items, req.PromotionID, tier, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			index := make(map[int64]int)
+			for _, line := range earned.Items {
+				if line.Points <= 0 {
+					continue
+				}
+				if i, ok := index[line.ProductID]; ok {
+					points[i].Points += line.Points
+					continue
+				}
+				index[line.ProductID] = len(points)
+				points = append(points, repository.OrderPointsCreate{ProductID: line.ProductID, Points: line.Points})
+			}
+		} else {
+			earned = &domain.EarnPreviewResponse{Tier: tier}
+		}
+
+		if settings.LoyaltyMode.UsesWallet() {
+			wallet = &repository.OrderWalletCreate{
+				EarnPoints: walletEarnPoints(totalPrice, walletAmount, req.Wallet, settings, tier),
+			}
+			if req.Wallet != nil {
+				wallet.RedeemPoints = req.Wallet.Points
+				wallet.RedeemAmount = walletAmount
+				wallet.RedeemedAs = string(req.Wallet.RedeemAs)
+			}
+			if wallet.EarnPoints == 0 && wallet.RedeemPoints == 0 {
+				wallet = nil
+			}
+		}
+	}
 
 	order := &repository.OrderCreate{
 		StoreID:       storeID,
@@ -131,22 +232,94 @@ func (s *orderService) CreateOrder(ctx context.Context, storeID, branchID, shift
 		ChangeAmount:  decimal.NewFromFloat(req.ChangeAmount),
 		Payments:      payments,
 		PromotionID:   req.PromotionID,
+		Points:        points,
+		Wallet:        wallet,
 	}
 
 	result, err := s.repo.CreateOrderTx(ctx, order)
+	if errors.Is(err, repository.ErrInsufficientPoints) {
+		return nil, fmt.Errorf("แต้มในกระเป๋าไม่เพียงพอ: ไม่สามารถใช้ %d แต้มได้", req.Wallet.Points)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	var warnings []domain.OrderWarning
+	for _, w := range result.StockWarnings {
+		warnings = append(warnings, domain.OrderWarning{
+			Code:      domain.OrderWarningNegativeStock,
+			Message:   fmt.Sprintf("%s sold %d with %d on stock; stock is now %d", w.ProductName, w.Quantity, w.FromStock, w.ToStock),
+			ProductID: w.ProductID,
+			OnStock:   w.ToStock,
+		})
+	}
+
+	var pointsEarned *domain.OrderPointsEarned
+	if req.CustomerID != nil {
+		names := make(map[int64]string, len(earned.Items))
+		for _, line := range earned.Items {
+			names[line.ProductID] = line.ProductName
+		}
+		pointsEarned = &domain.OrderPointsEarned{
+			Items:       []domain.OrderPointsItem{},
+			Tier:        earned.Tier,
+			TierChanged: result.TierChanged,
+		}
+		if len(result.Points) > 0 || (result.Wallet != nil && result.Wallet.PointsEarned > 0) {
+			pointsEarned.Tier = result.Tier
+		}
+		for _, p := range result.Points {
+			pointsEarned.PointsEarned += p.Points
+			pointsEarned.Items = append(pointsEarned.Items, domain.OrderPointsItem{
+				ProductID:    p.ProductID,
+				ProductName:  names[p.ProductID],
+				PointsEarned: p.Points,
+				Balance:      p.Balance,
+			})
+		}
+		if result.Wallet != nil {
+			amount, _ := walletAmount.Float64()
+			pointsEarned.Wallet = &domain.OrderWalletResult{
+				PointsRedeemed: result.Wallet.PointsRedeemed,
+				AmountRedeemed: amount,
+				PointsEarned:   result.Wallet.PointsEarned,
+				Balance:        result.Wallet.Balance,
+			}
+			if req.Wallet != nil {
+				pointsEarned.Wallet.RedeemedAs = req.Wallet.RedeemAs
+			}
+		}
+	}
+
 	return &domain.CreateOrderResponse{
 		OrderID:      result.OrderID,
 		Status:       result.Status,
 		TotalPrice:   req.TotalPrice,
 		ChangeAmount: req.ChangeAmount,
+		Warnings:     warnings,
+		Points:       pointsEarned,
 		CreatedAt:    result.CreatedAt,
 	}, nil
 }
 
+// walletEarnPoints is the wallet points an order earns: one per
+// wallet_baht_per_point of the total not paid with wallet points, times the
+// customer's tier multiplier, rounded down
+func walletEarnPoints(totalPrice, walletAmount decimal.Decimal, req *domain.OrderWalletRequest, settings *domain.StoreSettings, tier *domain.CustomerTier) int {
+	spend := totalPrice
+	if req != nil && req.RedeemAs == domain.WalletRedeemAsPayment {
+		spend = spend.Sub(walletAmount)
+	}
+	if !spend.IsPositive() {
+		return 0
+	}
+	points := spend.Div(decimal.NewFromFloat(*settings.WalletBahtPerPoint))
+	if tier != nil {
+		points = points.Mul(decimal.NewFromFloat(tier.EarnMultiplier))
+	}
+	return int(points.Floor().IntPart())
+}
+
 func (s *orderService) GetOrdersByShift(ctx context.Context, storeID, branchID, shiftID int64) (*domain.ListOrdersResponse, error) {
 	orders, err := s.repo.GetOrdersByShift(ctx, storeID, branchID, shiftID)
 	if err != nil {
@@ -247,6 +420,9 @@ func (s *orderService) GetOrderByID(ctx context.Context, storeID, orderID int64)
 
 func (s *orderService) CancelOrder(ctx context.Context, storeID, orderID int64, reason string, cancelledBy *int64) (*domain.CancelOrderResponse, error) {
 	err := s.repo.CancelOrder(ctx, storeID, orderID, reason, cancelledBy)
+	if errors.Is(err, repository.ErrInsufficientPoints) {
+		return nil, errors.New("ไม่สามารถยกเลิกได้: ลูกค้าใช้แต้มที่ได้จากออเดอร์นี้ไปแล้ว")
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/mini-membership/api/internal/domain"
 	"github.com/mini-membership/api/internal/repository"
@@ -12,20 +15,29 @@ import (
 type PointsService interface {
 	GetCustomerPoints(ctx context.Context, storeID, customerID int64, customerName, customerCode string) (*domain.GetCustomerPointsResponse, error)
 	GetRedeemableProducts(ctx context.Context, storeID, branchID int64) (*domain.ListRedeemableProductsResponse, error)
-	RedeemPoints(ctx context.Context, storeID, branchID int64, req *domain.RedeemPointsRequest, staffID *int64) (*domain.RedeemPointsResponse, error)
-	EarnPointsFromOrder(ctx context.Context, storeID, branchID int64, customerID int64, orderID int64, items []domain.OrderItemForPoints, staffID *int64) (*domain.EarnPointsResponse, error)
+	RedeemPoints(ctx context.Context, storeID, branchID, shiftID int64, req *domain.RedeemPointsRequest, staffID *int64) (*domain.RedeemPointsResponse, error)
+	ReverseRedemption(ctx context.Context, storeID, redemptionID, staffID, approvedBy int64, req *domain.ReverseRedemptionRequest) (*domain.ReverseRedemptionResponse, error)
+	AdjustPoints(ctx context.Context, storeID, branchID, staffID int64, req *domain.AdjustPointsRequest) (*domain.AdjustPointsResponse, error)
+	GetAdjustments(ctx context.Context, storeID int64, filter *domain.PointAdjustmentFilter, limit, offset int) (*domain.PointAdjustmentReportResponse, error)
 	GetPointHistory(ctx context.Context, storeID, customerID int64, page, limit int) (*domain.GetPointHistoryResponse, error)
+	GetExpiringPoints(ctx context.Context, storeID int64, customerID *int64, days, limit, offset int) (*domain.ExpiringPointsResponse, error)
+	ExpirePoints(ctx context.Context, storeID *int64) (*domain.ExpirePointsResult, error)
+	GetWallet(ctx context.Context, storeID, customerID int64, page, limit int) (*domain.GetWalletResponse, error)
 }
 
 type pointsService struct {
-	pointsRepo repository.PointsRepository
-	orderRepo  repository.OrderRepository
+	pointsRepo   repository.PointsRepository
+	orderRepo    repository.OrderRepository
+	tierRepo     repository.MembershipTierRepository
+	settingsRepo repository.StoreSettingsRepository
 }
 
-func NewPointsService(pointsRepo repository.PointsRepository, orderRepo repository.OrderRepository) PointsService {
+func NewPointsService(pointsRepo repository.PointsRepository, orderRepo repository.OrderRepository, tierRepo repository.MembershipTierRepository, settingsRepo repository.StoreSettingsRepository) PointsService {
 	return &pointsService{
-		pointsRepo: pointsRepo,
-		orderRepo:  orderRepo,
+		pointsRepo:   pointsRepo,
+		orderRepo:    orderRepo,
+		tierRepo:     tierRepo,
+		settingsRepo: settingsRepo,
 	}
 }
 
@@ -35,161 +47,175 @@ func (s *pointsService) GetCustomerPoints(ctx context.Context, storeID, customer
 		return nil, err
 	}
 
-	return &domain.GetCustomerPointsResponse{
+	tier, err := s.tierRepo.GetCustomerTier(ctx, storeID, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &domain.GetCustomerPointsResponse{
 		CustomerID:   customerID,
 		CustomerName: customerName,
 		CustomerCode: customerCode,
+		Tier:         tier,
 		Products:     products,
-	}, nil
-}
+	}
 
-func (s *pointsService) GetRedeemableProducts(ctx context.Context, storeID, branchID int64) (*domain.ListRedeemableProductsResponse, error) {
-	products, err := s.pointsRepo.GetRedeemableProducts(ctx, storeID, branchID)
+	settings, err := s.settingsRepo.GetByStore(ctx, storeID)
 	if err != nil {
 		return nil, err
 	}
-
-	return &domain.ListRedeemableProductsResponse{
-		Products: products,
-	}, nil
+	if settings.LoyaltyMode.UsesWallet() {
+		resp.Wallet, err = s.getWalletBalance(ctx, storeID, customerID, settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
 }
 
-func (s *pointsService) RedeemPoints(ctx context.Context, storeID, branchID int64, req *domain.RedeemPointsRequest, staffID *int64) (*domain.RedeemPointsResponse, error) {
-	// Get product points requirement
-	pointsRequired, err := s.pointsRepo.GetProductPointsToRedeem(ctx, req.ProductID)
+// GetWallet returns the customer's wallet balance and its ledger, newest first
+func (s *pointsService) GetWallet(ctx context.Context, storeID, customerID int64, page, limit int) (*domain.GetWalletResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	settings, err := s.settingsRepo.GetByStore(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	wallet, err := s.getWalletBalance(ctx, storeID, customerID, settings)
+	if err != nil {
+		return nil, err
+	}
+	history, total, err := s.pointsRepo.GetWalletHistory(ctx, storeID, customerID, limit, (page-1)*limit)
 	if err != nil {
 		return nil, err
 	}
+	if history == nil {
+		history = []domain.WalletTransaction{}
+	}
 
-	totalPointsNeeded := *pointsRequired * req.Quantity
+	return &domain.GetWalletResponse{
+		CustomerID: customerID,
+		Wallet:     *wallet,
+		History:    history,
+		Total:      total,
+	}, nil
+}
 
-	// Check customer has enough points for THIS PRODUCT
-	productPoints, err := s.pointsRepo.GetProductPoints(ctx, storeID, req.CustomerID, req.ProductID)
+// getWalletBalance values the customer's wallet at the store's point value
+func (s *pointsService) getWalletBalance(ctx context.Context, storeID, customerID int64, settings *domain.StoreSettings) (*domain.WalletBalance, error) {
+	wallet, err := s.pointsRepo.GetWallet(ctx, storeID, customerID)
 	if err != nil {
 		return nil, err
 	}
-	if productPoints == nil || productPoints.Points < totalPointsNeeded {
-		currentPoints := 0
-		if productPoints != nil {
-			currentPoints = productPoints.Points
-		}
-		return nil, fmt.Errorf("แต้มไม่เพียงพอ: ต้องการ %d แต้ม, มี %d แต้ม", totalPointsNeeded, currentPoints)
+	if settings.WalletPointValue != nil {
+		wallet.Value = math.Round(float64(wallet.Balance)**settings.WalletPointValue*100) / 100
 	}
+	return wallet, nil
+}
 
-	// Deduct points for this product
-	err = s.pointsRepo.DeductProductPoints(ctx, storeID, req.CustomerID, req.ProductID, totalPointsNeeded)
+func (s *pointsService) GetRedeemableProducts(ctx context.Context, storeID, branchID int64) (*domain.ListRedeemableProductsResponse, error) {
+	products, err := s.pointsRepo.GetRedeemableProducts(ctx, storeID, branchID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create redemption record
-	redemption := &domain.PointRedemption{
+	return &domain.ListRedeemableProductsResponse{
+		Products: products,
+	}, nil
+}
+
+func (s *pointsService) RedeemPoints(ctx context.Context, storeID, branchID, shiftID int64, req *domain.RedeemPointsRequest, staffID *int64) (*domain.RedeemPointsResponse, error) {
+	result, err := s.pointsRepo.RedeemTx(ctx, &domain.PointRedemption{
 		StoreID:    storeID,
 		BranchID:   branchID,
 		CustomerID: req.CustomerID,
 		ProductID:  req.ProductID,
-		PointsUsed: totalPointsNeeded,
 		Quantity:   req.Quantity,
+		ShiftID:    &shiftID,
 		StaffID:    staffID,
-	}
-	redemptionID, err := s.pointsRepo.CreateRedemption(ctx, redemption)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Create point transaction record
-	refTable := "point_redemptions"
-	pointChange := -totalPointsNeeded
-	ptx := &domain.PointTransaction{
-		StoreID:         storeID,
-		BranchID:        branchID,
-		CustomerID:      req.CustomerID,
-		TransactionType: "REDEEM",
-		PointsChange:    pointChange,
-		ReferenceTable:  &refTable,
-		ReferenceID:     &redemptionID,
-		ProductID:       &req.ProductID,
-		StaffID:         staffID,
-	}
-	err = s.pointsRepo.CreatePointTransaction(ctx, ptx)
+	return &domain.RedeemPointsResponse{
+		RedemptionID:    result.RedemptionID,
+		PointsUsed:      result.PointsUsed,
+		RemainingPoints: result.RemainingPoints,
+		ProductName:     result.ProductName,
+		Quantity:        req.Quantity,
+		RemainingStock:  result.RemainingStock,
+		Message:         fmt.Sprintf("แลกสำเร็จ: %s x%d ใช้ %d แต้ม", result.ProductName, req.Quantity, result.PointsUsed),
+	}, nil
+}
+
+// ReverseRedemption undoes a redemption approved by the manager approvedBy
+func (s *pointsService) ReverseRedemption(ctx context.Context, storeID, redemptionID, staffID, approvedBy int64, req *domain.ReverseRedemptionRequest) (*domain.ReverseRedemptionResponse, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.New("reason is required")
+	}
+
+	resp, err := s.pointsRepo.ReverseRedemptionTx(ctx, storeID, redemptionID, staffID, approvedBy, reason)
 	if err != nil {
-		fmt.Printf("Failed to create point transaction: %v\n", err)
+		return nil, err
 	}
+	resp.Message = fmt.Sprintf("ยกเลิกการแลกสำเร็จ: คืน %d แต้ม", resp.PointsRestored)
+	return resp, nil
+}
 
-	// Get product name for response
-	products, _ := s.pointsRepo.GetRedeemableProducts(ctx, storeID, branchID)
-	productName := "Unknown Product"
-	for _, p := range products {
-		if p.ID == req.ProductID {
-			productName = p.ProductName
-			break
-		}
+// AdjustPoints grants or removes points by hand; the reason code and note are
+// kept on the ADJUST transaction for the adjustment report
+func (s *pointsService) AdjustPoints(ctx context.Context, storeID, branchID, staffID int64, req *domain.AdjustPointsRequest) (*domain.AdjustPointsResponse, error) {
+	req.Note = strings.TrimSpace(req.Note)
+	if req.Note == "" {
+		return nil, errors.New("note is required")
+	}
+	if req.Points == 0 {
+		return nil, errors.New("points must not be zero")
 	}
 
-	// Get updated points for this product
-	updatedPoints, _ := s.pointsRepo.GetProductPoints(ctx, storeID, req.CustomerID, req.ProductID)
-	remainingPoints := 0
-	if updatedPoints != nil {
-		remainingPoints = updatedPoints.Points
+	balance, err := s.pointsRepo.AdjustPointsTx(ctx, storeID, branchID, staffID, req)
+	if errors.Is(err, repository.ErrInsufficientPoints) {
+		return nil, fmt.Errorf("แต้มไม่เพียงพอ: ไม่สามารถหัก %d แต้มได้", -req.Points)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return &domain.RedeemPointsResponse{
-		RedemptionID:    redemptionID,
-		PointsUsed:      totalPointsNeeded,
-		RemainingPoints: remainingPoints,
-		ProductName:     productName,
-		Quantity:        req.Quantity,
-		Message:         fmt.Sprintf("แลกสำเร็จ: %s x%d ใช้ %d แต้ม", productName, req.Quantity, totalPointsNeeded),
+	message := fmt.Sprintf("เพิ่ม %d แต้มสำเร็จ", req.Points)
+	if req.Points < 0 {
+		message = fmt.Sprintf("หัก %d แต้มสำเร็จ", -req.Points)
+	}
+	return &domain.AdjustPointsResponse{
+		CustomerID:   req.CustomerID,
+		ProductID:    req.ProductID,
+		PointsChange: req.Points,
+		Balance:      balance,
+		Message:      message,
 	}, nil
 }
 
-func (s *pointsService) EarnPointsFromOrder(ctx context.Context, storeID, branchID int64, customerID int64, orderID int64, items []domain.OrderItemForPoints, staffID *int64) (*domain.EarnPointsResponse, error) {
-	if customerID <= 0 {
-		return nil, errors.New("customer ID is required for earning points")
+// GetAdjustments returns the manual adjustment report
+func (s *pointsService) GetAdjustments(ctx context.Context, storeID int64, filter *domain.PointAdjustmentFilter, limit, offset int) (*domain.PointAdjustmentReportResponse, error) {
+	if limit <= 0 {
+		limit = 20
 	}
-	if len(items) == 0 {
-		return nil, errors.New("no items to earn points from")
+	if limit > 100 {
+		limit = 100
 	}
-
-	totalPointsEarned := 0
-	refTable := "orders"
-
-	// Add points for each product purchased
-	for _, item := range items {
-		// Each item = 1 point for that product
-		pointsToEarn := item.Quantity
-		totalPointsEarned += pointsToEarn
-
-		// Add points for this specific product
-		err := s.pointsRepo.CreateOrUpdateProductPoints(ctx, storeID, customerID, item.ProductID, pointsToEarn)
-		if err != nil {
-			fmt.Printf("Failed to add points for product %d: %v\n", item.ProductID, err)
-			continue
-		}
-
-		// Create point transaction record for each product
-		ptx := &domain.PointTransaction{
-			StoreID:         storeID,
-			BranchID:        branchID,
-			CustomerID:      customerID,
-			TransactionType: "EARN",
-			PointsChange:    pointsToEarn,
-			ReferenceTable:  &refTable,
-			ReferenceID:     &orderID,
-			ProductID:       &item.ProductID,
-			StaffID:         staffID,
-		}
-		err = s.pointsRepo.CreatePointTransaction(ctx, ptx)
-		if err != nil {
-			fmt.Printf("Failed to create point transaction for product %d: %v\n", item.ProductID, err)
-		}
+	if offset < 0 {
+		offset = 0
 	}
-
-	return &domain.EarnPointsResponse{
-		PointsEarned:    totalPointsEarned,
-		TotalPoints:     totalPointsEarned,
-		RemainingPoints: totalPointsEarned,
-	}, nil
+	return s.pointsRepo.GetAdjustments(ctx, storeID, filter, limit, offset)
 }
 
 func (s *pointsService) GetPointHistory(ctx context.Context, storeID, customerID int64, page, limit int) (*domain.GetPointHistoryResponse, error) {
@@ -205,3 +231,38 @@ func (s *pointsService) GetPointHistory(ctx context.Context, storeID, customerID
 		Total:      total,
 	}, nil
 }
+
+// GetExpiringPoints lists points that expire within the next days days, including
+// any already past expiry that the expiry job has not processed yet
+func (s *pointsService) GetExpiringPoints(ctx context.Context, storeID int64, customerID *int64, days, limit, offset int) (*domain.ExpiringPointsResponse, error) {
+	if days <= 0 {
+		days = domain.DefaultExpiringPointsDays
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	before := time.Now().AddDate(0, 0, days)
+	items, total, err := s.pointsRepo.GetExpiringPoints(ctx, storeID, customerID, before, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.ExpiringPointsResponse{
+		Days:  days,
+		Items: items,
+		Total: total,
+	}, nil
+}
+
+// ExpirePoints writes EXPIRE transactions for every balance past its store's expiry
+// policy; storeID nil runs every store
+func (s *pointsService) ExpirePoints(ctx context.Context, storeID *int64) (*domain.ExpirePointsResult, error) {
+	return s.pointsRepo.ExpireDuePoints(ctx, storeID, time.Now())
+}
@@ -9,7 +9,7 @@ import (
 )
 
 type PromotionService interface {
-	GetActivePromotions(ctx context.Context, storeID, branchID int64) ([]domain.PromotionResponse, error)
+	GetActivePromotions(ctx context.Context, storeID, branchID int64, customerID *int64) ([]domain.PromotionResponse, error)
 	CalculateDiscount(ctx context.Context, storeID int64, req *domain.CalculateDiscountRequest) (*domain.CalculateDiscountResponse, error)
 	DetectApplicablePromotions(ctx context.Context, storeID, branchID int64, req *domain.DetectPromotionsRequest) ([]domain.DetectedPromotion, error)
 }
@@ -22,8 +22,8 @@ func NewPromotionService(repo repository.PromotionRepository) PromotionService {
 	return &promotionService{repo: repo}
 }
 
-func (s *promotionService) GetActivePromotions(ctx context.Context, storeID, branchID int64) ([]domain.PromotionResponse, error) {
-	return s.repo.GetActivePromotions(ctx, storeID, branchID)
+func (s *promotionService) GetActivePromotions(ctx context.Context, storeID, branchID int64, customerID *int64) ([]domain.PromotionResponse, error) {
+	return s.repo.GetActivePromotions(ctx, storeID, branchID, customerID)
 }
 
 func (s *promotionService) CalculateDiscount(ctx context.Context, storeID int64, req *domain.CalculateDiscountRequest) (*domain.CalculateDiscountResponse, error) {
@@ -50,6 +50,19 @@ func (s *promotionService) CalculateDiscount(ctx context.Context, storeID int64,
 		IsApplicable:  true,
 	}
 
+	if promo.TierCode != nil {
+		open, err := s.repo.IsOpenToCustomer(ctx, storeID, promo.ID, req.CustomerID)
+		if err != nil {
+			return nil, err
+		}
+		if !open {
+			response.IsApplicable = false
+			response.FinalTotal = subtotal
+			response.Message = "promotion is only for " + *promo.TierCode + " members and above"
+			return response, nil
+		}
+	}
+
 	// Calculate discount based on promotion type
 	discountAmount := s.calculateDiscountAmount(promo, req.Items, subtotal)
 
@@ -185,7 +198,7 @@ func (s *promotionService) getMatchingProductsTotal(promo *domain.PromotionRespo
 }
 
 func (s *promotionService) DetectApplicablePromotions(ctx context.Context, storeID, branchID int64, req *domain.DetectPromotionsRequest) ([]domain.DetectedPromotion, error) {
-	promotions, err := s.repo.GetActivePromotions(ctx, storeID, branchID)
+	promotions, err := s.repo.GetActivePromotions(ctx, storeID, branchID, req.CustomerID)
 	if err != nil {
 		return nil, err
 	}
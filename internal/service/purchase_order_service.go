@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+)
+
+type PurchaseOrderService interface {
+	CreateSupplier(ctx context.Context, storeID int64, req *domain.CreateSupplierRequest) (*domain.Supplier, error)
+	UpdateSupplier(ctx context.Context, storeID, supplierID int64, req *domain.UpdateSupplierRequest) (*domain.Supplier, error)
+	GetSuppliers(ctx context.Context, storeID int64) ([]domain.Supplier, error)
+	CreatePurchaseOrder(ctx context.Context, storeID, branchID, staffID int64, req *domain.CreatePurchaseOrderRequest) (*domain.PurchaseOrderResponse, error)
+	GetPurchaseOrder(ctx context.Context, storeID, purchaseOrderID int64) (*domain.PurchaseOrderResponse, error)
+	GetPurchaseOrders(ctx context.Context, storeID int64, branchID *int64, status domain.PurchaseOrderStatus, limit, offset int) (*domain.PurchaseOrderListResponse, error)
+	ReceiveGoods(ctx context.Context, storeID, branchID, purchaseOrderID, staffID int64, req *domain.ReceiveGoodsRequest) (*domain.PurchaseOrderResponse, error)
+	GetGoodsReceivedNotes(ctx context.Context, storeID, purchaseOrderID int64) ([]domain.GoodsReceivedNoteResponse, error)
+	ClosePurchaseOrder(ctx context.Context, storeID, purchaseOrderID int64) error
+	CancelPurchaseOrder(ctx context.Context, storeID, purchaseOrderID int64) error
+}
+
+type purchaseOrderService struct {
+	repo repository.PurchaseOrderRepository
+}
+
+func NewPurchaseOrderService(repo repository.PurchaseOrderRepository) PurchaseOrderService {
+	return &purchaseOrderService{repo: repo}
+}
+
+func (s *purchaseOrderService) CreateSupplier(ctx context.Context, storeID int64, req *domain.CreateSupplierRequest) (*domain.Supplier, error) {
+	return s.repo.CreateSupplier(ctx, storeID, req)
+}
+
+func (s *purchaseOrderService) UpdateSupplier(ctx context.Context, storeID, supplierID int64, req *domain.UpdateSupplierRequest) (*domain.Supplier, error) {
+	supplier, err := s.repo.GetSupplierByID(ctx, storeID, supplierID)
+	if err != nil {
+		return nil, err
+	}
+	if supplier == nil {
+		return nil, errors.New("supplier not found")
+	}
+
+	if err := s.repo.UpdateSupplier(ctx, storeID, supplierID, req); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetSupplierByID(ctx, storeID, supplierID)
+}
+
+func (s *purchaseOrderService) GetSuppliers(ctx context.Context, storeID int64) ([]domain.Supplier, error) {
+	return s.repo.GetSuppliers(ctx, storeID)
+}
+
+func (s *purchaseOrderService) CreatePurchaseOrder(ctx context.Context, storeID, branchID, staffID int64, req *domain.CreatePurchaseOrderRequest) (*domain.PurchaseOrderResponse, error) {
+	if len(req.Items) == 0 {
+		return nil, errors.New("at least one item is required")
+	}
+
+	supplier, err := s.repo.GetSupplierByID(ctx, storeID, req.SupplierID)
+	if err != nil {
+		return nil, err
+	}
+	if supplier == nil || !supplier.IsActive {
+		return nil, errors.New("supplier not found")
+	}
+
+	seen := make(map[int64]bool, len(req.Items))
+	for _, item := range req.Items {
+		if seen[item.ProductID] {
+			return nil, errors.New("each product can only appear once per purchase order")
+		}
+		seen[item.ProductID] = true
+	}
+
+	// Deliver to the current branch if not specified
+	if req.BranchID != nil {
+		branchID = *req.BranchID
+	}
+
+	purchaseOrderID, err := s.repo.Create(ctx, storeID, branchID, staffID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, storeID, purchaseOrderID)
+}
+
+func (s *purchaseOrderService) GetPurchaseOrder(ctx context.Context, storeID, purchaseOrderID int64) (*domain.PurchaseOrderResponse, error) {
+	return s.repo.GetByID(ctx, storeID, purchaseOrderID)
+}
+
+func (s *purchaseOrderService) GetPurchaseOrders(ctx context.Context, storeID int64, branchID *int64, status domain.PurchaseOrderStatus, limit, offset int) (*domain.PurchaseOrderListResponse, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.List(ctx, storeID, branchID, status, limit, offset)
+}
+
+func (s *purchaseOrderService) ReceiveGoods(ctx context.Context, storeID, branchID, purchaseOrderID, staffID int64, req *domain.ReceiveGoodsRequest) (*domain.PurchaseOrderResponse, error) {
+	po, err := s.repo.GetByID(ctx, storeID, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+	if po == nil {
+		return nil, errors.New("purchase order not found")
+	}
+
+	// Goods are received by the branch the purchase order delivers to
+	if po.BranchID != branchID {
+		return nil, errors.New("purchase order is not addressed to this branch")
+	}
+
+	if po.Status != domain.PurchaseOrderStatusOpen && po.Status != domain.PurchaseOrderStatusPartiallyReceived {
+		return nil, errors.New("purchase order is not open for receiving")
+	}
+
+	for _, item := range req.Items {
+		if item.UnitCost != nil && item.UnitCost.IsNegative() {
+			return nil, fmt.Errorf("product %d: unit cost must not be negative", item.ProductID)
+		}
+	}
+
+	if _, err := s.repo.ReceiveGoods(ctx, storeID, purchaseOrderID, staffID, req); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, storeID, purchaseOrderID)
+}
+
+func (s *purchaseOrderService) GetGoodsReceivedNotes(ctx context.Context, storeID, purchaseOrderID int64) ([]domain.GoodsReceivedNoteResponse, error) {
+	return s.repo.GetGoodsReceivedNotes(ctx, storeID, purchaseOrderID)
+}
+
+// ClosePurchaseOrder closes a purchase order short; outstanding quantities will not be delivered
+func (s *purchaseOrderService) ClosePurchaseOrder(ctx context.Context, storeID, purchaseOrderID int64) error {
+	po, err := s.repo.GetByID(ctx, storeID, purchaseOrderID)
+	if err != nil {
+		return err
+	}
+	if po == nil {
+		return errors.New("purchase order not found")
+	}
+
+	if po.Status != domain.PurchaseOrderStatusOpen && po.Status != domain.PurchaseOrderStatusPartiallyReceived {
+		return errors.New("purchase order is already closed")
+	}
+
+	return s.repo.UpdateStatus(ctx, storeID, purchaseOrderID, domain.PurchaseOrderStatusClosed)
+}
+
+func (s *purchaseOrderService) CancelPurchaseOrder(ctx context.Context, storeID, purchaseOrderID int64) error {
+	po, err := s.repo.GetByID(ctx, storeID, purchaseOrderID)
+	if err != nil {
+		return err
+	}
+	if po == nil {
+		return errors.New("purchase order not found")
+	}
+
+	if po.Status != domain.PurchaseOrderStatusOpen {
+		return errors.New("only open purchase orders with nothing received can be cancelled")
+	}
+
+	return s.repo.UpdateStatus(ctx, storeID, purchaseOrderID, domain.PurchaseOrderStatusCancelled)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+)
+
+type StockReconciliationService interface {
+	Reconcile(ctx context.Context, storeID, branchID *int64, apply bool, changedBy *int64) (*domain.StockReconciliationResponse, error)
+}
+
+type stockReconciliationService struct {
+	repo repository.InventoryRepository
+}
+
+func NewStockReconciliationService(repo repository.InventoryRepository) StockReconciliationService {
+	return &stockReconciliationService{repo: repo}
+}
+
+// Reconcile reports branch products whose on_stock has drifted from the movement
+// ledger. With apply, each drift is booked as a corrective ADJUST movement.
+// A nil storeID or branchID widens the run to every store or branch.
+func (s *stockReconciliationService) Reconcile(ctx context.Context, storeID, branchID *int64, apply bool, changedBy *int64) (*domain.StockReconciliationResponse, error) {
+	items, err := s.repo.GetStockDrift(ctx, storeID, branchID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &domain.StockReconciliationResponse{
+		StoreID:    storeID,
+		BranchID:   branchID,
+		Applied:    apply,
+		DriftCount: len(items),
+		Items:      items,
+		RunAt:      time.Now(),
+	}
+
+	if !apply {
+		return resp, nil
+	}
+
+	for i := range resp.Items {
+		item := &resp.Items[i]
+		corrected, err := s.repo.CorrectStockDrift(ctx, item.StoreID, item.BranchID, item.ProductID, changedBy)
+		if err != nil {
+			return nil, err
+		}
+		if corrected != 0 {
+			item.Corrected = true
+			resp.CorrectedCount++
+		}
+	}
+
+	return resp, nil
+}
@@ -14,16 +14,22 @@ type StockTransferService interface {
 	GetTransferByID(ctx context.Context, storeID, transferID int64) (*domain.StockTransferResponse, error)
 	GetTransfersByBranch(ctx context.Context, storeID, branchID int64, limit, offset int) (*domain.StockTransferListResponse, error)
 	GetPendingTransfers(ctx context.Context, storeID, branchID int64) ([]domain.StockTransferResponse, error)
-	ReceiveTransfer(ctx context.Context, storeID, branchID, transferID, staffID int64, items []domain.UpdateStockTransferItemInput) error
+	GetOutgoingTransfers(ctx context.Context, storeID, branchID int64) ([]domain.StockTransferResponse, error)
+	DispatchTransfer(ctx context.Context, storeID, branchID, transferID, staffID int64, req *domain.DispatchStockTransferRequest) (*domain.StockTransferResponse, error)
+	ReceiveTransfer(ctx context.Context, storeID, branchID, transferID, staffID int64, req *domain.UpdateStockTransferRequest) (*domain.StockTransferResponse, error)
+	GetReceipts(ctx context.Context, storeID, transferID int64) ([]domain.StockTransferReceiptResponse, error)
+	GetDiscrepancies(ctx context.Context, storeID, branchID int64, status domain.DiscrepancyStatus) ([]domain.StockTransferDiscrepancy, error)
+	ResolveDiscrepancy(ctx context.Context, storeID, branchID, discrepancyID, staffID int64, req *domain.ResolveDiscrepancyRequest) (*domain.StockTransferDiscrepancy, error)
 	CancelTransfer(ctx context.Context, storeID, transferID int64) error
 }
 
 type stockTransferService struct {
-	repo repository.StockTransferRepository
+	repo          repository.StockTransferRepository
+	warehouseRepo repository.WarehouseRepository
 }
 
-func NewStockTransferService(repo repository.StockTransferRepository) StockTransferService {
-	return &stockTransferService{repo: repo}
+func NewStockTransferService(repo repository.StockTransferRepository, warehouseRepo repository.WarehouseRepository) StockTransferService {
+	return &stockTransferService{repo: repo, warehouseRepo: warehouseRepo}
 }
 
 func (s *stockTransferService) CreateTransfer(ctx context.Context, storeID, branchID, staffID int64, req *domain.CreateStockTransferRequest) (*domain.StockTransferResponse, error) {
@@ -31,8 +37,20 @@ func (s *stockTransferService) CreateTransfer(ctx context.Context, storeID, bran
 		return nil, errors.New("at least one item is required")
 	}
 
-	// Set from_branch_id to current branch if not specified
-	if req.FromBranchID == nil {
+	if req.FromBranchID != nil && req.FromWarehouseID != nil {
+		return nil, errors.New("transfer can only have one source")
+	}
+
+	if req.FromWarehouseID != nil {
+		warehouse, err := s.warehouseRepo.GetByID(ctx, storeID, *req.FromWarehouseID)
+		if err != nil {
+			return nil, err
+		}
+		if warehouse == nil || !warehouse.IsActive {
+			return nil, errors.New("warehouse not found")
+		}
+	} else if req.FromBranchID == nil {
+		// Set from_branch_id to current branch if not specified
 		req.FromBranchID = &branchID
 	}
 
@@ -49,14 +67,30 @@ func (s *stockTransferService) WithdrawGoods(ctx context.Context, storeID, branc
 		return nil, errors.New("at least one item is required")
 	}
 
-	// Withdraw = Request goods FROM central/other branch TO current branch
-	// from_branch_id = NULL means from central warehouse
-	// to_branch_id = current branch (requesting branch)
+	// Withdraw = Request goods FROM a warehouse TO current branch
+	// The store's default warehouse is used unless one is given
+	var warehouse *domain.Warehouse
+	var err error
+	if req.WarehouseID != nil {
+		warehouse, err = s.warehouseRepo.GetByID(ctx, storeID, *req.WarehouseID)
+	} else {
+		warehouse, err = s.warehouseRepo.GetDefault(ctx, storeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if warehouse == nil || !warehouse.IsActive {
+		if req.WarehouseID == nil {
+			return nil, errors.New("no default warehouse configured for this store")
+		}
+		return nil, errors.New("warehouse not found")
+	}
+
 	transferReq := &domain.CreateStockTransferRequest{
-		FromBranchID: nil, // From central (NULL)
-		ToBranchID:   branchID,
-		Note:         req.Note,
-		Items:        make([]domain.CreateStockTransferItemInput, len(req.Items)),
+		FromWarehouseID: &warehouse.ID,
+		ToBranchID:      branchID,
+		Note:            req.Note,
+		Items:           make([]domain.CreateStockTransferItemInput, len(req.Items)),
 	}
 
 	for i, item := range req.Items {
@@ -67,7 +101,7 @@ func (s *stockTransferService) WithdrawGoods(ctx context.Context, storeID, branc
 	}
 
 	// Create transfer request with status CREATED
-	// Stock is NOT reduced - central will process and send goods
+	// Stock is NOT reduced - the warehouse will pick and dispatch the goods
 	transfer, err := s.repo.Create(ctx, storeID, transferReq, staffID)
 	if err != nil {
 		return nil, err
@@ -94,26 +128,94 @@ func (s *stockTransferService) GetPendingTransfers(ctx context.Context, storeID,
 	return s.repo.GetPendingTransfers(ctx, storeID, branchID)
 }
 
-func (s *stockTransferService) ReceiveTransfer(ctx context.Context, storeID, branchID, transferID, staffID int64, items []domain.UpdateStockTransferItemInput) error {
+func (s *stockTransferService) GetOutgoingTransfers(ctx context.Context, storeID, branchID int64) ([]domain.StockTransferResponse, error) {
+	return s.repo.GetOutgoingTransfers(ctx, storeID, branchID)
+}
+
+func (s *stockTransferService) DispatchTransfer(ctx context.Context, storeID, branchID, transferID, staffID int64, req *domain.DispatchStockTransferRequest) (*domain.StockTransferResponse, error) {
+	transfer, err := s.repo.GetByID(ctx, storeID, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, errors.New("transfer not found")
+	}
+
+	// Only the sending branch can dispatch its own transfers
+	if transfer.FromBranchID == nil || *transfer.FromBranchID != branchID {
+		return nil, errors.New("transfer does not belong to this branch")
+	}
+
+	if transfer.Status != domain.StockTransferStatusCreated {
+		return nil, errors.New("transfer is not in CREATED status")
+	}
+
+	// Deduct source stock and mark as SENT in a single transaction
+	if err := s.repo.DispatchAndDeductStock(ctx, storeID, transferID, req.Items, req.Note, staffID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, storeID, transferID)
+}
+
+func (s *stockTransferService) ReceiveTransfer(ctx context.Context, storeID, branchID, transferID, staffID int64, req *domain.UpdateStockTransferRequest) (*domain.StockTransferResponse, error) {
 	// Get current transfer
 	transfer, err := s.repo.GetByID(ctx, storeID, transferID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if transfer == nil {
-		return errors.New("transfer not found")
+		return nil, errors.New("transfer not found")
 	}
 
-	if transfer.Status != domain.StockTransferStatusSent {
-		return errors.New("transfer is not in SENT status")
+	if transfer.ToBranchID != branchID {
+		return nil, errors.New("transfer is not addressed to this branch")
 	}
 
-	if len(items) == 0 {
-		return errors.New("at least one item is required")
+	if transfer.Status != domain.StockTransferStatusSent && transfer.Status != domain.StockTransferStatusPartiallyReceived {
+		return nil, errors.New("transfer is not in SENT or PARTIALLY_RECEIVED status")
 	}
 
-	// Receive goods and add stock in a single transaction
-	return s.repo.ReceiveAndAddStock(ctx, storeID, branchID, transferID, items, staffID)
+	// A receipt needs items unless it only closes the transfer
+	if len(req.Items) == 0 && !req.Close {
+		return nil, errors.New("at least one item is required")
+	}
+
+	// Record the receipt and add stock in a single transaction
+	if err := s.repo.ReceiveAndAddStock(ctx, storeID, branchID, transferID, req.Items, req.Note, req.Close, staffID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, storeID, transferID)
+}
+
+func (s *stockTransferService) GetReceipts(ctx context.Context, storeID, transferID int64) ([]domain.StockTransferReceiptResponse, error) {
+	return s.repo.GetReceipts(ctx, storeID, transferID)
+}
+
+func (s *stockTransferService) GetDiscrepancies(ctx context.Context, storeID, branchID int64, status domain.DiscrepancyStatus) ([]domain.StockTransferDiscrepancy, error) {
+	return s.repo.GetDiscrepancies(ctx, storeID, &branchID, nil, status)
+}
+
+func (s *stockTransferService) ResolveDiscrepancy(ctx context.Context, storeID, branchID, discrepancyID, staffID int64, req *domain.ResolveDiscrepancyRequest) (*domain.StockTransferDiscrepancy, error) {
+	discrepancy, err := s.repo.GetDiscrepancyByID(ctx, storeID, discrepancyID)
+	if err != nil {
+		return nil, err
+	}
+	if discrepancy == nil {
+		return nil, errors.New("discrepancy not found")
+	}
+
+	// The sending branch owns the missing units, so it resolves them
+	if discrepancy.FromBranchID == nil || *discrepancy.FromBranchID != branchID {
+		return nil, errors.New("discrepancy can only be resolved by the sending branch")
+	}
+
+	if err := s.repo.ResolveDiscrepancy(ctx, storeID, discrepancyID, req.Resolution, req.Note, staffID); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetDiscrepancyByID(ctx, storeID, discrepancyID)
 }
 
 func (s *stockTransferService) CancelTransfer(ctx context.Context, storeID, transferID int64) error {
@@ -129,5 +231,10 @@ func (s *stockTransferService) CancelTransfer(ctx context.Context, storeID, tran
 		return errors.New("cannot cancel received transfer")
 	}
 
+	// Stock has already left the sending branch once dispatched
+	if transfer.Status == domain.StockTransferStatusSent || transfer.Status == domain.StockTransferStatusPartiallyReceived {
+		return errors.New("cannot cancel transfer that has already been sent")
+	}
+
 	return s.repo.UpdateStatus(ctx, storeID, transferID, domain.StockTransferStatusCancelled, nil)
 }
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+)
+
+type StocktakeService interface {
+	CreateStocktake(ctx context.Context, storeID, branchID, staffID int64, req *domain.CreateStocktakeRequest) (*domain.StocktakeResponse, error)
+	GetStocktake(ctx context.Context, storeID, stocktakeID int64) (*domain.StocktakeResponse, error)
+	GetStocktakes(ctx context.Context, storeID int64, branchID *int64, status domain.StocktakeStatus, limit, offset int) (*domain.StocktakeListResponse, error)
+	SubmitCounts(ctx context.Context, storeID, branchID, stocktakeID, staffID int64, req *domain.SubmitStocktakeCountsRequest) (*domain.StocktakeResponse, error)
+	RequestRecount(ctx context.Context, storeID, branchID, stocktakeID int64, req *domain.RequestRecountRequest) (*domain.StocktakeResponse, error)
+	PostStocktake(ctx context.Context, storeID, branchID, stocktakeID, staffID int64, req *domain.PostStocktakeRequest) (*domain.StocktakeResponse, error)
+	CancelStocktake(ctx context.Context, storeID, branchID, stocktakeID int64) error
+}
+
+type stocktakeService struct {
+	repo repository.StocktakeRepository
+}
+
+func NewStocktakeService(repo repository.StocktakeRepository) StocktakeService {
+	return &stocktakeService{repo: repo}
+}
+
+func (s *stocktakeService) CreateStocktake(ctx context.Context, storeID, branchID, staffID int64, req *domain.CreateStocktakeRequest) (*domain.StocktakeResponse, error) {
+	if req.CountType == domain.StocktakeTypeCycle && len(req.CategoryIDs) == 0 {
+		return nil, errors.New("a cycle count needs at least one category")
+	}
+	if req.CountType == domain.StocktakeTypeFull {
+		req.CategoryIDs = nil
+	}
+
+	stocktakeID, err := s.repo.Create(ctx, storeID, branchID, staffID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, storeID, stocktakeID)
+}
+
+func (s *stocktakeService) GetStocktake(ctx context.Context, storeID, stocktakeID int64) (*domain.StocktakeResponse, error) {
+	return s.repo.GetByID(ctx, storeID, stocktakeID)
+}
+
+func (s *stocktakeService) GetStocktakes(ctx context.Context, storeID int64, branchID *int64, status domain.StocktakeStatus, limit, offset int) (*domain.StocktakeListResponse, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.List(ctx, storeID, branchID, status, limit, offset)
+}
+
+func (s *stocktakeService) SubmitCounts(ctx context.Context, storeID, branchID, stocktakeID, staffID int64, req *domain.SubmitStocktakeCountsRequest) (*domain.StocktakeResponse, error) {
+	seen := make(map[int64]bool, len(req.Items))
+	for _, item := range req.Items {
+		if seen[item.ProductID] {
+			return nil, errors.New("each product can only appear once per submission")
+		}
+		seen[item.ProductID] = true
+	}
+
+	if err := s.checkBranch(ctx, storeID, branchID, stocktakeID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SubmitCounts(ctx, storeID, stocktakeID, staffID, req.Items); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, storeID, stocktakeID)
+}
+
+func (s *stocktakeService) RequestRecount(ctx context.Context, storeID, branchID, stocktakeID int64, req *domain.RequestRecountRequest) (*domain.StocktakeResponse, error) {
+	if err := s.checkBranch(ctx, storeID, branchID, stocktakeID); err != nil {
+		return nil, err
+	}
+
+	recounted, err := s.repo.RequestRecount(ctx, storeID, stocktakeID, req.ProductIDs)
+	if err != nil {
+		return nil, err
+	}
+	if recounted == 0 {
+		return nil, errors.New("no counted items to recount")
+	}
+
+	return s.repo.GetByID(ctx, storeID, stocktakeID)
+}
+
+func (s *stocktakeService) PostStocktake(ctx context.Context, storeID, branchID, stocktakeID, staffID int64, req *domain.PostStocktakeRequest) (*domain.StocktakeResponse, error) {
+	if err := s.checkBranch(ctx, storeID, branchID, stocktakeID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Post(ctx, storeID, stocktakeID, staffID, req.ZeroUncounted); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetByID(ctx, storeID, stocktakeID)
+}
+
+func (s *stocktakeService) CancelStocktake(ctx context.Context, storeID, branchID, stocktakeID int64) error {
+	if err := s.checkBranch(ctx, storeID, branchID, stocktakeID); err != nil {
+		return err
+	}
+	return s.repo.Cancel(ctx, storeID, stocktakeID)
+}
+
+// checkBranch makes sure a stocktake is counted and posted from its own branch
+func (s *stocktakeService) checkBranch(ctx context.Context, storeID, branchID, stocktakeID int64) error {
+	st, err := s.repo.GetByID(ctx, storeID, stocktakeID)
+	if err != nil {
+		return err
+	}
+	if st == nil {
+		return errors.New("stocktake not found")
+	}
+	if st.BranchID != branchID {
+		return errors.New("stocktake belongs to another branch")
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+)
+
+const storeExportManifestFile = "manifest.json"
+
+type StoreExportService interface {
+	Export(ctx context.Context, storeID int64, format domain.StoreExportFormat, w io.Writer) (*domain.StoreExportManifest, error)
+	Restore(ctx context.Context, r io.ReaderAt, size int64, dryRun bool) (*domain.StoreRestoreResult, error)
+}
+
+type storeExportService struct {
+	repo repository.StoreExportRepository
+}
+
+func NewStoreExportService(repo repository.StoreExportRepository) StoreExportService {
+	return &storeExportService{repo: repo}
+}
+
+// storeExportZip writes each exported table as a file in the zip and keeps
+// the manifest's table list and row counts
+type storeExportZip struct {
+	zip      *zip.Writer
+	manifest *domain.StoreExportManifest
+	csv      *csv.Writer
+	jsonl    io.Writer
+	columns  [][]byte
+}
+
+func (z *storeExportZip) flush() error {
+	if z.csv == nil {
+		return nil
+	}
+	z.csv.Flush()
+	return z.csv.Error()
+}
+
+func (z *storeExportZip) Table(name string, columns []string) error {
+	if err := z.flush(); err != nil {
+		return err
+	}
+
+	file := name + "." + string(z.manifest.Format)
+	f, err := z.zip.Create(file)
+	if err != nil {
+		return err
+	}
+	z.manifest.Tables = append(z.manifest.Tables, domain.StoreExportTable{Name: name, File: file, Columns: columns})
+
+	if z.manifest.Format == domain.StoreExportJSONL {
+		z.csv = nil
+		z.jsonl = f
+		z.columns = make([][]byte, len(columns))
+		for i, c := range columns {
+			if z.columns[i], err = json.Marshal(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	z.csv = csv.NewWriter(f)
+	return z.csv.Write(columns)
+}
+
+func (z *storeExportZip) Row(values []sql.NullString) error {
+	z.manifest.Tables[len(z.manifest.Tables)-1].Rows++
+
+	if z.csv != nil {
+		cells := make([]string, len(values))
+		for i, v := range values {
+			switch {
+			case !v.Valid:
+				cells[i] = domain.StoreExportCSVNull
+			case strings.HasPrefix(v.String, `\`):
+				cells[i] = `\` + v.String
+			default:
+				cells[i] = v.String
+			}
+		}
+		return z.csv.Write(cells)
+	}
+
+	// Written by hand to keep the keys in column order
+	var line bytes.Buffer
+	line.WriteByte('{')
+	for i, v := range values {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		line.Write(z.columns[i])
+		line.WriteByte(':')
+		if !v.Valid {
+			line.WriteString("null")
+			continue
+		}
+		value, err := json.Marshal(v.String)
+		if err != nil {
+			return err
+		}
+		line.Write(value)
+	}
+	line.WriteString("}\n")
+	_, err := z.jsonl.Write(line.Bytes())
+	return err
+}
+
+// Export writes a zip holding every table of the store's data, one file per
+// table, and a manifest.json describing them
+func (s *storeExportService) Export(ctx context.Context, storeID int64, format domain.StoreExportFormat, w io.Writer) (*domain.StoreExportManifest, error) {
+	storeName, err := s.repo.GetStoreName(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	if storeName == nil {
+		return nil, fmt.Errorf("store %d not found", storeID)
+	}
+
+	manifest := &domain.StoreExportManifest{
+		Version:    domain.StoreExportVersion,
+		StoreID:    storeID,
+		StoreName:  *storeName,
+		Format:     format,
+		ExportedAt: time.Now(),
+		Excluded:   repository.StoreExportExcludedTables,
+	}
+	z := &storeExportZip{zip: zip.NewWriter(w), manifest: manifest}
+	if err := s.repo.ExportStoreTx(ctx, storeID, z); err != nil {
+		return nil, err
+	}
+	if err := z.flush(); err != nil {
+		return nil, err
+	}
+
+	f, err := z.zip.Create(storeExportManifestFile)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return nil, err
+	}
+	if err := z.zip.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// csvTableReader reads a CSV table file, undoing the NULL marker and backslash
+// escaping storeExportZip writes
+type csvTableReader struct {
+	file    io.ReadCloser
+	csv     *csv.Reader
+	columns []string
+}
+
+func (r *csvTableReader) Columns() []string { return r.columns }
+func (r *csvTableReader) Close() error      { return r.file.Close() }
+
+func (r *csvTableReader) Next() ([]sql.NullString, error) {
+	record, err := r.csv.Read()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]sql.NullString, len(record))
+	for i, cell := range record {
+		switch {
+		case cell == domain.StoreExportCSVNull:
+		case strings.HasPrefix(cell, `\\`):
+			values[i] = sql.NullString{String: cell[1:], Valid: true}
+		default:
+			values[i] = sql.NullString{String: cell, Valid: true}
+		}
+	}
+	return values, nil
+}
+
+// jsonlTableReader reads a JSON-lines table file. Values are normally strings;
+// other JSON values are passed on as their JSON text.
+type jsonlTableReader struct {
+	file    io.ReadCloser
+	lines   *bufio.Scanner
+	columns []string
+}
+
+func (r *jsonlTableReader) Columns() []string { return r.columns }
+func (r *jsonlTableReader) Close() error      { return r.file.Close() }
+
+func (r *jsonlTableReader) Next() ([]sql.NullString, error) {
+	for r.lines.Scan() {
+		line := bytes.TrimSpace(r.lines.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(line, &object); err != nil {
+			return nil, err
+		}
+		values := make([]sql.NullString, len(r.columns))
+		for i, c := range r.columns {
+			raw, ok := object[c]
+			if !ok || string(raw) == "null" {
+				continue
+			}
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				s = string(raw)
+			}
+			values[i] = sql.NullString{String: s, Valid: true}
+		}
+		return values, nil
+	}
+	if err := r.lines.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// countedTableReader fails a table whose row count differs from the
+// manifest's, which means the archive was cut short or edited
+type countedTableReader struct {
+	repository.StoreTableReader
+	table string
+	want  int
+	got   int
+}
+
+func (r *countedTableReader) Next() ([]sql.NullString, error) {
+	values, err := r.StoreTableReader.Next()
+	if err == io.EOF && r.got != r.want {
+		return nil, fmt.Errorf("%s holds %d rows but the manifest lists %d", r.table, r.got, r.want)
+	}
+	if err == nil {
+		r.got++
+	}
+	return values, err
+}
+
+// Restore loads a store export into this database, which must not hold the
+// store yet. The archive's manifest decides which tables are loaded.
+func (s *storeExportService) Restore(ctx context.Context, r io.ReaderAt, size int64, dryRun bool) (*domain.StoreRestoreResult, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("invalid export archive: %w", err)
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	mf, ok := files[storeExportManifestFile]
+	if !ok {
+		return nil, errors.New("invalid export archive: manifest.json is missing")
+	}
+	rc, err := mf.Open()
+	if err != nil {
+		return nil, err
+	}
+	var manifest domain.StoreExportManifest
+	err = json.NewDecoder(rc).Decode(&manifest)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest.json: %w", err)
+	}
+	if _, err := domain.ParseStoreExportFormat(string(manifest.Format)); err != nil {
+		return nil, err
+	}
+	switch {
+	case manifest.Version == 1 && manifest.Format == domain.StoreExportCSV:
+		return nil, errors.New("version 1 CSV exports cannot tell NULL from empty text, export the store again or use a JSON-lines export")
+	case manifest.Version != 1 && manifest.Version != domain.StoreExportVersion:
+		return nil, fmt.Errorf("unsupported export version %d", manifest.Version)
+	}
+
+	tables := make(map[string]domain.StoreExportTable, len(manifest.Tables))
+	for _, t := range manifest.Tables {
+		tables[t.Name] = t
+	}
+	open := func(name string) (repository.StoreTableReader, error) {
+		t, ok := tables[name]
+		if !ok {
+			return nil, nil
+		}
+		f, ok := files[t.File]
+		if !ok {
+			return nil, fmt.Errorf("invalid export archive: %s is missing", t.File)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		var reader repository.StoreTableReader
+		if manifest.Format == domain.StoreExportJSONL {
+			lines := bufio.NewScanner(rc)
+			lines.Buffer(make([]byte, 64*1024), 16*1024*1024)
+			reader = &jsonlTableReader{file: rc, lines: lines, columns: t.Columns}
+		} else {
+			cr := csv.NewReader(rc)
+			header, err := cr.Read()
+			if err != nil {
+				rc.Close()
+				return nil, fmt.Errorf("%s: %w", t.File, err)
+			}
+			reader = &csvTableReader{file: rc, csv: cr, columns: header}
+		}
+		return &countedTableReader{StoreTableReader: reader, table: name, want: t.Rows}, nil
+	}
+
+	restored, err := s.repo.RestoreStoreTx(ctx, manifest.StoreID, open, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.StoreRestoreResult{
+		StoreID:   manifest.StoreID,
+		StoreName: manifest.StoreName,
+		DryRun:    dryRun,
+		Tables:    restored,
+	}
+	for _, t := range restored {
+		result.TotalRows += t.Rows
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+)
+
+var (
+	// ErrExpiryMonthsRequired is returned when FIXED_PERIOD expiry has no number of months
+	ErrExpiryMonthsRequired = errors.New("points_expiry_months is required for FIXED_PERIOD expiry")
+	// ErrWalletRatesRequired is returned when the points wallet is enabled without both rates
+	ErrWalletRatesRequired = errors.New("wallet_baht_per_point and wallet_point_value are required for the points wallet")
+)
+
+type StoreSettingsService interface {
+	GetSettings(ctx context.Context, storeID int64) (*domain.StoreSettings, error)
+	UpdateSettings(ctx context.Context, storeID int64, req *domain.UpdateStoreSettingsRequest) (*domain.StoreSettings, error)
+}
+
+type storeSettingsService struct {
+	repo repository.StoreSettingsRepository
+}
+
+func NewStoreSettingsService(repo repository.StoreSettingsRepository) StoreSettingsService {
+	return &storeSettingsService{repo: repo}
+}
+
+func (s *storeSettingsService) GetSettings(ctx context.Context, storeID int64) (*domain.StoreSettings, error) {
+	return s.repo.GetByStore(ctx, storeID)
+}
+
+func (s *storeSettingsService) UpdateSettings(ctx context.Context, storeID int64, req *domain.UpdateStoreSettingsRequest) (*domain.StoreSettings, error) {
+	current, err := s.repo.GetByStore(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	if req.AdjustmentApprovalQty == nil {
+		req.AdjustmentApprovalQty = current.AdjustmentApprovalQty
+	} else if *req.AdjustmentApprovalQty == 0 {
+		req.AdjustmentApprovalQty = nil
+	}
+	if req.AdjustmentApprovalValue == nil {
+		req.AdjustmentApprovalValue = current.AdjustmentApprovalValue
+	} else if *req.AdjustmentApprovalValue == 0 {
+		req.AdjustmentApprovalValue = nil
+	}
+	if req.NegativeStockPolicy == "" {
+		req.NegativeStockPolicy = current.NegativeStockPolicy
+	}
+	if req.PointsExpiryMode == "" {
+		req.PointsExpiryMode = current.PointsExpiryMode
+	}
+	if req.PointsExpiryMonths == nil {
+		req.PointsExpiryMonths = current.PointsExpiryMonths
+	}
+	if req.TierWindowMonths == nil {
+		req.TierWindowMonths = current.TierWindowMonths
+	} else if *req.TierWindowMonths == 0 {
+		req.TierWindowMonths = nil
+	}
+	if req.PointsExpiryMode == domain.PointsExpiryFixedPeriod && req.PointsExpiryMonths == nil {
+		return nil, ErrExpiryMonthsRequired
+	}
+	if req.LoyaltyMode == "" {
+		req.LoyaltyMode = current.LoyaltyMode
+	}
+	if req.WalletBahtPerPoint == nil {
+		req.WalletBahtPerPoint = current.WalletBahtPerPoint
+	}
+	if req.WalletPointValue == nil {
+		req.WalletPointValue = current.WalletPointValue
+	}
+	if req.LoyaltyMode.UsesWallet() && (req.WalletBahtPerPoint == nil || req.WalletPointValue == nil) {
+		return nil, ErrWalletRatesRequired
+	}
+
+	if err := s.repo.Upsert(ctx, storeID, req); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByStore(ctx, storeID)
+}
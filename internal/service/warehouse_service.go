@@ -0,0 +1,256 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mini-membership/api/internal/domain"
+	"github.com/mini-membership/api/internal/repository"
+)
+
+type WarehouseService interface {
+	CreateWarehouse(ctx context.Context, storeID int64, req *domain.CreateWarehouseRequest) (*domain.Warehouse, error)
+	GetWarehouses(ctx context.Context, storeID int64) ([]domain.Warehouse, error)
+	GetStock(ctx context.Context, storeID, warehouseID int64) (*domain.WarehouseStockResponse, error)
+	GetMovements(ctx context.Context, storeID, warehouseID int64, limit, offset int) ([]domain.InventoryMovementResponse, error)
+	AdjustStock(ctx context.Context, storeID, warehouseID, staffID int64, approvedBy *int64, req *domain.AdjustWarehouseStockRequest) error
+	GetQueue(ctx context.Context, storeID, warehouseID int64) ([]domain.StockTransferResponse, error)
+	PickTransfer(ctx context.Context, storeID, warehouseID, transferID int64, req *domain.PickStockTransferRequest) (*domain.StockTransferResponse, error)
+	DispatchTransfer(ctx context.Context, storeID, warehouseID, transferID, staffID int64, req *domain.DispatchStockTransferRequest) (*domain.StockTransferResponse, error)
+	GetShortShipped(ctx context.Context, storeID, warehouseID int64, limit, offset int) (*domain.ShortShippedResponse, error)
+	GetDiscrepancies(ctx context.Context, storeID, warehouseID int64, status domain.DiscrepancyStatus) ([]domain.StockTransferDiscrepancy, error)
+	ResolveDiscrepancy(ctx context.Context, storeID, warehouseID, discrepancyID, staffID int64, req *domain.ResolveDiscrepancyRequest) (*domain.StockTransferDiscrepancy, error)
+}
+
+type warehouseService struct {
+	repo              repository.WarehouseRepository
+	stockTransferRepo repository.StockTransferRepository
+	inventoryRepo     repository.InventoryRepository
+	settingsRepo      repository.StoreSettingsRepository
+}
+
+func NewWarehouseService(repo repository.WarehouseRepository, stockTransferRepo repository.StockTransferRepository, inventoryRepo repository.InventoryRepository, settingsRepo repository.StoreSettingsRepository) WarehouseService {
+	return &warehouseService{repo: repo, stockTransferRepo: stockTransferRepo, inventoryRepo: inventoryRepo, settingsRepo: settingsRepo}
+}
+
+func (s *warehouseService) CreateWarehouse(ctx context.Context, storeID int64, req *domain.CreateWarehouseRequest) (*domain.Warehouse, error) {
+	// The first warehouse of a store always becomes the default
+	current, err := s.repo.GetDefault(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		req.IsDefault = true
+	}
+
+	return s.repo.Create(ctx, storeID, req)
+}
+
+func (s *warehouseService) GetWarehouses(ctx context.Context, storeID int64) ([]domain.Warehouse, error) {
+	return s.repo.GetByStore(ctx, storeID)
+}
+
+func (s *warehouseService) GetStock(ctx context.Context, storeID, warehouseID int64) (*domain.WarehouseStockResponse, error) {
+	if err := s.checkWarehouse(ctx, storeID, warehouseID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetStock(ctx, storeID, warehouseID)
+}
+
+func (s *warehouseService) GetMovements(ctx context.Context, storeID, warehouseID int64, limit, offset int) ([]domain.InventoryMovementResponse, error) {
+	if err := s.checkWarehouse(ctx, storeID, warehouseID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	return s.repo.GetMovements(ctx, storeID, warehouseID, limit, offset)
+}
+
+// AdjustStock receives or adjusts warehouse stock. Adjustments other than
+// receipts are checked against the reason catalogue and the store's approval
+// thresholds as branch adjustments are; approvedBy is the approving manager.
+func (s *warehouseService) AdjustStock(ctx context.Context, storeID, warehouseID, staffID int64, approvedBy *int64, req *domain.AdjustWarehouseStockRequest) error {
+	if req.Quantity == 0 {
+		return errors.New("quantity must not be zero")
+	}
+	if req.LotNumber != nil && *req.LotNumber != "" && req.Quantity < 0 {
+		return errors.New("lot number only applies to incoming stock")
+	}
+	if req.UnitCost != nil && req.UnitCost.IsNegative() {
+		return errors.New("unit cost must not be negative")
+	}
+
+	var reasonCode *string
+	reasonText := req.Reason
+	if req.MovementType == domain.MovementTypeReceive {
+		if req.Quantity < 0 {
+			return errors.New("received quantity must be positive")
+		}
+		if reasonText == "" {
+			return errors.New("reason is required")
+		}
+	} else {
+		if req.ReasonCode == "" {
+			return errors.New("reason code is required")
+		}
+		reason, err := s.inventoryRepo.GetAdjustmentReasonByCode(ctx, storeID, req.ReasonCode)
+		if err != nil {
+			return err
+		}
+		if reason == nil || !reason.IsActive {
+			return errors.New("unknown reason code")
+		}
+		if reason.MovementType != req.MovementType {
+			return fmt.Errorf("reason code %s is recorded as %s", reason.Code, reason.MovementType)
+		}
+
+		// Damage and issues only ever take stock out; ADJUST goes either way
+		if req.MovementType != domain.MovementTypeAdjust && req.Quantity > 0 {
+			return fmt.Errorf("%s adjustments must have a negative quantity", req.MovementType)
+		}
+
+		cost, err := s.inventoryRepo.GetAverageCost(ctx, storeID, nil, &warehouseID, req.ProductID)
+		if err != nil {
+			return err
+		}
+		if cost == nil {
+			return errors.New("product not found")
+		}
+
+		if approvedBy == nil {
+			quantity := req.Quantity
+			if quantity < 0 {
+				quantity = -quantity
+			}
+			if err := checkApprovalThreshold(ctx, s.settingsRepo, storeID, quantity, *cost); err != nil {
+				return err
+			}
+		}
+
+		reasonCode = &reason.Code
+		if reasonText == "" {
+			reasonText = reason.Label
+		}
+	}
+
+	if err := s.checkWarehouse(ctx, storeID, warehouseID); err != nil {
+		return err
+	}
+
+	var note *string
+	if req.Note != "" {
+		note = &req.Note
+	}
+
+	return s.repo.AdjustStock(ctx, storeID, warehouseID, req.ProductID, req.Quantity, req.UnitCost, req.LotNumber, req.ExpiryDate, req.MovementType, reasonCode, &reasonText, note, staffID, approvedBy)
+}
+
+func (s *warehouseService) GetQueue(ctx context.Context, storeID, warehouseID int64) ([]domain.StockTransferResponse, error) {
+	if err := s.checkWarehouse(ctx, storeID, warehouseID); err != nil {
+		return nil, err
+	}
+	return s.stockTransferRepo.GetWarehouseQueue(ctx, storeID, warehouseID)
+}
+
+func (s *warehouseService) PickTransfer(ctx context.Context, storeID, warehouseID, transferID int64, req *domain.PickStockTransferRequest) (*domain.StockTransferResponse, error) {
+	if err := s.checkTransfer(ctx, storeID, warehouseID, transferID); err != nil {
+		return nil, err
+	}
+
+	for _, item := range req.Items {
+		if item.PickedCount < 0 {
+			return nil, fmt.Errorf("picked count for product %d cannot be negative", item.ProductID)
+		}
+	}
+
+	if err := s.stockTransferRepo.UpdatePickedCounts(ctx, storeID, transferID, req.Items); err != nil {
+		return nil, err
+	}
+
+	return s.stockTransferRepo.GetByID(ctx, storeID, transferID)
+}
+
+func (s *warehouseService) DispatchTransfer(ctx context.Context, storeID, warehouseID, transferID, staffID int64, req *domain.DispatchStockTransferRequest) (*domain.StockTransferResponse, error) {
+	if err := s.checkTransfer(ctx, storeID, warehouseID, transferID); err != nil {
+		return nil, err
+	}
+
+	// Deduct warehouse stock and mark as SENT in a single transaction
+	if err := s.stockTransferRepo.DispatchAndDeductStock(ctx, storeID, transferID, req.Items, req.Note, staffID); err != nil {
+		return nil, err
+	}
+
+	return s.stockTransferRepo.GetByID(ctx, storeID, transferID)
+}
+
+func (s *warehouseService) GetShortShipped(ctx context.Context, storeID, warehouseID int64, limit, offset int) (*domain.ShortShippedResponse, error) {
+	if err := s.checkWarehouse(ctx, storeID, warehouseID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+	return s.repo.GetShortShipped(ctx, storeID, warehouseID, limit, offset)
+}
+
+func (s *warehouseService) GetDiscrepancies(ctx context.Context, storeID, warehouseID int64, status domain.DiscrepancyStatus) ([]domain.StockTransferDiscrepancy, error) {
+	if err := s.checkWarehouse(ctx, storeID, warehouseID); err != nil {
+		return nil, err
+	}
+	return s.stockTransferRepo.GetDiscrepancies(ctx, storeID, nil, &warehouseID, status)
+}
+
+func (s *warehouseService) ResolveDiscrepancy(ctx context.Context, storeID, warehouseID, discrepancyID, staffID int64, req *domain.ResolveDiscrepancyRequest) (*domain.StockTransferDiscrepancy, error) {
+	discrepancy, err := s.stockTransferRepo.GetDiscrepancyByID(ctx, storeID, discrepancyID)
+	if err != nil {
+		return nil, err
+	}
+	if discrepancy == nil {
+		return nil, errors.New("discrepancy not found")
+	}
+	if discrepancy.FromWarehouseID == nil || *discrepancy.FromWarehouseID != warehouseID {
+		return nil, errors.New("discrepancy does not belong to this warehouse")
+	}
+
+	if err := s.stockTransferRepo.ResolveDiscrepancy(ctx, storeID, discrepancyID, req.Resolution, req.Note, staffID); err != nil {
+		return nil, err
+	}
+
+	return s.stockTransferRepo.GetDiscrepancyByID(ctx, storeID, discrepancyID)
+}
+
+func (s *warehouseService) checkWarehouse(ctx context.Context, storeID, warehouseID int64) error {
+	warehouse, err := s.repo.GetByID(ctx, storeID, warehouseID)
+	if err != nil {
+		return err
+	}
+	if warehouse == nil {
+		return errors.New("warehouse not found")
+	}
+	return nil
+}
+
+func (s *warehouseService) checkTransfer(ctx context.Context, storeID, warehouseID, transferID int64) error {
+	transfer, err := s.stockTransferRepo.GetByID(ctx, storeID, transferID)
+	if err != nil {
+		return err
+	}
+	if transfer == nil {
+		return errors.New("transfer not found")
+	}
+	if transfer.FromWarehouseID == nil || *transfer.FromWarehouseID != warehouseID {
+		return errors.New("transfer does not belong to this warehouse")
+	}
+	if transfer.Status != domain.StockTransferStatusCreated && transfer.Status != domain.StockTransferStatusPicking {
+		return errors.New("transfer is not waiting to be dispatched")
+	}
+	return nil
+}
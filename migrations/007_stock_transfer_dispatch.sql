@@ -0,0 +1,31 @@
+-- =========================================================
+-- 007_stock_transfer_dispatch.sql - Stock transfer dispatch step
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Keep the requested quantity separate from the sent quantity
+--    send_count is confirmed by the sending branch on dispatch,
+--    requested_count keeps what the receiving branch asked for
+-- =========================================================
+
+ALTER TABLE stock_transfer_items
+ADD COLUMN IF NOT EXISTS requested_count INTEGER NOT NULL DEFAULT 0;
+
+UPDATE stock_transfer_items
+SET requested_count = send_count
+WHERE requested_count = 0;
+
+ALTER TABLE stock_transfer_items
+DROP CONSTRAINT IF EXISTS chk_stock_transfer_items_requested_count;
+
+ALTER TABLE stock_transfer_items
+ADD CONSTRAINT chk_stock_transfer_items_requested_count CHECK (requested_count >= 0);
+
+COMMENT ON COLUMN stock_transfer_items.requested_count IS 'Quantity requested when the transfer was created. send_count is the quantity actually dispatched.';
+
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_store_from_status
+ON stock_transfers(store_id, from_branch_id, status);
+
+COMMIT;
@@ -0,0 +1,154 @@
+-- =========================================================
+-- 008_warehouses.sql - Central warehouse stock locations
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Warehouses
+--    A store-level stock location that supplies branches.
+--    One warehouse per store is the default target of withdraw requests.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS warehouses (
+  id              BIGSERIAL PRIMARY KEY,
+  store_id        BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+
+  warehouse_name  TEXT NOT NULL,
+  is_default      BOOLEAN NOT NULL DEFAULT false,
+  is_active       BOOLEAN NOT NULL DEFAULT true,
+
+  created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (store_id, warehouse_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_warehouses_store ON warehouses(store_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_warehouses_store_default ON warehouses(store_id) WHERE is_default = true;
+
+CREATE TRIGGER trg_warehouses_updated_at
+BEFORE UPDATE ON warehouses
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- =========================================================
+-- 2) Warehouse stock (same shape as branch_products)
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS warehouse_products (
+  id             BIGSERIAL PRIMARY KEY,
+  store_id       BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  warehouse_id   BIGINT NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+  product_id     BIGINT NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+
+  is_active      BOOLEAN NOT NULL DEFAULT true,
+  on_stock       INTEGER NOT NULL DEFAULT 0,
+  reorder_level  INTEGER NOT NULL DEFAULT 0,
+
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (warehouse_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_warehouse_products_store_warehouse ON warehouse_products(store_id, warehouse_id);
+CREATE INDEX IF NOT EXISTS idx_warehouse_products_product_id ON warehouse_products(product_id);
+
+CREATE TRIGGER trg_warehouse_products_updated_at
+BEFORE UPDATE ON warehouse_products
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- =========================================================
+-- 3) Warehouse ledger (same shape as inventory_movements)
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS warehouse_movements (
+  id               BIGSERIAL PRIMARY KEY,
+  store_id          BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  warehouse_id      BIGINT NOT NULL REFERENCES warehouses(id) ON DELETE RESTRICT,
+  product_id        BIGINT NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+
+  movement_type     TEXT NOT NULL,
+  quantity_change   INTEGER NOT NULL,
+
+  from_stock_count  INTEGER,
+  to_stock_count    INTEGER,
+
+  reason            TEXT,
+  note              TEXT,
+
+  changed_by        BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+
+  reference_table   TEXT,
+  reference_id      BIGINT,
+
+  created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_warehouse_movement_type
+    CHECK (movement_type IN ('RECEIVE','ISSUE','ADJUST','TRANSFER_IN','TRANSFER_OUT','DAMAGE'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_warehouse_movements_store_warehouse_time ON warehouse_movements(store_id, warehouse_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_warehouse_movements_product_time ON warehouse_movements(product_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_warehouse_movements_reference ON warehouse_movements(reference_table, reference_id);
+
+
+-- =========================================================
+-- 4) Stock transfers can now be sourced from a warehouse
+--    PICKING = warehouse staff are picking the goods
+-- =========================================================
+
+ALTER TABLE stock_transfers
+ADD COLUMN IF NOT EXISTS from_warehouse_id BIGINT REFERENCES warehouses(id) ON DELETE RESTRICT;
+
+ALTER TABLE stock_transfers
+DROP CONSTRAINT IF EXISTS chk_stock_transfers_status;
+
+ALTER TABLE stock_transfers
+ADD CONSTRAINT chk_stock_transfers_status
+  CHECK (status IN ('CREATED','PICKING','SENT','RECEIVED','CANCELLED'));
+
+ALTER TABLE stock_transfers
+DROP CONSTRAINT IF EXISTS chk_stock_transfers_single_source;
+
+ALTER TABLE stock_transfers
+ADD CONSTRAINT chk_stock_transfers_single_source
+  CHECK (from_branch_id IS NULL OR from_warehouse_id IS NULL);
+
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_store_warehouse_status
+ON stock_transfers(store_id, from_warehouse_id, status);
+
+ALTER TABLE stock_transfer_items
+ADD COLUMN IF NOT EXISTS picked_count INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE stock_transfer_items
+DROP CONSTRAINT IF EXISTS chk_stock_transfer_items_picked_count;
+
+ALTER TABLE stock_transfer_items
+ADD CONSTRAINT chk_stock_transfer_items_picked_count CHECK (picked_count >= 0);
+
+
+-- =========================================================
+-- 5) Backfill: one default warehouse per store, and point open
+--    central withdraw requests (from_branch_id NULL) at it
+-- =========================================================
+
+INSERT INTO warehouses (store_id, warehouse_name, is_default)
+SELECT s.id, 'Central Warehouse', true
+FROM stores s
+WHERE NOT EXISTS (
+  SELECT 1 FROM warehouses w WHERE w.store_id = s.id AND w.is_default = true
+);
+
+UPDATE stock_transfers st
+SET from_warehouse_id = w.id
+FROM warehouses w
+WHERE w.store_id = st.store_id
+  AND w.is_default = true
+  AND st.from_branch_id IS NULL
+  AND st.from_warehouse_id IS NULL
+  AND st.status = 'CREATED';
+
+COMMIT;
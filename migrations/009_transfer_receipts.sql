@@ -0,0 +1,99 @@
+-- =========================================================
+-- 009_transfer_receipts.sql - Partial receipts and transfer discrepancies
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) PARTIALLY_RECEIVED = at least one receipt recorded, transfer still open
+-- =========================================================
+
+ALTER TABLE stock_transfers
+DROP CONSTRAINT IF EXISTS chk_stock_transfers_status;
+
+ALTER TABLE stock_transfers
+ADD CONSTRAINT chk_stock_transfers_status
+  CHECK (status IN ('CREATED','PICKING','SENT','PARTIALLY_RECEIVED','RECEIVED','CANCELLED'));
+
+
+-- =========================================================
+-- 2) Receipts
+--    Each receive call is one receipt. stock_transfer_items.receive_count
+--    is the running total across all receipts.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS stock_transfer_receipts (
+  id                 BIGSERIAL PRIMARY KEY,
+  store_id           BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  stock_transfer_id  BIGINT NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+
+  received_by        BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+  note               TEXT,
+
+  -- true when this receipt closed the transfer
+  is_final           BOOLEAN NOT NULL DEFAULT false,
+
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_receipts_transfer ON stock_transfer_receipts(stock_transfer_id, created_at);
+
+
+CREATE TABLE IF NOT EXISTS stock_transfer_receipt_items (
+  id             BIGSERIAL PRIMARY KEY,
+  receipt_id     BIGINT NOT NULL REFERENCES stock_transfer_receipts(id) ON DELETE CASCADE,
+  product_id     BIGINT NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+
+  receive_count  INTEGER NOT NULL,
+
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_stock_transfer_receipt_items_count CHECK (receive_count > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_receipt_items_receipt ON stock_transfer_receipt_items(receipt_id);
+
+
+-- =========================================================
+-- 3) Discrepancies
+--    Created when a transfer closes with receive_count < send_count.
+--    Resolved by the sending side as lost in transit, damaged or re-sent.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS stock_transfer_discrepancies (
+  id                  BIGSERIAL PRIMARY KEY,
+  store_id            BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  stock_transfer_id   BIGINT NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+  product_id          BIGINT NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+
+  send_count          INTEGER NOT NULL,
+  receive_count       INTEGER NOT NULL,
+  missing_count       INTEGER NOT NULL,
+
+  status              TEXT NOT NULL DEFAULT 'OPEN',
+  resolution          TEXT,
+  resolution_note     TEXT,
+  resolved_by         BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+  resolved_at         TIMESTAMPTZ,
+
+  -- the follow-up transfer when resolution = RESENT
+  resent_transfer_id  BIGINT REFERENCES stock_transfers(id) ON DELETE SET NULL,
+
+  created_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at          TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (stock_transfer_id, product_id),
+
+  CONSTRAINT chk_stock_transfer_discrepancies_missing CHECK (missing_count > 0),
+  CONSTRAINT chk_stock_transfer_discrepancies_status CHECK (status IN ('OPEN','RESOLVED')),
+  CONSTRAINT chk_stock_transfer_discrepancies_resolution
+    CHECK (resolution IS NULL OR resolution IN ('LOST_IN_TRANSIT','DAMAGED','RESENT'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_discrepancies_store_status ON stock_transfer_discrepancies(store_id, status, created_at DESC);
+
+CREATE TRIGGER trg_stock_transfer_discrepancies_updated_at
+BEFORE UPDATE ON stock_transfer_discrepancies
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+COMMIT;
@@ -0,0 +1,133 @@
+-- =========================================================
+-- 010_purchase_orders.sql - Suppliers, purchase orders and goods receiving
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Suppliers
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS suppliers (
+  id             BIGSERIAL PRIMARY KEY,
+  store_id       BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+
+  supplier_name  TEXT NOT NULL,
+  contact_name   TEXT,
+  phone          TEXT,
+  email          TEXT,
+  note           TEXT,
+
+  is_active      BOOLEAN NOT NULL DEFAULT true,
+
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (store_id, supplier_name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_suppliers_store ON suppliers(store_id);
+
+CREATE TRIGGER trg_suppliers_updated_at
+BEFORE UPDATE ON suppliers
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- =========================================================
+-- 2) Purchase orders
+--    OPEN -> PARTIALLY_RECEIVED -> CLOSED (all received or closed short)
+--    OPEN -> CANCELLED (nothing received yet)
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS purchase_orders (
+  id            BIGSERIAL PRIMARY KEY,
+  store_id      BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  supplier_id   BIGINT NOT NULL REFERENCES suppliers(id) ON DELETE RESTRICT,
+  branch_id     BIGINT NOT NULL REFERENCES branches(id) ON DELETE RESTRICT,
+
+  status        TEXT NOT NULL DEFAULT 'OPEN',
+  expected_at   DATE,
+  note          TEXT,
+
+  created_by    BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+  closed_at     TIMESTAMPTZ,
+
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_purchase_orders_status
+    CHECK (status IN ('OPEN','PARTIALLY_RECEIVED','CLOSED','CANCELLED'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_store_status ON purchase_orders(store_id, status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_store_branch ON purchase_orders(store_id, branch_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_purchase_orders_supplier ON purchase_orders(supplier_id);
+
+CREATE TRIGGER trg_purchase_orders_updated_at
+BEFORE UPDATE ON purchase_orders
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+CREATE TABLE IF NOT EXISTS purchase_order_items (
+  id                 BIGSERIAL PRIMARY KEY,
+  purchase_order_id  BIGINT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+  product_id         BIGINT NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+
+  ordered_qty        INTEGER NOT NULL,
+  received_qty       INTEGER NOT NULL DEFAULT 0,
+  unit_cost          NUMERIC(12,2) NOT NULL DEFAULT 0,
+
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (purchase_order_id, product_id),
+
+  CONSTRAINT chk_purchase_order_items_qty CHECK (ordered_qty > 0 AND received_qty >= 0),
+  CONSTRAINT chk_purchase_order_items_cost CHECK (unit_cost >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_purchase_order_items_po ON purchase_order_items(purchase_order_id);
+
+CREATE TRIGGER trg_purchase_order_items_updated_at
+BEFORE UPDATE ON purchase_order_items
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- =========================================================
+-- 3) Goods received notes
+--    One GRN per delivery; each GRN writes RECEIVE movements
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS goods_received_notes (
+  id                 BIGSERIAL PRIMARY KEY,
+  store_id           BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  purchase_order_id  BIGINT NOT NULL REFERENCES purchase_orders(id) ON DELETE RESTRICT,
+  branch_id          BIGINT NOT NULL REFERENCES branches(id) ON DELETE RESTRICT,
+
+  supplier_ref       TEXT,
+  note               TEXT,
+  received_by        BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_goods_received_notes_po ON goods_received_notes(purchase_order_id, created_at);
+
+
+CREATE TABLE IF NOT EXISTS goods_received_note_items (
+  id          BIGSERIAL PRIMARY KEY,
+  grn_id      BIGINT NOT NULL REFERENCES goods_received_notes(id) ON DELETE CASCADE,
+  product_id  BIGINT NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+
+  quantity    INTEGER NOT NULL,
+  unit_cost   NUMERIC(12,2) NOT NULL DEFAULT 0,
+
+  created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_goods_received_note_items_qty CHECK (quantity > 0),
+  CONSTRAINT chk_goods_received_note_items_cost CHECK (unit_cost >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_goods_received_note_items_grn ON goods_received_note_items(grn_id);
+
+COMMIT;
@@ -0,0 +1,86 @@
+-- =========================================================
+-- 011_stock_adjustments.sql - Typed stock adjustments, reason codes and approval thresholds
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Store settings
+--    Adjustments at or above either threshold need a manager PIN.
+--    NULL disables the threshold.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS store_settings (
+  store_id                      BIGINT PRIMARY KEY REFERENCES stores(id) ON DELETE CASCADE,
+
+  adjustment_approval_qty       INTEGER,
+  adjustment_approval_value     NUMERIC(12,2),
+
+  created_at                    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at                    TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_store_settings_adjustment_approval
+    CHECK (
+      (adjustment_approval_qty IS NULL OR adjustment_approval_qty > 0)
+      AND (adjustment_approval_value IS NULL OR adjustment_approval_value > 0)
+    )
+);
+
+CREATE TRIGGER trg_store_settings_updated_at
+BEFORE UPDATE ON store_settings
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- =========================================================
+-- 2) Adjustment reason codes
+--    store_id NULL rows are system defaults available to every store;
+--    a store can add its own codes or override a default by code.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS stock_adjustment_reasons (
+  id             BIGSERIAL PRIMARY KEY,
+  store_id       BIGINT REFERENCES stores(id) ON DELETE CASCADE,
+
+  code           TEXT NOT NULL,
+  label          TEXT NOT NULL,
+  movement_type  TEXT NOT NULL,
+
+  is_active      BOOLEAN NOT NULL DEFAULT true,
+
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_stock_adjustment_reasons_movement_type
+    CHECK (movement_type IN ('DAMAGE','ISSUE','ADJUST'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_stock_adjustment_reasons_store_code
+  ON stock_adjustment_reasons(store_id, code) WHERE store_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_stock_adjustment_reasons_default_code
+  ON stock_adjustment_reasons(code) WHERE store_id IS NULL;
+
+CREATE TRIGGER trg_stock_adjustment_reasons_updated_at
+BEFORE UPDATE ON stock_adjustment_reasons
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+INSERT INTO stock_adjustment_reasons (store_id, code, label, movement_type)
+VALUES
+  (NULL, 'FOUND',            'Found stock',        'ADJUST'),
+  (NULL, 'COUNT_CORRECTION', 'Count correction',   'ADJUST'),
+  (NULL, 'DAMAGED',          'Damaged',            'DAMAGE'),
+  (NULL, 'EXPIRED',          'Expired',            'DAMAGE'),
+  (NULL, 'THEFT',            'Theft',              'ISSUE'),
+  (NULL, 'SAMPLE',           'Sampling / tasting', 'ISSUE'),
+  (NULL, 'INTERNAL_USE',     'Internal use',       'ISSUE')
+ON CONFLICT DO NOTHING;
+
+
+-- =========================================================
+-- 3) Movement audit columns
+-- =========================================================
+
+ALTER TABLE inventory_movements
+  ADD COLUMN IF NOT EXISTS reason_code TEXT,
+  ADD COLUMN IF NOT EXISTS approved_by BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL;
+
+COMMIT;
@@ -0,0 +1,30 @@
+-- =========================================================
+-- 012_replenishment.sql - Per-product reorder settings for replenishment
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Lead time per branch product
+--    Days between ordering and the goods being on the shelf
+-- =========================================================
+
+ALTER TABLE branch_products
+  ADD COLUMN IF NOT EXISTS lead_time_days INTEGER NOT NULL DEFAULT 7;
+
+ALTER TABLE branch_products
+  DROP CONSTRAINT IF EXISTS chk_branch_products_reorder;
+
+ALTER TABLE branch_products
+  ADD CONSTRAINT chk_branch_products_reorder
+  CHECK (reorder_level >= 0 AND lead_time_days >= 0);
+
+
+-- =========================================================
+-- 2) Sales velocity lookups
+-- =========================================================
+
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_branch_type_time
+  ON inventory_movements(store_id, branch_id, movement_type, created_at DESC);
+
+COMMIT;
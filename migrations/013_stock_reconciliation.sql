@@ -0,0 +1,11 @@
+-- =========================================================
+-- 013_stock_reconciliation.sql - Ledger lookups for stock reconciliation
+-- =========================================================
+
+BEGIN;
+
+-- Replaying the ledger sums movements per branch product
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_branch_product
+  ON inventory_movements(store_id, branch_id, product_id, created_at);
+
+COMMIT;
@@ -0,0 +1,48 @@
+-- =========================================================
+-- 014_negative_stock_policy.sql - Negative stock policy at checkout
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Store-wide policy
+--    BLOCK              - reject the sale
+--    ALLOW              - let stock go negative
+--    ALLOW_WITH_WARNING - let stock go negative and warn the cashier
+-- =========================================================
+
+ALTER TABLE store_settings
+  ADD COLUMN IF NOT EXISTS negative_stock_policy TEXT NOT NULL DEFAULT 'ALLOW_WITH_WARNING';
+
+ALTER TABLE store_settings
+  DROP CONSTRAINT IF EXISTS chk_store_settings_negative_stock_policy;
+
+ALTER TABLE store_settings
+  ADD CONSTRAINT chk_store_settings_negative_stock_policy
+  CHECK (negative_stock_policy IN ('BLOCK','ALLOW','ALLOW_WITH_WARNING'));
+
+
+-- =========================================================
+-- 2) Per-product override (NULL = use the store policy)
+-- =========================================================
+
+ALTER TABLE products
+  ADD COLUMN IF NOT EXISTS negative_stock_policy TEXT;
+
+ALTER TABLE products
+  DROP CONSTRAINT IF EXISTS chk_products_negative_stock_policy;
+
+ALTER TABLE products
+  ADD CONSTRAINT chk_products_negative_stock_policy
+  CHECK (negative_stock_policy IS NULL OR negative_stock_policy IN ('BLOCK','ALLOW','ALLOW_WITH_WARNING'));
+
+
+-- =========================================================
+-- 3) Oversell report lookups
+-- =========================================================
+
+CREATE INDEX IF NOT EXISTS idx_inventory_movements_oversell
+  ON inventory_movements(store_id, branch_id, created_at DESC)
+  WHERE movement_type = 'SALE' AND to_stock_count < 0;
+
+COMMIT;
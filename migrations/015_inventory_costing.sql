@@ -0,0 +1,65 @@
+-- =========================================================
+-- 015_inventory_costing.sql - Moving-average cost, cost of goods sold and valuation
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Moving-average cost per stock location
+-- =========================================================
+
+ALTER TABLE branch_products
+  ADD COLUMN IF NOT EXISTS avg_cost NUMERIC(12,4) NOT NULL DEFAULT 0;
+
+ALTER TABLE warehouse_products
+  ADD COLUMN IF NOT EXISTS avg_cost NUMERIC(12,4) NOT NULL DEFAULT 0;
+
+
+-- =========================================================
+-- 2) Cost captured on every movement, transfer line and sale line
+-- =========================================================
+
+ALTER TABLE inventory_movements
+  ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(12,4);
+
+ALTER TABLE warehouse_movements
+  ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(12,4);
+
+-- Set at dispatch from the source's average cost
+ALTER TABLE stock_transfer_items
+  ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(12,4);
+
+ALTER TABLE order_items
+  ADD COLUMN IF NOT EXISTS unit_cost NUMERIC(12,4),
+  ADD COLUMN IF NOT EXISTS cost_total NUMERIC(12,2);
+
+
+-- =========================================================
+-- 3) Seed average cost from the latest goods receipt per product
+-- =========================================================
+
+WITH last_cost AS (
+  SELECT DISTINCT ON (grn.store_id, grni.product_id)
+    grn.store_id, grni.product_id, grni.unit_cost
+  FROM goods_received_note_items grni
+  JOIN goods_received_notes grn ON grni.grn_id = grn.id
+  ORDER BY grn.store_id, grni.product_id, grn.created_at DESC, grni.id DESC
+)
+UPDATE branch_products bp
+SET avg_cost = lc.unit_cost
+FROM last_cost lc
+WHERE bp.store_id = lc.store_id AND bp.product_id = lc.product_id AND bp.avg_cost = 0;
+
+WITH last_cost AS (
+  SELECT DISTINCT ON (grn.store_id, grni.product_id)
+    grn.store_id, grni.product_id, grni.unit_cost
+  FROM goods_received_note_items grni
+  JOIN goods_received_notes grn ON grni.grn_id = grn.id
+  ORDER BY grn.store_id, grni.product_id, grn.created_at DESC, grni.id DESC
+)
+UPDATE warehouse_products wp
+SET avg_cost = lc.unit_cost
+FROM last_cost lc
+WHERE wp.store_id = lc.store_id AND wp.product_id = lc.product_id AND wp.avg_cost = 0;
+
+COMMIT;
@@ -0,0 +1,107 @@
+-- =========================================================
+-- 016_stock_lots.sql - Lot numbers and expiry dates for perishable products
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Products opt in to lot tracking
+--    Receipts of a tracked product must name a lot.
+-- =========================================================
+
+ALTER TABLE products
+  ADD COLUMN IF NOT EXISTS track_lots BOOLEAN NOT NULL DEFAULT false;
+
+
+-- =========================================================
+-- 2) Lots held at a branch or a warehouse
+--    quantity is what is left of the lot at that location;
+--    on_stock stays the total and may include untracked units.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS stock_lots (
+  id            BIGSERIAL PRIMARY KEY,
+  store_id      BIGINT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+  branch_id     BIGINT REFERENCES branches(id) ON DELETE CASCADE,
+  warehouse_id  BIGINT REFERENCES warehouses(id) ON DELETE CASCADE,
+  product_id    BIGINT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+
+  lot_number    TEXT NOT NULL,
+  expiry_date   DATE,
+  quantity      INTEGER NOT NULL DEFAULT 0,
+
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_stock_lots_location
+    CHECK ((branch_id IS NULL) <> (warehouse_id IS NULL)),
+  CONSTRAINT chk_stock_lots_quantity CHECK (quantity >= 0)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_stock_lots_branch_lot
+  ON stock_lots(branch_id, product_id, lot_number) WHERE branch_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS uq_stock_lots_warehouse_lot
+  ON stock_lots(warehouse_id, product_id, lot_number) WHERE warehouse_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_lots_expiry
+  ON stock_lots(store_id, expiry_date) WHERE quantity > 0;
+
+CREATE TRIGGER trg_stock_lots_updated_at
+BEFORE UPDATE ON stock_lots
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- =========================================================
+-- 3) Lot ledger
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS stock_lot_movements (
+  id               BIGSERIAL PRIMARY KEY,
+  store_id         BIGINT NOT NULL REFERENCES stores(id) ON DELETE CASCADE,
+  lot_id           BIGINT NOT NULL REFERENCES stock_lots(id) ON DELETE CASCADE,
+
+  movement_type    TEXT NOT NULL,
+  quantity_change  INTEGER NOT NULL,
+
+  changed_by       BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+  reference_table  TEXT,
+  reference_id     BIGINT,
+
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_lot_movements_lot ON stock_lot_movements(lot_id, created_at);
+
+
+-- =========================================================
+-- 4) Lots carried on transfers and goods receipts
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS stock_transfer_item_lots (
+  id                 BIGSERIAL PRIMARY KEY,
+  stock_transfer_id  BIGINT NOT NULL REFERENCES stock_transfers(id) ON DELETE CASCADE,
+  product_id         BIGINT NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+
+  lot_number         TEXT NOT NULL,
+  expiry_date        DATE,
+  send_count         INTEGER NOT NULL,
+  receive_count      INTEGER NOT NULL DEFAULT 0,
+
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_stock_transfer_item_lots_counts
+    CHECK (send_count > 0 AND receive_count >= 0 AND receive_count <= send_count)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_item_lots_transfer
+  ON stock_transfer_item_lots(stock_transfer_id, product_id);
+
+CREATE TRIGGER trg_stock_transfer_item_lots_updated_at
+BEFORE UPDATE ON stock_transfer_item_lots
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+ALTER TABLE goods_received_note_items
+  ADD COLUMN IF NOT EXISTS lot_number TEXT,
+  ADD COLUMN IF NOT EXISTS expiry_date DATE;
+
+COMMIT;
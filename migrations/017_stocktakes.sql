@@ -0,0 +1,109 @@
+-- =========================================================
+-- 017_stocktakes.sql - Full and cycle stocktakes independent of shifts
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Stocktake sessions
+--    On-hand quantities are frozen into stocktake_items when the session
+--    starts; posting applies counted - snapshot on top of current stock,
+--    so sales during the count are not lost.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS stocktakes (
+  id                 BIGSERIAL PRIMARY KEY,
+  store_id           BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  branch_id          BIGINT NOT NULL REFERENCES branches(id) ON DELETE RESTRICT,
+
+  count_type         TEXT NOT NULL,
+  status             TEXT NOT NULL DEFAULT 'COUNTING',
+  recount_threshold  INTEGER,
+  note               TEXT,
+
+  created_by         BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+  posted_by          BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+  posted_at          TIMESTAMPTZ,
+  cancelled_at       TIMESTAMPTZ,
+
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_stocktakes_count_type CHECK (count_type IN ('FULL','CYCLE')),
+  CONSTRAINT chk_stocktakes_status CHECK (status IN ('COUNTING','POSTED','CANCELLED')),
+  CONSTRAINT chk_stocktakes_recount_threshold CHECK (recount_threshold IS NULL OR recount_threshold > 0)
+);
+
+-- One count in progress per branch
+CREATE UNIQUE INDEX IF NOT EXISTS uq_stocktakes_branch_counting
+  ON stocktakes(branch_id) WHERE status = 'COUNTING';
+CREATE INDEX IF NOT EXISTS idx_stocktakes_store_branch
+  ON stocktakes(store_id, branch_id, created_at DESC);
+
+CREATE TRIGGER trg_stocktakes_updated_at
+BEFORE UPDATE ON stocktakes
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- Categories covered by a cycle count
+CREATE TABLE IF NOT EXISTS stocktake_categories (
+  stocktake_id  BIGINT NOT NULL REFERENCES stocktakes(id) ON DELETE CASCADE,
+  category_id   BIGINT NOT NULL REFERENCES categories(id) ON DELETE RESTRICT,
+  PRIMARY KEY (stocktake_id, category_id)
+);
+
+
+-- =========================================================
+-- 2) Items with their frozen snapshot
+--    round starts at 1 and goes up each time a recount is requested.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS stocktake_items (
+  id             BIGSERIAL PRIMARY KEY,
+  stocktake_id   BIGINT NOT NULL REFERENCES stocktakes(id) ON DELETE CASCADE,
+  product_id     BIGINT NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+
+  snapshot_qty   INTEGER NOT NULL,
+  snapshot_cost  NUMERIC(12,4) NOT NULL DEFAULT 0,
+  counted_qty    INTEGER,
+  round          INTEGER NOT NULL DEFAULT 1,
+  status         TEXT NOT NULL DEFAULT 'PENDING',
+  adjusted_qty   INTEGER,
+
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at     TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (stocktake_id, product_id),
+  CONSTRAINT chk_stocktake_items_status CHECK (status IN ('PENDING','COUNTED','RECOUNT'))
+);
+
+CREATE TRIGGER trg_stocktake_items_updated_at
+BEFORE UPDATE ON stocktake_items
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- =========================================================
+-- 3) Count entries
+--    Each counter records one quantity per item and round; entries from
+--    different counters in the same round are added together.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS stocktake_counts (
+  id                 BIGSERIAL PRIMARY KEY,
+  stocktake_item_id  BIGINT NOT NULL REFERENCES stocktake_items(id) ON DELETE CASCADE,
+  round              INTEGER NOT NULL,
+  counted_qty        INTEGER NOT NULL,
+  counted_by         BIGINT NOT NULL REFERENCES staff_accounts(id) ON DELETE RESTRICT,
+
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (stocktake_item_id, round, counted_by),
+  CONSTRAINT chk_stocktake_counts_qty CHECK (counted_qty >= 0)
+);
+
+CREATE TRIGGER trg_stocktake_counts_updated_at
+BEFORE UPDATE ON stocktake_counts
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+COMMIT;
@@ -0,0 +1,62 @@
+-- =========================================================
+-- 018_loyalty_earn_rules.sql - Configurable loyalty earn rules per store
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Earn rules
+--    PER_UNIT and PER_BAHT rules set the base points of an order line; the
+--    most specific matching rule wins (product, then category, then store-wide).
+--    MULTIPLIER rules scale the base points and stack with each other.
+--    A store with no active rules keeps the default of 1 point per unit.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS loyalty_earn_rules (
+  id               BIGSERIAL PRIMARY KEY,
+  store_id         BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+
+  rule_name        TEXT NOT NULL,
+  rule_type        TEXT NOT NULL,
+
+  -- amounts, one per rule type
+  points_per_unit  INTEGER,
+  baht_per_point   NUMERIC(12,2),
+  multiplier       NUMERIC(6,2),
+
+  -- conditions; NULL means any
+  product_id       BIGINT REFERENCES products(id) ON DELETE CASCADE,
+  category_id      BIGINT REFERENCES categories(id) ON DELETE CASCADE,
+  promotion_id     BIGINT REFERENCES promotions(id) ON DELETE CASCADE,
+  tier_code        TEXT,
+  days_of_week     SMALLINT[],       -- 0 = Sunday ... 6 = Saturday
+  start_time       TIME,             -- happy hour window, may wrap past midnight
+  end_time         TIME,
+  starts_at        TIMESTAMPTZ,
+  ends_at          TIMESTAMPTZ,
+
+  priority         INTEGER NOT NULL DEFAULT 0,
+  is_active        BOOLEAN NOT NULL DEFAULT true,
+
+  created_by       BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_loyalty_earn_rules_type CHECK (rule_type IN ('PER_UNIT','PER_BAHT','MULTIPLIER')),
+  CONSTRAINT chk_loyalty_earn_rules_amount CHECK (
+    (rule_type = 'PER_UNIT' AND points_per_unit > 0) OR
+    (rule_type = 'PER_BAHT' AND baht_per_point > 0) OR
+    (rule_type = 'MULTIPLIER' AND multiplier > 0)
+  ),
+  CONSTRAINT chk_loyalty_earn_rules_time CHECK ((start_time IS NULL) = (end_time IS NULL)),
+  CONSTRAINT chk_loyalty_earn_rules_dates CHECK (starts_at IS NULL OR ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_loyalty_earn_rules_store_active
+  ON loyalty_earn_rules(store_id) WHERE is_active = true;
+
+CREATE TRIGGER trg_loyalty_earn_rules_updated_at
+BEFORE UPDATE ON loyalty_earn_rules
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+COMMIT;
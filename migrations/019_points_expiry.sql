@@ -0,0 +1,82 @@
+-- =========================================================
+-- 019_points_expiry.sql - Points expiry policy and earn lots
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Store-wide expiry policy
+--    NONE          - points never expire
+--    FIXED_PERIOD  - points expire points_expiry_months after they were earned
+--    END_OF_YEAR   - points expire at the end of the year they were earned
+--    Expiry dates are worked out from the current policy, so a policy change
+--    applies to points already earned.
+-- =========================================================
+
+ALTER TABLE store_settings
+  ADD COLUMN IF NOT EXISTS points_expiry_mode TEXT NOT NULL DEFAULT 'NONE',
+  ADD COLUMN IF NOT EXISTS points_expiry_months INTEGER;
+
+ALTER TABLE store_settings
+  DROP CONSTRAINT IF EXISTS chk_store_settings_points_expiry;
+
+ALTER TABLE store_settings
+  ADD CONSTRAINT chk_store_settings_points_expiry CHECK (
+    points_expiry_mode IN ('NONE','FIXED_PERIOD','END_OF_YEAR')
+    AND (points_expiry_mode <> 'FIXED_PERIOD' OR points_expiry_months > 0)
+  );
+
+
+-- =========================================================
+-- 2) Earn lots
+--    Every point credit opens a lot; deductions and expiry consume the
+--    oldest lots first. points_remaining across a customer's lots for a
+--    product matches customer_product_points.points.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS point_earn_lots (
+  id                BIGSERIAL PRIMARY KEY,
+  store_id          BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  customer_id       BIGINT NOT NULL REFERENCES customers(id) ON DELETE RESTRICT,
+  product_id        BIGINT NOT NULL REFERENCES products(id) ON DELETE RESTRICT,
+
+  earned_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+  points_earned     INTEGER NOT NULL,
+  points_remaining  INTEGER NOT NULL,
+
+  reference_table   TEXT,
+  reference_id      BIGINT,
+
+  created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_point_earn_lots_points CHECK (points_earned > 0 AND points_remaining BETWEEN 0 AND points_earned)
+);
+
+CREATE INDEX IF NOT EXISTS idx_point_earn_lots_open
+  ON point_earn_lots(store_id, customer_id, product_id, earned_at)
+  WHERE points_remaining > 0;
+
+CREATE TRIGGER trg_point_earn_lots_updated_at
+BEFORE UPDATE ON point_earn_lots
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+-- Existing balances start their expiry clock now
+INSERT INTO point_earn_lots (store_id, customer_id, product_id, points_earned, points_remaining, reference_table)
+SELECT cpp.store_id, cpp.customer_id, cpp.product_id, cpp.points, cpp.points, 'customer_product_points'
+FROM customer_product_points cpp
+WHERE cpp.points > 0
+  AND NOT EXISTS (
+    SELECT 1 FROM point_earn_lots l
+    WHERE l.store_id = cpp.store_id AND l.customer_id = cpp.customer_id AND l.product_id = cpp.product_id
+  );
+
+
+-- =========================================================
+-- 3) Expiry runs are store-level, not tied to a branch
+-- =========================================================
+
+ALTER TABLE point_transactions
+  ALTER COLUMN branch_id DROP NOT NULL;
+
+COMMIT;
@@ -0,0 +1,31 @@
+-- =========================================================
+-- 020_redemption_stock.sql - Stock deduction and shift link for point redemptions
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Redemptions belong to the shift they were made in
+-- =========================================================
+
+ALTER TABLE point_redemptions
+  ADD COLUMN IF NOT EXISTS shift_id BIGINT REFERENCES shifts(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_point_redemptions_shift
+  ON point_redemptions(shift_id) WHERE shift_id IS NOT NULL;
+
+
+-- =========================================================
+-- 2) REDEEM movements take the free product out of branch stock
+-- =========================================================
+
+ALTER TABLE inventory_movements
+  DROP CONSTRAINT IF EXISTS chk_inventory_movement_type;
+
+ALTER TABLE inventory_movements
+  ADD CONSTRAINT chk_inventory_movement_type
+  CHECK (movement_type IN (
+    'SALE','CANCEL_SALE','RECEIVE','ISSUE','ADJUST','TRANSFER_IN','TRANSFER_OUT','DAMAGE','REDEEM'
+  ));
+
+COMMIT;
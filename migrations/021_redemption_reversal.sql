@@ -0,0 +1,49 @@
+-- =========================================================
+-- 021_redemption_reversal.sql - Manager-approved reversal of point redemptions
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Reversed redemptions keep who reversed them and why
+-- =========================================================
+
+ALTER TABLE point_redemptions
+  ADD COLUMN IF NOT EXISTS reversed_by      BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS approved_by      BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS reversed_at      TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS reversal_reason  TEXT;
+
+ALTER TABLE point_redemptions
+  DROP CONSTRAINT IF EXISTS chk_redemption_status;
+
+ALTER TABLE point_redemptions
+  ADD CONSTRAINT chk_redemption_status CHECK (status IN ('COMPLETED', 'CANCELLED', 'REVERSED'));
+
+
+-- =========================================================
+-- 2) REVERSAL point transactions give the redeemed points back
+-- =========================================================
+
+ALTER TABLE point_transactions
+  DROP CONSTRAINT IF EXISTS chk_point_transaction_type;
+
+ALTER TABLE point_transactions
+  ADD CONSTRAINT chk_point_transaction_type
+  CHECK (transaction_type IN ('EARN', 'REDEEM', 'ADJUST', 'EXPIRE', 'REVERSAL'));
+
+
+-- =========================================================
+-- 3) CANCEL_REDEEM movements return the free product to stock
+-- =========================================================
+
+ALTER TABLE inventory_movements
+  DROP CONSTRAINT IF EXISTS chk_inventory_movement_type;
+
+ALTER TABLE inventory_movements
+  ADD CONSTRAINT chk_inventory_movement_type
+  CHECK (movement_type IN (
+    'SALE','CANCEL_SALE','RECEIVE','ISSUE','ADJUST','TRANSFER_IN','TRANSFER_OUT','DAMAGE','REDEEM','CANCEL_REDEEM'
+  ));
+
+COMMIT;
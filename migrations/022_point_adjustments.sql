@@ -0,0 +1,30 @@
+-- =========================================================
+-- 022_point_adjustments.sql - Manual point adjustments with reason codes
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) ADJUST transactions record why the points were changed
+--    GOODWILL      - points given as a gesture of goodwill
+--    CORRECTION    - fixes points earned or redeemed by mistake
+--    COMPENSATION  - makes up for a service problem
+--    OTHER         - anything else; the note explains it
+-- =========================================================
+
+ALTER TABLE point_transactions
+  ADD COLUMN IF NOT EXISTS reason_code TEXT;
+
+ALTER TABLE point_transactions
+  DROP CONSTRAINT IF EXISTS chk_point_transaction_reason_code;
+
+ALTER TABLE point_transactions
+  ADD CONSTRAINT chk_point_transaction_reason_code CHECK (
+    reason_code IS NULL OR reason_code IN ('GOODWILL','CORRECTION','COMPENSATION','OTHER')
+  );
+
+CREATE INDEX IF NOT EXISTS idx_point_transactions_adjustments
+  ON point_transactions(store_id, created_at)
+  WHERE transaction_type = 'ADJUST';
+
+COMMIT;
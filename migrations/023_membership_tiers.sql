@@ -0,0 +1,92 @@
+-- =========================================================
+-- 023_membership_tiers.sql - Membership tiers from qualifying points
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Store-defined tiers
+--    A customer holds the active tier with the highest min_points they
+--    have reached. earn_multiplier scales the points earned on every order
+--    line and stacks with MULTIPLIER earn rules.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS membership_tiers (
+  id               BIGSERIAL PRIMARY KEY,
+  store_id         BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+
+  tier_code        TEXT NOT NULL,
+  tier_name        TEXT NOT NULL,
+  min_points       INTEGER NOT NULL DEFAULT 0,
+  earn_multiplier  NUMERIC(6,2) NOT NULL DEFAULT 1,
+
+  is_active        BOOLEAN NOT NULL DEFAULT true,
+
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (store_id, tier_code),
+  CONSTRAINT chk_membership_tiers_min_points CHECK (min_points >= 0),
+  CONSTRAINT chk_membership_tiers_multiplier CHECK (earn_multiplier > 0)
+);
+
+CREATE TRIGGER trg_membership_tiers_updated_at
+BEFORE UPDATE ON membership_tiers
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- =========================================================
+-- 2) Evaluation window
+--    NULL qualifies on lifetime points (customer_product_points.total_points);
+--    otherwise on points earned or granted in the last tier_window_months.
+-- =========================================================
+
+ALTER TABLE store_settings
+  ADD COLUMN IF NOT EXISTS tier_window_months INTEGER;
+
+ALTER TABLE store_settings
+  DROP CONSTRAINT IF EXISTS chk_store_settings_tier_window;
+
+ALTER TABLE store_settings
+  ADD CONSTRAINT chk_store_settings_tier_window CHECK (tier_window_months IS NULL OR tier_window_months > 0);
+
+
+-- =========================================================
+-- 3) Current tier and tier change history
+--    History keeps the tier codes rather than ids so it survives a tier
+--    being deleted.
+-- =========================================================
+
+ALTER TABLE customers
+  ADD COLUMN IF NOT EXISTS tier_id BIGINT REFERENCES membership_tiers(id) ON DELETE SET NULL,
+  ADD COLUMN IF NOT EXISTS tier_updated_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS customer_tier_changes (
+  id                 BIGSERIAL PRIMARY KEY,
+  store_id           BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  customer_id        BIGINT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+
+  from_tier_code     TEXT,
+  to_tier_code       TEXT,
+  qualifying_points  INTEGER NOT NULL,
+  reason             TEXT NOT NULL,
+
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_customer_tier_changes_reason CHECK (reason IN ('ORDER','ADJUST','EVALUATION'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_tier_changes_customer
+  ON customer_tier_changes(store_id, customer_id, created_at);
+
+
+-- =========================================================
+-- 4) Tier-only promotions
+--    A promotion with a tier_code is open to customers in that tier or a
+--    higher one (by min_points).
+-- =========================================================
+
+ALTER TABLE promotions
+  ADD COLUMN IF NOT EXISTS tier_code TEXT;
+
+COMMIT;
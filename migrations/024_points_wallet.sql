@@ -0,0 +1,98 @@
+-- =========================================================
+-- 024_points_wallet.sql - Store-wide points wallet alongside product stamps
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Loyalty mode
+--    STAMPS  - per-product points (customer_product_points) only
+--    WALLET  - one wallet balance per customer, earned per baht spent
+--    BOTH    - orders earn stamps and wallet points
+--    wallet_baht_per_point is the spend that earns one wallet point;
+--    wallet_point_value is what one point is worth when spent.
+-- =========================================================
+
+ALTER TABLE store_settings
+  ADD COLUMN IF NOT EXISTS loyalty_mode TEXT NOT NULL DEFAULT 'STAMPS',
+  ADD COLUMN IF NOT EXISTS wallet_baht_per_point NUMERIC(12,2),
+  ADD COLUMN IF NOT EXISTS wallet_point_value NUMERIC(12,2);
+
+ALTER TABLE store_settings
+  DROP CONSTRAINT IF EXISTS chk_store_settings_loyalty_mode;
+
+ALTER TABLE store_settings
+  ADD CONSTRAINT chk_store_settings_loyalty_mode CHECK (
+    loyalty_mode IN ('STAMPS','WALLET','BOTH')
+    AND (loyalty_mode = 'STAMPS' OR (wallet_baht_per_point > 0 AND wallet_point_value > 0))
+  );
+
+
+-- =========================================================
+-- 2) Wallet balances and ledger
+--    balance always equals the sum of the customer's wallet transactions.
+--    REDEEM rows record whether the points paid for the order or were a
+--    discount line, and the baht amount they covered.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS customer_wallets (
+  id               BIGSERIAL PRIMARY KEY,
+  store_id         BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  customer_id      BIGINT NOT NULL REFERENCES customers(id) ON DELETE RESTRICT,
+
+  balance          INTEGER NOT NULL DEFAULT 0,
+  lifetime_earned  INTEGER NOT NULL DEFAULT 0,
+
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (store_id, customer_id),
+  CONSTRAINT chk_customer_wallets_balance CHECK (balance >= 0)
+);
+
+CREATE TRIGGER trg_customer_wallets_updated_at
+BEFORE UPDATE ON customer_wallets
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+CREATE TABLE IF NOT EXISTS wallet_transactions (
+  id                BIGSERIAL PRIMARY KEY,
+  store_id          BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  branch_id         BIGINT REFERENCES branches(id) ON DELETE RESTRICT,
+  customer_id       BIGINT NOT NULL REFERENCES customers(id) ON DELETE RESTRICT,
+
+  transaction_type  TEXT NOT NULL,
+  points_change     INTEGER NOT NULL,
+  balance_after     INTEGER NOT NULL,
+  amount            NUMERIC(12,2),
+  redeemed_as       TEXT,
+
+  reference_table   TEXT,
+  reference_id      BIGINT,
+  note              TEXT,
+  staff_id          BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+
+  created_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_wallet_transactions_type CHECK (transaction_type IN ('EARN','REDEEM','ADJUST')),
+  CONSTRAINT chk_wallet_transactions_redeemed_as CHECK (redeemed_as IS NULL OR redeemed_as IN ('PAYMENT','DISCOUNT'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_customer
+  ON wallet_transactions(store_id, customer_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_wallet_transactions_reference
+  ON wallet_transactions(reference_table, reference_id);
+
+
+-- =========================================================
+-- 3) Wallet points can pay for an order
+-- =========================================================
+
+ALTER TABLE payments
+  DROP CONSTRAINT IF EXISTS chk_payments_method;
+
+ALTER TABLE payments
+  ADD CONSTRAINT chk_payments_method
+  CHECK (method IN ('CASH','TRANSFER','QR','CARD','OTHER','WALLET'));
+
+COMMIT;
@@ -0,0 +1,76 @@
+-- =========================================================
+-- 025_customer_merge.sql - Merge duplicate customer records
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Merged customers stay as inactive records pointing at the survivor
+-- =========================================================
+
+ALTER TABLE customers
+  ADD COLUMN IF NOT EXISTS merged_into_id BIGINT REFERENCES customers(id) ON DELETE RESTRICT,
+  ADD COLUMN IF NOT EXISTS merged_at TIMESTAMPTZ;
+
+-- Phone numbers compared by digits, with +66 numbers in local 0 form
+CREATE INDEX IF NOT EXISTS idx_customers_store_phone_key
+  ON customers (
+    store_id,
+    (CASE
+      WHEN regexp_replace(phone, '\D', '', 'g') ~ '^66[0-9]{9}$'
+        THEN '0' || substr(regexp_replace(phone, '\D', '', 'g'), 3)
+      ELSE regexp_replace(phone, '\D', '', 'g')
+    END)
+  )
+  WHERE is_active = true AND phone IS NOT NULL;
+
+
+-- =========================================================
+-- 2) Merge audit
+--    One row per merged customer, keeping the details the record had and
+--    what moved to the survivor. Orders, point transactions, redemptions,
+--    earn lots and wallet transactions are re-pointed to the survivor;
+--    wallet balance_after values on moved rows keep the merged customer's
+--    running balance.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS customer_merges (
+  id                        BIGSERIAL PRIMARY KEY,
+  store_id                  BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  survivor_id               BIGINT NOT NULL REFERENCES customers(id) ON DELETE RESTRICT,
+  merged_id                 BIGINT NOT NULL REFERENCES customers(id) ON DELETE RESTRICT,
+
+  merged_customer_code      TEXT,
+  merged_full_name          TEXT,
+  merged_phone              TEXT,
+
+  product_points_moved      INTEGER NOT NULL DEFAULT 0,
+  wallet_points_moved       INTEGER NOT NULL DEFAULT 0,
+  orders_moved              INTEGER NOT NULL DEFAULT 0,
+  point_transactions_moved  INTEGER NOT NULL DEFAULT 0,
+  redemptions_moved         INTEGER NOT NULL DEFAULT 0,
+
+  note                      TEXT,
+  merged_by                 BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL,
+  created_at                TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (merged_id),
+  CONSTRAINT chk_customer_merges_distinct CHECK (survivor_id <> merged_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_merges_survivor
+  ON customer_merges(store_id, survivor_id, created_at);
+
+
+-- =========================================================
+-- 3) A merge can move the survivor to a new tier
+-- =========================================================
+
+ALTER TABLE customer_tier_changes
+  DROP CONSTRAINT IF EXISTS chk_customer_tier_changes_reason;
+
+ALTER TABLE customer_tier_changes
+  ADD CONSTRAINT chk_customer_tier_changes_reason
+  CHECK (reason IN ('ORDER','ADJUST','EVALUATION','MERGE'));
+
+COMMIT;
@@ -0,0 +1,52 @@
+-- =========================================================
+-- 026_customer_otp.sql - Customer self-service points lookup with OTP
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) One-time passwords
+--    phone_key is the phone reduced to digits in local 0 form. Only the
+--    hash of the code is kept; attempts counts wrong guesses.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS customer_otps (
+  id            BIGSERIAL PRIMARY KEY,
+  store_id      BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  customer_id   BIGINT REFERENCES customers(id) ON DELETE CASCADE,
+  phone_key     TEXT NOT NULL,
+
+  code_hash     TEXT NOT NULL,
+  attempts      INTEGER NOT NULL DEFAULT 0,
+  expires_at    TIMESTAMPTZ NOT NULL,
+  verified_at   TIMESTAMPTZ,
+
+  created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_otps_phone
+  ON customer_otps(store_id, phone_key, created_at DESC);
+
+
+-- =========================================================
+-- 2) Customer sessions
+--    A verified OTP opens a short session scoped to one customer of one
+--    store.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS customer_sessions (
+  id             BIGSERIAL PRIMARY KEY,
+  store_id       BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  customer_id    BIGINT NOT NULL REFERENCES customers(id) ON DELETE CASCADE,
+
+  session_token  TEXT NOT NULL UNIQUE,
+  expires_at     TIMESTAMPTZ NOT NULL,
+  revoked_at     TIMESTAMPTZ,
+
+  created_at     TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_customer_sessions_customer
+  ON customer_sessions(store_id, customer_id, created_at DESC);
+
+COMMIT;
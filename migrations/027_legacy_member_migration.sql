@@ -0,0 +1,49 @@
+-- =========================================================
+-- 027_legacy_member_migration.sql - Map v1 members onto v2 customers
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Member to customer map
+--    points_1_0_liter / points_1_5_liter are the v1 bucket balances already
+--    carried into customer_product_points, so a re-run only applies what
+--    changed in v1 since.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS legacy_member_map (
+  member_id         UUID PRIMARY KEY,
+  store_id          BIGINT NOT NULL REFERENCES stores(id) ON DELETE RESTRICT,
+  customer_id       BIGINT NOT NULL REFERENCES customers(id) ON DELETE RESTRICT,
+
+  points_1_0_liter  INTEGER NOT NULL DEFAULT 0,
+  points_1_5_liter  INTEGER NOT NULL DEFAULT 0,
+
+  migrated_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  UNIQUE (customer_id)
+);
+
+CREATE TRIGGER trg_legacy_member_map_updated_at
+BEFORE UPDATE ON legacy_member_map
+FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+
+-- =========================================================
+-- 2) Replayed history
+--    Each v1 member_point_transactions row is copied into
+--    point_transactions once.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS legacy_point_transaction_map (
+  legacy_transaction_id  UUID PRIMARY KEY,
+  member_id              UUID NOT NULL,
+  point_transaction_id   BIGINT NOT NULL REFERENCES point_transactions(id) ON DELETE CASCADE,
+  migrated_at            TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_legacy_point_transaction_map_member
+  ON legacy_point_transaction_map(member_id);
+
+COMMIT;
@@ -0,0 +1,17 @@
+-- =========================================================
+-- 028_csv_imports.sql - Bulk CSV import of customers, products and stock
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Products are upserted by SKU, so a SKU must be unique within a store.
+--    Find duplicates to resolve before running this with:
+--      SELECT store_id, sku, COUNT(*) FROM products
+--      WHERE sku IS NOT NULL GROUP BY store_id, sku HAVING COUNT(*) > 1;
+-- =========================================================
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_products_store_sku
+  ON products(store_id, sku) WHERE sku IS NOT NULL;
+
+COMMIT;
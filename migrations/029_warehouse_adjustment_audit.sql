@@ -0,0 +1,16 @@
+-- =========================================================
+-- 029_warehouse_adjustment_audit.sql - Reason codes and approvals on warehouse adjustments
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Warehouse adjustments record the catalogue reason code and the approving
+--    manager, as inventory_movements does since 011
+-- =========================================================
+
+ALTER TABLE warehouse_movements
+  ADD COLUMN IF NOT EXISTS reason_code TEXT,
+  ADD COLUMN IF NOT EXISTS approved_by BIGINT REFERENCES staff_accounts(id) ON DELETE SET NULL;
+
+COMMIT;
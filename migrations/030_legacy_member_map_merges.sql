@@ -0,0 +1,18 @@
+-- =========================================================
+-- 030_legacy_member_map_merges.sql - Let merged customers keep their v1 mappings
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) A customer merge re-points the merged customer's v1 mappings to the
+--    survivor, so one customer can hold several v1 members
+-- =========================================================
+
+ALTER TABLE legacy_member_map
+  DROP CONSTRAINT IF EXISTS legacy_member_map_customer_id_key;
+
+CREATE INDEX IF NOT EXISTS idx_legacy_member_map_customer
+  ON legacy_member_map(customer_id);
+
+COMMIT;
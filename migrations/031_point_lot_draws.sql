@@ -0,0 +1,31 @@
+-- =========================================================
+-- 031_point_lot_draws.sql - Record which earn lots each deduction drew from
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Lot draws
+--    A deduction records the points it took from each earn lot, so a
+--    reversal can put them back into the same lots and keep their expiry.
+--    Deductions made before this migration have no draws.
+-- =========================================================
+
+CREATE TABLE IF NOT EXISTS point_lot_draws (
+  id                    BIGSERIAL PRIMARY KEY,
+  point_transaction_id  BIGINT NOT NULL REFERENCES point_transactions(id) ON DELETE RESTRICT,
+  lot_id                BIGINT NOT NULL REFERENCES point_earn_lots(id) ON DELETE RESTRICT,
+  points                INTEGER NOT NULL,
+
+  created_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+
+  CONSTRAINT chk_point_lot_draws_points CHECK (points > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_point_lot_draws_transaction
+  ON point_lot_draws(point_transaction_id);
+
+CREATE INDEX IF NOT EXISTS idx_point_lot_draws_lot
+  ON point_lot_draws(lot_id);
+
+COMMIT;
@@ -0,0 +1,34 @@
+-- =========================================================
+-- 032_stock_lots_restrict.sql - Stop deletes cascading into lot stock and history
+-- =========================================================
+
+BEGIN;
+
+-- =========================================================
+-- 1) Lots and their ledger keep their parents, as branch and warehouse
+--    stock do; 016 created these references with ON DELETE CASCADE
+-- =========================================================
+
+ALTER TABLE stock_lots
+  DROP CONSTRAINT IF EXISTS stock_lots_store_id_fkey,
+  DROP CONSTRAINT IF EXISTS stock_lots_branch_id_fkey,
+  DROP CONSTRAINT IF EXISTS stock_lots_warehouse_id_fkey,
+  DROP CONSTRAINT IF EXISTS stock_lots_product_id_fkey,
+  ADD CONSTRAINT stock_lots_store_id_fkey
+    FOREIGN KEY (store_id) REFERENCES stores(id) ON DELETE RESTRICT,
+  ADD CONSTRAINT stock_lots_branch_id_fkey
+    FOREIGN KEY (branch_id) REFERENCES branches(id) ON DELETE RESTRICT,
+  ADD CONSTRAINT stock_lots_warehouse_id_fkey
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(id) ON DELETE RESTRICT,
+  ADD CONSTRAINT stock_lots_product_id_fkey
+    FOREIGN KEY (product_id) REFERENCES products(id) ON DELETE RESTRICT;
+
+ALTER TABLE stock_lot_movements
+  DROP CONSTRAINT IF EXISTS stock_lot_movements_store_id_fkey,
+  DROP CONSTRAINT IF EXISTS stock_lot_movements_lot_id_fkey,
+  ADD CONSTRAINT stock_lot_movements_store_id_fkey
+    FOREIGN KEY (store_id) REFERENCES stores(id) ON DELETE RESTRICT,
+  ADD CONSTRAINT stock_lot_movements_lot_id_fkey
+    FOREIGN KEY (lot_id) REFERENCES stock_lots(id) ON DELETE RESTRICT;
+
+COMMIT;
@@ -0,0 +1,38 @@
+// Package sms sends text messages through a configurable provider
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Sender delivers a text message to a phone number
+type Sender interface {
+	Send(ctx context.Context, phone, message string) error
+}
+
+// Provider names accepted by New
+const (
+	ProviderConsole = "console"
+)
+
+// New returns the sender for the named provider. Only the console stub ships
+// with the API; a real gateway is added as another Sender and case here.
+func New(provider string) (Sender, error) {
+	switch strings.ToLower(provider) {
+	case "", ProviderConsole:
+		return ConsoleSender{}, nil
+	}
+	return nil, fmt.Errorf("unsupported SMS provider %q", provider)
+}
+
+// ConsoleSender writes messages to the log instead of sending them, for local
+// development
+type ConsoleSender struct{}
+
+func (ConsoleSender) Send(ctx context.Context, phone, message string) error {
+	log.Printf("[sms] to %s: %s", phone, message)
+	return nil
+}